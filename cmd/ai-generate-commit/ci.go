@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/ci"
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// ciSystems are the values of the --ci flag.
+var ciSystems = []string{ci.GitHub, ci.GitLab}
+
+// ciSystem is the CI system results are reported to, given with --ci; "" outside CI.
+var ciSystem string
+
+func setCISystem(name string) error {
+	// Selects the CI system of --ci, checking that it is supported.
+	if name != "" && !slices.Contains(ciSystems, name) {
+		return fmt.Errorf("unknown CI system %q for --ci, expected one of: %s", name, strings.Join(ciSystems, ", "))
+	}
+	ciSystem = name
+	return nil
+}
+
+func reportCIError(err error) {
+	// Annotates the workflow run with err, so that it shows on the page of the run.
+	if ciSystem == ci.GitHub {
+		fmt.Println(ci.GitHubError(err.Error()))
+	}
+}
+
+func reportCommitMessage(message string, committed bool) error {
+	// Sets the message, its subject, whether it was committed and the new commit as outputs of the
+	// step, and shows the message in the summary of the job.
+	if ciSystem != ci.GitHub {
+		return nil
+	}
+	subject, _, _ := strings.Cut(message, "\n")
+	outputs := map[string]string{"message": message, "subject": subject, "committed": fmt.Sprint(committed), "sha": ""}
+	summary := "### Generated commit message\n\n" + ci.CodeBlock(message)
+	if committed {
+		sha, err := git.ResolveCommit("HEAD")
+		if err != nil {
+			return err
+		}
+		outputs["sha"] = sha
+		summary += fmt.Sprintf("\nCommitted as %s.\n", sha)
+	}
+	if err := ci.SetGitHubOutputs(outputs); err != nil {
+		return err
+	}
+	return ci.AddGitHubSummary(summary + "\n")
+}
+
+func reportPullRequest(pr service.PullRequest, baseBranch string) error {
+	// Sets the title and description as outputs of the step, and shows them in the summary of the job.
+	if ciSystem != ci.GitHub {
+		return nil
+	}
+	if err := ci.SetGitHubOutputs(map[string]string{"title": pr.Title, "body": pr.Body, "base": baseBranch}); err != nil {
+		return err
+	}
+	return ci.AddGitHubSummary(fmt.Sprintf("### Pull request description\n\n**%s**\n\n%s\n\n", pr.Title, pr.Body))
+}
+
+func ciBaseBranch() (string, error) {
+	// Returns the branch the pull or merge request of the pipeline targets, as fetched from origin,
+	// or "" outside CI and for pipelines of other events.
+	switch ciSystem {
+	case ci.GitHub:
+		event, err := ci.ReadGitHubEvent()
+		if err != nil || event.PullRequest == nil {
+			return "", err
+		}
+		return "origin/" + event.PullRequest.Base.Ref, nil
+	case ci.GitLab:
+		if target := os.Getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME"); target != "" {
+			return "origin/" + target, nil
+		}
+	}
+	return "", nil
+}
+
+func pipelineMergeRequest() (*ci.GitLabMergeRequest, string, error) {
+	// Returns the merge request of the GitLab pipeline and the token to update it with, before the
+	// description is generated for nothing.
+	if ciSystem != ci.GitLab {
+		return nil, "", fmt.Errorf("-update requires --ci gitlab")
+	}
+	mr, err := ci.GitLabMergeRequestFromEnv()
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := config.GetConfig("GITLAB_TOKEN")
+	if err != nil {
+		return nil, "", err
+	}
+	if token == "" {
+		return nil, "", fmt.Errorf("set GITLAB_TOKEN, e.g. as the AI_COMMIT_GITLAB_TOKEN variable of the project, to a token with the api scope")
+	}
+	return mr, token, nil
+}
+
+func updateMergeRequest(mr *ci.GitLabMergeRequest, token string, pr service.PullRequest) error {
+	// Writes the description into the merge request, replacing the one written by an earlier pipeline
+	// and keeping the rest of the description.
+	description, err := mr.Description(token)
+	if err != nil {
+		return err
+	}
+	if err := mr.UpdateDescription(token, ci.ReplaceSection(description, pr.Body)); err != nil {
+		return err
+	}
+	statusf("Description of merge request !%s updated.", mr.IID)
+	return nil
+}
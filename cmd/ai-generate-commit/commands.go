@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// command describes a subcommand for dispatching, help and shell completion.
+type command struct {
+	name    string
+	aliases []string
+	args    string // Arguments after the flags in the usage line, e.g. "COMMIT|FROM..TO"
+	summary string // One-line description shown in the command list and the command's help
+	hidden  bool   // Whether the command is left out of the command list and shell completion
+	run     func(args []string) error
+}
+
+func commandList() []command {
+	// Returns the subcommands in the order the help lists them. The camelCase names are kept for
+	// compatibility; every such command also has a kebab-case alias.
+	return []command{
+		{name: "generate", aliases: []string{"gen"}, summary: "Generate a commit message for the staged changes and commit (default)", run: runGenerate},
+		{name: "tui", summary: "Review the staged changes and the generated message full-screen", run: runTUI},
+		{name: "reword", args: "COMMIT|FROM..TO", summary: "Regenerate the message of an existing commit or of a range of commits", run: runReword},
+		{name: "history", args: "[NUMBER]", summary: "List the generated messages, or print or commit with one of them", run: runHistory},
+		{name: "undo", summary: "Undo the latest commit created by the tool, keeping its changes staged", run: runUndo},
+		{name: "pr", summary: "Write a pull request title and description for the current branch", run: runPR},
+		{name: "releaseNotes", aliases: []string{"release-notes"}, args: "[FROM [TO]]", summary: "Write release notes for the commits between two revisions", run: runReleaseNotes},
+		{name: "serve", summary: "Answer a local JSON API for editor integrations, reusing one process", run: runServe},
+		{name: "mcp", summary: "Serve the tools of the tool to AI agents over the Model Context Protocol", run: runMCP},
+		{name: "daemon", args: "[start|stop|status|run]", summary: "Keep the connections to the provider open between runs, in the background", run: runDaemon},
+		{name: "init", summary: "Set up the provider, API key, model and prompt interactively", run: runInit},
+		{name: "setConfig", aliases: []string{"set-config"}, summary: "Set a key in the global configuration file", run: runSetConfig},
+		{name: "unsetConfig", aliases: []string{"unset-config"}, summary: "Remove a key from the global configuration file", run: runUnsetConfig},
+		{name: "getConfig", aliases: []string{"get-config"}, summary: "Print the effective value of a configuration key", run: runGetConfig},
+		{name: "listConfig", aliases: []string{"list-config"}, summary: "List all configuration keys with their values and sources", run: runListConfig},
+		{name: "editConfig", aliases: []string{"edit-config"}, summary: "Edit the global or repository configuration file in your editor", run: runEditConfig},
+		{name: "getConfigPath", aliases: []string{"get-config-path"}, summary: "Print the path of the global configuration file", run: runGetConfigPath},
+		{name: "encryptConfig", aliases: []string{"encrypt-config"}, summary: "Encrypt the global configuration file at rest", run: runEncryptConfig},
+		{name: "decryptConfig", aliases: []string{"decrypt-config"}, summary: "Store the global configuration file in plain text again", run: runDecryptConfig},
+		{name: "prompts", args: "[list|show NAME|edit NAME]", summary: "List, print or edit the prompt templates the messages are generated with", run: runPrompts},
+		{name: "integrations", args: "lazygit|commitizen", summary: "Print the configuration that runs the tool from another program: lazygit or Commitizen", run: runIntegrations},
+		{name: "telemetry", args: "[enable|disable|status]", summary: "Turn the anonymous usage statistics on or off, or show what they contain", run: runTelemetry},
+		{name: "doctor", summary: "Show how every setting is resolved and whether it is valid", run: runDoctor},
+		{name: "installHook", aliases: []string{"install-hook"}, summary: "Install the prepare-commit-msg hook in the repository", run: runInstallHook},
+		{name: "uninstallHook", aliases: []string{"uninstall-hook"}, summary: "Remove the prepare-commit-msg hook from the repository", run: runUninstallHook},
+		{name: "prepareCommitMsg", args: "MESSAGE_FILE [SOURCE [SHA]]", summary: "Run as the prepare-commit-msg hook", hidden: true, run: runPrepareCommitMsg},
+		{name: "completion", args: "bash|zsh|fish|powershell", summary: "Print the shell completion script for a shell", run: runCompletion},
+		{name: "version", summary: "Print the version and build metadata", run: runVersion},
+		{name: "help", args: "[COMMAND]", summary: "Show the help of the tool or of a command", run: runHelp},
+		{name: "__complete", summary: "Print completions for the completion scripts", hidden: true, run: runComplete},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	// Looks up a command by its name or one of its aliases.
+	for _, c := range commandList() {
+		if c.name == name {
+			return c, true
+		}
+		for _, alias := range c.aliases {
+			if alias == name {
+				return c, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// globalFlags holds the flags every command accepts, anywhere on the command line.
+type globalFlags struct {
+	dir      string // Repository to work in, given with -C
+	envFile  string // .env file to load instead of the repository's, given with --env-file
+	profile  string // Profile to use, given with --profile
+	logLevel string // Level of the diagnostics, given with --log-level, or set by --verbose and --debug
+	quiet    bool   // Whether to print only messages, questions and errors
+	noColor  bool   // Whether to print without colors
+	ci       string // CI system to report results to, given with --ci
+}
+
+// globalUsage describes the global flags in the help of the tool and of every command.
+const globalUsage = `Global flags:
+  -C PATH            Run in the repository at PATH instead of the current directory
+  --env-file PATH    Load settings from this .env file instead of the repository's
+  --profile NAME     Use this configuration profile (overrides PROFILE)
+  --log-level LEVEL  Record diagnostics of this level: debug, info, warn or error (overrides LOG_LEVEL)
+  --verbose          Print the Git commands that run and the configuration in use (same as --log-level info)
+  --debug            Also print how the prompt was built and every API request and response (same as --log-level debug)
+  -q, --quiet        Print only messages, questions and errors, without progress or success messages
+  --no-color         Print without colors (also with NO_COLOR set)
+  --ci SYSTEM        Run in a CI pipeline: github (step outputs and job summary) or gitlab (merge requests)
+`
+
+func extractGlobalFlags(args []string) ([]string, globalFlags, error) {
+	// Removes the global flags from args and returns the remaining arguments together with the flags.
+	// Flags with values may be given as "--flag VALUE" or "--flag=VALUE", with one or two dashes.
+	// As with git, each further -C is interpreted relative to the previous one. Arguments after
+	// "--" are left alone.
+	var rest []string
+	var globals globalFlags
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+		switch name {
+		case "verbose":
+			if globals.logLevel != logging.LevelDebug {
+				globals.logLevel = logging.LevelInfo
+			}
+			continue
+		case "debug":
+			globals.logLevel = logging.LevelDebug
+			continue
+		case "q", "quiet":
+			globals.quiet = true
+			continue
+		case "no-color":
+			globals.noColor = true
+			continue
+		case "C", "env-file", "profile", "log-level", "ci":
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, globalFlags{}, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			value = args[i]
+		}
+		switch {
+		case name == "env-file":
+			globals.envFile = value
+		case name == "profile":
+			globals.profile = value
+		case name == "log-level":
+			globals.logLevel = value
+		case name == "ci":
+			globals.ci = value
+		case filepath.IsAbs(value) || globals.dir == "":
+			globals.dir = value
+		default:
+			globals.dir = filepath.Join(globals.dir, value)
+		}
+	}
+	return rest, globals, nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	// Creates the flag set of a command. Its -h and -help output shows the usage line and summary
+	// of the command, its flags and the global flags.
+	cmd := flag.NewFlagSet(name, flag.ExitOnError)
+	cmd.Usage = func() {
+		printCommandUsage(cmd.Output(), cmd)
+	}
+	return cmd
+}
+
+func parseNoArgs(name string, args []string) error {
+	// Parses the arguments of a command that takes neither flags nor arguments, so that -h shows its help.
+	cmd := newFlagSet(name)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 0 {
+		return fmt.Errorf("%s takes no arguments", name)
+	}
+	return nil
+}
+
+func printCommandUsage(w io.Writer, flags *flag.FlagSet) {
+	// Writes the help of the command the flag set belongs to.
+	c, _ := findCommand(flags.Name())
+	usage := "ai-generate-commit " + c.name
+	hasFlags := false
+	flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		usage += " [flags]"
+	}
+	if c.args != "" {
+		usage += " " + c.args
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s.\n", usage, c.summary)
+	if len(c.aliases) > 0 {
+		fmt.Fprintf(w, "\nAliases: %s\n", strings.Join(c.aliases, ", "))
+	}
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		flags.SetOutput(w)
+		flags.PrintDefaults()
+	}
+	fmt.Fprintf(w, "\n%s", globalUsage)
+}
+
+func printUsage(w io.Writer) {
+	// Writes the help of the tool: how to call it, its commands and the global flags.
+	fmt.Fprintln(w, "ai-generate-commit generates commit messages for your staged changes with AI.")
+	fmt.Fprintln(w, "\nUsage: ai-generate-commit [global flags] [COMMAND] [flags] [arguments]")
+	fmt.Fprintln(w, "\nWithout a command, or with flags only, generate is run.")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, c := range commandList() {
+		if !c.hidden {
+			fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%s", globalUsage)
+	fmt.Fprintln(w, "\nRun 'ai-generate-commit help COMMAND' or 'ai-generate-commit COMMAND -h' for the flags of a command.")
+}
+
+func runHelp(args []string) error {
+	// Shows the help of the tool, or of the command given as argument.
+	cmd := newFlagSet("help")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() == 0 {
+		printUsage(os.Stdout)
+		return nil
+	}
+	c, ok := findCommand(cmd.Arg(0))
+	if !ok || c.hidden {
+		return fmt.Errorf("unknown command: %s", cmd.Arg(0))
+	}
+	// Every command shows its help and exits when given -h.
+	return c.run([]string{"-h"})
+}
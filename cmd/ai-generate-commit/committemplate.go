@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+func useCommitTemplate() (bool, error) {
+	// Reports whether generated messages are merged into the commit template.
+	enabled, _, err := config.GetBool("USE_COMMIT_TEMPLATE")
+	return enabled, err
+}
+
+func applyCommitTemplate(gitRepo git.GitRepo, message string) (string, error) {
+	// Merges message into the file set as commit.template, if there is one, and removes the
+	// template's comments, which git only strips from messages written in an editor.
+	enabled, err := useCommitTemplate()
+	if err != nil || !enabled {
+		return message, err
+	}
+	template, err := gitRepo.CommitTemplate()
+	if err != nil || template == "" {
+		return message, err
+	}
+	return git.StripComments(service.MergeCommitTemplate(message, template))
+}
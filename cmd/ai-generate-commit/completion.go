@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// completionFlags lists the flags of the commands for shell completion, by command name.
+var completionFlags = map[string][]string{
+	"generate": {
+		"-candidates", "-model", "-temperature", "-max-tokens", "-top-p", "-seed", "-deterministic", "-style", "-lang", "-base", "-no-cache", "-offline", "-all", "-a",
+		"-add-untracked", "-amend", "-split", "-dry-run", "-print-only", "-raw", "-porcelain", "-json", "-output", "-stdin", "-copy",
+		"-description", "-S", "-gpg-sign", "-s", "-signoff", "-no-verify", "-no-post-rewrite", "-allow-empty",
+		"-push", "-yes", "-y", "-trailer", "-co-author",
+	},
+	"tui":           {"-model", "-style", "-lang", "-no-cache", "-all", "-a", "-add-untracked", "-S", "-s", "-no-verify", "-push", "-trailer", "-co-author"},
+	"reword":        {"-style", "-lang", "-no-verify", "-no-post-rewrite", "-yes", "-y"},
+	"history":       {"-all", "-n", "-status", "-commit", "-yes", "-y"},
+	"undo":          {"-yes", "-y"},
+	"pr":            {"-base", "-create", "-draft", "-update", "-yes", "-y"},
+	"releaseNotes":  {"-audience", "-output"},
+	"serve":         {"-addr", "-socket"},
+	"mcp":           {"-yes", "-y"},
+	"setConfig":     {"-key", "-value"},
+	"unsetConfig":   {"-key"},
+	"getConfig":     {"-key"},
+	"editConfig":    {"-repo"},
+	"encryptConfig": {"-method"},
+	"installHook":   {"-force"},
+	"integrations":  {"-key", "-no-edit"},
+}
+
+// completionShells are the shells the "completion" command writes scripts for.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// The completion scripts call the hidden __complete command with the position of the word being
+// completed followed by the words after the program name, and offer the lines it prints.
+const (
+	bashCompletion = `# bash completion for ai-generate-commit
+_ai_generate_commit() {
+    local IFS=$'\n'
+    COMPREPLY=($(ai-generate-commit __complete "$((COMP_CWORD - 1))" "${COMP_WORDS[@]:1}" 2>/dev/null </dev/null))
+}
+complete -o default -F _ai_generate_commit ai-generate-commit
+`
+	zshCompletion = `# zsh completion for ai-generate-commit
+_ai_generate_commit() {
+    local -a candidates
+    candidates=(${(f)"$(ai-generate-commit __complete "$((CURRENT - 2))" "${(@)words[2,-1]}" 2>/dev/null </dev/null)"})
+    if (( ${#candidates} )); then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+compdef _ai_generate_commit ai-generate-commit
+`
+	fishCompletion = `# fish completion for ai-generate-commit
+function __ai_generate_commit_complete
+    set -l words (commandline -opc)[2..-1]
+    ai-generate-commit __complete (count $words) $words (commandline -ct) 2>/dev/null </dev/null
+end
+complete -c ai-generate-commit -f -a '(__ai_generate_commit_complete)'
+`
+	powershellCompletion = `# PowerShell completion for ai-generate-commit
+Register-ArgumentCompleter -Native -CommandName ai-generate-commit -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } | ForEach-Object { $_.ToString() })
+    $index = $words.Count
+    if ($wordToComplete -ne '') { $index-- }
+    ai-generate-commit __complete $index @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
+)
+
+func runCompletion(args []string) error {
+	// Prints the completion script for the given shell, to be sourced from the shell's startup file.
+	cmd := newFlagSet("completion")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() != 1 {
+		return fmt.Errorf("usage: ai-generate-commit completion %s", strings.Join(completionShells, "|"))
+	}
+	switch cmd.Arg(0) {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	case "powershell":
+		fmt.Print(powershellCompletion)
+	default:
+		return fmt.Errorf("unsupported shell %q, expected one of: %s", cmd.Arg(0), strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func runComplete(args []string) error {
+	// Prints the candidates for one word of a command line, one per line, for the completion scripts.
+	// The first argument is the position of that word among the remaining arguments; a position
+	// past the end means a new, empty word. Invalid input prints nothing, so the shell offers nothing.
+	if len(args) == 0 {
+		return nil
+	}
+	words := args[1:]
+	index, err := strconv.Atoi(args[0])
+	if err != nil || index < 0 || index > len(words) {
+		return nil
+	}
+	current := ""
+	if index < len(words) {
+		current = words[index]
+	}
+	for _, candidate := range completeWord(words[:index], current) {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+	return nil
+}
+
+func completeWord(words []string, current string) []string {
+	// Returns the candidates for the word following words, which the caller filters by current.
+	// Skips the global flags and their values before the command.
+	i := 0
+	for i < len(words) && strings.HasPrefix(words[i], "-") {
+		flagName := strings.TrimLeft(words[i], "-")
+		if flagName == "verbose" || flagName == "debug" || flagName == "q" || flagName == "quiet" || flagName == "no-color" {
+			i++
+			continue
+		}
+		if flagName != "C" && flagName != "env-file" && flagName != "profile" && flagName != "log-level" && flagName != "ci" {
+			break
+		}
+		if i+1 == len(words) {
+			// Completing the value of a global flag: a path the shell completes itself, a profile, a level or a CI system.
+			values, _ := completeFlagValue(words)
+			return values
+		}
+		i += 2
+	}
+
+	// Flags at the start belong to the default "generate" command.
+	name, rest := "generate", words[i:]
+	if i < len(words) && !strings.HasPrefix(words[i], "-") {
+		name, rest = words[i], words[i+1:]
+		if c, ok := findCommand(name); ok {
+			name = c.name
+		}
+	} else if i == len(words) && !strings.HasPrefix(current, "-") {
+		var names []string
+		for _, c := range commandList() {
+			if !c.hidden {
+				names = append(names, c.name)
+			}
+		}
+		return names
+	}
+
+	if len(rest) > 0 {
+		if values, ok := completeFlagValue(rest); ok {
+			return values
+		}
+	}
+	switch {
+	case name == "completion" && len(rest) == 0:
+		return completionShells
+	case name == "telemetry" && len(rest) == 0:
+		return telemetryActions
+	case name == "daemon" && len(rest) == 0:
+		return daemonActions
+	case name == "prompts" && len(rest) == 0:
+		return promptsActions
+	case name == "integrations" && len(rest) == 0:
+		return integrations
+	case name == "help" && len(rest) == 0:
+		return completeWord(nil, "")
+	case !strings.HasPrefix(current, "-"):
+		return nil
+	}
+	flags := append([]string{"-C", "--env-file", "--profile", "--log-level", "--verbose", "--debug", "--quiet", "--no-color", "--ci"}, completionFlags[name]...)
+	if i == len(words) {
+		flags = append(flags, "--help", "--version")
+	}
+	return flags
+}
+
+func completeFlagValue(words []string) ([]string, bool) {
+	// Returns the values of the flag words ends with, and whether that flag takes a value
+	// that can be completed. Models are fetched from the configured provider.
+	switch strings.TrimLeft(words[len(words)-1], "-") {
+	case "key":
+		var names []string
+		for _, key := range config.Keys() {
+			names = append(names, key.Name)
+		}
+		return names, true
+	case "value":
+		// Completes the value of the key given with -key, if it has a known set of values.
+		for i := len(words) - 2; i > 0; i-- {
+			if strings.TrimLeft(words[i-1], "-") == "key" {
+				return keyValues(words[i]), true
+			}
+		}
+		return nil, true
+	case "model":
+		return keyValues("MODEL"), true
+	case "style":
+		return keyValues("COMMIT_STYLE"), true
+	case "lang":
+		return service.LanguageNames(), true
+	case "audience":
+		return keyValues("RELEASE_AUDIENCE"), true
+	case "log-level":
+		return logging.Levels, true
+	case "ci":
+		return ciSystems, true
+	case "porcelain":
+		return porcelainFormats, true
+	case "status":
+		return []string{string(history.StatusAccepted), string(history.StatusRejected), string(history.StatusPrinted)}, true
+	case "method":
+		return []string{config.MethodPassphrase, config.MethodDPAPI}, true
+	case "profile":
+		profiles, _ := config.LoadProfiles()
+		var names []string
+		for _, profile := range profiles {
+			names = append(names, profile.Name)
+		}
+		return names, true
+	}
+	return nil, false
+}
+
+func keyValues(name string) []string {
+	// Returns the values a configuration key accepts, or the provider's models for MODEL.
+	if name == "MODEL" {
+		client, _, err := provider.New()
+		if err != nil {
+			return nil
+		}
+		models, _ := client.ListModels()
+		return models
+	}
+	key, err := config.LookupKey(name)
+	if err != nil {
+		return nil
+	}
+	if key.Type == config.TypeBool {
+		return []string{"true", "false"}
+	}
+	return key.Values
+}
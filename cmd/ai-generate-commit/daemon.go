@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/daemon"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+)
+
+// daemonActions are the arguments of the "daemon" command.
+var daemonActions = []string{"start", "stop", "status", "run"}
+
+// daemonStartTimeout bounds the wait for a started daemon to answer.
+const daemonStartTimeout = 5 * time.Second
+
+func runDaemon(args []string) error {
+	// Starts or stops the daemon keeping the connections to the provider open, or shows whether it runs.
+	cmd := newFlagSet("daemon")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 1 {
+		return fmt.Errorf("usage: ai-generate-commit daemon [%s]", strings.Join(daemonActions, "|"))
+	}
+	action := "status"
+	if cmd.NArg() == 1 {
+		action = cmd.Arg(0)
+	}
+
+	switch action {
+	case "start":
+		return startDaemon()
+	case "stop":
+		if err := daemon.Stop(); err != nil {
+			return err
+		}
+		statusf("Daemon stopped.")
+		return nil
+	case "status":
+		status, err := daemon.GetStatus()
+		if errors.Is(err, daemon.ErrNotRunning) {
+			fmt.Println("Daemon: not running (start it with 'ai-generate-commit daemon start')")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Daemon: running (pid %d) for %s\n", status.PID, time.Since(status.Started).Round(time.Second))
+		fmt.Printf("Socket: %s\n", daemon.SocketPath())
+		fmt.Printf("Requests forwarded: %d\n", status.Requests)
+		fmt.Printf("Configuration reloads: %d\n", status.Reloads)
+		return nil
+	case "run":
+		// Runs the daemon in the foreground, e.g. under a service manager, until interrupted.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		statusf("Daemon listening on %s", daemon.SocketPath())
+		return daemon.Serve(ctx, provider.NewDaemonTransport, config.GetConfigPath())
+	}
+	return fmt.Errorf("unknown action %q, expected one of: %s", action, strings.Join(daemonActions, ", "))
+}
+
+func startDaemon() error {
+	// Starts "daemon run" in the background, detached from the terminal, and waits until it answers.
+	if status, err := daemon.GetStatus(); err == nil {
+		statusf("The daemon is already running (pid %d).", status.PID)
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate the executable of the daemon: %w", err)
+	}
+	// Runs in the home directory, so that the configuration of the current repository does not apply.
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, "daemon", "run")
+	cmd.Dir = home
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the daemon: %w", err)
+	}
+	cmd.Process.Release()
+
+	deadline := time.Now().Add(daemonStartTimeout)
+	for time.Now().Before(deadline) {
+		if status, err := daemon.GetStatus(); err == nil {
+			statusf("Daemon started (pid %d).", status.PID)
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("the daemon did not start; run 'ai-generate-commit --verbose daemon run' to see why")
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/hooks"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// errNoDiff is returned for a diff handed to the tool that changes no files.
+var errNoDiff = errors.New("no Git diff found")
+
+// conventionSample is the number of recent commits whose subjects decide the "auto" commit style.
+const conventionSample = 20
+
+func promptDiff(gitRepo git.GitRepo, files []string, getDiff func([]string) (string, error)) (string, error) {
+	// Builds the diff sent to the AI, leaving out files matching DIFF_EXCLUDE such as lockfiles,
+	// whose diffs are large and say little about the change, and files ignored by .aicommitignore.
+	ignore, err := loadIgnore(gitRepo)
+	if err != nil {
+		return "", err
+	}
+	included, excluded := ignore.Filter(files)
+	if len(excluded) > 0 {
+		logging.Debug("left files out of the prompt", "reason", "DIFF_EXCLUDE or "+git.IgnoreFileName, "files", strings.Join(excluded, ", "))
+	}
+
+	// Only asks for a diff when files remain, since an empty file list would diff everything.
+	diff := ""
+	if len(included) > 0 {
+		if diff, err = getDiff(included); err != nil {
+			return "", err
+		}
+	}
+
+	// Gives an overview of the changed files before the hunks, which helps with changes to many files.
+	stat := ""
+	if showStat, _, err := config.GetBool("DIFF_STAT"); err != nil {
+		return "", err
+	} else if showStat {
+		stat = git.DiffStat(diff)
+	}
+
+	// Summarizes pure renames and large new files instead of sending them whole.
+	opts, err := contentOptions()
+	if err != nil {
+		return "", err
+	}
+	summarized := git.SummarizeNewFiles(git.SummarizeRenames(diff), opts)
+	if len(summarized) != len(diff) {
+		logging.Debug("summarized renamed files and new files", "max_new_file_lines", opts.MaxNewFileLines, "bytes_before", len(diff), "bytes_after", len(summarized))
+	}
+	diff = summarized
+	if stat != "" {
+		diff = "Summary of the changed files:\n" + stat + "\n\n" + diff
+	}
+
+	// Still names the excluded files, so a commit that only touches them gets a meaningful message.
+	if len(excluded) > 0 {
+		diff += "\n\nFiles changed whose diff is omitted:\n- " + strings.Join(excluded, "\n- ")
+	}
+
+	// Lets PRE_GENERATE_HOOK check or rewrite the diff, e.g. to redact secrets.
+	diff = strings.TrimSpace(diff)
+	if diff == "" {
+		return "", nil
+	}
+	diff, err = runHook(hooks.PreGenerate, diff)
+	return strings.TrimSpace(diff), err
+}
+
+func readStdinDiff(gitRepo git.GitRepo) (string, []string, error) {
+	// Reads a diff piped into the tool, e.g. from "git diff HEAD~3" or a mailed patch, and prepares it
+	// for the prompt like the staged changes. Also returns the files the diff changes.
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", nil, fmt.Errorf("-stdin expects a diff to be piped in, e.g. git diff HEAD~3 | ai-generate-commit generate -stdin")
+	}
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read the diff from stdin: %w", err)
+	}
+	diff, files, err := givenDiff(gitRepo, string(input))
+	if errors.Is(err, errNoDiff) {
+		return "", nil, fmt.Errorf("%w on stdin", err)
+	}
+	return diff, files, err
+}
+
+func givenDiff(gitRepo git.GitRepo, input string) (string, []string, error) {
+	// Prepares a diff that was handed to the tool rather than read from the repository, like the
+	// staged changes. Also returns the files the diff changes.
+	files := git.DiffFiles(input)
+	if len(files) == 0 {
+		return "", nil, errNoDiff
+	}
+	diff, err := promptDiff(gitRepo, files, func(files []string) (string, error) {
+		return git.FilterDiff(input, files), nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return diff, files, nil
+}
+
+func contentOptions() (git.ContentOptions, error) {
+	// Reads how the contents of new files are included in the prompt.
+	var opts git.ContentOptions
+	var err error
+	if opts.MaxNewFileLines, _, err = config.GetInt("NEW_FILE_MAX_LINES"); err != nil {
+		return opts, err
+	}
+	if opts.PreviewLines, _, err = config.GetInt("NEW_FILE_PREVIEW_LINES"); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func loadIgnore(gitRepo git.GitRepo) (*git.Ignore, error) {
+	// Combines the DIFF_EXCLUDE patterns with the repository's .aicommitignore, which comes last
+	// so that its "!pattern" rules can re-include files excluded by default.
+	patterns, _, err := config.GetList("DIFF_EXCLUDE")
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 1 && patterns[0] == "none" {
+		patterns = nil
+	}
+
+	ignore, err := git.NewIgnore(patterns)
+	if err != nil {
+		return nil, err
+	}
+	// A diff read with -stdin may be described outside of any repository.
+	if gitRepo.AssertRepo() != nil {
+		return ignore, nil
+	}
+	root, err := gitRepo.Root()
+	if err != nil {
+		return nil, err
+	}
+	if err := ignore.AddFile(filepath.Join(root, git.IgnoreFileName)); err != nil {
+		return nil, err
+	}
+	return ignore, nil
+}
+
+func repoContext(gitRepo git.GitRepo, amend bool) (service.RepoContext, error) {
+	// Collects recent commit subjects as style examples, the branch name and,
+	// if BASE_BRANCH is set, how the branch differs from it.
+	var repo service.RepoContext
+
+	examples, _, err := config.GetInt("HISTORY_EXAMPLES")
+	if err != nil {
+		return repo, err
+	}
+	if examples > 0 {
+		// Leaves out the commit being amended, whose message is about to be replaced.
+		skip := 0
+		if amend {
+			skip = 1
+		}
+		if repo.Examples, err = gitRepo.RecentSubjects(examples, skip); err != nil {
+			return repo, err
+		}
+	}
+
+	// Detects the convention of the history when the style is chosen automatically.
+	style, err := config.GetConfig("COMMIT_STYLE")
+	if err != nil {
+		return repo, err
+	}
+	if style == service.StyleAuto {
+		skip := 0
+		if amend {
+			skip = 1
+		}
+		subjects, err := gitRepo.RecentSubjects(conventionSample, skip)
+		if err != nil {
+			return repo, err
+		}
+		repo.Convention = service.DetectStyle(subjects)
+	}
+
+	branchContext, ok, err := config.GetBool("BRANCH_CONTEXT")
+	if err != nil {
+		return repo, err
+	}
+	if branchContext || !ok {
+		if repo.Branch, err = gitRepo.CurrentBranch(); err != nil {
+			return repo, err
+		}
+	}
+
+	// Tells the AI about the issue the branch refers to. The issue is only context, so a tracker that
+	// cannot be reached is reported without failing.
+	if repo.Issue, err = branchIssue(gitRepo); err != nil {
+		warnf("the issue of the branch is left out of the prompt: %v", err)
+	}
+
+	base, err := config.GetConfig("BASE_BRANCH")
+	if err != nil || base == "" {
+		return repo, err
+	}
+	repo.BaseBranch = base
+	if repo.BaseStat, err = gitRepo.BranchDiffStat(base); err != nil {
+		return repo, err
+	}
+	return repo, nil
+}
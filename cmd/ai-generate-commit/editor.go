@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/hambosto/ai-generate-commit/internal/shell"
+)
+
+func launchEditor(path string) error {
+	// Opens path in the user's editor and waits for it to exit.
+	// $VISUAL and $EDITOR may contain arguments (e.g. "code --wait"), so they are run through the shell.
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	cmd := shell.Command(editor, path)
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
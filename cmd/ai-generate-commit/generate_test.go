@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+)
+
+const (
+	mainDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+ 
++// main runs the program.
+ func main() {}
+`
+	readmeDiff = `diff --git a/README.md b/README.md
+index 3333333..4444444 100644
+--- a/README.md
++++ b/README.md
+@@ -1 +1,2 @@
+ # App
++Usage notes.
+`
+)
+
+// setupGenerate runs generate away from the repository of the tests and the settings of the user,
+// whose configuration file, hooks and AI_COMMIT_* variables are left out: without linting, caching
+// or history, and without showing anything but questions.
+func setupGenerate(t *testing.T) {
+	t.Helper()
+	if err := git.SetWorkDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	for _, variable := range os.Environ() {
+		if name, _, _ := strings.Cut(variable, "="); strings.HasPrefix(name, config.EnvPrefix) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("AI_COMMIT_LINT", "false")
+	t.Setenv("AI_COMMIT_CACHE_TTL", "0")
+	t.Setenv("AI_COMMIT_HISTORY_SIZE", "0")
+	quiet = true
+	t.Cleanup(func() {
+		quiet, assumeYes = false, false
+	})
+}
+
+func TestGenerateCommitsStagedChanges(t *testing.T) {
+	setupGenerate(t)
+	repo := &git.FakeRepo{Dir: t.TempDir(), Branch: "main", Staged: mainDiff}
+
+	if err := generate(repo, []string{"-offline", "-y"}); err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	if len(repo.Commits) != 1 {
+		t.Fatalf("generate() made %d commits, want 1", len(repo.Commits))
+	}
+	commit := repo.Commits[0]
+	if commit.Diff != mainDiff {
+		t.Errorf("commit diff = %q, want the staged changes %q", commit.Diff, mainDiff)
+	}
+	if !strings.Contains(commit.Message, "main.go") {
+		t.Errorf("commit message = %q, want it to name main.go", commit.Message)
+	}
+	if repo.Staged != "" {
+		t.Errorf("staged changes left after the commit: %q", repo.Staged)
+	}
+}
+
+func TestGenerateAmendsLastCommit(t *testing.T) {
+	setupGenerate(t)
+	repo := &git.FakeRepo{Dir: t.TempDir(), Branch: "main", Head: mainDiff, Staged: readmeDiff, Subjects: []string{"Document main"}}
+
+	if err := generate(repo, []string{"-offline", "-y", "-amend"}); err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	if len(repo.Commits) != 1 || !repo.Commits[0].Options.Amend {
+		t.Fatalf("generate() commits = %+v, want one amended commit", repo.Commits)
+	}
+	for _, file := range []string{"main.go", "README.md"} {
+		if !strings.Contains(repo.Commits[0].Diff, "b/"+file) {
+			t.Errorf("amended commit does not change %s: %q", file, repo.Commits[0].Diff)
+		}
+	}
+	if len(repo.Subjects) != 1 {
+		t.Errorf("subjects = %q, want the last one replaced", repo.Subjects)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		repo *git.FakeRepo
+		args []string
+		want error
+	}{
+		{
+			name: "outside a repository",
+			repo: &git.FakeRepo{Staged: mainDiff},
+			args: []string{"-offline", "-y"},
+			want: git.ErrNotGitRepo,
+		},
+		{
+			name: "nothing staged",
+			repo: &git.FakeRepo{Dir: "/repo", Branch: "main"},
+			args: []string{"-offline", "-y"},
+			want: git.ErrNoChanges,
+		},
+		{
+			name: "failing git",
+			repo: &git.FakeRepo{Dir: "/repo", Staged: mainDiff, Err: errors.New("git exploded")},
+			args: []string{"-offline", "-y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupGenerate(t)
+			err := generate(tt.repo, tt.args)
+			if err == nil {
+				t.Fatal("generate() succeeded, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("generate() error = %v, want %v", err, tt.want)
+			}
+			if len(tt.repo.Commits) != 0 {
+				t.Errorf("generate() made commits after failing: %+v", tt.repo.Commits)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+func recordHistory(generator *service.CommitMessageGenerator, status history.Status, messages ...string) {
+	// Adds messages to the local history, and counts them for the usage statistics. A failure is only
+	// reported, since the history is never worth failing a commit over.
+	repo, _ := git.GetRepoRoot()
+	now := time.Now()
+	var entries []history.Entry
+	for _, message := range messages {
+		if strings.TrimSpace(message) == "" {
+			continue
+		}
+		entries = append(entries, history.Entry{
+			Time:     now,
+			Repo:     repo,
+			Provider: generator.Provider(),
+			Model:    generator.Model(),
+			Status:   status,
+			Message:  message,
+		})
+	}
+	if err := history.Append(entries...); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the message was not saved in the history: %v\n", err)
+	}
+	countMessages(generator, status, len(entries))
+}
+
+func runHistory(args []string) error {
+	// Defines the "history" command, which lists the generated messages, newest first, prints one of
+	// them for reuse, or commits the staged changes with it.
+	cmd := newFlagSet("history")
+	all := cmd.Bool("all", false, "List the messages of every repository instead of the current one")
+	limit := cmd.Int("n", 20, "Number of messages to list")
+	status := cmd.String("status", "", "List only accepted, rejected or printed messages")
+	commit := cmd.Bool("commit", false, "Commit the staged changes with the message given by its number, after confirmation")
+	addYesFlags(cmd)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 1 || (*commit && cmd.NArg() == 0) {
+		return fmt.Errorf("usage: ai-generate-commit history [-all] [-n N] [-status STATUS] [-commit] [NUMBER]")
+	}
+	switch history.Status(*status) {
+	case "", history.StatusAccepted, history.StatusRejected, history.StatusPrinted:
+	default:
+		return fmt.Errorf("invalid -status %q: must be accepted, rejected or printed", *status)
+	}
+
+	entries, err := loadHistory(*all, history.Status(*status))
+	if err != nil {
+		return err
+	}
+
+	// Prints the whole message given by its number, raw so that it can be piped into git commit -F -.
+	if cmd.NArg() == 1 {
+		n, err := strconv.Atoi(cmd.Arg(0))
+		if err != nil || n < 1 || n > len(entries) {
+			return fmt.Errorf("no message number %s in the history; run history to list the numbers", cmd.Arg(0))
+		}
+		message := entries[n-1].Message
+		if !*commit {
+			fmt.Println(message)
+			return nil
+		}
+		return commitFromHistory(message)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No generated messages in the history (%s).\n", history.Path())
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, entry := range entries[:min(*limit, len(entries))] {
+		subject, _, _ := strings.Cut(entry.Message, "\n")
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, entry.Time.Local().Format("2006-01-02 15:04"), entry.Status, entry.Model, subject)
+	}
+	tw.Flush()
+	fmt.Println("\nRun 'ai-generate-commit history NUMBER' to print a message, or add -commit to commit with it.")
+	return nil
+}
+
+func loadHistory(all bool, status history.Status) ([]history.Entry, error) {
+	// Returns the messages of the history newest first, of the current repository unless all is set,
+	// and with the given status unless it is empty.
+	entries, err := history.Load()
+	if err != nil {
+		return nil, err
+	}
+	repo := ""
+	if !all {
+		if repo, err = git.GetRepoRoot(); err != nil {
+			return nil, fmt.Errorf("%w; pass -all to list the messages of every repository", err)
+		}
+	}
+	var result []history.Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if (all || entries[i].Repo == repo) && (status == "" || entries[i].Status == status) {
+			result = append(result, entries[i])
+		}
+	}
+	return result, nil
+}
+
+func commitFromHistory(message string) error {
+	// Commits the staged changes with a message from the history once confirmed.
+	if err := git.AssertGitRepo(); err != nil {
+		return err
+	}
+	files, err := git.GetStagedFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%w to commit", git.ErrNothingStaged)
+	}
+	fmt.Printf("Commit Message:\n\n%s\n\n", message)
+	if !confirm("Do you want to commit the staged changes with this message?") {
+		statusf("Commit aborted.")
+		return errAborted
+	}
+	if err := git.GitCommit(message, git.CommitOptions{}); err != nil {
+		return err
+	}
+	statusf("Changes committed successfully.")
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/hooks"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+const (
+	// hookName is the Git hook the tool installs.
+	hookName = "prepare-commit-msg"
+	// hookMarker identifies hooks installed by the tool, so other hooks are never overwritten or removed.
+	hookMarker = "# Installed by ai-generate-commit."
+	// scissors follows the comment character on the line below which Git drops the rest of the
+	// message file, such as the diff shown by "git commit -v".
+	scissors = "------------------------ >8 ------------------------"
+)
+
+func runInstallHook(args []string) error {
+	// Defines the "installHook" command.
+	cmd := newFlagSet("installHook")
+	force := cmd.Bool("force", false, "Replace an existing prepare-commit-msg hook")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+
+	hookPath, err := hookFilePath()
+	if err != nil {
+		return err
+	}
+
+	// Refuses to overwrite a hook that was not installed by the tool.
+	if data, err := os.ReadFile(hookPath); err == nil && !strings.Contains(string(data), hookMarker) && !*force {
+		return fmt.Errorf("%s already exists; remove it or use -force to replace it", hookPath)
+	}
+
+	// Calls this executable by its absolute path so the hook works without it being on PATH.
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the executable: %w", err)
+	}
+	executable = filepath.ToSlash(executable)
+
+	script := fmt.Sprintf("#!/bin/sh\n%s\n# Remove it with 'ai-generate-commit uninstallHook'.\nexec '%s' prepareCommitMsg \"$@\" < /dev/null\n",
+		hookMarker, strings.ReplaceAll(executable, "'", `'\''`))
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(hookPath, []byte(script), 0o755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+
+	statusf("Installed %s", hookPath)
+	return nil
+}
+
+func runUninstallHook(args []string) error {
+	// Removes the hook, but only if it was installed by the tool.
+	if err := parseNoArgs("uninstallHook", args); err != nil {
+		return err
+	}
+	hookPath, err := hookFilePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		fmt.Println("No prepare-commit-msg hook is installed.")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read hook: %w", err)
+	}
+	if !strings.Contains(string(data), hookMarker) {
+		return fmt.Errorf("%s was not installed by ai-generate-commit; remove it manually", hookPath)
+	}
+
+	if err := os.Remove(hookPath); err != nil {
+		return fmt.Errorf("failed to remove hook: %w", err)
+	}
+	statusf("Removed %s", hookPath)
+	return nil
+}
+
+func hookFilePath() (string, error) {
+	// Returns the path of the prepare-commit-msg hook of the current repository.
+	if err := git.AssertGitRepo(); err != nil {
+		return "", err
+	}
+	hooksDir, err := git.GetHooksDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(hooksDir, hookName), nil
+}
+
+func runPrepareCommitMsg(args []string) error {
+	// Runs as the prepare-commit-msg hook: git passes the message file, the message source and a commit.
+	// Failures are reported as warnings so that a generation problem never blocks the commit.
+	// The pre-commit framework only passes the message file, with the source and commit in its
+	// environment, and may leave the terminal on stdin, so nothing is asked under it.
+	if os.Getenv("PRE_COMMIT") == "1" {
+		assumeYes = true
+		if len(args) == 1 {
+			args = append(args, os.Getenv("PRE_COMMIT_COMMIT_MSG_SOURCE"), os.Getenv("PRE_COMMIT_COMMIT_OBJECT_NAME"))
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ai-generate-commit prepareCommitMsg MESSAGE_FILE [SOURCE [COMMIT]]")
+	}
+	if err := prepareCommitMsg(args[0], args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ai-generate-commit: no message generated: %v\n", err)
+	}
+	return nil
+}
+
+func prepareCommitMsg(messageFile string, rest []string) error {
+	// Leaves messages given with -m, -F, -c, merges and squashes alone; only plain and template commits get a suggestion.
+	if len(rest) > 0 && rest[0] != "" && rest[0] != "template" {
+		return nil
+	}
+
+	data, err := os.ReadFile(messageFile)
+	if err != nil {
+		return fmt.Errorf("failed to read commit message file: %w", err)
+	}
+
+	// Keeps a message that was already written, unless it is a commit template the suggestion is merged into.
+	// Templates of pairing tools that only list co-authors still get a suggestion.
+	mergeTemplate := false
+	if len(rest) > 0 && rest[0] == "template" {
+		if mergeTemplate, err = useCommitTemplate(); err != nil {
+			return err
+		}
+	}
+	if !mergeTemplate && hasMessage(string(data)) {
+		return nil
+	}
+
+	// Describes the staged changes without prompting; there is nothing to do if none are staged.
+	gitRepo := git.NewRepo()
+	stagedFiles, err := gitRepo.StagedFiles()
+	if err != nil {
+		return err
+	}
+	if len(stagedFiles) == 0 {
+		return nil
+	}
+	diff, err := promptDiff(gitRepo, stagedFiles, gitRepo.Diff)
+	if err != nil || diff == "" {
+		return err
+	}
+
+	repo, err := repoContext(gitRepo, false)
+	if err != nil {
+		return err
+	}
+	generator, err := service.NewCommitMessageGenerator(service.Options{Context: repo})
+	if err != nil {
+		return err
+	}
+	spinner := startSpinner(generator, "Generating the commit message")
+	commitMessage, err := generator.GenerateCommitMessage(diff)
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+	if commitMessage, err = runHook(hooks.PostGenerate, commitMessage); err != nil {
+		return err
+	}
+	if commitMessage, err = addIssueReference(gitRepo, strings.TrimSpace(commitMessage)); err != nil {
+		return err
+	}
+
+	// Puts the suggestion above the comments git added, so it can be reviewed in the editor.
+	// The trailers are added last, so they join the co-authors of a template instead of repeating them.
+	trailers, err := collectTrailers(gitRepo, nil, nil)
+	if err != nil {
+		return err
+	}
+	content := commitMessage + "\n" + string(data)
+	if mergeTemplate {
+		// Leaves the comments in place; git strips them once the message has been edited.
+		content = service.MergeCommitTemplate(commitMessage, string(data))
+	}
+	content, err = git.AddTrailers(content, trailers)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(messageFile, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write commit message file: %w", err)
+	}
+	recordHistory(generator, history.StatusPrinted, commitMessage)
+	return nil
+}
+
+func hasMessage(content string) bool {
+	// Reports whether content has any line that is neither blank, a comment nor a co-author trailer,
+	// above the scissors line.
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if comment, rest, ok := strings.Cut(line, " "); ok && rest == scissors && utf8.RuneCountInString(comment) == 1 {
+			break
+		}
+		if line != "" && !strings.HasPrefix(line, "#") && !isCoAuthorLine(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCoAuthorLine(line string) bool {
+	// Reports whether line is a Co-authored-by trailer.
+	token, _, found := strings.Cut(line, ":")
+	return found && strings.EqualFold(token, git.CoAuthorTrailer)
+}
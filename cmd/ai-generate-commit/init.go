@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+func runInit(args []string) error {
+	// Walks the user through the initial configuration and saves it at the end.
+	if err := parseNoArgs("init", args); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(os.Stdin)
+	values := map[string]string{}
+
+	fmt.Println("Welcome to ai-generate-commit! Let's set up your configuration.")
+	fmt.Println()
+
+	// Chooses the provider.
+	names := provider.Names()
+	providerName, err := promptChoice(reader, "Select a provider:", names, 0)
+	if err != nil {
+		return err
+	}
+	values["PROVIDER"] = providerName
+
+	preset, err := provider.Lookup(providerName)
+	if err != nil {
+		return err
+	}
+
+	// Asks for the provider-specific settings.
+	switch preset.Name {
+	case "llamacpp":
+		serverURL, err := promptLine(reader, "llama.cpp server URL", "http://localhost:8080")
+		if err != nil {
+			return err
+		}
+		values["LLAMACPP_URL"] = serverURL
+	case "vertex":
+		fmt.Println("Vertex AI uses Application Default Credentials; run 'gcloud auth application-default login' if you haven't yet.")
+		project, err := promptLine(reader, "Google Cloud project (empty to use the credentials' project)", "")
+		if err != nil {
+			return err
+		}
+		region, err := promptLine(reader, "Region", "us-central1")
+		if err != nil {
+			return err
+		}
+		values["VERTEX_PROJECT"] = project
+		values["VERTEX_REGION"] = region
+	case "mock":
+		fmt.Println("The mock provider replays responses recorded with MOCK_MODE set to record; see the README.")
+		dir, err := promptLine(reader, "Directory of the recorded responses", ".ai-commit-fixtures")
+		if err != nil {
+			return err
+		}
+		values["MOCK_FIXTURES"] = dir
+	}
+
+	if preset.APIKeyConfig != "" {
+		apiKey, err := promptSecret(reader, fmt.Sprintf("Enter your %s", preset.APIKeyConfig))
+		if err != nil {
+			return err
+		}
+		if apiKey == "" {
+			return fmt.Errorf("%s must be provided", preset.APIKeyConfig)
+		}
+		values[preset.APIKeyConfig] = apiKey
+	}
+
+	// Picks a model from the list offered by the provider.
+	model, err := promptModel(reader, values, preset)
+	if err != nil {
+		return err
+	}
+	values["MODEL"] = model
+
+	// Selects the commit message style, or a custom prompt for the default style.
+	styleKey, _ := config.LookupKey("COMMIT_STYLE")
+	style, err := promptChoice(reader, "Select a commit message style:", append(styleKey.Values, "custom prompt"), 0)
+	if err != nil {
+		return err
+	}
+	values["COMMIT_STYLE"] = style
+	values["COMMIT_PROMPT"] = ""
+	if style == "custom prompt" {
+		values["COMMIT_STYLE"] = service.StyleDefault
+		customPrompt, err := promptLine(reader, "Custom prompt", "")
+		if err != nil {
+			return err
+		}
+		values["COMMIT_PROMPT"] = customPrompt
+	}
+
+	// Saves all settings at once so an aborted wizard leaves the config untouched.
+	if err := config.SetConfigValues(values); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nConfiguration saved to %s\n", config.GetConfigPath())
+	fmt.Println("Stage some changes and run 'ai-generate-commit generate' to try it out.")
+	return nil
+}
+
+func promptModel(reader *bufio.Reader, values map[string]string, preset provider.Preset) (string, error) {
+	// Builds a client from the values entered so far, falling back to the saved config,
+	// and lets the user type a model name when the provider cannot be reached.
+	lookup := func(key string) (string, error) {
+		if value, ok := values[key]; ok {
+			return value, nil
+		}
+		return config.GetConfig(key)
+	}
+
+	client, _, err := provider.NewWithLookup(lookup)
+	if err != nil {
+		// The llama.cpp server may not be running yet, or Vertex AI credentials not set up.
+		fmt.Printf("Could not connect to the provider: %v\n", err)
+		return promptLine(reader, "Model", preset.DefaultModel)
+	}
+
+	fmt.Println("\nFetching available models...")
+	models, err := client.ListModels()
+	if err != nil || len(models) == 0 {
+		// Lets the user type a model name when the list cannot be fetched.
+		if err != nil {
+			fmt.Printf("Could not fetch the model list: %v\n", err)
+		}
+		return promptLine(reader, "Model", preset.DefaultModel)
+	}
+
+	defaultIndex := 0
+	for i, model := range models {
+		if model == preset.DefaultModel {
+			defaultIndex = i
+		}
+	}
+	return promptChoice(reader, "Select a model:", models, defaultIndex)
+}
+
+func promptChoice(reader *bufio.Reader, question string, options []string, defaultIndex int) (string, error) {
+	// Prints a numbered list of options and returns the selected one.
+	fmt.Println(question)
+	for i, option := range options {
+		fmt.Printf("  %d) %s\n", i+1, option)
+	}
+
+	for {
+		answer, err := promptLine(reader, "Choice", strconv.Itoa(defaultIndex+1))
+		if err != nil {
+			return "", err
+		}
+		index, err := strconv.Atoi(answer)
+		if err == nil && index >= 1 && index <= len(options) {
+			return options[index-1], nil
+		}
+		fmt.Printf("Invalid choice. Please enter a number between 1 and %d.\n", len(options))
+	}
+}
+
+func promptLine(reader *bufio.Reader, label, defaultValue string) (string, error) {
+	// Reads a single line, returning defaultValue when the line is empty.
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]: ", label, defaultValue)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return defaultValue, nil
+	}
+	return line, nil
+}
+
+func promptSecret(reader *bufio.Reader, label string) (string, error) {
+	// Reads a line without echoing it when stdin is a terminal.
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return promptLine(reader, label, "")
+	}
+
+	fmt.Printf("%s (input hidden): ", label)
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// integrations are the tools the "integrations" command writes configuration for.
+var integrations = []string{"lazygit", "commitizen"}
+
+// lazygitTemplate is the custom command of lazygit that commits the staged changes with a generated
+// message, see https://github.com/jesseduffield/lazygit/blob/master/docs/Custom_Command_Keybindings.md.
+// The message is generated first, so that a failure leaves nothing committed and is shown by lazygit.
+const lazygitTemplate = `# Add this to the configuration file of lazygit, in the directory shown by
+# "lazygit --print-config-dir", then press %[1]s in the files panel.
+customCommands:
+  - key: "%[1]s"
+    context: files
+    description: Commit the staged changes with a generated message
+%[2]s`
+
+// commitizenAdapter is an adapter of Commitizen (https://github.com/commitizen/cz-cli), which makes
+// "git cz" commit with a generated message instead of asking for its parts. Commitizen commits with
+// git, so the commit-msg hooks of the repository, such as commitlint, still check the message.
+const commitizenAdapter = `// Commitizen adapter committing with a message written by ai-generate-commit.
+// Written by "ai-generate-commit integrations commitizen"; point the commitizen path of
+// package.json or .czrc at this file.
+"use strict";
+
+const { execFileSync } = require("child_process");
+
+module.exports = {
+  prompter(cz, commit) {
+    let message;
+    try {
+      message = execFileSync("ai-generate-commit", ["generate", "-raw", "-style", "conventional"], {
+        encoding: "utf8",
+        stdio: ["ignore", "pipe", "inherit"],
+      }).trim();
+    } catch (err) {
+      // The error has been printed on stderr.
+      process.exitCode = err.status || 1;
+      return;
+    }
+
+    cz.prompt([
+      {
+        type: "list",
+        name: "action",
+        message: "Generated commit message:\n\n" + message + "\n\n",
+        choices: [
+          { name: "Commit", value: "commit" },
+          { name: "Edit, then commit", value: "edit" },
+          { name: "Abort", value: "abort" },
+        ],
+      },
+      {
+        type: "editor",
+        name: "message",
+        message: "Edit the commit message",
+        default: message,
+        when: (answers) => answers.action === "edit",
+      },
+    ]).then((answers) => {
+      if (answers.action === "abort") {
+        process.exitCode = 6;
+        return;
+      }
+      commit((answers.message || message).trim());
+    });
+  },
+};
+`
+
+func runIntegrations(args []string) error {
+	// Prints the configuration that runs the tool from another program, e.g. a custom command of lazygit.
+	cmd := newFlagSet("integrations")
+	key := cmd.String("key", "<c-g>", "Key of the lazygit custom command, in the notation of lazygit (lazygit)")
+	noEdit := cmd.Bool("no-edit", false, "Commit with the generated message right away instead of opening it in the editor of Git (lazygit)")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	usage := fmt.Errorf("usage: ai-generate-commit integrations lazygit [-key KEY] [-no-edit] | commitizen")
+	if cmd.NArg() == 0 {
+		return usage
+	}
+	// Also accepts the flags after the name of the integration.
+	name := cmd.Arg(0)
+	if err := cmd.Parse(cmd.Args()[1:]); err != nil {
+		return err
+	}
+	if cmd.NArg() > 0 {
+		return usage
+	}
+
+	switch name {
+	case "lazygit":
+		fmt.Printf(lazygitTemplate, *key, lazygitCommand(*noEdit))
+		return nil
+	case "commitizen":
+		fmt.Print(commitizenAdapter)
+		return nil
+	}
+	return fmt.Errorf("unknown integration %q, expected one of: %s", name, strings.Join(integrations, ", "))
+}
+
+func lazygitCommand(noEdit bool) string {
+	// Returns the remaining lines of the custom command. Editing the message needs the terminal, which
+	// lazygit only hands over to a subprocess, without showing its loading text.
+	generate := `msg="$(ai-generate-commit generate -raw)"`
+	if noEdit {
+		return fmt.Sprintf("    loadingText: Generating the commit message...\n    command: '%s && git commit -m \"$msg\"'\n", generate)
+	}
+	return fmt.Sprintf("    command: '%s && git commit -e -m \"$msg\"'\n    subprocess: true\n", generate)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+	"github.com/hambosto/ai-generate-commit/internal/tracker"
+)
+
+func addIssueReference(gitRepo git.GitRepo, message string) (string, error) {
+	// Adds the issue named by the current branch, such as "PROJ-123" in "feat/PROJ-123-new-login",
+	// to the subject of message.
+	branch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return "", err
+	}
+	reference, err := service.FindIssueReference(branch)
+	if err != nil {
+		return "", err
+	}
+	return service.InsertIssueReference(message, reference)
+}
+
+func branchIssue(gitRepo git.GitRepo) (*tracker.Issue, error) {
+	// Fetches the issue named by the current branch from the issue tracker, if one is configured.
+	branch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	reference, err := service.FindIssueReference(branch)
+	if err != nil || reference == "" {
+		return nil, err
+	}
+	return tracker.Fetch(reference)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// generateResult is the output of "generate -json".
+type generateResult struct {
+	Message  string       `json:"message"`  // The finished commit message, including trailers
+	Provider string       `json:"provider"` // The provider the message was generated with
+	Model    string       `json:"model"`    // The model the message was generated with
+	Files    []string     `json:"files"`    // The files described by the message
+	Tokens   resultTokens `json:"tokens"`   // The tokens used by all requests
+
+	// Omitted lists the parts of the diff left out to fit the context window of the model.
+	Omitted []string `json:"omitted,omitempty"`
+}
+
+// resultTokens is the token usage reported in generateResult; it is zero for providers that do not report it.
+type resultTokens struct {
+	Prompt     int `json:"prompt"`
+	Completion int `json:"completion"`
+	Total      int `json:"total"`
+}
+
+func describedFiles(gitRepo git.GitRepo, amend bool) ([]string, error) {
+	// Returns the files a generated message describes: the staged files, or those of the amended commit.
+	if amend {
+		return gitRepo.AmendFiles()
+	}
+	return gitRepo.StagedFiles()
+}
+
+func writeGenerateJSON(w io.Writer, generator *service.CommitMessageGenerator, message string, files []string) error {
+	// Writes the generated message with the model, the files it describes and the token usage as
+	// one indented JSON object.
+	if files == nil {
+		files = []string{}
+	}
+
+	var omitted []string
+	for _, omission := range generator.Omitted() {
+		omitted = append(omitted, omission.String())
+	}
+
+	usage := generator.Usage()
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(generateResult{
+		Message:  message,
+		Provider: generator.Provider(),
+		Model:    generator.Model(),
+		Files:    files,
+		Tokens: resultTokens{
+			Prompt:     usage.PromptTokens,
+			Completion: usage.CompletionTokens,
+			Total:      usage.TotalTokens,
+		},
+		Omitted: omitted,
+	})
+}
@@ -2,56 +2,242 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
 
 	"github.com/hambosto/ai-generate-commit/internal/config"
 	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/hooks"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/notify"
 	"github.com/hambosto/ai-generate-commit/internal/service"
+	"github.com/hambosto/ai-generate-commit/internal/ui"
+)
+
+var (
+	// stdin reads answers to prompts. It is shared so that input read ahead by one prompt is not lost to the next.
+	stdin = bufio.NewReader(os.Stdin)
+	// assumeYes answers yes to every confirmation without asking, set by the -yes flag.
+	assumeYes bool
+	// quiet suppresses the output that only reports progress or success, set by the --quiet flag.
+	quiet bool
+	// rawOutput also suppresses warnings, set by the -raw flag of generate.
+	rawOutput bool
+)
+
+// errAborted is returned when the user declines, after the abort has been reported.
+var errAborted = errors.New("aborted")
+
+// Exit codes of the tool, so that scripts and hooks can tell why it failed.
+const (
+	exitError      = 1 // Any other error
+	exitUsage      = 2 // Invalid flags, as reported by the flag package
+	exitNotRepo    = 3 // Not run in a Git repository
+	exitNoChanges  = 4 // Nothing staged or no changes to describe
+	exitAPIFailure = 5 // The request to the AI failed
+	exitAborted    = 6 // The user declined
 )
 
 func main() {
 	// Main entry point of the application. It calls the run() function
-	// and handles any errors by logging them and terminating the program.
+	// and handles any errors by logging them and exiting with the matching code.
 	if err := run(); err != nil {
-		log.Fatalf("Error: %v", err)
+		code := exitCode(err)
+		// An abort has already been reported, so it is not an error worth printing.
+		if code != exitAborted {
+			log.Printf("Error: %v", err)
+			reportCIError(err)
+		}
+		os.Exit(code)
+	}
+}
+
+func exitCode(err error) int {
+	// Returns the exit code for an error returned by run.
+	switch {
+	case errors.Is(err, errAborted), errors.Is(err, ui.ErrCanceled):
+		return exitAborted
+	case errors.Is(err, git.ErrNotGitRepo):
+		return exitNotRepo
+	case errors.Is(err, git.ErrNoChanges), errors.Is(err, git.ErrNothingStaged):
+		return exitNoChanges
+	case errors.Is(err, groq.ErrRequest):
+		return exitAPIFailure
 	}
+	return exitError
 }
 
 func run() error {
-	// Determines which command to execute based on the provided arguments.
-	// Defaults to running the "generate" command if no arguments are given.
-	if len(os.Args) < 2 {
-		return runGenerate()
-	}
-
-	// Switches between different commands based on the first argument.
-	switch os.Args[1] {
-	case "setConfig":
-		return runSetConfig()
-	case "getConfig":
-		return runGetConfig()
-	case "getConfigPath":
-		return runGetConfigPath()
-	case "generate":
-		return runGenerate()
-	default:
-		// Returns an error if an unknown command is provided.
-		return fmt.Errorf("unknown command: %s", os.Args[1])
+	// Asks for the passphrase of an encrypted config file interactively.
+	config.PassphraseFunc = promptPassphrase
+
+	// Extracts the global flags, which may appear anywhere in the arguments. The words handed
+	// to __complete by the completion scripts are left as typed.
+	args := os.Args[1:]
+	var globals globalFlags
+	if len(args) == 0 || args[0] != "__complete" {
+		var err error
+		if args, globals, err = extractGlobalFlags(args); err != nil {
+			return err
+		}
+	}
+
+	// Shows the help or the version before anything is loaded, so that both work with a broken configuration.
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			printUsage(os.Stdout)
+			return nil
+		case "-version", "--version", "version":
+			return runVersion(args[1:])
+		case "help":
+			return runHelp(args[1:])
+		}
+	}
+
+	// Leaves only the messages, questions and errors with --quiet.
+	quiet = globals.quiet
+	git.SetQuiet(quiet)
+
+	// Reports results to the CI system given with --ci.
+	if err := setCISystem(globals.ci); err != nil {
+		return err
+	}
+
+	// Reports the retries of failed API requests, which may otherwise look like a hang.
+	if !quiet {
+		groq.SetNotify(ui.Notify)
+	}
+	// Warns about diffs shortened to fit the context window, even with --quiet, since the message
+	// cannot describe what the AI has not seen.
+	service.SetNotify(ui.Notify)
+	// Warns about the settings of a repository file that are ignored, which the user may rely on.
+	config.SetNotify(ui.Notify)
+
+	// Turns off colors with --no-color, in addition to NO_COLOR and output that is not a terminal.
+	if globals.noColor {
+		ui.DisableColor()
+	}
+
+	// Prints the diagnostics of the level given with --log-level, --verbose or --debug to stderr until
+	// the configuration is loaded, which may send them to LOG_FILE instead.
+	if err := setFlagOverrides(map[string]string{"LOG_LEVEL": globals.logLevel}); err != nil {
+		return err
+	}
+	if globals.logLevel != "" {
+		if err := configureLogging(globals.logLevel, ""); err != nil {
+			return err
+		}
+	}
+
+	// Runs in another repository with "-C PATH", like git.
+	if globals.dir != "" {
+		if err := git.SetWorkDir(globals.dir); err != nil {
+			return err
+		}
+	}
+
+	// Loads the explicitly requested .env file, or the one in the repository root, which may only
+	// set the keys a repository may.
+	envFile, repoEnv := globals.envFile, false
+	if envFile == "" {
+		envFile, repoEnv = config.FindEnvFile(), true
+	}
+	if envFile != "" {
+		if err := config.LoadEnvFile(envFile, repoEnv); err != nil {
+			return err
+		}
+	}
+
+	// Selects the profile given with --profile.
+	if err := setFlagOverrides(map[string]string{"PROFILE": globals.profile}); err != nil {
+		return err
+	}
+
+	// Records the diagnostics as configured, which may be set in the .env file or the profile.
+	logLevel, err := config.GetConfig("LOG_LEVEL")
+	if err != nil {
+		return err
+	}
+	logFile, err := config.GetConfig("LOG_FILE")
+	if err != nil {
+		return err
+	}
+	if err := configureLogging(logLevel, logFile); err != nil {
+		return err
+	}
+	if logging.Enabled(slog.LevelInfo) {
+		if profile, err := config.GetActiveProfile(); err == nil && profile != nil {
+			logging.Info("using profile", "profile", profile.Name, "reason", profile.Reason)
+		}
+	}
+
+	// Selects how the repository is read, which may be configured in the .env file.
+	backend, err := config.GetConfig("GIT_BACKEND")
+	if err != nil {
+		return err
+	}
+	if err := git.SetBackend(backend); err != nil {
+		return err
+	}
+
+	// Runs the "generate" command if no command is given, including when the arguments start with its flags.
+	c, rest := command{name: "generate", run: runGenerate}, args
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		var ok bool
+		if c, ok = findCommand(args[0]); !ok {
+			return fmt.Errorf("unknown command: %s (run 'ai-generate-commit help' for a list of commands)", args[0])
+		}
+		rest = args[1:]
+	}
+	err = c.run(rest)
+
+	// Records the run for the usage statistics if TELEMETRY is enabled. Completions, which run on every
+	// keypress, and the telemetry command itself are left out.
+	if c.name != "__complete" && c.name != "telemetry" {
+		recordTelemetry(c.name, err)
+	}
+	return err
+}
+
+func statusf(format string, args ...any) {
+	// Prints a line that reports progress or success to stdout, except with --quiet.
+	if !quiet {
+		fmt.Printf(format+"\n", args...)
 	}
 }
 
-func runSetConfig() error {
+func configureLogging(level, file string) error {
+	// Records the diagnostics of level and above in file, or prints them to stderr if file is empty.
+	logLevel, err := logging.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	return logging.Configure(logLevel, file)
+}
+
+func runSetConfig(args []string) error {
 	// Defines the "setConfig" command to set a configuration key-value pair.
-	cmd := flag.NewFlagSet("setConfig", flag.ExitOnError)
+	cmd := newFlagSet("setConfig")
 	key := cmd.String("key", "", "Config key")
 	value := cmd.String("value", "", "Config value")
 
 	// Parses the arguments for the setConfig command.
-	if err := cmd.Parse(os.Args[2:]); err != nil {
+	if err := cmd.Parse(args); err != nil {
 		return err
 	}
 
@@ -64,13 +250,32 @@ func runSetConfig() error {
 	return config.SetConfig(*key, *value)
 }
 
-func runGetConfig() error {
+func runUnsetConfig(args []string) error {
+	// Defines the "unsetConfig" command to remove a configuration value by key.
+	cmd := newFlagSet("unsetConfig")
+	key := cmd.String("key", "", "Config key")
+
+	// Parses the arguments for the unsetConfig command.
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+
+	// Ensures the key is provided, returns an error otherwise.
+	if *key == "" {
+		return fmt.Errorf("key must be provided")
+	}
+
+	// Calls UnsetConfig from the config package to clear the key.
+	return config.UnsetConfig(*key)
+}
+
+func runGetConfig(args []string) error {
 	// Defines the "getConfig" command to retrieve a configuration value by key.
-	cmd := flag.NewFlagSet("getConfig", flag.ExitOnError)
+	cmd := newFlagSet("getConfig")
 	key := cmd.String("key", "", "Config key")
 
 	// Parses the arguments for the getConfig command.
-	if err := cmd.Parse(os.Args[2:]); err != nil {
+	if err := cmd.Parse(args); err != nil {
 		return err
 	}
 
@@ -90,90 +295,819 @@ func runGetConfig() error {
 	return nil
 }
 
-func runGetConfigPath() error {
+func runListConfig(args []string) error {
+	// Resolves every configuration key with its effective value and source.
+	if err := parseNoArgs("listConfig", args); err != nil {
+		return err
+	}
+	settings, err := config.Resolve()
+	if err != nil {
+		return err
+	}
+
+	// Prints the settings as aligned columns, masking secrets such as API keys.
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, setting := range settings {
+		source := "(not set)"
+		if setting.Set {
+			source = describeSource(setting.Value)
+		}
+		fmt.Fprintf(w, "%s=%s\t%s\n", setting.Key, displayValue(setting.Key, setting.Value.Value), source)
+	}
+	return w.Flush()
+}
+
+func runDoctor(args []string) error {
+	// Shows how every configuration key is resolved, including the values it overrides,
+	// and reports values that fail validation.
+	if err := parseNoArgs("doctor", args); err != nil {
+		return err
+	}
+	settings, err := config.Resolve()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Configuration sources, in increasing precedence:")
+	fmt.Println("  default < global file < profile < repo file < env < flag")
+	fmt.Printf("  global file: %s", config.GetConfigPath())
+	if method, err := config.EncryptionMethod(); err == nil && method != "" {
+		fmt.Printf(" (encrypted with %s)", method)
+	}
+	fmt.Println()
+	if repoPath := config.GetRepoConfigPath(); repoPath != "" {
+		fmt.Printf("  repo file:   %s\n", repoPath)
+	} else {
+		fmt.Println("  repo file:   (none)")
+	}
+	if profile, err := config.GetActiveProfile(); err != nil {
+		return err
+	} else if profile != nil {
+		fmt.Printf("  profile:     %s (%s)\n", profile.Name, profile.Reason)
+	} else {
+		fmt.Println("  profile:     (none)")
+	}
+	fmt.Println()
+
+	problems := 0
+	for _, setting := range settings {
+		if !setting.Set {
+			fmt.Printf("%s (not set)\n", setting.Key)
+			continue
+		}
+
+		fmt.Printf("%s=%s\n", setting.Key, displayValue(setting.Key, setting.Value.Value))
+		fmt.Printf("  from %s\n", describeSource(setting.Value))
+		for _, shadowed := range setting.Shadowed {
+			fmt.Printf("  overrides %s: %s\n", describeSource(shadowed), displayValue(setting.Key, shadowed.Value))
+		}
+
+		// Values from files and the environment are not validated when they are loaded.
+		key, _ := config.LookupKey(setting.Key)
+		if err := key.Validate(setting.Value.Value); err != nil {
+			fmt.Printf("  problem: %v\n", err)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d invalid configuration value(s)", problems)
+	}
+	return nil
+}
+
+func describeSource(value config.Value) string {
+	// Formats where a configuration value came from, e.g. "env AI_COMMIT_MODEL".
+	if value.Origin == "" {
+		return value.Source.String()
+	}
+	return value.Source.String() + " " + value.Origin
+}
+
+func displayValue(key, value string) string {
+	// Masks secrets and keeps multi-line values such as prompts on a single line.
+	if config.IsSecret(key) {
+		value = config.MaskSecret(value)
+	}
+	return strings.ReplaceAll(value, "\n", "\\n")
+}
+
+func setFlagOverrides(values map[string]string) error {
+	// Applies command-line flags as the highest-precedence configuration layer.
+	// Values are validated against the config schema; empty values are ignored.
+	for key, value := range values {
+		if err := config.SetFlag(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runEditConfig(args []string) error {
+	// Defines the "editConfig" command to edit a configuration file in $EDITOR.
+	cmd := newFlagSet("editConfig")
+	repo := cmd.Bool("repo", false, "Edit the per-repository configuration file instead of the global one")
+
+	// Parses the arguments for the editConfig command.
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+
+	// Resolves which configuration file to edit.
+	path := config.GetConfigPath()
+	if *repo {
+		var err error
+		if path, err = config.RepoConfigTarget(); err != nil {
+			return err
+		}
+	}
+
+	// Reads the current contents, starting from an empty object for a new file.
+	data, err := config.ReadConfigData(path)
+	if os.IsNotExist(err) {
+		data = []byte("{\n}\n")
+	} else if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Edits a temporary copy so the real file is only replaced with a valid config.
+	tmp, err := os.CreateTemp("", "ai-commit-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	tmp.Close()
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		if err := launchEditor(tmp.Name()); err != nil {
+			return fmt.Errorf("editor failed: %w", err)
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read edited file: %w", err)
+		}
+
+		// Saves the file only if it parses and all keys and values are valid.
+		err = config.WriteConfigFile(path, edited)
+		if err == nil {
+			fmt.Printf("Configuration saved to %s\n", path)
+			return nil
+		}
+		fmt.Printf("The edited configuration is invalid: %v\n", err)
+
+		fmt.Print("Do you want to edit it again? (y/n): ")
+		response, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(response)) != "y" {
+			return fmt.Errorf("configuration not saved")
+		}
+	}
+}
+
+func runEncryptConfig(args []string) error {
+	// Defines the "encryptConfig" command to encrypt the global configuration file at rest.
+	cmd := newFlagSet("encryptConfig")
+	method := cmd.String("method", config.MethodPassphrase, "Encryption method: passphrase or dpapi (Windows only)")
+
+	// Parses the arguments for the encryptConfig command.
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+
+	if err := config.EncryptConfig(*method); err != nil {
+		return err
+	}
+	fmt.Printf("Configuration file encrypted with %s: %s\n", *method, config.GetConfigPath())
+	return nil
+}
+
+func runDecryptConfig(args []string) error {
+	// Stores the global configuration file in plain text again.
+	if err := parseNoArgs("decryptConfig", args); err != nil {
+		return err
+	}
+	method, err := config.EncryptionMethod()
+	if err != nil {
+		return err
+	}
+	if method == "" {
+		return fmt.Errorf("configuration file is not encrypted")
+	}
+
+	if err := config.DecryptConfig(); err != nil {
+		return err
+	}
+	fmt.Printf("Configuration file decrypted: %s\n", config.GetConfigPath())
+	return nil
+}
+
+func promptPassphrase(confirm bool) (string, error) {
+	// Uses AI_COMMIT_PASSPHRASE when set, otherwise asks on the terminal without echoing.
+	if passphrase := os.Getenv(config.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("the config file is encrypted; set %s to decrypt it", config.PassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Config passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if string(again) != string(passphrase) {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
+
+func runGetConfigPath(args []string) error {
 	// Prints the path to the configuration file.
+	if err := parseNoArgs("getConfigPath", args); err != nil {
+		return err
+	}
 	fmt.Printf("Configuration file path: %s\n", config.GetConfigPath())
+
+	// Prints the path to the per-repository configuration file, if one applies.
+	if repoPath := config.GetRepoConfigPath(); repoPath != "" {
+		fmt.Printf("Repository configuration file path: %s\n", repoPath)
+	}
 	return nil
 }
 
-func runGenerate() error {
-	// Ensures that the current directory is a valid Git repository.
-	if err := git.AssertGitRepo(); err != nil {
+func runGenerate(args []string) error {
+	// Runs the "generate" command on the repository of the work directory.
+	return generate(git.NewRepo(), args)
+}
+
+func generate(gitRepo git.GitRepo, args []string) error {
+	// Runs the "generate" command on gitRepo, which a git.FakeRepo can stand in for.
+	// Defines the "generate" command and its flags.
+	cmd := newFlagSet("generate")
+	candidates := cmd.String("candidates", "", "Number of alternative messages to generate and choose from (overrides CANDIDATES)")
+	model := cmd.String("model", "", "Model used for generation, e.g. llama-3.3-70b-versatile (overrides MODEL)")
+	temperature := cmd.String("temperature", "", "Sampling temperature between 0 and 2 (overrides TEMPERATURE)")
+	maxTokens := cmd.String("max-tokens", "", "Maximum number of tokens to generate (overrides MAX_TOKENS)")
+	topP := cmd.String("top-p", "", "Nucleus sampling probability between 0 and 1 (overrides TOP_P)")
+	seed := cmd.String("seed", "", "Seed of the sampling, for repeatable messages where the provider supports it (overrides SEED)")
+	deterministic := cmd.Bool("deterministic", false, "Use temperature 0 and a fixed seed for repeatable messages (overrides DETERMINISTIC)")
+	style := addStyleFlag(cmd)
+	lang := cmd.String("lang", "", "Language of the message, e.g. Japanese or de (overrides COMMIT_LANGUAGE)")
+	base := cmd.String("base", "", "Include the changes of the branch compared to this branch, e.g. origin/main (overrides BASE_BRANCH)")
+	noCache := cmd.Bool("no-cache", false, "Generate a new message even if one was generated for the same changes recently")
+	offline := cmd.Bool("offline", false, "Write the message from the changed files without the AI, e.g. without network access")
+	stageAll := cmd.Bool("all", false, "Stage the changes of all tracked files first, like git commit -a, without asking")
+	cmd.BoolVar(stageAll, "a", false, "Stage the changes of all tracked files first (same as -all)")
+	addUntracked := cmd.Bool("add-untracked", false, "With -all, also stage new files that are not ignored")
+	amend := cmd.Bool("amend", false, "Regenerate the message of the last commit, including newly staged changes, and amend it")
+	split := cmd.Bool("split", false, "Propose several logical commits for the staged changes and create them after confirmation")
+	dryRun := cmd.Bool("dry-run", false, "Print only the generated message to stdout, without committing or asking anything")
+	cmd.BoolVar(dryRun, "print-only", false, "Print only the generated message to stdout (same as -dry-run)")
+	raw := cmd.Bool("raw", false, "Like -dry-run, but without colors, progress or warnings either, e.g. for a lazygit custom command")
+	fromStdin := cmd.Bool("stdin", false, "Describe the diff piped into stdin instead of the staged changes, printing the message like -dry-run")
+	copyToClipboard := cmd.Bool("copy", false, "Copy the final message to the clipboard, e.g. for a graphical Git client")
+	output := cmd.String("output", "", "Write the message to this file instead of committing, e.g. the message file given to a prepare-commit-msg hook")
+	porcelain := cmd.String("porcelain", "", "Talk to an editor plugin over stdin and stdout, one record per line: tsv or json")
+	jsonOutput := cmd.Bool("json", false, "Print the message, model, files and token usage as JSON, without committing or asking anything")
+	description := cmd.String("description", "", "Describe the purpose of an empty commit to generate its message from (with -allow-empty)")
+	var commitOpts git.CommitOptions
+	cmd.BoolVar(&commitOpts.GPGSign, "S", false, "GPG-sign the commit")
+	cmd.BoolVar(&commitOpts.GPGSign, "gpg-sign", false, "GPG-sign the commit (same as -S)")
+	cmd.BoolVar(&commitOpts.Signoff, "s", false, "Add a Signed-off-by trailer")
+	cmd.BoolVar(&commitOpts.Signoff, "signoff", false, "Add a Signed-off-by trailer (same as -s)")
+	cmd.BoolVar(&commitOpts.NoVerify, "no-verify", false, "Bypass the pre-commit and commit-msg hooks")
+	cmd.BoolVar(&commitOpts.NoPostRewrite, "no-post-rewrite", false, "Bypass the post-rewrite hook when amending")
+	cmd.BoolVar(&commitOpts.AllowEmpty, "allow-empty", false, "Allow a commit without changes, whose message is generated from -description")
+	push := cmd.Bool("push", false, "Push the branch after committing, setting up its upstream if it has none")
+	addYesFlags(cmd)
+	var trailerFlags, coAuthorFlags stringList
+	cmd.Var(&trailerFlags, "trailer", `Append a trailer such as "Ticket: PROJ-12" (repeatable)`)
+	cmd.Var(&coAuthorFlags, "co-author", `Credit a co-author given as "Name <email>" (repeatable)`)
+
+	// Parses the arguments for the generate command.
+	if err := cmd.Parse(args); err != nil {
 		return err
 	}
 
-	// Checks if there are files staged for commit.
-	if err := git.EnsureFilesAreStaged(); err != nil {
+	// Raw output is read by other programs, which show stderr as an error, so only the message and
+	// errors are printed, without colors or questions.
+	if *raw {
+		*dryRun = true
+		setRawOutput()
+	}
+
+	// JSON output is meant for tools, so it asks and commits as little as -dry-run does.
+	// A diff from stdin need not match the repository, so it is never committed either.
+	if *jsonOutput || *fromStdin || *output != "" {
+		*dryRun = true
+	}
+	if *fromStdin && (*amend || *split || commitOpts.AllowEmpty || *stageAll || *addUntracked) {
+		return fmt.Errorf("-stdin cannot be combined with -amend, -split, -allow-empty, -all or -add-untracked")
+	}
+	if *push && *dryRun {
+		return fmt.Errorf("-push cannot be combined with -dry-run, -json, -stdin or -output, which do not commit")
+	}
+	if *porcelain != "" {
+		if !slices.Contains(porcelainFormats, *porcelain) {
+			return fmt.Errorf("unknown format %q for -porcelain, expected one of: %s", *porcelain, strings.Join(porcelainFormats, ", "))
+		}
+		if *dryRun || *split || *push || commitOpts.AllowEmpty || *copyToClipboard {
+			return fmt.Errorf("-porcelain cannot be combined with -dry-run, -raw, -json, -stdin, -output, -split, -push, -allow-empty or -copy")
+		}
+	}
+	if *deterministic && *temperature != "" {
+		return fmt.Errorf("-deterministic cannot be combined with -temperature")
+	}
+	deterministicValue := ""
+	if *deterministic {
+		deterministicValue = "true"
+	}
+
+	// Applies the flags on top of the configuration.
+	if err := setFlagOverrides(map[string]string{
+		"CANDIDATES":      *candidates,
+		"MODEL":           *model,
+		"TEMPERATURE":     *temperature,
+		"MAX_TOKENS":      *maxTokens,
+		"TOP_P":           *topP,
+		"SEED":            *seed,
+		"DETERMINISTIC":   deterministicValue,
+		"COMMIT_STYLE":    *style,
+		"COMMIT_LANGUAGE": *lang,
+		"BASE_BRANCH":     *base,
+	}); err != nil {
 		return err
 	}
 
-	// Retrieves a list of staged files.
-	stagedFiles, err := git.GetStagedFiles()
+	// Ensures that the current directory is a valid Git repository, unless the diff is read from stdin.
+	if !*fromStdin {
+		if err := gitRepo.AssertRepo(); err != nil {
+			return err
+		}
+	}
+
+	// Collects the trailers appended to the generated message.
+	trailers, err := collectTrailers(gitRepo, trailerFlags, coAuthorFlags)
 	if err != nil {
 		return err
 	}
 
-	// Gets the diff (changes) for the staged files.
-	diff, err := git.GetDiff(stagedFiles)
+	// Stages the changes without asking, like "git commit -a".
+	if *stageAll || *addUntracked {
+		if err := gitRepo.StageAll(*addUntracked); err != nil {
+			return err
+		}
+	}
+
+	// Leaves reviewing the message to the editor plugin that started the command.
+	if *porcelain != "" {
+		commitOpts.Amend = *amend
+		return runPorcelain(gitRepo, *porcelain, service.Options{NoCache: *noCache, Offline: *offline}, commitOpts, trailers)
+	}
+
+	// Splits the staged changes into several commits instead of creating one.
+	if *split {
+		if *amend {
+			return fmt.Errorf("-split cannot be combined with -amend")
+		}
+		if commitOpts.AllowEmpty {
+			return fmt.Errorf("-split cannot be combined with -allow-empty")
+		}
+		if *dryRun {
+			return fmt.Errorf("-split cannot be combined with -dry-run or -json")
+		}
+		return runSplit(gitRepo, commitOpts, trailers, *push)
+	}
+
+	// Gets the diff to describe: the staged changes, the last commit plus the staged changes when amending,
+	// or the diff from stdin. Offers to stage changes only when questions may be asked.
+	var diff string
+	var files []string
+	if *fromStdin {
+		diff, files, err = readStdinDiff(gitRepo)
+	} else {
+		diff, err = getGenerateDiff(gitRepo, *amend, commitOpts.AllowEmpty, !assumeYes && !*dryRun)
+	}
 	if err != nil {
 		return err
 	}
 
-	// Returns an error if no changes are detected in the staged files.
-	if diff == "" {
-		return fmt.Errorf("no changes detected in the staged files")
+	// Shows what is about to be sent, so that accidentally staged files can be unstaged in time.
+	if diff != "" && !*dryRun && !quiet {
+		if err := showDiffPreview(gitRepo, *amend); err != nil {
+			return err
+		}
+	}
+
+	// Asks what an empty commit is for, since there is no diff to describe.
+	if diff == "" && *description == "" {
+		if *dryRun {
+			return fmt.Errorf("nothing to describe; pass -description to explain the empty commit")
+		}
+		if *description, err = promptDescription(); err != nil {
+			return err
+		}
+	}
+
+	// Collects the branch context for the prompt. A diff from stdin may come from anywhere,
+	// so the current branch and history say nothing about it.
+	var repo service.RepoContext
+	if !*fromStdin {
+		if repo, err = repoContext(gitRepo, *amend); err != nil {
+			return err
+		}
 	}
 
 	// Initializes the commit message generator.
-	generator, err := service.NewCommitMessageGenerator("")
+	generator, err := service.NewCommitMessageGenerator(service.Options{Context: repo, NoCache: *noCache, Offline: *offline})
 	if err != nil {
 		return err
 	}
 
-	// Generates the commit message based on the diff.
-	commitMessage, err := generator.GenerateCommitMessage(diff)
+	// Generates the commit message based on the diff, or on the description of an empty commit.
+	// Several candidates are offered to choose from if CANDIDATES asks for them, except with -dry-run.
+	count, _, err := config.GetInt("CANDIDATES")
+	if err != nil {
+		return err
+	}
+	var generated string
+	spinner := startSpinner(generator, "Generating the commit message")
+	preview := streamPreview(generator, spinner)
+	switch {
+	case diff == "":
+		generated, err = generator.GenerateFromDescription(*description)
+	case count > 1 && !*dryRun:
+		var messages []string
+		if messages, err = generator.GenerateCandidates(diff, count); err == nil {
+			spinner.Stop()
+			generated, err = pickCandidate(messages)
+			// Keeps the candidates that were not picked in the history.
+			recordHistory(generator, history.StatusRejected, slices.DeleteFunc(messages, func(message string) bool {
+				return message == generated
+			})...)
+		}
+	default:
+		generated, err = generator.GenerateCommitMessage(diff)
+	}
+	spinner.Stop()
+	preview.Clear()
 	if err != nil {
 		return err
 	}
+	// Says that the message was reused, so that the same message does not look like a failure to regenerate.
+	if at, ok := generator.CachedAt(); ok && !quiet {
+		fmt.Fprintf(os.Stderr, "Reusing the message generated for the same changes %s ago; run with -no-cache for a new one.\n",
+			time.Since(at).Round(time.Second))
+	}
+	// The branch and commit template belong to the repository, not to a diff from stdin,
+	// which only gets the trailers.
+	var commitMessage string
+	if *fromStdin {
+		if commitMessage, err = runHook(hooks.PostGenerate, generated); err == nil {
+			commitMessage, err = git.AddTrailers(strings.TrimSpace(commitMessage), trailers)
+		}
+	} else {
+		commitMessage, err = finishMessage(gitRepo, generated, trailers)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Copies the message right away when it is not reviewed; otherwise the reviewed message is copied below.
+	if *dryRun && *copyToClipboard {
+		if err := copyMessage(commitMessage); err != nil {
+			return err
+		}
+	}
 
-	// Displays the generated commit message.
-	fmt.Printf("Generated Commit Message:\n\n%s\n\n", commitMessage)
+	// Prints nothing but the message with -dry-run, so it can be piped into other tools, or a JSON object
+	// with -json for editor plugins and scripts. With -output, the result is written to the file instead,
+	// which happens only now so that a failure leaves the file alone.
+	if *dryRun {
+		var result bytes.Buffer
+		if *jsonOutput {
+			if !*fromStdin {
+				if files, err = describedFiles(gitRepo, *amend); err != nil {
+					return err
+				}
+			}
+			if err := writeGenerateJSON(&result, generator, commitMessage, files); err != nil {
+				return err
+			}
+		} else {
+			result.WriteString(commitMessage + "\n")
+		}
+		if *output == "" {
+			if _, err := os.Stdout.Write(result.Bytes()); err != nil {
+				return err
+			}
+		} else if err := os.WriteFile(*output, result.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write the message to %s: %w", *output, err)
+		}
+		recordHistory(generator, history.StatusPrinted, commitMessage)
+		return reportCommitMessage(commitMessage, false)
+	}
 
-	// Prompts the user for confirmation to proceed with the commit.
-	if confirmCommit() {
+	// Displays the generated commit message and lets the user accept, edit, regenerate or decline it.
+	// Every message replaced by a regenerated one is kept in the history as rejected.
+	// With --quiet, the message is only shown if there is a question about it.
+	if canAsk() || !quiet {
+		fmt.Printf("Generated Commit Message:\n\n%s\n\n", commitMessage)
+	}
+	shown := commitMessage
+	commitMessage, accepted, err := reviewMessage(commitMessage, func(hint string) (string, error) {
+		spinner := startSpinner(generator, "Generating another commit message")
+		preview := streamPreview(generator, spinner)
+		message, err := generator.Regenerate(generated, hint)
+		spinner.Stop()
+		preview.Clear()
+		if err != nil {
+			return "", err
+		}
+		recordHistory(generator, history.StatusRejected, shown)
+		generated = message
+		shown, err = finishMessage(gitRepo, message, trailers)
+		return shown, err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Copies the message whether or not it is committed, so it can be pasted elsewhere after declining.
+	if *copyToClipboard {
+		if err := copyMessage(commitMessage); err != nil {
+			return err
+		}
+		statusf("Commit message copied to the clipboard.")
+	}
+
+	// Proceeds with the commit if the message was accepted.
+	if accepted {
 		// Commits the changes with the generated commit message if confirmed.
-		if err := git.GitCommit(commitMessage); err != nil {
+		commitOpts.Amend = *amend
+		if err := gitRepo.Commit(commitMessage, commitOpts); err != nil {
+			// Keeps the message for another attempt, e.g. after a failing pre-commit hook.
+			recordHistory(generator, history.StatusRejected, commitMessage)
 			return err
 		}
-		fmt.Println("Changes committed successfully.")
+		recordHistory(generator, history.StatusAccepted, commitMessage)
+		afterCommit(commitMessage)
+		if err := reportCommitMessage(commitMessage, true); err != nil {
+			return err
+		}
+		if *amend {
+			statusf("Commit amended successfully.")
+		} else {
+			statusf("Changes committed successfully.")
+		}
+		if *push {
+			return pushCommits(gitRepo)
+		}
 	} else {
-		// Aborts the commit if the user declines.
-		fmt.Println("Commit aborted.")
+		// Aborts the commit if the user declines, keeping the message in the history.
+		recordHistory(generator, history.StatusRejected, commitMessage)
+		statusf("Commit aborted.")
+		return errAborted
 	}
 
 	return nil
 }
 
-func confirmCommit() bool {
-	// Prompts the user to confirm if they want to use the generated commit message.
-	reader := bufio.NewReader(os.Stdin)
+func finishMessage(gitRepo git.GitRepo, message string, trailers []string) (string, error) {
+	// Completes a generated message, once POST_GENERATE_HOOK had its say, with the issue reference of
+	// the branch, the commit template and trailers.
+	message, err := runHook(hooks.PostGenerate, message)
+	if err != nil {
+		return "", err
+	}
+	if message, err = addIssueReference(gitRepo, strings.TrimSpace(message)); err != nil {
+		return "", err
+	}
+	if message, err = applyCommitTemplate(gitRepo, message); err != nil {
+		return "", err
+	}
+	return git.AddTrailers(message, trailers)
+}
+
+func runHook(key, input string) (string, error) {
+	// Runs the hook configured under key in the work directory, see hooks.Run.
+	dir, err := git.WorkDir()
+	if err != nil {
+		return "", err
+	}
+	return hooks.Run(key, dir, input)
+}
+
+func afterCommit(message string) {
+	// Runs POST_COMMIT_HOOK with the message that was committed. Its failure is only reported, since
+	// the commit is made.
+	if _, err := runHook(hooks.PostCommit, message); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := notifyWebhooks(message); err != nil {
+		warnf("%v", err)
+	}
+}
+
+func notifyWebhooks(message string) error {
+	// Tells the webhooks of WEBHOOKS about the commit that was just made.
+	urls, _, err := config.GetList("WEBHOOKS")
+	if err != nil || len(urls) == 0 {
+		return err
+	}
+	sha, err := git.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+	branch, err := git.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+	repository := config.OriginRemote()
+	if repository == "" {
+		root, err := git.GetRepoRoot()
+		if err != nil {
+			return err
+		}
+		repository = filepath.Base(root)
+	}
+	return notify.Send(urls, notify.Commit{Repository: repository, Branch: branch, SHA: sha, Message: message})
+}
+
+func pushCommits(gitRepo git.GitRepo) error {
+	// Pushes the current branch after committing with -push, setting up its upstream if it has none.
+	if err := gitRepo.Push(); err != nil {
+		return err
+	}
+	statusf("Changes pushed successfully.")
+	return nil
+}
+
+func warnf(format string, args ...any) {
+	// Prints a warning to stderr, even with --quiet, but not with -raw.
+	if !rawOutput {
+		fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+	}
+}
+
+func setRawOutput() {
+	// Suppresses everything but the result and errors: progress, warnings, colors and questions.
+	quiet = true
+	rawOutput = true
+	assumeYes = true
+	git.SetQuiet(true)
+	groq.SetNotify(nil)
+	service.SetNotify(nil)
+	config.SetNotify(nil)
+	ui.DisableColor()
+}
+
+func startSpinner(generator *service.CommitMessageGenerator, label string) *ui.Spinner {
+	// Shows label with the provider and model on stderr until the returned spinner is stopped.
+	// Nothing is shown with --quiet.
+	if quiet {
+		return &ui.Spinner{}
+	}
+	return ui.StartSpinner(fmt.Sprintf("%s with %s/%s", label, generator.Provider(), generator.Model()))
+}
+
+func streamPreview(generator *service.CommitMessageGenerator, spinner *ui.Spinner) *ui.Preview {
+	// Shows the text of the next generation as it arrives, in place of the spinner, when STREAM is set and
+	// stderr is a terminal. The caller clears the preview before the final version is shown.
+	preview := &ui.Preview{}
+	if enabled, _, err := config.GetBool("STREAM"); err == nil && enabled && !quiet {
+		preview = ui.NewPreview()
+	}
+	if !preview.Enabled() {
+		generator.SetStream(nil)
+		return preview
+	}
+	generator.SetStream(func(delta string) {
+		spinner.Stop()
+		preview.Write(delta)
+	})
+	return preview
+}
+
+func addYesFlags(cmd *flag.FlagSet) {
+	// Adds the -yes and -y flags, which answer yes to every confirmation of the command.
+	cmd.BoolVar(&assumeYes, "yes", false, "Answer yes to every confirmation, e.g. in scripts")
+	cmd.BoolVar(&assumeYes, "y", false, "Answer yes to every confirmation (same as -yes)")
+}
+
+func addStyleFlag(cmd *flag.FlagSet) *string {
+	// Adds the -style flag, whose help lists the commit styles and presets of the service.
+	styles := append([]string{service.StyleAuto}, service.Styles()...)
+	list := strings.Join(styles[:len(styles)-1], ", ") + " or " + styles[len(styles)-1]
+	return cmd.String("style", "", "Commit message style or preset: "+list+" (overrides COMMIT_STYLE)")
+}
+
+func confirm(question string) bool {
+	// Asks a yes/no question until the user answers with y or n.
+	return choose(question, "yn") == 'y'
+}
+
+func choose(question, choices string) byte {
+	// Asks question until the user answers with one of the letters in choices, whose first letter
+	// means yes and second means no. Scripts, hooks and CI jobs, whose stdin is not a terminal,
+	// and -yes get yes without being asked, which --quiet does not report.
+	options := strings.Join(strings.Split(choices, ""), "/")
+	if !canAsk() {
+		if !quiet {
+			fmt.Printf("%s (%s): %c\n", question, options, choices[0])
+		}
+		return choices[0]
+	}
 	for {
-		fmt.Print("Do you want to use this commit message? (y/n): ")
-		response, err := reader.ReadString('\n')
+		fmt.Printf("%s (%s): ", question, options)
+		response, err := stdin.ReadString('\n')
 		if err != nil {
-			fmt.Println("Error reading input. Please try again.")
-			continue
+			// Treats the end of the input, e.g. Ctrl-D, as no.
+			fmt.Println()
+			return choices[1]
 		}
 		// Converts the response to lowercase and removes surrounding whitespace.
 		response = strings.TrimSpace(strings.ToLower(response))
-		// Checks for valid inputs (y/n) and returns a boolean value accordingly.
-		switch response {
-		case "y":
-			return true
-		case "n":
-			return false
-		default:
-			fmt.Println("Invalid input. Please enter 'y' for yes or 'n' for no.")
+		if len(response) == 1 && strings.Contains(choices, response) {
+			return response[0]
+		}
+		fmt.Printf("Invalid input. Please enter one of %s.\n", strings.Join(strings.Split(choices, ""), ", "))
+	}
+}
+
+func canAsk() bool {
+	// Reports whether questions are asked, rather than answered with yes because of -yes or because
+	// stdin is not a terminal.
+	return !assumeYes && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func promptDescription() (string, error) {
+	// Reads a short description of an empty commit, which the message is generated from.
+	description, err := promptLine(stdin, "Describe the purpose of the empty commit", "")
+	if err != nil || description == "" {
+		return "", fmt.Errorf("nothing to describe; pass -description to explain the empty commit")
+	}
+	return description, nil
+}
+
+func getGenerateDiff(gitRepo git.GitRepo, amend, allowEmpty, stage bool) (string, error) {
+	// Combines the last commit with the staged changes when amending; nothing needs to be staged.
+	// With allowEmpty, an empty diff is returned instead of an error. Unless stage is set,
+	// the user is not offered to stage changes when none are staged.
+	if amend {
+		files, err := gitRepo.AmendFiles()
+		if err != nil {
+			return "", err
+		}
+		diff, err := promptDiff(gitRepo, files, gitRepo.AmendDiff)
+		if err != nil {
+			return "", err
+		}
+		if diff == "" && !allowEmpty {
+			return "", fmt.Errorf("%w in the last commit or the staged files", git.ErrNoChanges)
+		}
+		return diff, nil
+	}
+
+	// Checks if there are files staged for commit, offering to stage changes.
+	if !allowEmpty && stage {
+		if err := gitRepo.EnsureStaged(); err != nil {
+			return "", err
 		}
 	}
+
+	// Retrieves a list of staged files.
+	stagedFiles, err := gitRepo.StagedFiles()
+	if err != nil {
+		return "", err
+	}
+
+	// Gets the diff (changes) for the staged files.
+	diff, err := promptDiff(gitRepo, stagedFiles, gitRepo.Diff)
+	if err != nil {
+		return "", err
+	}
+
+	// Returns an error if no changes are detected in the staged files.
+	if diff == "" && !allowEmpty {
+		return "", fmt.Errorf("%w in the staged files", git.ErrNoChanges)
+	}
+	return diff, nil
 }
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/mcp"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// mcpArgs are the arguments of the MCP tools; each tool reads those it declares.
+type mcpArgs struct {
+	Files   []string `json:"files"`
+	All     bool     `json:"all"`
+	Amend   bool     `json:"amend"`
+	Model   string   `json:"model"`
+	Message string   `json:"message"`
+}
+
+func runMCP(args []string) error {
+	// Defines the "mcp" command, which serves the tools below over the Model Context Protocol on
+	// stdin and stdout, so that AI agents and editors can stage, describe and commit changes.
+	cmd := newFlagSet("mcp")
+	addYesFlags(cmd)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 0 {
+		return fmt.Errorf("mcp takes no arguments")
+	}
+
+	// Keeps stdout for the protocol: progress is never printed, and warnings go to stderr.
+	quiet = true
+	git.SetQuiet(true)
+
+	gitRepo := git.NewRepo()
+	var server *mcp.Server
+	tools := []mcp.Tool{
+		{
+			Name:        "status",
+			Description: "Show the current branch and the files with staged, unstaged or untracked changes.",
+			ReadOnly:    true,
+			Call: func(json.RawMessage) (string, error) {
+				return mcpStatus(gitRepo)
+			},
+		},
+		{
+			Name:        "stage",
+			Description: "Stage changes for the next commit: the given files, or every change with all.",
+			Properties: map[string]mcp.Property{
+				"files": {Type: "array", Description: "Paths of the files to stage, relative to the top of the repository", Items: &mcp.Property{Type: "string", Description: "A path"}},
+				"all":   {Type: "boolean", Description: "Stage every change, new files included"},
+			},
+			Call: func(raw json.RawMessage) (string, error) {
+				args, err := parseMCPArgs(raw)
+				if err != nil {
+					return "", err
+				}
+				return mcpStage(gitRepo, args)
+			},
+		},
+		{
+			Name:        "diff",
+			Description: "Show the staged changes as they are described to the AI, i.e. without excluded files.",
+			Properties: map[string]mcp.Property{
+				"amend": {Type: "boolean", Description: "Show the changes of the last commit together with the staged ones"},
+			},
+			ReadOnly: true,
+			Call: func(raw json.RawMessage) (string, error) {
+				args, err := parseMCPArgs(raw)
+				if err != nil {
+					return "", err
+				}
+				return mcpDiff(gitRepo, args)
+			},
+		},
+		{
+			Name:        "generate",
+			Description: "Generate a commit message for the staged changes without committing.",
+			Properties: map[string]mcp.Property{
+				"amend": {Type: "boolean", Description: "Describe the last commit together with the staged changes"},
+				"model": {Type: "string", Description: "Model to use instead of the configured one"},
+			},
+			ReadOnly: true,
+			Call: func(raw json.RawMessage) (string, error) {
+				args, err := parseMCPArgs(raw)
+				if err != nil {
+					return "", err
+				}
+				generator, message, _, err := generateStaged(gitRepo, args.Amend, args.Model)
+				if err != nil {
+					return "", err
+				}
+				recordHistory(generator, history.StatusPrinted, message)
+				return message, nil
+			},
+		},
+		{
+			Name:        "commit",
+			Description: "Commit the staged changes with message, or with a generated message if none is given. The user is asked to confirm the message first.",
+			Properties: map[string]mcp.Property{
+				"message": {Type: "string", Description: "The commit message; generated if empty"},
+				"amend":   {Type: "boolean", Description: "Replace the last commit, adding the staged changes to it"},
+				"model":   {Type: "string", Description: "Model to generate the message with instead of the configured one"},
+			},
+			Destructive: true,
+			Call: func(raw json.RawMessage) (string, error) {
+				args, err := parseMCPArgs(raw)
+				if err != nil {
+					return "", err
+				}
+				return mcpCommit(gitRepo, server, args)
+			},
+		},
+	}
+
+	v, _, _ := buildVersion()
+	server = mcp.NewServer("ai-generate-commit", v, tools)
+	return server.Serve(os.Stdin, os.Stdout)
+}
+
+func parseMCPArgs(raw json.RawMessage) (mcpArgs, error) {
+	// Decodes the arguments of a tool call.
+	var args mcpArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return args, fmt.Errorf("invalid arguments: %w", err)
+	}
+	return args, nil
+}
+
+func mcpStatus(gitRepo git.GitRepo) (string, error) {
+	// Lists the branch and the changed files, with whether each is staged.
+	if err := gitRepo.AssertRepo(); err != nil {
+		return "", err
+	}
+	branch, err := gitRepo.CurrentBranch()
+	if err != nil {
+		return "", err
+	}
+	if branch == "" {
+		branch = "(detached HEAD)"
+	}
+	changed, err := gitRepo.ChangedFiles()
+	if err != nil {
+		return "", err
+	}
+	staged, err := gitRepo.StagedFiles()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Branch: %s\n", branch)
+	if len(changed) == 0 {
+		b.WriteString("No changes.\n")
+	}
+	for _, file := range changed {
+		fmt.Fprintf(&b, "%s: %s", file.Status, file.Path)
+		if file.OrigPath != "" {
+			fmt.Fprintf(&b, " (from %s)", file.OrigPath)
+		}
+		for _, s := range staged {
+			if s == file.Path {
+				b.WriteString(" [staged]")
+				break
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+func mcpStage(gitRepo git.GitRepo, args mcpArgs) (string, error) {
+	// Stages the files of args, or every change with All, and lists what is staged then.
+	if err := gitRepo.AssertRepo(); err != nil {
+		return "", err
+	}
+	switch {
+	case args.All:
+		if err := gitRepo.StageAll(true); err != nil {
+			return "", err
+		}
+	case len(args.Files) > 0:
+		if err := gitRepo.StageFiles(args.Files); err != nil {
+			return "", err
+		}
+	default:
+		return "", errors.New("give the files to stage, or all")
+	}
+	staged, err := gitRepo.StagedFiles()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Staged files:\n%s\n", strings.Join(staged, "\n")), nil
+}
+
+func mcpDiff(gitRepo git.GitRepo, args mcpArgs) (string, error) {
+	// Returns the diff a generated message would describe.
+	if err := gitRepo.AssertRepo(); err != nil {
+		return "", err
+	}
+	diff, err := getGenerateDiff(gitRepo, args.Amend, true, false)
+	if err != nil {
+		return "", err
+	}
+	if diff == "" {
+		return "Nothing is staged.", nil
+	}
+	return diff, nil
+}
+
+func mcpCommit(gitRepo git.GitRepo, server *mcp.Server, args mcpArgs) (string, error) {
+	// Commits with the message of args or a generated one, once the user confirmed it through the
+	// client. Clients that cannot ask are refused unless the server was started with -yes.
+	// Only generated messages are recorded in the history, with their generator.
+	message := strings.TrimSpace(args.Message)
+	var generator *service.CommitMessageGenerator
+	if message == "" {
+		var err error
+		if generator, message, _, err = generateStaged(gitRepo, args.Amend, args.Model); err != nil {
+			return "", err
+		}
+	} else if err := gitRepo.AssertRepo(); err != nil {
+		return "", err
+	}
+
+	if !assumeYes {
+		action := "Commit the staged changes"
+		if args.Amend {
+			action = "Amend the last commit"
+		}
+		err := server.Confirm(fmt.Sprintf("%s with this message?\n\n%s", action, message))
+		if errors.Is(err, mcp.ErrNoElicitation) {
+			return "", fmt.Errorf("%w, so nothing was committed; start the server with -yes to commit without confirmation", err)
+		}
+		if err != nil {
+			if generator != nil {
+				recordHistory(generator, history.StatusRejected, message)
+			}
+			return "", err
+		}
+	}
+
+	if err := gitRepo.Commit(message, git.CommitOptions{Amend: args.Amend}); err != nil {
+		return "", err
+	}
+	if generator != nil {
+		recordHistory(generator, history.StatusAccepted, message)
+	}
+	afterCommit(message)
+	subject, _, _ := strings.Cut(message, "\n")
+	return fmt.Sprintf("Committed: %s", subject), nil
+}
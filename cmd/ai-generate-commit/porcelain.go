@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// porcelainFormats are the formats of "generate -porcelain": tab-separated fields or JSON objects,
+// one record per line in both directions.
+var porcelainFormats = []string{"tsv", "json"}
+
+// porcelainEvent is a record written to stdout by "generate -porcelain".
+type porcelainEvent struct {
+	Type    string `json:"type"`              // "message", "committed" or "error"
+	ID      int    `json:"id,omitempty"`      // Number of the message, counting from 1
+	Message string `json:"message,omitempty"` // The finished commit message of a "message" event
+	SHA     string `json:"sha,omitempty"`     // The new commit of a "committed" event
+	Code    int    `json:"code,omitempty"`    // Exit code the error would end the command with
+	Error   string `json:"error,omitempty"`   // Description of the error of an "error" event
+}
+
+// porcelainRequest is a record read from stdin by "generate -porcelain".
+type porcelainRequest struct {
+	Command string `json:"command"` // "regenerate", "accept" or "abort"
+	Hint    string `json:"hint"`    // What the next message should do better, for "regenerate"
+	Message string `json:"message"` // The message as edited by the user, for "accept"; "" keeps the last one
+}
+
+var (
+	// tsvEscaper and tsvUnescaper keep a field of a tab-separated record on one line.
+	tsvEscaper   = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	tsvUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+)
+
+// porcelainSession is the state of "generate -porcelain" between the requests of the editor.
+type porcelainSession struct {
+	gitRepo   git.GitRepo
+	format    string
+	out       io.Writer
+	generator *service.CommitMessageGenerator
+	trailers  []string
+	generated string // The last message as the AI wrote it
+	message   string // The last message as sent to the editor
+	count     int    // Number of messages sent so far
+}
+
+func runPorcelain(gitRepo git.GitRepo, format string, options service.Options, commitOpts git.CommitOptions, trailers []string) error {
+	// Generates a message for the staged changes and then answers the requests of an editor plugin on
+	// stdin: regenerate it with a hint, commit it as edited, or give up. Every result is a record on
+	// stdout, so that plugins need not read the output meant for people.
+	quiet = true
+	git.SetQuiet(true)
+	s := &porcelainSession{gitRepo: gitRepo, format: format, out: os.Stdout, trailers: trailers}
+
+	diff, err := getGenerateDiff(gitRepo, commitOpts.Amend, false, false)
+	if err == nil {
+		options.Context, err = repoContext(gitRepo, commitOpts.Amend)
+	}
+	if err == nil {
+		s.generator, err = service.NewCommitMessageGenerator(options)
+	}
+	if err == nil {
+		s.generator.SetStream(nil)
+		err = s.send(s.generator.GenerateCommitMessage(diff))
+	}
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+
+	// Reads the requests until one ends the session; the end of the input gives up like "abort".
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		req, err := s.parse(scanner.Text())
+		if err != nil {
+			s.fail(err)
+			continue
+		}
+		switch req.Command {
+		case "regenerate":
+			recordHistory(s.generator, history.StatusRejected, s.message)
+			if err := s.send(s.generator.Regenerate(s.generated, req.Hint)); err != nil {
+				s.fail(err)
+			}
+		case "accept":
+			if message := strings.TrimSpace(req.Message); message != "" {
+				s.message = message
+			}
+			// A failing commit, e.g. because of a pre-commit hook, leaves the session open for another attempt.
+			if err := s.commit(commitOpts); err != nil {
+				s.fail(err)
+				continue
+			}
+			return nil
+		case "abort":
+			recordHistory(s.generator, history.StatusRejected, s.message)
+			return errAborted
+		default:
+			s.fail(fmt.Errorf("unknown command %q, expected regenerate, accept or abort", req.Command))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read the requests: %w", err)
+	}
+	recordHistory(s.generator, history.StatusRejected, s.message)
+	return errAborted
+}
+
+func (s *porcelainSession) send(generated string, err error) error {
+	// Completes a message the AI generated like generate does, and sends it to the editor.
+	if err != nil {
+		return err
+	}
+	message, err := finishMessage(s.gitRepo, generated, s.trailers)
+	if err != nil {
+		return err
+	}
+	s.generated, s.message = generated, message
+	s.count++
+	return s.write(porcelainEvent{Type: "message", ID: s.count, Message: message})
+}
+
+func (s *porcelainSession) commit(commitOpts git.CommitOptions) error {
+	// Commits with the last message and reports the new commit.
+	if err := s.gitRepo.Commit(s.message, commitOpts); err != nil {
+		return err
+	}
+	recordHistory(s.generator, history.StatusAccepted, s.message)
+	afterCommit(s.message)
+	sha, err := git.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+	return s.write(porcelainEvent{Type: "committed", SHA: sha})
+}
+
+func (s *porcelainSession) fail(err error) {
+	// Reports err to the editor. A failure to write it is not reported, since the editor is gone then.
+	s.write(porcelainEvent{Type: "error", Code: exitCode(err), Error: strings.TrimSpace(err.Error())})
+}
+
+func (s *porcelainSession) write(event porcelainEvent) error {
+	// Writes event as one line in the format of the session.
+	if s.format == "json" {
+		return json.NewEncoder(s.out).Encode(event)
+	}
+	fields := []string{event.Type}
+	switch event.Type {
+	case "message":
+		fields = append(fields, strconv.Itoa(event.ID), tsvEscaper.Replace(event.Message))
+	case "committed":
+		fields = append(fields, event.SHA)
+	case "error":
+		fields = append(fields, strconv.Itoa(event.Code), tsvEscaper.Replace(event.Error))
+	}
+	_, err := fmt.Fprintln(s.out, strings.Join(fields, "\t"))
+	return err
+}
+
+func (s *porcelainSession) parse(line string) (porcelainRequest, error) {
+	// Reads a request in the format of the session. The argument of a tab-separated request is the
+	// hint of "regenerate" or the message of "accept".
+	var req porcelainRequest
+	if s.format == "json" {
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			return req, fmt.Errorf("invalid request: %w", err)
+		}
+		return req, nil
+	}
+	command, argument, _ := strings.Cut(strings.TrimRight(line, "\r"), "\t")
+	req.Command = command
+	if command == "accept" {
+		req.Message = tsvUnescaper.Replace(argument)
+	} else {
+		req.Hint = tsvUnescaper.Replace(argument)
+	}
+	return req, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/ci"
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+func runPR(args []string) error {
+	// Defines the "pr" command, which describes the current branch as a pull request.
+	cmd := newFlagSet("pr")
+	base := cmd.String("base", "", "Branch the pull request targets (default: BASE_BRANCH, then origin's default branch)")
+	create := cmd.Bool("create", false, "Create the pull request with gh or glab after confirmation")
+	draft := cmd.Bool("draft", false, "Create the pull request as a draft")
+	update := cmd.Bool("update", false, "Write the description into the merge request of the pipeline, with --ci gitlab")
+	addYesFlags(cmd)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	var mr *ci.GitLabMergeRequest
+	var token string
+	if *update {
+		if *create {
+			return fmt.Errorf("-update cannot be combined with -create")
+		}
+		var err error
+		if mr, token, err = pipelineMergeRequest(); err != nil {
+			return err
+		}
+	}
+
+	gitRepo := git.NewRepo()
+	if err := gitRepo.AssertRepo(); err != nil {
+		return err
+	}
+	baseBranch, err := prBaseBranch(*base)
+	if err != nil {
+		return err
+	}
+
+	// Describes everything the branch changed since it diverged from the base branch.
+	files, err := git.GetBranchFiles(baseBranch)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("the current branch has no changes compared to %s", baseBranch)
+	}
+	diff, err := promptDiff(gitRepo, files, func(files []string) (string, error) {
+		return git.GetBranchDiff(baseBranch, files)
+	})
+	if err != nil {
+		return err
+	}
+	subjects, err := git.GetBranchSubjects(baseBranch)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repoContext(gitRepo, false)
+	if err != nil {
+		return err
+	}
+	generator, err := service.NewCommitMessageGenerator(service.Options{Context: repo})
+	if err != nil {
+		return err
+	}
+	spinner := startSpinner(generator, "Writing the pull request description")
+	preview := streamPreview(generator, spinner)
+	pr, err := generator.GeneratePullRequest(subjects, diff)
+	spinner.Stop()
+	preview.Clear()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n\n%s\n", pr.Title, pr.Body)
+	if err := reportPullRequest(pr, baseBranch); err != nil {
+		return err
+	}
+	if *update {
+		return updateMergeRequest(mr, token, pr)
+	}
+	if !*create {
+		return nil
+	}
+
+	fmt.Println()
+	if !confirm(fmt.Sprintf("Do you want to create this pull request against %s?", baseBranch)) {
+		statusf("Pull request not created.")
+		return errAborted
+	}
+	return createPullRequest(pr, baseBranch, *draft)
+}
+
+func prBaseBranch(flagValue string) (string, error) {
+	// Uses the -base flag, then the target of the pull request of the CI event, then BASE_BRANCH,
+	// then the default branch of origin.
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	base, err := ciBaseBranch()
+	if err != nil || base != "" {
+		return base, err
+	}
+	base, err = config.GetConfig("BASE_BRANCH")
+	if err != nil || base != "" {
+		return base, err
+	}
+	return git.GetDefaultBranch()
+}
+
+func createPullRequest(pr service.PullRequest, baseBranch string, draft bool) error {
+	// Creates the pull request with the GitLab CLI for GitLab remotes and the GitHub CLI otherwise.
+	// The CLIs may ask where to push the branch, so they are connected to the terminal.
+	target := strings.TrimPrefix(baseBranch, "origin/")
+	remote, _ := git.GetRemoteURL("origin")
+
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("glab"); err == nil && strings.Contains(remote, "gitlab") {
+		args := []string{"mr", "create", "--title", pr.Title, "--description", pr.Body, "--target-branch", target}
+		if draft {
+			args = append(args, "--draft")
+		}
+		cmd = exec.Command("glab", args...)
+	} else if _, err := exec.LookPath("gh"); err == nil {
+		args := []string{"pr", "create", "--title", pr.Title, "--body", pr.Body, "--base", target}
+		if draft {
+			args = append(args, "--draft")
+		}
+		cmd = exec.Command("gh", args...)
+	} else {
+		return fmt.Errorf("creating pull requests requires the GitHub CLI (gh) or the GitLab CLI (glab)")
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to create the pull request: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/ui"
+)
+
+func showDiffPreview(gitRepo git.GitRepo, amend bool) error {
+	// Shows the changes about to be described before they are sent to the AI, so that accidentally
+	// staged files are noticed: a summary by default, or the whole diff with DIFF_PREVIEW=full.
+	// Files whose diff is left out of the prompt are marked.
+	mode, err := config.GetConfig("DIFF_PREVIEW")
+	if err != nil || mode == "off" {
+		return err
+	}
+
+	getFiles, getDiff := gitRepo.StagedFiles, gitRepo.Diff
+	if amend {
+		getFiles, getDiff = gitRepo.AmendFiles, gitRepo.AmendDiff
+	}
+	files, err := getFiles()
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	diff, err := getDiff(files)
+	if err != nil {
+		return err
+	}
+	ignore, err := loadIgnore(gitRepo)
+	if err != nil {
+		return err
+	}
+	_, excluded := ignore.Filter(files)
+	omitted := map[string]bool{}
+	for _, file := range excluded {
+		omitted[file] = true
+	}
+
+	if mode == "full" {
+		fmt.Printf("%s\n\n", colorDiff(diff))
+	}
+
+	stats := git.DiffStats(diff)
+	width, added, removed := 0, 0, 0
+	for _, stat := range stats {
+		width = max(width, len([]rune(stat.Name)))
+		added += stat.Added
+		removed += stat.Removed
+	}
+	if amend {
+		fmt.Println(ui.Colorize(ui.Bold, "Changes to describe:"))
+	} else {
+		fmt.Println(ui.Colorize(ui.Bold, "Staged changes:"))
+	}
+	for _, stat := range stats {
+		counts := ui.Colorize(ui.Green, fmt.Sprintf("+%d", stat.Added)) + " " + ui.Colorize(ui.Red, fmt.Sprintf("-%d", stat.Removed))
+		if stat.Binary {
+			counts = "binary"
+		}
+		// Renames are listed as "old → new"; the new path decides whether the diff is sent.
+		_, path, found := strings.Cut(stat.Name, " → ")
+		if !found {
+			path = stat.Name
+		}
+		if omitted[path] {
+			counts += ui.Colorize(ui.Dim, " (diff not sent)")
+		}
+		fmt.Printf(" %s%s | %s\n", stat.Name, strings.Repeat(" ", width-len([]rune(stat.Name))), counts)
+	}
+	fmt.Printf(" %d file(s) changed, %s insertion(s), %s deletion(s)\n\n", len(stats),
+		ui.Colorize(ui.Green, fmt.Sprintf("%d", added)), ui.Colorize(ui.Red, fmt.Sprintf("%d", removed)))
+	return nil
+}
+
+func colorDiff(diff string) string {
+	// Colors the file headers, hunk headers and added and removed lines of a diff like git does.
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	inHunk := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHunk = false
+			lines[i] = ui.Colorize(ui.Bold, line)
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+			lines[i] = ui.Colorize(ui.Cyan, line)
+		case !inHunk:
+			lines[i] = ui.Colorize(ui.Bold, line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = ui.Colorize(ui.Green, line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = ui.Colorize(ui.Red, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// promptsActions are the actions of the "prompts" command.
+var promptsActions = []string{"list", "show", "edit"}
+
+func runPrompts(args []string) error {
+	// Lists the prompts, prints one, or edits one of the templates of the prompts directory.
+	cmd := newFlagSet("prompts")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	action := "list"
+	if cmd.NArg() > 0 {
+		action = cmd.Arg(0)
+	}
+	usage := fmt.Errorf("usage: ai-generate-commit prompts [list|show NAME|edit NAME]")
+
+	switch action {
+	case "list":
+		if cmd.NArg() > 1 {
+			return usage
+		}
+		return listPrompts()
+	case "show":
+		if cmd.NArg() != 2 {
+			return usage
+		}
+		text, _, err := service.ReadPrompt(cmd.Arg(1))
+		if err != nil {
+			return err
+		}
+		fmt.Print(text)
+		if !strings.HasSuffix(text, "\n") {
+			fmt.Println()
+		}
+		return nil
+	case "edit":
+		if cmd.NArg() != 2 {
+			return usage
+		}
+		return editPrompt(cmd.Arg(1))
+	}
+	return fmt.Errorf("unknown action %q, expected one of: %s", action, strings.Join(promptsActions, ", "))
+}
+
+func listPrompts() error {
+	// Lists the built-in prompts of the commit styles and the templates of the prompts directory,
+	// with the one selected with PROMPT_TEMPLATE.
+	names, err := service.PromptNames()
+	if err != nil {
+		return err
+	}
+	selected, err := config.GetConfig("PROMPT_TEMPLATE")
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, style := range service.Styles() {
+		source := "built-in"
+		if slices.Contains(names, style) {
+			source = "template, replaces the built-in prompt of the style"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", style, source)
+	}
+	for _, name := range names {
+		if _, ok := service.BuiltinPrompt(name); ok {
+			continue
+		}
+		source := "template"
+		if name == selected {
+			source = "template, selected with PROMPT_TEMPLATE"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, source)
+	}
+	tw.Flush()
+	fmt.Printf("\nTemplates are read from %s.\n", service.PromptsDir())
+	if selected != "" && !slices.Contains(names, selected) {
+		fmt.Printf("Warning: PROMPT_TEMPLATE selects %q, which does not exist.\n", selected)
+	}
+	return nil
+}
+
+func editPrompt(name string) error {
+	// Edits the template name in $EDITOR, starting from the built-in prompt of the style of that name,
+	// or that of the default style for a new prompt. The template is only saved if it renders.
+	path, err := service.PromptPath(name)
+	if err != nil {
+		return err
+	}
+	text, _, err := service.ReadPrompt(name)
+	if err != nil {
+		text, _ = service.BuiltinPrompt(service.StyleDefault)
+	}
+
+	// Edits a temporary copy so the real file is only replaced with a valid template.
+	tmp, err := os.CreateTemp("", "ai-commit-prompt-*.tmpl")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(text); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	tmp.Close()
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		if err := launchEditor(tmp.Name()); err != nil {
+			return fmt.Errorf("editor failed: %w", err)
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read edited file: %w", err)
+		}
+
+		err = service.CheckPrompt(string(edited))
+		if err == nil {
+			if err := os.MkdirAll(service.PromptsDir(), 0o755); err != nil {
+				return fmt.Errorf("failed to create the prompts directory: %w", err)
+			}
+			if err := os.WriteFile(path, edited, 0o644); err != nil {
+				return fmt.Errorf("failed to save prompt: %w", err)
+			}
+			fmt.Printf("Prompt saved to %s\n", path)
+			if _, builtin := service.BuiltinPrompt(name); !builtin {
+				fmt.Printf("Run 'ai-generate-commit setConfig -key PROMPT_TEMPLATE -value %s' to use it.\n", name)
+			}
+			return nil
+		}
+		fmt.Printf("The edited prompt is invalid: %v\n", err)
+
+		fmt.Print("Do you want to edit it again? (y/n): ")
+		response, _ := reader.ReadString('\n')
+		if strings.TrimSpace(strings.ToLower(response)) != "y" {
+			return fmt.Errorf("prompt not saved")
+		}
+	}
+}
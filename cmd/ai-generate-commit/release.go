@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+func runReleaseNotes(args []string) error {
+	// Defines the "releaseNotes" command, which summarizes the changes merged between two tags.
+	cmd := newFlagSet("releaseNotes")
+	audience := cmd.String("audience", "", "Readers of the notes: user or developer (overrides RELEASE_AUDIENCE)")
+	output := cmd.String("output", "", "Write the notes to this file instead of printing them")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 2 {
+		return fmt.Errorf("usage: ai-generate-commit releaseNotes [-audience AUDIENCE] [-output FILE] [FROM [TO]]")
+	}
+	if err := setFlagOverrides(map[string]string{"RELEASE_AUDIENCE": *audience}); err != nil {
+		return err
+	}
+
+	if err := git.AssertGitRepo(); err != nil {
+		return err
+	}
+
+	// Covers the changes from the previous tag up to HEAD unless a range is given.
+	to := "HEAD"
+	if cmd.NArg() == 2 {
+		to = cmd.Arg(1)
+	}
+	from := cmd.Arg(0)
+	if from == "" {
+		var err error
+		if from, err = git.GetPreviousTag(to); err != nil {
+			return fmt.Errorf("%w; pass the previous release explicitly", err)
+		}
+	}
+
+	changes, err := git.GetMergedChanges(from, to)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("no changes between %s and %s", from, to)
+	}
+
+	generator, err := service.NewCommitMessageGenerator(service.Options{})
+	if err != nil {
+		return err
+	}
+	version := to
+	if to == "HEAD" {
+		version = "Unreleased"
+	}
+	spinner := startSpinner(generator, "Writing the release notes")
+	notes, err := generator.GenerateReleaseNotes(version, changes, "")
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Println(notes)
+		return nil
+	}
+	if err := os.WriteFile(*output, []byte(notes+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write release notes: %w", err)
+	}
+	statusf("Release notes for %d change(s) written to %s", len(changes), *output)
+	return nil
+}
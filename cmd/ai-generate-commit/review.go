@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/atotto/clipboard"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+	"github.com/hambosto/ai-generate-commit/internal/ui"
+)
+
+// editHelp is appended to a message opened in the editor, and removed again with the other comments.
+const editHelp = `
+# Edit the commit message. Lines starting with '#' are ignored,
+# and an empty message aborts the commit.
+`
+
+func reviewMessage(message string, regenerate func(hint string) (string, error)) (string, bool, error) {
+	// Asks whether to use the message, letting the user change it first with "e", replace it with "r",
+	// which calls regenerate with an optional hint, or copy it to the clipboard with "c".
+	// Returns the message to commit and whether it was accepted.
+	for {
+		switch choose("Do you want to use this commit message?", "ynerc") {
+		case 'y':
+			return message, true, nil
+		case 'n':
+			return message, false, nil
+		case 'e':
+			edited, err := changeMessage(message)
+			if err != nil {
+				return "", false, err
+			}
+			if edited == "" {
+				fmt.Println("The edited message is empty.")
+				return message, false, nil
+			}
+			message = edited
+			fmt.Printf("\nEdited Commit Message:\n\n%s\n\n", message)
+		case 'r':
+			hint, err := promptLine(stdin, "Hint for the new message (optional)", "")
+			if err != nil {
+				return "", false, err
+			}
+			regenerated, err := regenerate(hint)
+			if errors.Is(err, service.ErrOffline) {
+				fmt.Println(`The message was written offline, so there is no other one; change it with "e" instead.`)
+				continue
+			}
+			if err != nil {
+				return "", false, err
+			}
+			message = regenerated
+			fmt.Printf("\nRegenerated Commit Message:\n\n%s\n\n", message)
+		case 'c':
+			// A missing clipboard is not worth losing the message over, so the question is asked again.
+			if err := copyMessage(message); err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println("Commit message copied to the clipboard.")
+			}
+		}
+	}
+}
+
+func copyMessage(message string) error {
+	// Puts the message on the system clipboard, e.g. for pasting into a graphical Git client.
+	// On Linux this needs xclip, xsel or wl-clipboard.
+	if err := clipboard.WriteAll(message); err != nil {
+		return fmt.Errorf("failed to copy to the clipboard: %w", err)
+	}
+	return nil
+}
+
+func changeMessage(message string) (string, error) {
+	// Edits a message without a body right in the terminal, which is quicker for small tweaks to the
+	// subject. Messages with a body are opened in the editor, as is a subject on Ctrl-X or without a terminal.
+	if strings.Contains(strings.TrimSpace(message), "\n") || !ui.IsInteractive() {
+		return editMessage(message)
+	}
+	fmt.Println("Edit the message (enter: done, esc: cancel, ctrl-x: open the editor):")
+	edited, err := ui.EditLine("> ", strings.TrimSpace(message))
+	switch {
+	case errors.Is(err, ui.ErrOpenEditor):
+		return editMessage(edited)
+	case errors.Is(err, ui.ErrCanceled):
+		return message, nil
+	case err != nil:
+		return "", err
+	}
+	return strings.TrimSpace(edited), nil
+}
+
+func editMessage(message string) (string, error) {
+	// Opens the message in the user's editor and returns the edited message without comments.
+	// The file is named COMMIT_EDITMSG like git's, so editors highlight it as a commit message.
+	dir, err := os.MkdirTemp("", "ai-commit-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "COMMIT_EDITMSG")
+	if err := os.WriteFile(path, []byte(message+"\n"+editHelp), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := launchEditor(path); err != nil {
+		return "", fmt.Errorf("editor failed: %w", err)
+	}
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %w", err)
+	}
+	return git.StripComments(string(edited))
+}
+
+func pickCandidate(candidates []string) (string, error) {
+	// Shows the candidate messages as a numbered menu and returns the one the user picks.
+	// Without a terminal or with -yes, the first candidate is used, silently with --quiet.
+	if len(candidates) == 1 || (quiet && !canAsk()) {
+		return candidates[0], nil
+	}
+	fmt.Println("Candidate Commit Messages:")
+	for i, candidate := range candidates {
+		fmt.Printf("\n%d) %s\n", i+1, strings.ReplaceAll(candidate, "\n", "\n   "))
+	}
+	fmt.Println()
+
+	label := fmt.Sprintf("Choose a message (1-%d)", len(candidates))
+	if !canAsk() {
+		fmt.Printf("%s: 1\n\n", label)
+		return candidates[0], nil
+	}
+	for {
+		answer, err := promptLine(stdin, label, "1")
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(candidates) {
+			fmt.Println()
+			return candidates[n-1], nil
+		}
+		fmt.Printf("Invalid choice. Please enter a number from 1 to %d.\n", len(candidates))
+	}
+}
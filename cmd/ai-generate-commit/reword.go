@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+func runReword(args []string) error {
+	// Defines the "reword" command, which regenerates the message of an existing commit
+	// or of every commit in a range such as "main..HEAD".
+	cmd := newFlagSet("reword")
+	style := addStyleFlag(cmd)
+	lang := cmd.String("lang", "", "Language of the message, e.g. Japanese or de (overrides COMMIT_LANGUAGE)")
+	// Older commits are recreated without running any hooks, so these only matter when rewording HEAD.
+	commitOpts := git.CommitOptions{Amend: true, Only: true}
+	cmd.BoolVar(&commitOpts.NoVerify, "no-verify", false, "Bypass the pre-commit and commit-msg hooks when rewording HEAD")
+	cmd.BoolVar(&commitOpts.NoPostRewrite, "no-post-rewrite", false, "Bypass the post-rewrite hook when rewording HEAD")
+	addYesFlags(cmd)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() != 1 {
+		return fmt.Errorf("usage: ai-generate-commit reword [-style STYLE] [-lang LANGUAGE] [-no-verify] [-no-post-rewrite] [-yes] COMMIT|FROM..TO")
+	}
+	if err := setFlagOverrides(map[string]string{"COMMIT_STYLE": *style, "COMMIT_LANGUAGE": *lang}); err != nil {
+		return err
+	}
+
+	if err := git.AssertGitRepo(); err != nil {
+		return err
+	}
+	if strings.Contains(cmd.Arg(0), "..") {
+		return rewordRange(cmd.Arg(0))
+	}
+	return rewordCommit(cmd.Arg(0), commitOpts)
+}
+
+func rewordCommit(rev string, commitOpts git.CommitOptions) error {
+	// Regenerates the message of a single commit and applies it after confirmation.
+	sha, err := git.ResolveCommit(rev)
+	if err != nil {
+		return err
+	}
+	head, err := git.ResolveCommit("HEAD")
+	if err != nil {
+		return err
+	}
+	if err := git.CheckReword(sha); err != nil {
+		return err
+	}
+
+	generator, err := newRewordGenerator()
+	if err != nil {
+		return err
+	}
+	spinner := startSpinner(generator, "Generating the commit message")
+	newMessage, err := generateForCommit(generator, sha)
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+	oldMessage, err := git.GetCommitMessage(sha)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Current message of %s:\n\n%s\n\n", sha[:12], oldMessage)
+	fmt.Printf("Generated message:\n\n%s\n\n", newMessage)
+
+	// Warns before rewriting history, especially history that others may already have.
+	if sha != head {
+		fmt.Println("Warning: this rewrites the commit and every commit after it on the current branch.")
+		if err := warnSigned(sha); err != nil {
+			return err
+		}
+	}
+	if git.IsPushed(sha) {
+		fmt.Println("Warning: the commit has already been pushed; publishing the change requires a force push.")
+	}
+	if !confirm("Do you want to reword the commit?") {
+		statusf("Reword aborted.")
+		return errAborted
+	}
+
+	// Amends HEAD directly; older commits are recreated on top of their unchanged trees.
+	if sha == head {
+		if err := git.GitCommit(newMessage, commitOpts); err != nil {
+			return err
+		}
+		statusf("Commit reworded successfully.")
+		return nil
+	}
+	if _, err := git.RewordCommits(map[string]string{sha: newMessage}); err != nil {
+		return err
+	}
+	statusf("Commit reworded successfully. The previous history is available as ORIG_HEAD.")
+	return nil
+}
+
+func rewordRange(revRange string) error {
+	// Regenerates the message of every commit in the range, shows the old and new subjects side by side,
+	// and rewrites them all at once after confirmation.
+	shas, err := git.GetCommitRange(revRange)
+	if err != nil {
+		return err
+	}
+	if len(shas) == 0 {
+		return fmt.Errorf("no commits in %s", revRange)
+	}
+	if err := git.CheckReword(shas[0]); err != nil {
+		return err
+	}
+
+	generator, err := newRewordGenerator()
+	if err != nil {
+		return err
+	}
+
+	messages := make(map[string]string, len(shas))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMIT\tCURRENT\tGENERATED")
+	pushed := false
+	for i, sha := range shas {
+		spinner := startSpinner(generator, fmt.Sprintf("Generating message %d of %d", i+1, len(shas)))
+		newMessage, err := generateForCommit(generator, sha)
+		spinner.Stop()
+		if err != nil {
+			return fmt.Errorf("failed to generate the message of %s: %w", sha[:12], err)
+		}
+		oldMessage, err := git.GetCommitMessage(sha)
+		if err != nil {
+			return err
+		}
+		messages[sha] = newMessage
+		pushed = pushed || git.IsPushed(sha)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", sha[:12], truncate(subject(oldMessage), 50), subject(newMessage))
+	}
+	fmt.Println()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+
+	fmt.Printf("Warning: this rewrites %d commit(s) and every commit after them on the current branch.\n", len(shas))
+	if err := warnSigned(shas[0]); err != nil {
+		return err
+	}
+	if pushed {
+		fmt.Println("Warning: some of the commits have already been pushed; publishing the change requires a force push.")
+	}
+	if !confirm("Do you want to reword these commits?") {
+		statusf("Reword aborted.")
+		return errAborted
+	}
+
+	if _, err := git.RewordCommits(messages); err != nil {
+		return err
+	}
+	statusf("Reworded %d commit(s). The previous history is available as ORIG_HEAD.", len(shas))
+	return nil
+}
+
+func warnSigned(sha string) error {
+	// Warns when rewriting history from the commit drops the signatures of signed commits,
+	// which only happens when commit.gpgsign is not set to sign the new commits.
+	if git.ResignsCommits() {
+		return nil
+	}
+	signed, err := git.SignedCommits(sha)
+	if err != nil {
+		return err
+	}
+	if len(signed) > 0 {
+		fmt.Printf("Warning: %d of the rewritten commits are signed and will lose their signatures; set commit.gpgsign to sign them again.\n", len(signed))
+	}
+	return nil
+}
+
+func newRewordGenerator() (*service.CommitMessageGenerator, error) {
+	// Creates a generator with the repository context of the current branch.
+	repo, err := repoContext(git.NewRepo(), false)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewCommitMessageGenerator(service.Options{Context: repo})
+}
+
+func generateForCommit(generator *service.CommitMessageGenerator, sha string) (string, error) {
+	// Generates a message from the changes the commit made.
+	files, err := git.GetCommitFiles(sha)
+	if err != nil {
+		return "", err
+	}
+	diff, err := promptDiff(git.NewRepo(), files, func(files []string) (string, error) {
+		return git.GetCommitDiff(sha, files)
+	})
+	if err != nil {
+		return "", err
+	}
+	if diff == "" {
+		return "", fmt.Errorf("commit %s has no changes to describe", sha[:12])
+	}
+	return generator.GenerateCommitMessage(diff)
+}
+
+func subject(message string) string {
+	// Returns the first line of a commit message.
+	first, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return first
+}
+
+func truncate(s string, width int) string {
+	// Shortens s to at most width characters, marking the cut with an ellipsis.
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-3]) + "..."
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// maxRequestBytes bounds the body of a request to the server, which carries at most a diff.
+const maxRequestBytes = 32 << 20
+
+// serveRequest is the body of the generate requests of the server. Every field is optional,
+// except Diff for /v1/generate.
+type serveRequest struct {
+	Diff  string `json:"diff"`  // The diff to describe, for /v1/generate
+	Dir   string `json:"dir"`   // Repository to work in instead of the one the server was started in
+	Amend bool   `json:"amend"` // Describe the last commit plus the staged changes, for /v1/generate-staged
+	Model string `json:"model"` // Model to use instead of MODEL
+}
+
+// server answers the requests of editor integrations. It handles one request at a time, since the
+// repository it works in is global to the process.
+type server struct {
+	mu  sync.Mutex
+	dir string // Repository the server was started in
+}
+
+func runServe(args []string) error {
+	// Defines the "serve" command, which answers the JSON API below on a local port or Unix socket,
+	// so that editor plugins can reuse one process instead of running the tool for every request.
+	cmd := newFlagSet("serve")
+	addr := cmd.String("addr", "127.0.0.1:7431", "Loopback address to listen on; port 0 picks a free port, which is printed")
+	socket := cmd.String("socket", "", "Listen on this Unix socket instead of -addr")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 0 {
+		return fmt.Errorf("serve takes no arguments")
+	}
+
+	listener, err := serveListener(*addr, *socket)
+	if err != nil {
+		return err
+	}
+	dir, err := git.WorkDir()
+	if err != nil {
+		return err
+	}
+	s := &server{dir: dir}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", s.handleHealth)
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("POST /v1/generate", s.handleGenerate)
+	mux.HandleFunc("POST /v1/generate-staged", s.handleGenerateStaged)
+	httpServer := &http.Server{Handler: s.guard(mux), ReadHeaderTimeout: 10 * time.Second}
+
+	// Serves until interrupted, then finishes the request in progress.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+
+	if *socket != "" {
+		statusf("Listening on unix:%s", *socket)
+	} else {
+		statusf("Listening on http://%s", listener.Addr())
+	}
+	if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func serveListener(addr, socket string) (net.Listener, error) {
+	// Listens on socket, replacing the one of a server that did not shut down, or else on addr,
+	// which must be a loopback address since the API has no authentication.
+	if socket != "" {
+		if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(socket)
+		}
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+		}
+		return listener, os.Chmod(socket, 0o600)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -addr %q: %w", addr, err)
+	}
+	if !isLoopback(host) {
+		return nil, fmt.Errorf("-addr must be a loopback address such as 127.0.0.1:7431, not %s", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return listener, nil
+}
+
+func isLoopback(host string) bool {
+	// Reports whether host names the local machine.
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *server) guard(next http.Handler) http.Handler {
+	// Turns away requests from web pages, which browsers mark with an Origin header, and requests for
+	// other hosts, which a DNS rebinding attack would send. Serves the rest one at a time, and logs them.
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if r.Header.Get("Origin") != "" || (host != "" && !isLoopback(host)) {
+			writeServeError(w, http.StatusForbidden, errors.New("requests from browsers and other hosts are not accepted"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		logging.Info("served request", "method", r.Method, "path", r.URL.Path, "elapsed", time.Since(start).Round(time.Millisecond))
+	})
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	// Tells that the server is up, and its version.
+	v, _, _ := buildVersion()
+	writeServeJSON(w, map[string]string{"status": "ok", "version": v})
+}
+
+func (s *server) handleModels(w http.ResponseWriter, r *http.Request) {
+	// Lists the models of the configured provider.
+	client, _, err := provider.New()
+	if err == nil {
+		var models []string
+		if models, err = client.ListModels(); err == nil {
+			writeServeJSON(w, map[string][]string{"models": models})
+			return
+		}
+	}
+	writeServeError(w, serveStatus(err), err)
+}
+
+func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	// Generates a message for the diff in the request, like "generate -stdin -json".
+	req, gitRepo, ok := s.readRequest(w, r)
+	if !ok {
+		return
+	}
+	diff, files, err := givenDiff(gitRepo, req.Diff)
+	if err != nil {
+		writeServeError(w, serveStatus(err), err)
+		return
+	}
+	generator, err := service.NewCommitMessageGenerator(service.Options{Model: req.Model})
+	if err != nil {
+		writeServeError(w, serveStatus(err), err)
+		return
+	}
+	generator.SetStream(nil)
+	message, err := generator.GenerateCommitMessage(diff)
+	if err != nil {
+		writeServeError(w, serveStatus(err), err)
+		return
+	}
+	writeServeMessage(w, generator, message, files)
+}
+
+func (s *server) handleGenerateStaged(w http.ResponseWriter, r *http.Request) {
+	// Generates a message for the staged changes of the repository, like "generate -json".
+	req, gitRepo, ok := s.readRequest(w, r)
+	if !ok {
+		return
+	}
+	generator, message, files, err := generateStaged(gitRepo, req.Amend, req.Model)
+	if err != nil {
+		writeServeError(w, serveStatus(err), err)
+		return
+	}
+	writeServeMessage(w, generator, message, files)
+}
+
+func (s *server) readRequest(w http.ResponseWriter, r *http.Request) (serveRequest, git.GitRepo, bool) {
+	// Decodes the request, which may be empty, and switches to the repository it asks for.
+	var req serveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeServeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return req, nil, false
+	}
+	dir := req.Dir
+	if dir == "" {
+		dir = s.dir
+	}
+	if err := git.SetWorkDir(dir); err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return req, nil, false
+	}
+	return req, git.NewRepo(), true
+}
+
+func generateStaged(gitRepo git.GitRepo, amend bool, model string) (*service.CommitMessageGenerator, string, []string, error) {
+	// Generates a message for the staged changes without asking anything, with the branch context, issue
+	// reference, commit template and trailers of generate, for the servers of editors and agents. Also
+	// returns the generator and the files the message describes.
+	if err := gitRepo.AssertRepo(); err != nil {
+		return nil, "", nil, err
+	}
+	diff, err := getGenerateDiff(gitRepo, amend, false, false)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	files, err := describedFiles(gitRepo, amend)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	repo, err := repoContext(gitRepo, amend)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	generator, err := service.NewCommitMessageGenerator(service.Options{Model: model, Context: repo})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	generator.SetStream(nil)
+	message, err := generator.GenerateCommitMessage(diff)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	trailers, err := collectTrailers(gitRepo, nil, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if message, err = finishMessage(gitRepo, message, trailers); err != nil {
+		return nil, "", nil, err
+	}
+	return generator, message, files, nil
+}
+
+func writeServeMessage(w http.ResponseWriter, generator *service.CommitMessageGenerator, message string, files []string) {
+	// Writes a generated message in the format of "generate -json", and records it in the history.
+	var result bytes.Buffer
+	if err := writeGenerateJSON(&result, generator, message, files); err != nil {
+		writeServeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	recordHistory(generator, history.StatusPrinted, message)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result.Bytes())
+}
+
+func serveStatus(err error) int {
+	// Returns the HTTP status of an error, following the exit codes of the command.
+	switch {
+	case errors.Is(err, git.ErrNotGitRepo), errors.Is(err, errNoDiff):
+		return http.StatusBadRequest
+	case errors.Is(err, git.ErrNoChanges), errors.Is(err, git.ErrNothingStaged), errors.Is(err, git.ErrNoCommits):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, groq.ErrRequest), errors.Is(err, provider.ErrNoAPIKey):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func writeServeJSON(w http.ResponseWriter, value any) {
+	// Writes value as the JSON body of a successful response.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeServeError(w http.ResponseWriter, status int, err error) {
+	// Writes err as {"error": "..."} with status.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": strings.TrimSpace(err.Error())})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+func runSplit(gitRepo git.GitRepo, commitOpts git.CommitOptions, trailers []string, push bool) error {
+	// Asks the AI to group the staged files into logical commits, generates a message for each group,
+	// and creates the commits one after another once the plan is confirmed. With push, they are pushed afterwards.
+	if err := gitRepo.EnsureStaged(); err != nil {
+		return err
+	}
+	files, err := git.GetStagedPaths()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%w in the staged files", git.ErrNoChanges)
+	}
+
+	diff, err := promptDiff(gitRepo, files, gitRepo.Diff)
+	if err != nil {
+		return err
+	}
+	repo, err := repoContext(gitRepo, false)
+	if err != nil {
+		return err
+	}
+	generator, err := service.NewCommitMessageGenerator(service.Options{Context: repo})
+	if err != nil {
+		return err
+	}
+
+	spinner := startSpinner(generator, "Planning commits")
+	groups, err := generator.PlanSplit(files, diff)
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+
+	// Generates each message from the diff of its own files, so it follows the configured style.
+	messages := make([]string, len(groups))
+	for i, group := range groups {
+		groupDiff, err := promptDiff(gitRepo, group.Files, gitRepo.Diff)
+		if err != nil {
+			return err
+		}
+		spinner := startSpinner(generator, fmt.Sprintf("Generating message %d of %d", i+1, len(groups)))
+		messages[i], err = generator.GenerateCommitMessage(groupDiff)
+		spinner.Stop()
+		if err != nil {
+			return fmt.Errorf("failed to generate the message of commit %d: %w", i+1, err)
+		}
+		if messages[i], err = finishMessage(gitRepo, messages[i], trailers); err != nil {
+			return err
+		}
+	}
+
+	// Shows the plan, unless --quiet is given and there is no question about it.
+	if canAsk() || !quiet {
+		fmt.Printf("\nProposed %d commit(s):\n", len(groups))
+		for i, group := range groups {
+			fmt.Printf("\nCommit %d: %s\n", i+1, group.Summary)
+			for _, file := range group.Files {
+				fmt.Printf("  %s\n", file)
+			}
+			fmt.Printf("\n  %s\n", strings.ReplaceAll(messages[i], "\n", "\n  "))
+		}
+		fmt.Println()
+	}
+
+	if !confirm("Do you want to create these commits?") {
+		statusf("Commits aborted.")
+		return errAborted
+	}
+	if err := commitGroups(groups, messages, commitOpts); err != nil {
+		return err
+	}
+	if push {
+		return pushCommits(gitRepo)
+	}
+	return nil
+}
+
+func commitGroups(groups []service.CommitGroup, messages []string, commitOpts git.CommitOptions) error {
+	// Commits each group by rebuilding the index from HEAD plus the group's staged versions.
+	// The staged state is saved as a tree first, so partially staged files keep their staged content
+	// and the working tree is never touched.
+	staged, err := git.WriteIndexTree()
+	if err != nil {
+		return err
+	}
+
+	for i, group := range groups {
+		if err := commitGroup(staged, group, messages[i], commitOpts); err != nil {
+			// Stages the changes that were not committed again before giving up.
+			if restoreErr := git.ReadTree(staged); restoreErr != nil {
+				return fmt.Errorf("commit %d of %d failed: %w (restoring the index also failed: %v)", i+1, len(groups), err, restoreErr)
+			}
+			return fmt.Errorf("commit %d of %d failed; the remaining changes are staged: %w", i+1, len(groups), err)
+		}
+		afterCommit(messages[i])
+		statusf("Created commit %d of %d.", i+1, len(groups))
+	}
+
+	// Leaves the index exactly as it was staged, which matches HEAD once every group is committed.
+	return git.ReadTree(staged)
+}
+
+func commitGroup(staged string, group service.CommitGroup, message string, commitOpts git.CommitOptions) error {
+	// Starts from the last commit, or an empty index in a new repository.
+	base := ""
+	if git.HasCommits() {
+		base = "HEAD"
+	}
+	if err := git.ReadTree(base); err != nil {
+		return err
+	}
+	if err := git.StageFromTree(staged, group.Files); err != nil {
+		return err
+	}
+	return git.GitCommit(message, commitOpts)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+	"github.com/hambosto/ai-generate-commit/internal/telemetry"
+)
+
+// telemetryActions are the arguments of the "telemetry" command.
+var telemetryActions = []string{"enable", "disable", "status"}
+
+// runUsage collects what the telemetry event of the current run reports, see countMessages.
+var runUsage telemetry.Event
+
+func runTelemetry(args []string) error {
+	// Turns the usage statistics on or off, or shows whether they are sent and what is queued.
+	cmd := newFlagSet("telemetry")
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 1 {
+		return fmt.Errorf("usage: ai-generate-commit telemetry [%s]", strings.Join(telemetryActions, "|"))
+	}
+	action := "status"
+	if cmd.NArg() == 1 {
+		action = cmd.Arg(0)
+	}
+
+	switch action {
+	case "enable":
+		if err := config.SetConfig("TELEMETRY", "true"); err != nil {
+			return err
+		}
+		fmt.Println("Thank you! Each run now records the command, the provider, how long the AI took and how many messages")
+		fmt.Println("were accepted; never diffs, messages, names or paths. Run 'ai-generate-commit telemetry' to see them.")
+		if telemetry.DoNotTrack() {
+			fmt.Println("DO_NOT_TRACK is set in your environment, so nothing is recorded while it is.")
+		}
+		return nil
+	case "disable":
+		if err := config.SetConfig("TELEMETRY", "false"); err != nil {
+			return err
+		}
+		// Drops the events that were not sent yet, since the user no longer wants them sent.
+		return telemetry.Clear()
+	case "status":
+		return printTelemetryStatus()
+	}
+	return fmt.Errorf("unknown action %q, expected one of: %s", action, strings.Join(telemetryActions, ", "))
+}
+
+func printTelemetryStatus() error {
+	// Shows whether usage statistics are recorded, where they are sent, and a summary of the queued
+	// events, so that what would be sent can be checked.
+	enabled, err := telemetry.Enabled()
+	if err != nil {
+		return err
+	}
+	switch {
+	case telemetry.DoNotTrack():
+		fmt.Println("Telemetry: disabled by DO_NOT_TRACK")
+	case enabled:
+		fmt.Println("Telemetry: enabled")
+	default:
+		fmt.Println("Telemetry: disabled (run 'ai-generate-commit telemetry enable' to help prioritize features)")
+	}
+	endpoint, err := telemetry.Endpoint()
+	if err != nil {
+		return err
+	}
+	if endpoint == "" {
+		endpoint = "(none; events stay on this computer)"
+	}
+	fmt.Printf("Endpoint: %s\n", endpoint)
+
+	events, err := telemetry.Pending()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Queued events: %d in %s\n", len(events), telemetry.Path())
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Summarizes the queue the way the statistics are used: by command, and by accepted messages.
+	commands := map[string]int{}
+	var names []string
+	var accepted, rejected int
+	for _, event := range events {
+		if commands[event.Command] == 0 {
+			names = append(names, event.Command)
+		}
+		commands[event.Command]++
+		accepted += event.Accepted
+		rejected += event.Rejected
+	}
+	for _, name := range names {
+		fmt.Printf("  %-14s %d run(s)\n", name, commands[name])
+	}
+	if accepted+rejected > 0 {
+		fmt.Printf("Accepted messages: %d of %d (%.0f%%)\n", accepted, accepted+rejected, 100*float64(accepted)/float64(accepted+rejected))
+	}
+	return nil
+}
+
+func countMessages(generator *service.CommitMessageGenerator, status history.Status, n int) {
+	// Counts n messages of the run by what became of them, and notes the provider and how long the AI took.
+	runUsage.Provider = generator.Provider()
+	runUsage.LatencyMS = generator.Elapsed().Milliseconds()
+	switch status {
+	case history.StatusAccepted:
+		runUsage.Accepted += n
+	case history.StatusRejected:
+		runUsage.Rejected += n
+	case history.StatusPrinted:
+		runUsage.Printed += n
+	}
+}
+
+func recordTelemetry(name string, err error) {
+	// Records the run of the command name, which ended with err, if TELEMETRY is enabled. Failures are
+	// only logged, since the statistics are never worth bothering the user about.
+	event := runUsage
+	event.Time = time.Now()
+	event.Version, _, _ = buildVersion()
+	event.OS, event.Arch = runtime.GOOS, runtime.GOARCH
+	event.Command = name
+	if err != nil {
+		event.ExitCode = exitCode(err)
+	}
+	if err := telemetry.Record(event); err != nil {
+		logging.Debug("failed to record usage statistics", "error", err)
+	}
+}
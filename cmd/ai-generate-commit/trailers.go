@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+)
+
+// stringList is a flag that can be given several times, collecting every value.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func collectTrailers(gitRepo git.GitRepo, trailerFlags, coAuthorFlags []string) ([]string, error) {
+	// Combines the configured trailers and co-authors, the co-authors of the commit template,
+	// and the ones given as flags, in that order.
+	for _, trailer := range trailerFlags {
+		if !git.ValidTrailer(trailer) {
+			return nil, fmt.Errorf("invalid trailer %q, expected \"Token: value\"", trailer)
+		}
+	}
+
+	trailers, _, err := config.GetList("TRAILERS")
+	if err != nil {
+		return nil, err
+	}
+	coAuthors, _, err := config.GetList("CO_AUTHORS")
+	if err != nil {
+		return nil, err
+	}
+
+	detect, _, err := config.GetBool("DETECT_CO_AUTHORS")
+	if err != nil {
+		return nil, err
+	}
+	if detect {
+		paired, err := gitRepo.TemplateCoAuthors()
+		if err != nil {
+			return nil, err
+		}
+		coAuthors = append(coAuthors, paired...)
+	}
+
+	coAuthors = append(coAuthors, coAuthorFlags...)
+	for _, coAuthor := range coAuthors {
+		trailers = append(trailers, git.CoAuthorTrailer+": "+coAuthor)
+	}
+	return append(trailers, trailerFlags...), nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/history"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+	"github.com/hambosto/ai-generate-commit/internal/tui"
+	"github.com/hambosto/ai-generate-commit/internal/ui"
+)
+
+func runTUI(args []string) error {
+	// Defines the "tui" command, which shows the staged files, their diffs and the generated message
+	// full-screen, with keys to regenerate, edit, split and commit.
+	cmd := newFlagSet("tui")
+	model := cmd.String("model", "", "Model used for generation, e.g. llama-3.3-70b-versatile (overrides MODEL)")
+	style := addStyleFlag(cmd)
+	lang := cmd.String("lang", "", "Language of the message, e.g. Japanese or de (overrides COMMIT_LANGUAGE)")
+	noCache := cmd.Bool("no-cache", false, "Generate a new message even if one was generated for the same changes recently")
+	stageAll := cmd.Bool("all", false, "Stage the changes of all tracked files first, like git commit -a, without asking")
+	cmd.BoolVar(stageAll, "a", false, "Stage the changes of all tracked files first (same as -all)")
+	addUntracked := cmd.Bool("add-untracked", false, "With -all, also stage new files that are not ignored")
+	var commitOpts git.CommitOptions
+	cmd.BoolVar(&commitOpts.GPGSign, "S", false, "GPG-sign the commit")
+	cmd.BoolVar(&commitOpts.Signoff, "s", false, "Add a Signed-off-by trailer")
+	cmd.BoolVar(&commitOpts.NoVerify, "no-verify", false, "Bypass the pre-commit and commit-msg hooks")
+	push := cmd.Bool("push", false, "Push the branch after committing, setting up its upstream if it has none")
+	var trailerFlags, coAuthorFlags stringList
+	cmd.Var(&trailerFlags, "trailer", `Append a trailer such as "Ticket: PROJ-12" (repeatable)`)
+	cmd.Var(&coAuthorFlags, "co-author", `Credit a co-author given as "Name <email>" (repeatable)`)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if err := setFlagOverrides(map[string]string{"MODEL": *model, "COMMIT_STYLE": *style, "COMMIT_LANGUAGE": *lang}); err != nil {
+		return err
+	}
+
+	gitRepo := git.NewRepo()
+	if err := gitRepo.AssertRepo(); err != nil {
+		return err
+	}
+	if !ui.IsInteractive() {
+		return fmt.Errorf("the tui command needs a terminal; use generate in scripts")
+	}
+	trailers, err := collectTrailers(gitRepo, trailerFlags, coAuthorFlags)
+	if err != nil {
+		return err
+	}
+
+	// Stages the changes without asking with -all, or offers to stage changes before taking over the
+	// screen, since the TUI only shows staged files.
+	if *stageAll || *addUntracked {
+		if err := gitRepo.StageAll(*addUntracked); err != nil {
+			return err
+		}
+	}
+	diff, err := getGenerateDiff(gitRepo, false, false, true)
+	if err != nil {
+		return err
+	}
+	files, err := gitRepo.StagedFiles()
+	if err != nil {
+		return err
+	}
+
+	repo, err := repoContext(gitRepo, false)
+	if err != nil {
+		return err
+	}
+	generator, err := service.NewCommitMessageGenerator(service.Options{Context: repo, NoCache: *noCache})
+	if err != nil {
+		return err
+	}
+
+	// Keeps the raw generated message, which regeneration starts from, apart from the finished one shown.
+	// The messages replaced by regeneration are saved in the history once the screen is released.
+	// Only one generation runs at a time, so the callbacks need no locking.
+	var generated, shown string
+	var replaced []string
+	finish := func(message string, err error) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		if shown != "" {
+			replaced = append(replaced, shown)
+		}
+		generated = message
+		shown, err = finishMessage(gitRepo, message, trailers)
+		return shown, err
+	}
+	result, err := tui.Run(tui.Options{
+		Files: files,
+		Diff: func(file string) (string, error) {
+			return gitRepo.Diff([]string{file})
+		},
+		Generate: func() (string, error) {
+			return finish(generator.GenerateCommitMessage(diff))
+		},
+		Regenerate: func(hint string) (string, error) {
+			if generated == "" {
+				return finish(generator.GenerateCommitMessage(diff))
+			}
+			return finish(generator.Regenerate(generated, hint))
+		},
+		Edit: editMessage,
+	})
+	if err != nil {
+		return err
+	}
+	recordHistory(generator, history.StatusRejected, replaced...)
+
+	switch result.Action {
+	case tui.ActionCommit:
+		if err := gitRepo.Commit(result.Message, commitOpts); err != nil {
+			recordHistory(generator, history.StatusRejected, result.Message)
+			return err
+		}
+		recordHistory(generator, history.StatusAccepted, result.Message)
+		afterCommit(result.Message)
+		statusf("Changes committed successfully.")
+		if *push {
+			return pushCommits(gitRepo)
+		}
+	case tui.ActionSplit:
+		recordHistory(generator, history.StatusRejected, result.Message)
+		return runSplit(gitRepo, commitOpts, trailers, *push)
+	default:
+		recordHistory(generator, history.StatusRejected, result.Message)
+		statusf("Commit aborted.")
+		return errAborted
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+)
+
+func runUndo(args []string) error {
+	// Defines the "undo" command, which takes back the latest commit created by the tool and stages
+	// its changes again, so that the message can be regenerated or the changes fixed.
+	cmd := newFlagSet("undo")
+	addYesFlags(cmd)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() > 0 {
+		return fmt.Errorf("undo takes no arguments")
+	}
+
+	if err := git.AssertGitRepo(); err != nil {
+		return err
+	}
+	// Only a commit HEAD still points to can be undone, so nothing made since is lost.
+	commit, err := git.LastToolCommit()
+	if err != nil {
+		return err
+	}
+
+	if commit.Amend {
+		fmt.Printf("The last commit amended by ai-generate-commit is %s %q.\n", commit.Hash, commit.Subject)
+		fmt.Println("Undoing it restores the commit as it was before, and the changes added by the amend stay staged.")
+	} else {
+		fmt.Printf("The last commit created by ai-generate-commit is %s %q.\n", commit.Hash, commit.Subject)
+		fmt.Println("Undoing it removes the commit and stages its changes again; the working tree is not touched.")
+	}
+	if !confirm("Do you want to undo it?") {
+		statusf("Undo aborted.")
+		return errAborted
+	}
+	if err := git.UndoCommit(commit); err != nil {
+		return err
+	}
+	statusf("Undid %s; the changes are staged.", commit.Hash)
+	return nil
+}
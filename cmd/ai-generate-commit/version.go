@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, injected by release builds with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.date=2024-01-02T15:04:05Z".
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+func buildVersion() (string, string, string) {
+	// Returns the version, commit and build date. Builds without ldflags fall back to what the Go
+	// toolchain records: the module version for "go install ...@v1.2.3", and the commit of a checkout.
+	v, c, d := version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		settings := map[string]string{}
+		for _, setting := range info.Settings {
+			settings[setting.Key] = setting.Value
+		}
+		if c == "" && settings["vcs.revision"] != "" {
+			c = settings["vcs.revision"]
+			if settings["vcs.modified"] == "true" {
+				c += "-dirty"
+			}
+		}
+		if d == "" {
+			d = settings["vcs.time"]
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return v, c, d
+}
+
+func runVersion(args []string) error {
+	// Prints the version and build metadata, e.g. for bug reports.
+	if err := parseNoArgs("version", args); err != nil {
+		return err
+	}
+	v, c, d := buildVersion()
+	fmt.Printf("ai-generate-commit %s\n", v)
+	fmt.Printf("  commit:     %s\n", c)
+	fmt.Printf("  built:      %s\n", d)
+	fmt.Printf("  go version: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
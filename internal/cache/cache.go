@@ -0,0 +1,119 @@
+// Package cache keeps generated messages on disk for a while, so that asking again for a message
+// for the same prompt, e.g. after an aborted commit, does not call the API again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// dirName is the name of the cache directory within the user's cache directory.
+const dirName = "ai-generate-commit"
+
+// entry is a cached message, stored as a JSON file named after its key.
+type entry struct {
+	Time    time.Time `json:"time"`    // When the message was generated
+	Message string    `json:"message"` // The generated message
+}
+
+// Dir returns the directory of the cache: ai-generate-commit in the user's cache directory, such as
+// ~/.cache on Linux, or next to the global configuration file where there is none.
+func Dir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(filepath.Dir(config.GetConfigPath()), ".ai-commit-cache")
+	}
+	return filepath.Join(dir, dirName)
+}
+
+// Key returns the key of a message generated from parts, such as the model and the prompt.
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the message cached under key and when it was generated, unless it is older than ttl.
+// An entry that cannot be read is treated as missing, and recorded as a warning.
+func Get(key string, ttl time.Duration) (string, time.Time, bool) {
+	data, err := os.ReadFile(path(key))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logging.Warn("ignoring unreadable cache entry", "error", err)
+		}
+		return "", time.Time{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		logging.Warn("ignoring corrupt cache entry", "path", path(key), "error", err)
+		return "", time.Time{}, false
+	}
+	if time.Since(e.Time) > ttl || e.Message == "" {
+		return "", time.Time{}, false
+	}
+	return e.Message, e.Time, true
+}
+
+// Put caches message under key, replacing the message cached under it before, and removes the
+// messages older than ttl.
+func Put(key, message string, ttl time.Duration) error {
+	data, err := json.Marshal(entry{Time: time.Now(), Message: message})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	if err := os.MkdirAll(Dir(), 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// Writes to a temporary file first, so that a concurrent Get never reads half an entry.
+	tmp, err := os.CreateTemp(Dir(), "entry-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return prune(ttl)
+}
+
+// prune removes the entries older than ttl, judged by the time their file was written.
+func prune(ttl time.Duration) error {
+	files, err := os.ReadDir(Dir())
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	var errs []error
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || time.Since(info.ModTime()) <= ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(Dir(), file.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// path returns the path of the file of the entry cached under key.
+func path(key string) string {
+	return filepath.Join(Dir(), key+".json")
+}
@@ -0,0 +1,130 @@
+// Package ci reports the results of the tool to continuous integration systems, so that pipelines can
+// use generated messages and descriptions in later steps.
+package ci
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// GitHub is the name of GitHub Actions, as given with --ci.
+const GitHub = "github"
+
+// ErrNotGitHubActions is returned when the environment of GitHub Actions is missing.
+var ErrNotGitHubActions = errors.New("not running in GitHub Actions")
+
+// GitHubEvent is the part of the payload of the event that triggered a workflow the tool uses.
+type GitHubEvent struct {
+	Name        string             `json:"-"`            // Name of the event, e.g. "push" or "pull_request"
+	PullRequest *GitHubPullRequest `json:"pull_request"` // The pull request of pull_request events, nil for others
+	Before      string             `json:"before"`       // Commit the branch pointed to before a push
+	After       string             `json:"after"`        // Commit the branch points to after a push
+}
+
+// GitHubPullRequest is a pull request of a GitHub event.
+type GitHubPullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Base   struct {
+		Ref string `json:"ref"` // Branch the pull request targets, e.g. "main"
+		SHA string `json:"sha"`
+	} `json:"base"`
+	Head struct {
+		Ref string `json:"ref"` // Branch of the pull request
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+// ReadGitHubEvent reads the payload of the event that triggered the workflow, from the file named by
+// GITHUB_EVENT_PATH.
+func ReadGitHubEvent() (*GitHubEvent, error) {
+	path := os.Getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return nil, fmt.Errorf("%w: GITHUB_EVENT_PATH is not set", ErrNotGitHubActions)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the event payload: %w", err)
+	}
+	var event GitHubEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("invalid event payload %s: %w", path, err)
+	}
+	event.Name = os.Getenv("GITHUB_EVENT_NAME")
+	return &event, nil
+}
+
+// SetGitHubOutputs sets the outputs of the step, which later steps read as steps.<id>.outputs.<name>.
+// Values may span several lines.
+func SetGitHubOutputs(outputs map[string]string) error {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(outputs)) {
+		value := outputs[name]
+		delimiter, err := outputDelimiter(value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+	}
+	return appendEnvFile("GITHUB_OUTPUT", b.String())
+}
+
+// AddGitHubSummary appends markdown to the summary of the job shown on the page of the workflow run.
+func AddGitHubSummary(markdown string) error {
+	return appendEnvFile("GITHUB_STEP_SUMMARY", markdown)
+}
+
+// GitHubError returns the workflow command annotating the run with an error, to be printed on stdout.
+func GitHubError(message string) string {
+	escaper := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	return "::error::" + escaper.Replace(message)
+}
+
+// CodeBlock returns text as a fenced markdown code block, with a fence longer than any in text.
+func CodeBlock(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence + "\n"
+}
+
+// outputDelimiter returns a random delimiter of a multi-line output that does not occur in value.
+func outputDelimiter(value string) (string, error) {
+	for {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to create an output delimiter: %w", err)
+		}
+		delimiter := "ghadelimiter_" + hex.EncodeToString(b)
+		if !strings.Contains(value, delimiter) {
+			return delimiter, nil
+		}
+	}
+}
+
+// appendEnvFile appends text to the file named by the environment variable name, one of the files
+// GitHub Actions reads after each step.
+func appendEnvFile(name, text string) error {
+	path := os.Getenv(name)
+	if path == "" {
+		return fmt.Errorf("%w: %s is not set", ErrNotGitHubActions, name)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return f.Close()
+}
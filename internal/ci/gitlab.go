@@ -0,0 +1,121 @@
+package ci
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GitLab is the name of GitLab CI/CD, as given with --ci.
+const GitLab = "gitlab"
+
+const (
+	// gitlabTimeout bounds each request to the GitLab API.
+	gitlabTimeout = 30 * time.Second
+	// sectionStart and sectionEnd enclose the generated part of a merge request description, so that
+	// later pipelines replace it and leave what people wrote around it alone.
+	sectionStart = "<!-- ai-generate-commit -->"
+	sectionEnd   = "<!-- /ai-generate-commit -->"
+)
+
+// ErrNoMergeRequest is returned outside of a merge request pipeline of GitLab CI/CD.
+var ErrNoMergeRequest = errors.New("not running in a merge request pipeline of GitLab CI/CD")
+
+// GitLabMergeRequest is the merge request of a pipeline, read from the predefined CI_* variables.
+type GitLabMergeRequest struct {
+	APIURL    string // Root of the REST API, e.g. "https://gitlab.com/api/v4"
+	ProjectID string // ID of the project the merge request belongs to
+	IID       string // Number of the merge request within the project
+}
+
+// GitLabMergeRequestFromEnv returns the merge request of the running pipeline.
+func GitLabMergeRequestFromEnv() (*GitLabMergeRequest, error) {
+	mr := &GitLabMergeRequest{
+		APIURL:    os.Getenv("CI_API_V4_URL"),
+		ProjectID: os.Getenv("CI_PROJECT_ID"),
+		IID:       os.Getenv("CI_MERGE_REQUEST_IID"),
+	}
+	if mr.APIURL == "" || mr.ProjectID == "" || mr.IID == "" {
+		return nil, fmt.Errorf("%w: CI_API_V4_URL, CI_PROJECT_ID or CI_MERGE_REQUEST_IID is not set", ErrNoMergeRequest)
+	}
+	return mr, nil
+}
+
+// Description returns the current description of the merge request.
+func (mr *GitLabMergeRequest) Description(token string) (string, error) {
+	var result struct {
+		Description string `json:"description"`
+	}
+	if err := mr.call(http.MethodGet, token, nil, &result); err != nil {
+		return "", err
+	}
+	return result.Description, nil
+}
+
+// UpdateDescription replaces the description of the merge request with description.
+func (mr *GitLabMergeRequest) UpdateDescription(token, description string) error {
+	return mr.call(http.MethodPut, token, map[string]string{"description": description}, nil)
+}
+
+// call sends a request about the merge request to the API, with body as JSON unless it is nil, and
+// decodes the response into result unless it is nil.
+func (mr *GitLabMergeRequest) call(method, token string, body, result any) error {
+	endpoint := fmt.Sprintf("%s/projects/%s/merge_requests/%s",
+		strings.TrimRight(mr.APIURL, "/"), url.PathEscape(mr.ProjectID), url.PathEscape(mr.IID))
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create the GitLab request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: gitlabTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach GitLab: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GitLab answered %s for merge request !%s: %s", resp.Status, mr.IID, strings.TrimSpace(string(detail)))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("invalid response from GitLab: %w", err)
+	}
+	return nil
+}
+
+// ReplaceSection returns description with its generated section replaced by section, or with section
+// appended if it has none, so that the text around it is kept.
+func ReplaceSection(description, section string) string {
+	block := sectionStart + "\n" + strings.TrimSpace(section) + "\n" + sectionEnd
+	start := strings.Index(description, sectionStart)
+	end := strings.Index(description, sectionEnd)
+	if start >= 0 && end > start {
+		return description[:start] + block + description[end+len(sectionEnd):]
+	}
+	if strings.TrimSpace(description) == "" {
+		return block
+	}
+	return strings.TrimRight(description, "\n") + "\n\n" + block
+}
@@ -5,118 +5,287 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
 )
 
 // Config holds the configuration for the application.
-// It contains fields for storing the GROQ API key and commit prompt.
-type Config struct {
-	GROQAPIKey   string `json:"GROQ_APIKEY"`
-	CommitPrompt string `json:"COMMIT_PROMPT"`
-}
+// It maps configuration key names to their values; the valid keys are described by the key registry.
+type Config map[string]string
 
 const (
 	// configFileName is the name of the configuration file.
 	configFileName = ".ai-commit"
+	// repoConfigFileName is the name of the per-repository configuration file.
+	repoConfigFileName = ".ai-commit.json"
 )
 
-var (
-	// configFilePath holds the full path to the configuration file.
-	configFilePath string
-	// ErrUnknownKey is the error returned when an unknown configuration key is requested.
-	ErrUnknownKey = fmt.Errorf("unknown config key")
-)
+// ErrUnknownKey is the error returned when an unknown configuration key is requested.
+var ErrUnknownKey = fmt.Errorf("unknown config key")
 
-func init() {
-	// Initializes the configuration file path based on the user's home directory.
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		panic(fmt.Sprintf("Failed to get user home directory: %v", err))
-	}
-	configFilePath = filepath.Join(homeDir, configFileName)
+// loadGlobalConfig loads the configuration from the global file only.
+// If the file does not exist, it returns an empty Config.
+func loadGlobalConfig() (Config, error) {
+	return readConfigFile(GetConfigPath())
 }
 
-// loadConfig loads the configuration from the file.
-// If the file does not exist, it returns an empty Config struct.
-func loadConfig() (Config, error) {
-	data, err := os.ReadFile(configFilePath)
+// readConfigFile reads the configuration file at path.
+// A missing file is not an error and results in an empty Config.
+func readConfigFile(path string) (Config, error) {
+	data, err := ReadConfigData(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return Config{}, nil
 		}
-		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	// Unmarshals the JSON data from the file into a Config struct.
-	if err := json.Unmarshal(data, &config); err != nil {
-		return Config{}, fmt.Errorf("failed to parse config file: %w", err)
+	config, err := parseConfigData(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
 	return config, nil
 }
 
-// saveConfig saves the given Config struct to the configuration file.
+// parseConfigData parses the JSON contents of a configuration file.
+// Numbers and booleans are accepted for typed keys and converted to their string form.
+// Profiles are skipped; see parseProfiles.
+func parseConfigData(data []byte) (Config, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	config := make(Config, len(raw))
+	for key, value := range raw {
+		if key == profilesKey {
+			continue
+		}
+		str, err := scalarString(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if str != "" {
+			config[key] = str
+		}
+	}
+	return config, nil
+}
+
+// scalarString converts a JSON string, number, boolean or list of strings to its string form.
+// null is treated as unset and yields an empty string.
+func scalarString(key string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	case []any:
+		// Lists are stored as their comma-separated form.
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("unsupported value for %s: lists may only contain strings", key)
+			}
+			items = append(items, str)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value for %s", key)
+	}
+}
+
+// ValidateConfigData checks that data is a valid configuration file:
+// well-formed JSON containing only known keys with valid values, including in profiles.
+func ValidateConfigData(data []byte) error {
+	config, err := parseConfigData(data)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range config {
+		key, err := LookupKey(name)
+		if err != nil {
+			return err
+		}
+		if err := key.Validate(value); err != nil {
+			return err
+		}
+	}
+
+	profiles, err := parseProfiles(data)
+	if err != nil {
+		return err
+	}
+	return validateProfiles(profiles)
+}
+
+// WriteConfigFile validates data and writes it to the configuration file at path.
+// An encrypted file stays encrypted with the same method.
+func WriteConfigFile(path string, data []byte) error {
+	if err := ValidateConfigData(data); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return writeFile(path, data)
+}
+
+// saveConfig saves the given Config to the configuration file.
+// Values of numeric and boolean keys are written as JSON numbers and booleans.
 // It writes the file with permission 0600 to ensure only the user can read/write it.
+// If the file is encrypted, it is re-encrypted with the same method.
 func saveConfig(config Config) error {
-	data, err := json.MarshalIndent(config, "", "  ")
+	raw := make(map[string]any, len(config))
+	for key, value := range config {
+		raw[key] = encodeValue(key, value)
+	}
+
+	// Keeps the profiles, which are not part of Config, as they are.
+	existing, err := ReadConfigData(GetConfigPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err == nil {
+		var previous map[string]json.RawMessage
+		if json.Unmarshal(existing, &previous) == nil && previous[profilesKey] != nil {
+			raw[profilesKey] = previous[profilesKey]
+		}
+	}
+
+	data, err := json.MarshalIndent(raw, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Writes the configuration data to the config file.
-	if err := os.WriteFile(configFilePath, data, 0o600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
-	return nil
+	// Writes the configuration data to the config file, keeping it encrypted if it was.
+	return writeFile(GetConfigPath(), data)
 }
 
 // SetConfig updates the configuration for the given key with the specified value.
-// It first loads the existing configuration, modifies the key, and then saves the updated config.
+// It first loads the existing global configuration, modifies the key, and then saves the updated config.
+// The per-repository file is never written.
 func SetConfig(key, value string) error {
-	config, err := loadConfig()
-	if err != nil {
+	if err := SetConfigValues(map[string]string{key: value}); err != nil {
 		return err
 	}
 
-	// Updates the corresponding field based on the provided key.
-	switch key {
-	case "GROQ_APIKEY":
-		config.GROQAPIKey = value
-	case "COMMIT_PROMPT":
-		config.CommitPrompt = value
-	default:
-		// Returns an error if the key is not recognized.
-		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	fmt.Printf("Configuration updated: %s=%s\n", key, value)
+	return nil
+}
+
+// UnsetConfig removes the given key from the global configuration and rewrites the file.
+// The key falls back to the per-repository value or its default afterwards.
+func UnsetConfig(key string) error {
+	if _, err := LookupKey(key); err != nil {
+		return err
 	}
 
-	// Saves the updated configuration.
+	config, err := loadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	delete(config, key)
+
 	if err := saveConfig(config); err != nil {
 		return err
 	}
 
-	fmt.Printf("Configuration updated: %s=%s\n", key, value)
+	fmt.Printf("Configuration removed: %s\n", key)
 	return nil
 }
 
-// GetConfig retrieves the value of the specified configuration key.
-// It loads the current configuration and returns the value corresponding to the given key.
+// SetConfigValues updates several configuration keys at once and saves the global configuration.
+// Every value is validated against its key's schema; nothing is written if any of them is invalid.
+// An empty value removes the key.
+func SetConfigValues(values map[string]string) error {
+	config, err := loadGlobalConfig()
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		key, err := LookupKey(name)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			delete(config, name)
+			continue
+		}
+		if err := key.Validate(value); err != nil {
+			return err
+		}
+		config[name] = value
+	}
+
+	// Saves the updated configuration.
+	return saveConfig(config)
+}
+
+// GetConfig retrieves the effective value of the specified configuration key.
+// The value is resolved from, in increasing precedence: the key's default, the global file,
+// the per-repository file, the AI_COMMIT_<KEY> environment variable and command-line flags.
 func GetConfig(key string) (string, error) {
-	config, err := loadConfig()
+	setting, err := ResolveKey(key)
 	if err != nil {
 		return "", err
 	}
+	return setting.Value.Value, nil
+}
 
-	// Returns the value based on the key or an error if the key is unknown.
-	switch key {
-	case "GROQ_APIKEY":
-		return config.GROQAPIKey, nil
-	case "COMMIT_PROMPT":
-		return config.CommitPrompt, nil
-	default:
-		return "", fmt.Errorf("%w: %s", ErrUnknownKey, key)
+// GetConfigPath returns the full path to the configuration file, in the user's home directory.
+// The path is found anew on every call, so that changing HOME, e.g. in tests, moves the file.
+func GetConfigPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get user home directory: %v", err))
 	}
+	return filepath.Join(homeDir, configFileName)
 }
 
-// GetConfigPath returns the full path to the configuration file.
-func GetConfigPath() string {
-	return configFilePath
+// GetRepoConfigPath returns the path to the per-repository configuration file.
+// It searches from the work directory (see git.WorkDir) up to the repository root and returns
+// an empty string if no file is found.
+func GetRepoConfigPath() string {
+	dir, err := git.WorkDir()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		candidate := filepath.Join(dir, repoConfigFileName)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+
+		// Stops at the repository root, marked by a .git directory or file (worktrees).
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// RepoConfigTarget returns the per-repository configuration file to write to:
+// the existing file, or a new one in the repository root.
+func RepoConfigTarget() (string, error) {
+	if path := GetRepoConfigPath(); path != "" {
+		return path, nil
+	}
+	root := findRepoRoot()
+	if root == "" {
+		return "", fmt.Errorf("not inside a repository")
+	}
+	return filepath.Join(root, repoConfigFileName), nil
 }
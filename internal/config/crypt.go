@@ -0,0 +1,295 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	// MethodPassphrase encrypts the configuration file with an age passphrase.
+	MethodPassphrase = "passphrase"
+	// MethodDPAPI encrypts the configuration file with the Windows Data Protection API,
+	// which ties it to the current user account without a passphrase.
+	MethodDPAPI = "dpapi"
+
+	// dpapiHeader marks a file encrypted with DPAPI; the base64-encoded blob follows it.
+	dpapiHeader = "AI-COMMIT-DPAPI-V1\n"
+	// PassphraseEnv is the environment variable checked for the passphrase before prompting.
+	PassphraseEnv = "AI_COMMIT_PASSPHRASE"
+)
+
+// ErrDPAPIUnsupported is returned when DPAPI encryption is requested on a non-Windows system.
+var ErrDPAPIUnsupported = errors.New("dpapi encryption is only available on Windows")
+
+// PassphraseFunc returns the passphrase for an encrypted configuration file.
+// confirm is true when a new passphrase is being chosen and should be entered twice.
+// The command-line tool replaces it with an interactive prompt; the default reads AI_COMMIT_PASSPHRASE.
+var PassphraseFunc = func(confirm bool) (string, error) {
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", fmt.Errorf("the config file is encrypted; set %s to decrypt it", PassphraseEnv)
+}
+
+var (
+	// cryptMu guards cachedPassphrase and decryptCache, since the daemon reloads the config from another goroutine.
+	cryptMu sync.Mutex
+	// cachedPassphrase avoids asking for the passphrase more than once per process.
+	// It only holds a passphrase that decrypted or encrypted the file successfully.
+	cachedPassphrase string
+	// decryptCache holds decrypted files, since age's scrypt key derivation is deliberately slow.
+	decryptCache = map[string]decryptedFile{}
+)
+
+// decryptedFile is a cached decryption result, valid while the file is unchanged.
+type decryptedFile struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// encryptionMethod returns the method data is encrypted with, or an empty string for plain text.
+func encryptionMethod(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte(armor.Header)):
+		return MethodPassphrase
+	case bytes.HasPrefix(data, []byte(dpapiHeader)):
+		return MethodDPAPI
+	default:
+		return ""
+	}
+}
+
+// fileEncryptionMethod returns the method the file at path is encrypted with.
+// A missing file is reported as unencrypted.
+func fileEncryptionMethod(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	return encryptionMethod(data), nil
+}
+
+// EncryptionMethod returns the method the global configuration file is encrypted with,
+// or an empty string if it is stored in plain text.
+func EncryptionMethod() (string, error) {
+	return fileEncryptionMethod(GetConfigPath())
+}
+
+// ReadConfigData returns the contents of the configuration file at path, decrypting it if needed.
+func ReadConfigData(path string) ([]byte, error) {
+	cryptMu.Lock()
+	defer cryptMu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := decryptCache[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.data, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if encryptionMethod(data) == "" {
+		return data, nil
+	}
+
+	plaintext, err := decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config file %s: %w", path, err)
+	}
+	decryptCache[path] = decryptedFile{modTime: info.ModTime(), size: info.Size(), data: plaintext}
+	return plaintext, nil
+}
+
+// EncryptConfig encrypts the global configuration file with the given method.
+func EncryptConfig(method string) error {
+	if method != MethodPassphrase && method != MethodDPAPI {
+		return fmt.Errorf("unknown encryption method: %s (valid methods: %s, %s)", method, MethodPassphrase, MethodDPAPI)
+	}
+
+	data, err := ReadConfigData(GetConfigPath())
+	if os.IsNotExist(err) {
+		data = []byte("{}")
+	} else if err != nil {
+		return err
+	}
+
+	// Asks for a new passphrase even if the file was already encrypted with one.
+	if method == MethodPassphrase {
+		passphrase, err := PassphraseFunc(true)
+		if err != nil {
+			return err
+		}
+		cryptMu.Lock()
+		cachedPassphrase = passphrase
+		cryptMu.Unlock()
+	}
+	return writeConfigData(GetConfigPath(), data, method)
+}
+
+// DecryptConfig stores the global configuration file in plain text again.
+func DecryptConfig() error {
+	data, err := ReadConfigData(GetConfigPath())
+	if err != nil {
+		return err
+	}
+	return writeConfigData(GetConfigPath(), data, "")
+}
+
+// writeFile writes data to the configuration file at path,
+// keeping the encryption method of the existing file.
+func writeFile(path string, data []byte) error {
+	method, err := fileEncryptionMethod(path)
+	if err != nil {
+		return err
+	}
+	return writeConfigData(path, data, method)
+}
+
+// writeConfigData encrypts data with method, unless it is empty, and writes it to path.
+// The file is replaced atomically so a failed write never leaves a truncated config behind.
+func writeConfigData(path string, data []byte, method string) error {
+	cryptMu.Lock()
+	defer cryptMu.Unlock()
+
+	if method != "" {
+		var err error
+		if data, err = encrypt(data, method); err != nil {
+			return fmt.Errorf("failed to encrypt config file: %w", err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	delete(decryptCache, path)
+	return nil
+}
+
+// encrypt encrypts data with the given method. cryptMu must be held.
+func encrypt(data []byte, method string) ([]byte, error) {
+	switch method {
+	case MethodDPAPI:
+		blob, err := dpapiProtect(data)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(dpapiHeader + base64.StdEncoding.EncodeToString(blob) + "\n"), nil
+	case MethodPassphrase:
+		passphrase, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		recipient, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		// Armors the output so the file stays printable text.
+		var buf bytes.Buffer
+		armorWriter := armor.NewWriter(&buf)
+		w, err := age.Encrypt(armorWriter, recipient)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		if err := armorWriter.Close(); err != nil {
+			return nil, err
+		}
+		cachedPassphrase = passphrase
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown encryption method: %s", method)
+	}
+}
+
+// decrypt decrypts data according to the method detected from its header. cryptMu must be held.
+func decrypt(data []byte) ([]byte, error) {
+	switch encryptionMethod(data) {
+	case MethodDPAPI:
+		blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data[len(dpapiHeader):])))
+		if err != nil {
+			return nil, err
+		}
+		return dpapiUnprotect(blob)
+	case MethodPassphrase:
+		passphrase, err := passphrase()
+		if err != nil {
+			return nil, err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), identity)
+		if err != nil {
+			// Forgets a wrong passphrase so that the next read asks again.
+			cachedPassphrase = ""
+			return nil, err
+		}
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		cachedPassphrase = passphrase
+		return plaintext, nil
+	default:
+		return data, nil
+	}
+}
+
+// passphrase returns the cached passphrase or asks PassphraseFunc for it. cryptMu must be held.
+// The caller caches a passphrase it asked for once it has been used successfully.
+func passphrase() (string, error) {
+	if cachedPassphrase != "" {
+		return cachedPassphrase, nil
+	}
+	passphrase, err := PassphraseFunc(false)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+	return passphrase, nil
+}
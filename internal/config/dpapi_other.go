@@ -0,0 +1,13 @@
+//go:build !windows
+
+package config
+
+// dpapiProtect is not available outside Windows.
+func dpapiProtect(data []byte) ([]byte, error) {
+	return nil, ErrDPAPIUnsupported
+}
+
+// dpapiUnprotect is not available outside Windows.
+func dpapiUnprotect(data []byte) ([]byte, error) {
+	return nil, ErrDPAPIUnsupported
+}
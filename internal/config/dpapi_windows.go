@@ -0,0 +1,43 @@
+//go:build windows
+
+package config
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// dpapiProtect encrypts data with the current user's DPAPI key.
+func dpapiProtect(data []byte) ([]byte, error) {
+	in := newDataBlob(data)
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+// dpapiUnprotect decrypts data previously encrypted by dpapiProtect for the same user.
+func dpapiUnprotect(data []byte) ([]byte, error) {
+	in := newDataBlob(data)
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeDataBlob(&out), nil
+}
+
+// newDataBlob wraps data in a DataBlob without copying it.
+func newDataBlob(data []byte) *windows.DataBlob {
+	if len(data) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+// takeDataBlob copies the contents of a blob allocated by Windows and frees it.
+func takeDataBlob(blob *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	return append([]byte(nil), unsafe.Slice(blob.Data, blob.Size)...)
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+const (
+	// EnvPrefix is prepended to a key name to form the environment variable that overrides it,
+	// e.g. AI_COMMIT_MODEL overrides MODEL.
+	EnvPrefix = "AI_COMMIT_"
+	// envFileName is the name of the .env file loaded from the repository root.
+	envFileName = ".env"
+)
+
+// EnvName returns the environment variable that overrides key.
+func EnvName(key string) string {
+	return EnvPrefix + key
+}
+
+// lookupEnv returns the value of the environment variable overriding key, if it is set and not empty.
+func lookupEnv(key string) (string, bool) {
+	value, ok := os.LookupEnv(EnvName(key))
+	return value, ok && value != ""
+}
+
+// FindEnvFile returns the path to the .env file in the repository root.
+// It returns an empty string if the current directory is not inside a repository or there is no such file.
+func FindEnvFile() string {
+	root := findRepoRoot()
+	if root == "" {
+		return ""
+	}
+	path := filepath.Join(root, envFileName)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// LoadEnvFile reads the AI_COMMIT_<KEY> variables from the file at path into the process environment.
+// Other variables are left to the tools they are meant for, e.g. GIT_SSH_COMMAND or HTTPS_PROXY.
+// Variables that are already set take precedence over the file, so secrets can be kept
+// in the real environment while shared settings live in the file.
+// Blank lines, comments starting with '#' and an optional "export " prefix are supported,
+// and values may be wrapped in single or double quotes. Lines that cannot be parsed are skipped
+// with a warning.
+// If repo is true, the file comes with the repository, and like its configuration file it may
+// only set the keys that are RepoSafe; the variables of other keys are ignored with a warning.
+func LoadEnvFile(path string, repo bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer file.Close()
+	logging.Info("loading env file", "path", path)
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	var ignored []string
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			warnEnvLine(path, lineNumber, fmt.Errorf("expected NAME=VALUE"))
+			continue
+		}
+		key, ok := strings.CutPrefix(name, EnvPrefix)
+		if !ok {
+			logging.Debug("env file variable not for the tool", "path", path, "name", name)
+			continue
+		}
+		if repo && !repoSafe(key) {
+			ignored = append(ignored, name)
+			continue
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			warnEnvLine(path, lineNumber, err)
+			continue
+		}
+
+		if _, exists := os.LookupEnv(name); exists {
+			logging.Debug("env file variable already set", "path", path, "name", name)
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read env file: %w", err)
+	}
+	if len(ignored) > 0 {
+		logging.Warn("ignored variables of the repository env file", "path", path, "names", ignored)
+		if notify != nil {
+			notify(fmt.Sprintf("Warning: ignoring %s in %s; a repository file may only set the prompt, style, model, language and lint settings.",
+				strings.Join(ignored, ", "), path))
+		}
+	}
+	return nil
+}
+
+// warnEnvLine warns about the line at lineNumber of the env file at path, which is skipped because of err.
+func warnEnvLine(path string, lineNumber int, err error) {
+	logging.Warn("skipped a line of the env file", "path", path, "line", lineNumber, "error", err)
+	if notify != nil {
+		notify(fmt.Sprintf("Warning: skipping line %d of %s: %v", lineNumber, path, err))
+	}
+}
+
+// parseEnvValue unquotes a .env value.
+// Double-quoted values support the escape sequences \n, \" and \\ and keep other backslashes, as in
+// "C:\Users\me", single-quoted values are taken literally, and values end at an inline " #" comment.
+func parseEnvValue(value string) (string, error) {
+	quote := value[:min(len(value), 1)]
+	if quote != `"` && quote != "'" {
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		return value, nil
+	}
+
+	var unquoted strings.Builder
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == quote[0]:
+			if rest := strings.TrimSpace(value[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected %q after the closing quote", rest)
+			}
+			return unquoted.String(), nil
+		case c == '\\' && quote == `"` && i+1 < len(value):
+			switch next := value[i+1]; next {
+			case 'n':
+				unquoted.WriteByte('\n')
+				i++
+			case '"', '\\':
+				unquoted.WriteByte(next)
+				i++
+			default:
+				unquoted.WriteByte(c)
+			}
+		default:
+			unquoted.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("missing the closing %s", quote)
+}
+
+// findRepoRoot returns the closest parent of the work directory containing a .git entry.
+// It returns an empty string if there is none.
+func findRepoRoot() string {
+	dir, err := git.WorkDir()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
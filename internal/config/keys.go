@@ -0,0 +1,559 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// KeyType identifies how the value of a configuration key is parsed and validated.
+type KeyType int
+
+const (
+	TypeString KeyType = iota // Any string
+	TypeInt                   // A base-10 integer
+	TypeFloat                 // A floating point number
+	TypeBool                  // true or false
+	TypeEnum                  // One of a fixed set of strings
+	TypeList                  // A comma-separated list of strings
+)
+
+// String returns the name of the type as shown in help output.
+func (t KeyType) String() string {
+	switch t {
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeEnum:
+		return "enum"
+	case TypeList:
+		return "list"
+	default:
+		return "string"
+	}
+}
+
+// Key describes a configuration key and the values it accepts.
+type Key struct {
+	Name        string             // Name of the key as used in config files and commands
+	Type        KeyType            // Type of the value
+	Description string             // Short human-readable description
+	Default     string             // Value used when no other source defines the key
+	Values      []string           // Allowed values for TypeEnum keys
+	Min, Max    *float64           // Optional inclusive bounds for TypeInt and TypeFloat keys
+	Secret      bool               // Whether the value must be masked when displayed
+	RepoSafe    bool               // Whether the per-repository configuration file may set the key
+	Check       func(string) error // Optional additional validation
+}
+
+// keys is the registry of all supported configuration keys, in display order.
+// Adding a configuration option only requires a new entry here.
+var keys = []Key{
+	{
+		Name:        "PROVIDER",
+		Type:        TypeEnum,
+		Description: "AI provider used to generate messages",
+		Default:     "groq",
+		// Mirrors the presets in the provider package, which cannot be imported here.
+		Values: []string{"groq", "together", "llamacpp", "llamafile", "vertex", "mock"},
+	},
+	{Name: "PROFILE", Type: TypeString, Description: "Profile to use instead of selecting one from the origin remote"},
+	{Name: "MODEL", Type: TypeString, Description: "Model used for generation, defaults to the provider's model", RepoSafe: true},
+	{
+		Name:        "COMMIT_STYLE",
+		Type:        TypeEnum,
+		Description: "Format of generated messages",
+		Default:     "auto",
+		Values:      []string{"auto", "default", "conventional", "multiline", "plain", "gitmoji", "concise", "detailed", "corporate", "emoji-free", "kernel"},
+		RepoSafe:    true,
+	},
+	{Name: "COMMIT_PROMPT", Type: TypeString, Description: "Custom system prompt for the default commit style, a Go template like those of PROMPT_TEMPLATE", RepoSafe: true},
+	{
+		Name:        "PROMPT_TEMPLATE",
+		Type:        TypeString,
+		Description: `Name of a Go template in the prompts directory used as the system prompt, see "prompts list"; empty for that of the commit style`,
+		RepoSafe:    true,
+		Check:       checkPromptName,
+	},
+	{
+		Name:        "COMMIT_LANGUAGE",
+		Type:        TypeString,
+		Description: `Language generated messages are written in, e.g. "Japanese", "de" or "Bahasa Indonesia"; empty to follow the recent commits`,
+		RepoSafe:    true,
+	},
+	{
+		Name:        "STRUCTURED_OUTPUT",
+		Type:        TypeEnum,
+		Description: "Ask for messages as JSON objects of their parts, rendered locally: json_schema enforces the fields, json_object suits providers without schemas",
+		Default:     "off",
+		Values:      []string{"off", "json_schema", "json_object"},
+		RepoSafe:    true,
+	},
+	{
+		Name:        "STRUCTURED_TEMPLATE",
+		Type:        TypeString,
+		Description: `Go template rendering structured messages from .Type, .Scope, .Subject, .Body and .Breaking; empty for that of the commit style`,
+		RepoSafe:    true,
+		Check:       checkTemplate,
+	},
+	{Name: "GROQ_APIKEY", Type: TypeString, Description: "GROQ API key", Secret: true},
+	{Name: "TOGETHER_APIKEY", Type: TypeString, Description: "Together AI API key", Secret: true},
+	{Name: "APIKEY_COMMAND", Type: TypeString, Description: "Shell command whose output is used as the API key"},
+	{Name: "LLAMACPP_URL", Type: TypeString, Description: "Address of the llama.cpp server", Default: "http://localhost:8080", Check: checkURL},
+	{Name: "VERTEX_PROJECT", Type: TypeString, Description: "Google Cloud project for Vertex AI"},
+	{Name: "VERTEX_REGION", Type: TypeString, Description: "Google Cloud region for Vertex AI", Default: "us-central1"},
+	{Name: "VERTEX_MODEL", Type: TypeString, Description: "Default Vertex AI model"},
+	{
+		Name:        "MOCK_MODE",
+		Type:        TypeEnum,
+		Description: "Whether the mock provider replays recorded responses or records those of MOCK_RECORD_PROVIDER",
+		Default:     "replay",
+		Values:      []string{"replay", "record"},
+	},
+	{Name: "MOCK_FIXTURES", Type: TypeString, Description: "Directory of the requests and responses recorded for the mock provider", Default: ".ai-commit-fixtures"},
+	{Name: "MOCK_RECORD_PROVIDER", Type: TypeString, Description: "Provider whose responses the mock provider records, e.g. groq"},
+	{Name: "TEMPERATURE", Type: TypeFloat, Description: "Sampling temperature", Min: bound(0), Max: bound(2)},
+	{Name: "MAX_TOKENS", Type: TypeInt, Description: "Maximum number of tokens to generate", Min: bound(1)},
+	{Name: "TOP_P", Type: TypeFloat, Description: "Nucleus sampling probability", Min: bound(0), Max: bound(1)},
+	{Name: "SEED", Type: TypeInt, Description: "Seed of the sampling, so that the same prompt gets the same reply where the provider supports it", Min: bound(0)},
+	{Name: "DETERMINISTIC", Type: TypeBool, Description: "Use temperature 0 and a fixed seed unless SEED is set, for repeatable messages in demos and tests", Default: "false"},
+	{Name: "RETRIES", Type: TypeInt, Description: "Number of times a request that failed with a network or server error is retried", Default: "2", Min: bound(0), Max: bound(10)},
+	{Name: "RETRY_BACKOFF", Type: TypeFloat, Description: "Seconds to wait before the first retry, doubled for every further one and randomized by up to half", Default: "1", Min: bound(0)},
+	{Name: "RETRY_MAX_ELAPSED", Type: TypeFloat, Description: "Seconds after the first attempt after which no retry is started, 0 for no limit", Default: "60", Min: bound(0)},
+	{Name: "RATE_LIMIT_MAX_WAIT", Type: TypeFloat, Description: "Most seconds to wait for a rate limit to reset before retrying, 0 to fail right away", Default: "60", Min: bound(0)},
+	{Name: "PROXY_URL", Type: TypeString, Description: "Proxy for API requests, e.g. http://proxy:3128 or socks5://127.0.0.1:1080, or none; defaults to HTTPS_PROXY and ALL_PROXY", Secret: true},
+	{Name: "CA_CERT_FILE", Type: TypeString, Description: "PEM file with CA certificates to trust in addition to the system ones, e.g. that of a proxy inspecting TLS traffic"},
+	{Name: "TLS_INSECURE", Type: TypeBool, Description: "Skip TLS certificate verification of API requests; insecure, prefer CA_CERT_FILE", Default: "false"},
+	{Name: "DAEMON", Type: TypeBool, Description: "Send API requests through the daemon when it runs, reusing its open connections", Default: "true"},
+	{Name: "OFFLINE_FALLBACK", Type: TypeBool, Description: "Write the message from the changed files without the AI when no API key is set or the provider cannot be reached", Default: "true"},
+	{Name: "CACHE_TTL", Type: TypeFloat, Description: "Hours a message is reused when generating again for the same changes and settings, 0 to disable the cache", Default: "24", Min: bound(0)},
+	{Name: "STREAM", Type: TypeBool, Description: "Show messages and pull request descriptions as they are generated, in a terminal", Default: "true"},
+	{Name: "BRANCH_CONTEXT", Type: TypeBool, Description: "Include the current branch name in the prompt", Default: "true"},
+	{
+		Name:        "ISSUE_PATTERN",
+		Type:        TypeString,
+		Description: "Regular expression finding the issue reference in the branch name; the first capture group is used if there is one",
+		Default:     `[A-Z][A-Z0-9]+-[0-9]+|(?i:issue|gh)[-_/]?([0-9]+)`,
+		Check:       checkRegexp,
+	},
+	{
+		Name:        "ISSUE_TEMPLATE",
+		Type:        TypeString,
+		Description: `How the issue reference is added to the subject, e.g. "[{issue}] {subject}", or "none"`,
+		Default:     "{subject} ({issue})",
+		Check:       checkIssueTemplate,
+	},
+	{
+		Name:        "ISSUE_TRACKER",
+		Type:        TypeEnum,
+		Description: "Issue tracker the title and description of the issue of the branch are fetched from and sent to the provider with the diff",
+		Default:     "none",
+		Values:      []string{"none", "jira", "linear", "github"},
+	},
+	{Name: "JIRA_URL", Type: TypeString, Description: "Address of the Jira site of ISSUE_TRACKER jira, e.g. https://example.atlassian.net", Check: checkURL},
+	{Name: "JIRA_EMAIL", Type: TypeString, Description: "Email address of the Jira Cloud account of JIRA_TOKEN; leave empty for a personal access token of Jira Data Center"},
+	{Name: "JIRA_TOKEN", Type: TypeString, Description: "Jira API token, or personal access token of Jira Data Center, allowed to read the issues", Secret: true},
+	{Name: "LINEAR_API_KEY", Type: TypeString, Description: "Personal API key of Linear, for ISSUE_TRACKER linear", Secret: true},
+	{Name: "GITHUB_TOKEN", Type: TypeString, Description: "GitHub token allowed to read the issues of the repository, for ISSUE_TRACKER github; the GitHub CLI (gh) is used without one", Secret: true},
+	{Name: "BASE_BRANCH", Type: TypeString, Description: "Branch whose comparison with HEAD is included in the prompt, e.g. origin/main"},
+	{Name: "GITLAB_TOKEN", Type: TypeString, Description: `GitLab access token with the api scope, for "pr -update" in merge request pipelines`, Secret: true},
+	{Name: "CANDIDATES", Type: TypeInt, Description: "Number of alternative messages generated to choose from", Default: "1", Min: bound(1), Max: bound(10)},
+	{Name: "HISTORY_EXAMPLES", Type: TypeInt, Description: "Number of recent commit subjects shown to the AI as style examples, 0 to disable", Default: "10", Min: bound(0)},
+	{
+		Name:        "DIFF_EXCLUDE",
+		Type:        TypeList,
+		Description: `Globs of files whose changes are not sent to the AI, or "none"`,
+		Default:     "package-lock.json,go.sum,*.min.js,dist/**",
+		Check:       checkPatterns,
+	},
+	{Name: "LINT", Type: TypeBool, Description: "Check generated messages and ask the AI to fix the ones that break the rules", Default: "true", RepoSafe: true},
+	{Name: "LINT_RETRIES", Type: TypeInt, Description: "Number of times the AI is asked to fix a message before giving up", Default: "2", Min: bound(0), RepoSafe: true},
+	{Name: "LINT_MAX_SUBJECT_LENGTH", Type: TypeInt, Description: "Maximum length of the first line of a message, 0 to disable", Default: "100", Min: bound(0), RepoSafe: true},
+	{Name: "LINT_IMPERATIVE", Type: TypeBool, Description: "Require the subject to use the imperative mood", Default: "true", RepoSafe: true},
+	{
+		Name:        "LINT_TYPES",
+		Type:        TypeList,
+		Description: "Allowed Conventional Commits types",
+		Default:     "feat,fix,docs,style,refactor,perf,test,build,ci,chore,revert",
+		RepoSafe:    true,
+	},
+	{
+		Name:        "GIT_BACKEND",
+		Type:        TypeEnum,
+		Description: "How the repository is read: go-git, falling back to the git binary, or always exec the git binary",
+		Default:     git.BackendGoGit,
+		Values:      []string{git.BackendGoGit, git.BackendExec},
+	},
+	{Name: "DIFF_STAT", Type: TypeBool, Description: "Start the diff in the prompt with a summary of the changed files", Default: "true"},
+	{
+		Name:        "DIFF_PREVIEW",
+		Type:        TypeEnum,
+		Description: "What generate shows of the staged changes before calling the AI: a summary, the full diff or nothing",
+		Default:     "stat",
+		Values:      []string{"stat", "full", "off"},
+	},
+	{Name: "NEW_FILE_MAX_LINES", Type: TypeInt, Description: "New files with more lines are summarized in the prompt, 0 to always include them whole", Default: "300", Min: bound(0)},
+	{Name: "CONTEXT_WINDOW", Type: TypeInt, Description: "Tokens the model can read; larger diffs are shortened to fit. Defaults to that of known models", Min: bound(1024)},
+	{Name: "NEW_FILE_PREVIEW_LINES", Type: TypeInt, Description: "Number of lines shown at the start of a summarized new file", Default: "40", Min: bound(0)},
+	{
+		Name:        "TRAILERS",
+		Type:        TypeList,
+		Description: `Trailers appended to every generated message, e.g. "Reviewed-by: Jane Doe <jane@example.com>"`,
+		Check:       checkTrailers,
+	},
+	{
+		Name:        "CO_AUTHORS",
+		Type:        TypeList,
+		Description: `Co-authors credited in every generated message, e.g. "Jane Doe <jane@example.com>"`,
+		Check:       checkCoAuthors,
+	},
+	{Name: "DETECT_CO_AUTHORS", Type: TypeBool, Description: "Credit the Co-authored-by lines of the commit template set by pairing tools", Default: "true"},
+	{Name: "USE_COMMIT_TEMPLATE", Type: TypeBool, Description: "Merge generated messages into the file set as commit.template", Default: "true"},
+	{
+		Name:        "RELEASE_AUDIENCE",
+		Type:        TypeEnum,
+		Description: "Readers of generated release notes",
+		Default:     "user",
+		Values:      []string{"user", "developer"},
+	},
+	{Name: "HISTORY_SIZE", Type: TypeInt, Description: "Number of generated messages kept in the local history, 0 to disable it", Default: "1000", Min: bound(0)},
+	{
+		Name:        "LOG_LEVEL",
+		Type:        TypeEnum,
+		Description: "Diagnostics recorded: how the prompt was built and the API traffic (debug), the Git commands and configuration (info), problems worked around (warn), or failures (error)",
+		Default:     logging.LevelWarn,
+		Values:      logging.Levels,
+	},
+	{Name: "LOG_FILE", Type: TypeString, Description: "File the diagnostics are appended to with timestamps, instead of being printed to stderr"},
+	{Name: "PRE_GENERATE_HOOK", Type: TypeString, Description: "Shell command getting the diff on stdin before generating; its output replaces the diff, and its failure stops the command"},
+	{Name: "POST_GENERATE_HOOK", Type: TypeString, Description: "Shell command getting the generated message on stdin; its output replaces the message, and its failure stops the command"},
+	{Name: "POST_COMMIT_HOOK", Type: TypeString, Description: "Shell command getting the committed message on stdin, e.g. to send a notification"},
+	{
+		Name:        "WEBHOOKS",
+		Type:        TypeList,
+		Description: "URLs notified of every commit made with the tool: Slack or Discord incoming webhooks, or endpoints receiving JSON",
+		Secret:      true,
+		Check:       checkURLs,
+	},
+	{Name: "TELEMETRY", Type: TypeBool, Description: "Send anonymous usage statistics: commands, provider, latency and accepted messages, never diffs or messages", Default: "false"},
+	{Name: "TELEMETRY_URL", Type: TypeString, Description: "Endpoint the usage statistics are sent to, instead of the one of the release build", Check: checkURL},
+}
+
+// Keys returns the schema of every supported configuration key in display order.
+func Keys() []Key {
+	return slices.Clone(keys)
+}
+
+// LookupKey returns the schema of the named key.
+// The error for an unknown key lists all valid keys.
+func LookupKey(name string) (Key, error) {
+	for _, key := range keys {
+		if key.Name == name {
+			return key, nil
+		}
+	}
+
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.Name
+	}
+	return Key{}, fmt.Errorf("%w: %s (valid keys: %s)", ErrUnknownKey, name, strings.Join(names, ", "))
+}
+
+// Validate checks that value is acceptable for the key.
+func (k Key) Validate(value string) error {
+	switch k.Type {
+	case TypeInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q is not an integer", k.Name, value)
+		}
+		if err := k.checkRange(float64(n)); err != nil {
+			return err
+		}
+	case TypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q is not a number", k.Name, value)
+		}
+		if err := k.checkRange(f); err != nil {
+			return err
+		}
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %q is not true or false", k.Name, value)
+		}
+	case TypeEnum:
+		if !slices.Contains(k.Values, value) {
+			return fmt.Errorf("invalid value for %s: %q (valid values: %s)", k.Name, value, strings.Join(k.Values, ", "))
+		}
+	}
+
+	if k.Check != nil {
+		if err := k.Check(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", k.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkRange checks that n lies within the key's bounds, if any.
+func (k Key) checkRange(n float64) error {
+	if k.Min != nil && n < *k.Min {
+		return fmt.Errorf("invalid value for %s: must be at least %g", k.Name, *k.Min)
+	}
+	if k.Max != nil && n > *k.Max {
+		return fmt.Errorf("invalid value for %s: must be at most %g", k.Name, *k.Max)
+	}
+	return nil
+}
+
+// Getter returns the value of a configuration key, "" if it is not set. GetConfig reads the effective
+// configuration; Isolated returns one that reads given values only.
+type Getter func(key string) (string, error)
+
+// Isolated returns a Getter that reads the values, falling back to the defaults of the keys, but
+// never the configuration files, the environment or flags. Every value is validated against the
+// schema of its key. DAEMON defaults to false, since the daemon uses the settings of the configuration.
+func Isolated(values map[string]string) (Getter, error) {
+	config := Config{}
+	for _, key := range keys {
+		if key.Default != "" {
+			config[key.Name] = key.Default
+		}
+	}
+	config["DAEMON"] = "false"
+	for name, value := range values {
+		key, err := LookupKey(name)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		if err := key.Validate(value); err != nil {
+			return nil, err
+		}
+		config[name] = value
+	}
+	return func(key string) (string, error) {
+		if _, err := LookupKey(key); err != nil {
+			return "", err
+		}
+		return config[key], nil
+	}, nil
+}
+
+// Int retrieves an integer configuration value.
+// ok is false when the key is not set.
+func (get Getter) Int(key string) (n int, ok bool, err error) {
+	value, err := get(key)
+	if err != nil || value == "" {
+		return 0, false, err
+	}
+	n, err = strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid value for %s: %q is not an integer", key, value)
+	}
+	return n, true, nil
+}
+
+// Float retrieves a floating point configuration value.
+// ok is false when the key is not set.
+func (get Getter) Float(key string) (f float64, ok bool, err error) {
+	value, err := get(key)
+	if err != nil || value == "" {
+		return 0, false, err
+	}
+	f, err = strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid value for %s: %q is not a number", key, value)
+	}
+	return f, true, nil
+}
+
+// Bool retrieves a boolean configuration value.
+// ok is false when the key is not set.
+func (get Getter) Bool(key string) (b bool, ok bool, err error) {
+	value, err := get(key)
+	if err != nil || value == "" {
+		return false, false, err
+	}
+	b, err = strconv.ParseBool(value)
+	if err != nil {
+		return false, false, fmt.Errorf("invalid value for %s: %q is not true or false", key, value)
+	}
+	return b, true, nil
+}
+
+// List retrieves a comma-separated list configuration value.
+// Surrounding whitespace and empty items are dropped; ok is false when the key is not set.
+func (get Getter) List(key string) (items []string, ok bool, err error) {
+	value, err := get(key)
+	if err != nil || value == "" {
+		return nil, false, err
+	}
+	return splitList(value), true, nil
+}
+
+// GetInt retrieves an integer configuration value.
+// ok is false when the key is not set.
+func GetInt(key string) (n int, ok bool, err error) {
+	return Getter(GetConfig).Int(key)
+}
+
+// GetFloat retrieves a floating point configuration value.
+// ok is false when the key is not set.
+func GetFloat(key string) (f float64, ok bool, err error) {
+	return Getter(GetConfig).Float(key)
+}
+
+// GetBool retrieves a boolean configuration value.
+// ok is false when the key is not set.
+func GetBool(key string) (b bool, ok bool, err error) {
+	return Getter(GetConfig).Bool(key)
+}
+
+// GetList retrieves a comma-separated list configuration value.
+// Surrounding whitespace and empty items are dropped; ok is false when the key is not set.
+func GetList(key string) (items []string, ok bool, err error) {
+	return Getter(GetConfig).List(key)
+}
+
+// splitList splits a comma-separated list into its non-empty, trimmed items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// encodeValue converts a stored value to its JSON representation based on the key's type.
+// Unknown keys and values that fail to parse are kept as strings.
+func encodeValue(name, value string) any {
+	key, err := LookupKey(name)
+	if err != nil {
+		return value
+	}
+
+	switch key.Type {
+	case TypeInt:
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	case TypeFloat:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case TypeBool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case TypeList:
+		return splitList(value)
+	}
+	return value
+}
+
+// bound returns a pointer to n, for use in the Min and Max fields of a Key.
+func bound(n float64) *float64 {
+	return &n
+}
+
+// checkPatterns validates that every item of a comma-separated list is a valid glob pattern.
+func checkPatterns(value string) error {
+	for _, pattern := range splitList(value) {
+		if !git.ValidPattern(pattern) {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// checkTrailers validates that every item of value is a "Token: value" trailer.
+func checkTrailers(value string) error {
+	for _, trailer := range splitList(value) {
+		if !git.ValidTrailer(trailer) {
+			return fmt.Errorf("invalid trailer %q, expected \"Token: value\"", trailer)
+		}
+	}
+	return nil
+}
+
+// checkCoAuthors validates that every item of value has the "Name <email>" form.
+func checkCoAuthors(value string) error {
+	for _, author := range splitList(value) {
+		if !strings.HasSuffix(author, ">") || !strings.Contains(author, " <") {
+			return fmt.Errorf("invalid co-author %q, expected \"Name <email>\"", author)
+		}
+	}
+	return nil
+}
+
+// checkRegexp validates that value is a valid regular expression.
+func checkRegexp(value string) error {
+	if _, err := regexp.Compile(value); err != nil {
+		return fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return nil
+}
+
+// checkTemplate validates that value is a Go text template.
+func checkTemplate(value string) error {
+	if _, err := template.New("message").Parse(value); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return nil
+}
+
+// checkPromptName validates that value can name a file in the prompts directory.
+func checkPromptName(value string) error {
+	if value != "" && (value != filepath.Base(value) || strings.HasPrefix(value, ".")) {
+		return fmt.Errorf("%q must be the name of a prompt, without a directory or extension", value)
+	}
+	return nil
+}
+
+// checkIssueTemplate validates that value is "none" or contains both the {issue} and the {subject} placeholder.
+func checkIssueTemplate(value string) error {
+	if value == "none" {
+		return nil
+	}
+	if !strings.Contains(value, "{issue}") || !strings.Contains(value, "{subject}") {
+		return fmt.Errorf("%q must contain {issue} and {subject}", value)
+	}
+	return nil
+}
+
+// checkURL validates that value is an absolute http(s) URL.
+func checkURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
+
+// checkURLs validates that every item of a comma-separated list is an http(s) URL.
+func checkURLs(value string) error {
+	for _, item := range splitList(value) {
+		if err := checkURL(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestCheckIssueTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "none", value: "none"},
+		{name: "valid", value: "{subject} ({issue})"},
+		{name: "missing issue", value: "{subject}", wantErr: true},
+		{name: "missing subject", value: "[{issue}]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkIssueTemplate(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("checkIssueTemplate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
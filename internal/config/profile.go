@@ -0,0 +1,264 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/git"
+)
+
+const (
+	// profilesKey is the top-level key of the global file that holds the profiles.
+	profilesKey = "PROFILES"
+	// matchKey is the key inside a profile listing the remote patterns that select it.
+	matchKey = "MATCH"
+	// profileRemote is the remote whose URL is matched against profile patterns.
+	profileRemote = "origin"
+)
+
+// Profile is a named set of configuration values applied on top of the global configuration.
+type Profile struct {
+	Name   string   // Name of the profile
+	Match  []string // Remote patterns that select the profile automatically
+	Values Config   // Configuration values of the profile
+}
+
+// ActiveProfile describes the profile in effect for the current repository.
+type ActiveProfile struct {
+	Profile
+	Reason string // Why the profile was selected, e.g. "matched github.com/acme/app via github.com/acme"
+}
+
+// remoteCache holds the normalized origin URL, looked up once per process.
+var remoteCache *string
+
+// LoadProfiles returns the profiles defined in the global configuration file, sorted by name.
+func LoadProfiles() ([]Profile, error) {
+	data, err := ReadConfigData(GetConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	profiles, err := parseProfiles(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", GetConfigPath(), err)
+	}
+	return profiles, nil
+}
+
+// parseProfiles extracts the profiles from the JSON contents of a configuration file.
+// MATCH may be a single pattern or a list of patterns.
+func parseProfiles(data []byte) ([]Profile, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw[profilesKey] == nil {
+		return nil, nil
+	}
+
+	var rawProfiles map[string]map[string]any
+	if err := json.Unmarshal(raw[profilesKey], &rawProfiles); err != nil {
+		return nil, fmt.Errorf("%s must map profile names to objects", profilesKey)
+	}
+
+	profiles := make([]Profile, 0, len(rawProfiles))
+	for name, rawValues := range rawProfiles {
+		profile := Profile{Name: name, Values: Config{}}
+		for key, value := range rawValues {
+			if key == matchKey {
+				patterns, err := parsePatterns(value)
+				if err != nil {
+					return nil, fmt.Errorf("profile %s: %w", name, err)
+				}
+				profile.Match = patterns
+				continue
+			}
+
+			str, err := scalarString(key, value)
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: %w", name, err)
+			}
+			if str != "" {
+				profile.Values[key] = str
+			}
+		}
+		profiles = append(profiles, profile)
+	}
+
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// parsePatterns converts the MATCH value of a profile into a list of patterns.
+func parsePatterns(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		patterns := make([]string, 0, len(v))
+		for _, item := range v {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must contain only strings", matchKey)
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	default:
+		return nil, fmt.Errorf("%s must be a string or a list of strings", matchKey)
+	}
+}
+
+// validateProfiles checks that every profile only uses known keys with valid values.
+func validateProfiles(profiles []Profile) error {
+	for _, profile := range profiles {
+		for name, value := range profile.Values {
+			key, err := LookupKey(name)
+			if err != nil {
+				return fmt.Errorf("profile %s: %w", profile.Name, err)
+			}
+			if err := key.Validate(value); err != nil {
+				return fmt.Errorf("profile %s: %w", profile.Name, err)
+			}
+		}
+		for _, pattern := range profile.Match {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("profile %s: invalid pattern %q", profile.Name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// selectProfile returns the profile that applies given the already resolved layers.
+// An explicit PROFILE setting wins; otherwise the profile whose pattern most specifically
+// matches the origin remote is used. It returns nil if no profile applies.
+func selectProfile(layers []layer) (*ActiveProfile, error) {
+	profiles, err := LoadProfiles()
+	if err != nil || len(profiles) == 0 {
+		return nil, err
+	}
+
+	if explicit := resolveKey(layers, "PROFILE"); explicit.Set {
+		for _, profile := range profiles {
+			if profile.Name == explicit.Value.Value {
+				return &ActiveProfile{Profile: profile, Reason: "selected by PROFILE from " + explicit.Source.String()}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown profile: %s", explicit.Value.Value)
+	}
+
+	remote := originRemote()
+	if remote == "" {
+		return nil, nil
+	}
+
+	var best *ActiveProfile
+	bestLength := -1
+	for _, profile := range profiles {
+		for _, pattern := range profile.Match {
+			if matchRemote(pattern, remote) && len(pattern) > bestLength {
+				best = &ActiveProfile{
+					Profile: profile,
+					Reason:  fmt.Sprintf("matched %s via %s", remote, pattern),
+				}
+				bestLength = len(pattern)
+			}
+		}
+	}
+	return best, nil
+}
+
+// GetActiveProfile returns the profile in effect for the current repository, or nil if there is none.
+func GetActiveProfile() (*ActiveProfile, error) {
+	layers, err := loadLayers()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range layers {
+		if l.source == SourceProfile && l.profile != nil {
+			return l.profile, nil
+		}
+	}
+	return nil, nil
+}
+
+// OriginRemote returns the origin remote of the repository as "host/path", e.g. "github.com/owner/app",
+// or an empty string if there is none.
+func OriginRemote() string {
+	return originRemote()
+}
+
+// originRemote returns the normalized URL of the origin remote, or an empty string
+// if the current directory is not a repository or has no such remote.
+func originRemote() string {
+	if remoteCache == nil {
+		remote := ""
+		if rawURL, err := git.GetRemoteURL(profileRemote); err == nil {
+			remote = normalizeRemote(rawURL)
+		}
+		remoteCache = &remote
+	}
+	return *remoteCache
+}
+
+// normalizeRemote converts a remote URL to the form "host/path" without credentials,
+// port or ".git" suffix. Both URLs and scp-like addresses ("git@host:owner/repo.git") are supported.
+// The result is lower-cased. Local paths have no host and yield an empty string.
+func normalizeRemote(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+
+	var host, remotePath string
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Hostname() == "" {
+			return ""
+		}
+		host, remotePath = u.Hostname(), u.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		before, after, ok := strings.Cut(rawURL, ":")
+		if !ok || strings.Contains(before, "/") {
+			return ""
+		}
+		if _, h, ok := strings.Cut(before, "@"); ok {
+			before = h
+		}
+		host, remotePath = before, after
+	}
+
+	remotePath = strings.TrimSuffix(strings.Trim(remotePath, "/"), ".git")
+	if remotePath == "" {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(host + "/" + remotePath)
+}
+
+// matchRemote reports whether pattern matches the normalized remote.
+// Patterns are matched segment by segment with shell-style globs, and a pattern with fewer
+// segments matches any remote it is a prefix of: "*.corp.example.com" matches every repository
+// on those hosts, while "github.com/acme" matches only the acme organization.
+func matchRemote(pattern, remote string) bool {
+	patternSegments := strings.Split(strings.Trim(strings.ToLower(pattern), "/"), "/")
+	remoteSegments := strings.Split(remote, "/")
+	if len(remoteSegments) < len(patternSegments) {
+		return false
+	}
+
+	for i, segment := range patternSegments {
+		matched, err := path.Match(segment, remoteSegments[i])
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
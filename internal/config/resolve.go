@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// Source identifies a configuration layer.
+// Layers are applied in increasing order, so a later source overrides an earlier one.
+type Source int
+
+const (
+	SourceDefault Source = iota // Built-in default from the key registry
+	SourceGlobal                // Global configuration file in the home directory
+	SourceProfile               // Profile from the global configuration file
+	SourceRepo                  // Per-repository configuration file
+	SourceEnv                   // AI_COMMIT_<KEY> environment variable
+	SourceFlag                  // Command-line flag
+)
+
+// String returns a human-readable name for the source.
+func (s Source) String() string {
+	switch s {
+	case SourceGlobal:
+		return "global file"
+	case SourceProfile:
+		return "profile"
+	case SourceRepo:
+		return "repo file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	default:
+		return "default"
+	}
+}
+
+// Value is a configuration value together with where it was defined.
+type Value struct {
+	Value  string // The value as a string
+	Source Source // The layer that defined the value
+	Origin string // File path, profile name or environment variable name, empty for defaults and flags
+}
+
+// Setting describes the resolution of a single configuration key.
+type Setting struct {
+	Key      string  // Name of the configuration key
+	Value            // Effective value; the zero Value if the key is unset
+	Set      bool    // Whether any layer defines the key
+	Shadowed []Value // Values from lower layers that are overridden by the effective value, highest first
+}
+
+// layer holds the values defined by one configuration source.
+type layer struct {
+	source  Source
+	origin  string
+	values  Config
+	profile *ActiveProfile // The selected profile, for the profile layer
+}
+
+// notify receives warnings about the configuration, see SetNotify.
+var notify func(message string)
+
+// warnedRepoFiles holds the paths of the repository files whose ignored keys have been warned about.
+var warnedRepoFiles sync.Map
+
+// SetNotify makes f receive warnings about the configuration, such as the keys of the repository
+// file that are ignored, e.g. to show them to the user. A nil f stops it.
+func SetNotify(f func(message string)) {
+	notify = f
+}
+
+// flagOverrides holds values set from command-line flags for the current process.
+var flagOverrides = Config{}
+
+// SetFlag overrides key with a value given on the command line.
+// The override has the highest precedence and lasts for the rest of the process.
+// An empty value is ignored.
+func SetFlag(name, value string) error {
+	key, err := LookupKey(name)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return nil
+	}
+	if err := key.Validate(value); err != nil {
+		return err
+	}
+	flagOverrides[name] = value
+	return nil
+}
+
+// loadLayers reads every configuration source, ordered from lowest to highest precedence.
+func loadLayers() ([]layer, error) {
+	defaults := Config{}
+	for _, key := range keys {
+		if key.Default != "" {
+			defaults[key.Name] = key.Default
+		}
+	}
+
+	globalConfig, err := loadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	repoPath := GetRepoConfigPath()
+	repoConfig := Config{}
+	if repoPath != "" {
+		if repoConfig, err = readConfigFile(repoPath); err != nil {
+			return nil, err
+		}
+		dropRepoUnsafe(repoPath, repoConfig)
+	}
+
+	env := Config{}
+	for _, key := range keys {
+		if value, ok := lookupEnv(key.Name); ok {
+			env[key.Name] = value
+		}
+	}
+
+	layers := []layer{
+		{source: SourceDefault, values: defaults},
+		{source: SourceGlobal, origin: GetConfigPath(), values: globalConfig},
+		{source: SourceProfile, values: Config{}},
+		{source: SourceRepo, origin: repoPath, values: repoConfig},
+		{source: SourceEnv, values: env},
+		{source: SourceFlag, values: flagOverrides},
+	}
+
+	// Selects the profile from the other layers, then fills in its layer.
+	profile, err := selectProfile(layers)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		layers[SourceProfile] = layer{source: SourceProfile, origin: profile.Name, values: profile.Values, profile: profile}
+	}
+	return layers, nil
+}
+
+// dropRepoUnsafe removes the keys that are not RepoSafe from values, read from the repository
+// file at path, and warns about them. A cloned repository must not be able to run commands, send
+// the code or the user's tokens elsewhere, or turn on telemetry, so it only shapes the messages.
+func dropRepoUnsafe(path string, values Config) {
+	var dropped []string
+	for name := range values {
+		if !repoSafe(name) {
+			dropped = append(dropped, name)
+			delete(values, name)
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+	// The layers are loaded for every lookup, so each file is only warned about once.
+	if _, warned := warnedRepoFiles.LoadOrStore(path, true); warned {
+		return
+	}
+	slices.Sort(dropped)
+	logging.Warn("ignored keys of the repository file", "path", path, "keys", dropped)
+	if notify != nil {
+		notify(fmt.Sprintf("Warning: ignoring %s in %s; a repository file may only set the prompt, style, model, language and lint settings.",
+			strings.Join(dropped, ", "), path))
+	}
+}
+
+// repoSafe reports whether name is a key the repository may set, see Key.RepoSafe.
+func repoSafe(name string) bool {
+	key, err := LookupKey(name)
+	return err == nil && key.RepoSafe
+}
+
+// resolveKey determines the effective value of key across layers.
+// Empty values are treated as unset at every layer.
+func resolveKey(layers []layer, key string) Setting {
+	setting := Setting{Key: key}
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		value, ok := l.values[key]
+		if !ok || value == "" {
+			continue
+		}
+
+		origin := l.origin
+		if l.source == SourceEnv {
+			origin = EnvName(key)
+		}
+		v := Value{Value: value, Source: l.source, Origin: origin}
+
+		if !setting.Set {
+			setting.Value, setting.Set = v, true
+		} else {
+			setting.Shadowed = append(setting.Shadowed, v)
+		}
+	}
+	return setting
+}
+
+// Resolve returns the resolution of every configuration key in display order.
+func Resolve() ([]Setting, error) {
+	layers, err := loadLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		settings = append(settings, resolveKey(layers, key.Name))
+	}
+	return settings, nil
+}
+
+// ResolveKey returns the resolution of a single configuration key.
+func ResolveKey(name string) (Setting, error) {
+	if _, err := LookupKey(name); err != nil {
+		return Setting{}, err
+	}
+
+	layers, err := loadLayers()
+	if err != nil {
+		return Setting{}, err
+	}
+	return resolveKey(layers, name), nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"strings"
+)
+
+// IsSecret reports whether the value of key must not be displayed in full.
+func IsSecret(key string) bool {
+	k, err := LookupKey(key)
+	return err == nil && k.Secret
+}
+
+// MaskSecret hides all but the prefix and the last four characters of a secret,
+// e.g. "gsk_****abcd". Short values are masked entirely.
+func MaskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 8 {
+		return "****"
+	}
+
+	// Keeps well-known key prefixes such as "gsk_" so the kind of key stays recognizable.
+	prefix := ""
+	if i := strings.IndexAny(value, "_-"); i >= 0 && i < 5 {
+		prefix = value[:i+1]
+	}
+	return prefix + "****" + value[len(value)-4:]
+}
@@ -0,0 +1,376 @@
+// Package daemon keeps the connections to the provider open between runs of the tool. The daemon
+// listens on a Unix socket in the cache directory and forwards the API requests of the runs, which
+// then reuse a connection instead of opening one and negotiating TLS, often the slowest part of a
+// short request.
+//
+// The daemon only forwards the requests of runs with its network settings, see Transport: runs
+// whose proxy or certificates differ send their requests themselves.
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/cache"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+const (
+	// socketName is the name of the socket of the daemon in the cache directory.
+	socketName = "daemon.sock"
+	// urlHeader carries the URL a forwarded request is sent to.
+	urlHeader = "X-Ai-Commit-Url"
+	// networkHeader carries the network settings of the run, see Transport. The daemon answers
+	// requests with other settings with 421 Misdirected Request and this header.
+	networkHeader = "X-Ai-Commit-Network"
+	// errorHeader carries the error of a request the daemon could not send, which the run then sends itself.
+	errorHeader = "X-Ai-Commit-Error"
+	// watchInterval is how often the daemon checks whether the configuration file changed.
+	watchInterval = 2 * time.Second
+	// idleTimeout is how long a connection to the provider is kept open without requests.
+	idleTimeout = 15 * time.Minute
+)
+
+// ErrNotRunning is returned when there is no daemon to talk to.
+var ErrNotRunning = errors.New("the daemon is not running")
+
+// NewTransportFunc returns the transport the daemon sends requests with, and the network settings
+// it was built from, in the form passed to Transport.
+type NewTransportFunc func() (*http.Transport, string, error)
+
+// Status describes a running daemon.
+type Status struct {
+	PID      int       `json:"pid"`      // Process ID of the daemon
+	Started  time.Time `json:"started"`  // When the daemon started
+	Requests int64     `json:"requests"` // Number of requests forwarded
+	Reloads  int64     `json:"reloads"`  // Number of times the configuration was reloaded
+}
+
+// SocketPath returns the path of the socket of the daemon.
+func SocketPath() string {
+	return filepath.Join(cache.Dir(), socketName)
+}
+
+// server is a running daemon.
+type server struct {
+	newTransport NewTransportFunc
+	status       Status
+
+	mu        sync.Mutex
+	transport *http.Transport // Transport of the forwarded requests, replaced when the configuration changes
+	network   string          // Network settings of transport
+	reloads   atomic.Int64
+	requests  atomic.Int64
+}
+
+// Serve runs the daemon until ctx is done or it is asked to stop. The transport is rebuilt with
+// newTransport whenever the file at watch, the configuration file, changes.
+func Serve(ctx context.Context, newTransport NewTransportFunc, watch string) error {
+	if _, err := GetStatus(); err == nil {
+		return errors.New("the daemon is already running")
+	}
+	socket := SocketPath()
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(socket), err)
+	}
+	// Replaces the socket of a daemon that did not stop cleanly.
+	if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(socket)
+	}
+
+	s := &server{newTransport: newTransport, status: Status{PID: os.Getpid(), Started: time.Now()}}
+	if err := s.reload(); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/forward", s.forward)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("POST /stop", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+		stop()
+	})
+	httpServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+	go s.watch(ctx, watch)
+
+	logging.Info("daemon started", "socket", socket, "pid", s.status.PID)
+	if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// reload rebuilds the transport, closing the idle connections of the previous one.
+func (s *server) reload() error {
+	transport, network, err := s.newTransport()
+	if err != nil {
+		return err
+	}
+	transport.IdleConnTimeout = idleTimeout
+
+	s.mu.Lock()
+	previous := s.transport
+	s.transport, s.network = transport, network
+	s.mu.Unlock()
+	if previous != nil {
+		previous.CloseIdleConnections()
+		s.reloads.Add(1)
+		logging.Info("daemon reloaded the configuration")
+	}
+	return nil
+}
+
+// watch reloads the configuration whenever the file at path changes, until ctx is done. A
+// configuration that cannot be loaded is logged, and the previous one kept.
+func (s *server) watch(ctx context.Context, path string) {
+	stamp := func() string {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprint(info.ModTime().UnixNano(), info.Size())
+	}
+	last := stamp()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if current := stamp(); current != last {
+			last = current
+			if err := s.reload(); err != nil {
+				logging.Warn("daemon kept the previous configuration", "error", err)
+			}
+		}
+	}
+}
+
+// forward sends the request to the URL in its urlHeader and copies the response back as it arrives,
+// so that streamed responses stay streamed.
+func (s *server) forward(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	transport, network := s.transport, s.network
+	s.mu.Unlock()
+	if r.Header.Get(networkHeader) != network {
+		w.Header().Set(networkHeader, "mismatch")
+		w.WriteHeader(http.StatusMisdirectedRequest)
+		return
+	}
+
+	out, err := http.NewRequestWithContext(r.Context(), r.Method, r.Header.Get(urlHeader), r.Body)
+	if err != nil || (out.URL.Scheme != "http" && out.URL.Scheme != "https") {
+		w.Header().Set(errorHeader, fmt.Sprintf("invalid URL %q", r.Header.Get(urlHeader)))
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	out.Header = r.Header.Clone()
+	for _, name := range []string{urlHeader, networkHeader, "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		out.Header.Del(name)
+	}
+	out.ContentLength = r.ContentLength
+
+	s.requests.Add(1)
+	start := time.Now()
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		w.Header().Set(errorHeader, err.Error())
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	logging.Info("daemon forwarded a request", "url", out.URL.Redacted(), "status", resp.StatusCode, "elapsed", time.Since(start).Round(time.Millisecond))
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handleStatus answers with the Status of the daemon.
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.status
+	status.Requests, status.Reloads = s.requests.Load(), s.reloads.Load()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetStatus returns the status of the running daemon, or ErrNotRunning.
+func GetStatus() (Status, error) {
+	var status Status
+	resp, err := control(http.MethodGet, "/status")
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, fmt.Errorf("invalid status of the daemon: %w", err)
+	}
+	return status, nil
+}
+
+// Stop asks the running daemon to stop once the requests it is forwarding are done.
+func Stop() error {
+	resp, err := control(http.MethodPost, "/stop")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// control sends a request without body to the daemon.
+func control(method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, "http://daemon"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: socketTransport(), Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	var dialErr *dialError
+	if errors.As(err, &dialErr) {
+		return nil, ErrNotRunning
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the daemon: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("the daemon answered %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// dialError is returned when the socket of the daemon cannot be connected to, i.e. it is not running.
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string { return e.err.Error() }
+func (e *dialError) Unwrap() error { return e.err }
+
+// socketTransport returns a transport sending every request to the socket of the daemon.
+func socketTransport() *http.Transport {
+	socket := SocketPath()
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "unix", socket)
+			if err != nil {
+				return nil, &dialError{err: err}
+			}
+			return conn, nil
+		},
+	}
+}
+
+// Transport returns a transport sending requests through the daemon if it runs with the network
+// settings identified by network, and with direct otherwise.
+func Transport(network string, direct http.RoundTripper) http.RoundTripper {
+	return &clientTransport{network: network, direct: direct, socket: socketTransport()}
+}
+
+// clientTransport is the transport returned by Transport.
+type clientTransport struct {
+	network string
+	direct  http.RoundTripper
+	socket  *http.Transport
+}
+
+func (t *clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Keeps the body, so that the request can still be sent directly if the daemon cannot forward it.
+	if req.Body != nil && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+
+	out := req.Clone(req.Context())
+	out.URL = &url.URL{Scheme: "http", Host: "daemon", Path: "/forward"}
+	out.Host = "daemon"
+	out.Header.Set(urlHeader, req.URL.String())
+	out.Header.Set(networkHeader, t.network)
+	resp, err := t.socket.RoundTrip(out)
+
+	var dialErr *dialError
+	switch {
+	case errors.As(err, &dialErr):
+		return t.sendDirectly(req, "not running")
+	case err != nil:
+		return nil, err
+	case resp.StatusCode == http.StatusMisdirectedRequest && resp.Header.Get(networkHeader) != "":
+		resp.Body.Close()
+		return t.sendDirectly(req, "other network settings")
+	case resp.StatusCode == http.StatusBadGateway && resp.Header.Get(errorHeader) != "":
+		// Sends the request again directly, which fails with the error of the network, which is
+		// told apart from errors of the provider, e.g. to write messages offline.
+		resp.Body.Close()
+		return t.sendDirectly(req, resp.Header.Get(errorHeader))
+	}
+	logging.Debug("sent the request through the daemon", "url", req.URL.Redacted())
+	return resp, nil
+}
+
+// sendDirectly sends req without the daemon, which could not forward it for reason.
+func (t *clientTransport) sendDirectly(req *http.Request, reason string) (*http.Response, error) {
+	logging.Debug("sending the request without the daemon", "reason", reason)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.direct.RoundTrip(req)
+}
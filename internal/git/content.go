@@ -0,0 +1,133 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxOutlineLines limits the number of declarations listed for a summarized file.
+const maxOutlineLines = 60
+
+// outlinePattern matches lines that show the structure of a file: declarations in common languages
+// and Markdown headings, indented by at most one level.
+var outlinePattern = regexp.MustCompile(`^(?: {0,4}|\t)(?:func|type|class|def|async def|interface|struct|enum|impl|trait|fn|pub|export|module|package|var|const|let|public|private|protected)\b|^#{1,6} `)
+
+// ContentOptions controls how the contents of new files are included in a diff.
+type ContentOptions struct {
+	MaxNewFileLines int // New files with more lines are summarized; 0 includes every file whole
+	PreviewLines    int // Number of lines shown at the start of a summarized file
+}
+
+// SummarizeNewFiles replaces the contents of large new files in diff by their first lines and an outline
+// of their declarations, so that a generated or vendored file does not crowd out the rest of the change.
+// Modified and deleted files are left unchanged.
+func SummarizeNewFiles(diff string, opts ContentOptions) string {
+	if opts.MaxNewFileLines <= 0 || diff == "" {
+		return diff
+	}
+
+	sections := splitDiff(diff)
+	for i, section := range sections {
+		sections[i] = summarizeSection(section, opts)
+	}
+	return strings.Join(sections, "")
+}
+
+// SummarizeRenames replaces the diffs of files that were renamed or copied without changes
+// by a single line such as "renamed old.go → new.go".
+func SummarizeRenames(diff string) string {
+	if diff == "" {
+		return diff
+	}
+	sections := splitDiff(diff)
+	for i, section := range sections {
+		if summary := renameSummary(section); summary != "" {
+			sections[i] = summary + "\n"
+		}
+	}
+	return strings.Join(sections, "")
+}
+
+// renameSummary describes the diff of a single file if it is a pure rename or copy,
+// or returns an empty string otherwise. Renames that also change the content or the mode are kept.
+func renameSummary(section string) string {
+	var verb, from, to string
+	for _, line := range strings.Split(strings.TrimSuffix(section, "\n"), "\n")[1:] {
+		switch {
+		case line == "similarity index 100%":
+		case strings.HasPrefix(line, "rename from "):
+			verb, from = "renamed", unquotePath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			to = unquotePath(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "copy from "):
+			verb, from = "copied", unquotePath(strings.TrimPrefix(line, "copy from "))
+		case strings.HasPrefix(line, "copy to "):
+			to = unquotePath(strings.TrimPrefix(line, "copy to "))
+		default:
+			return ""
+		}
+	}
+	if verb == "" || to == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s %s → %s", verb, from, to)
+}
+
+// splitDiff splits a diff into one section per file, each starting with its "diff --git" line.
+func splitDiff(diff string) []string {
+	var sections []string
+	start := 0
+	for {
+		next := strings.Index(diff[start+1:], "\ndiff --git ")
+		if next < 0 {
+			return append(sections, diff[start:])
+		}
+		end := start + 1 + next + 1
+		sections = append(sections, diff[start:end])
+		start = end
+	}
+}
+
+// summarizeSection summarizes the diff of a single file if it adds a new file with too many lines.
+func summarizeSection(section string, opts ContentOptions) string {
+	header, body, found := strings.Cut(section, "\n@@")
+	if !found || !strings.Contains(header, "\nnew file mode") {
+		return section
+	}
+
+	// The body starts with the rest of the hunk header, followed by the added lines.
+	hunkHeader, content, _ := strings.Cut(body, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if len(lines) <= opts.MaxNewFileLines {
+		return section
+	}
+
+	preview := min(opts.PreviewLines, len(lines))
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n@@%s\n", header, hunkHeader)
+	for _, line := range lines[:preview] {
+		b.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&b, "[New file with %d lines; only the first %d are shown.]\n", len(lines), preview)
+
+	// Lists the declarations of the lines that are not shown, which tell what the file is about.
+	var outline []string
+	for _, line := range lines[preview:] {
+		code := strings.TrimPrefix(line, "+")
+		if strings.TrimSpace(code) != "" && outlinePattern.MatchString(code) {
+			outline = append(outline, strings.TrimRight(code, " {"))
+		}
+	}
+	if len(outline) > 0 {
+		b.WriteString("[Outline of the remaining lines:]\n")
+		for i, line := range outline {
+			if i == maxOutlineLines {
+				fmt.Fprintf(&b, "[... and %d more]\n", len(outline)-maxOutlineLines)
+				break
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+package git
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FakeRepo is a GitRepo held in memory, for tests of the code above this package. Its changes are
+// diffs in the format of git diff, which Commit moves from Staged to Head. Err, if set, is returned
+// by every method instead, e.g. to test the handling of a failing git binary.
+type FakeRepo struct {
+	Dir       string            // Top-level directory of the working tree; "" makes AssertRepo fail like outside a repository
+	Staged    string            // Diff of the staged changes
+	Unstaged  string            // Diff of the changes that are not staged, which EnsureStaged and StageAll stage
+	Untracked string            // Diff adding the new files that are not staged, which StageAll stages with untracked
+	Head      string            // Diff of the last commit, part of the changes of an amended commit
+	Branch    string            // Checked-out branch, "" for a detached HEAD
+	Subjects  []string          // Subjects of the commits, newest first
+	BaseStats map[string]string // Diffstats of HEAD compared to base branches, by base
+	Template  string            // Contents of the commit template, "" if there is none
+	Err       error             // Error every method returns if it is not nil
+
+	Commits []FakeCommit // Commits made with Commit, oldest first
+	Pushes  int          // Number of calls of Push
+}
+
+// FakeCommit is a commit made with FakeRepo.Commit.
+type FakeCommit struct {
+	Message string        // The message of the commit
+	Options CommitOptions // The options it was made with
+	Diff    string        // The changes it made, those of the amended commit included
+}
+
+// AssertRepo returns ErrNotGitRepo if Dir is not set.
+func (r *FakeRepo) AssertRepo() error {
+	if r.Err != nil {
+		return r.Err
+	}
+	if r.Dir == "" {
+		return ErrNotGitRepo
+	}
+	return nil
+}
+
+// Root returns Dir.
+func (r *FakeRepo) Root() (string, error) {
+	if err := r.AssertRepo(); err != nil {
+		return "", err
+	}
+	return r.Dir, nil
+}
+
+// StagedFiles returns the files of Staged.
+func (r *FakeRepo) StagedFiles() ([]string, error) {
+	return DiffFiles(r.Staged), r.Err
+}
+
+// Diff returns the parts of Staged that change files.
+func (r *FakeRepo) Diff(files []string) (string, error) {
+	return FilterDiff(r.Staged, files), r.Err
+}
+
+// AmendFiles returns the files of Head and Staged.
+func (r *FakeRepo) AmendFiles() ([]string, error) {
+	if len(r.Subjects) == 0 && r.Err == nil {
+		return nil, ErrNoCommits
+	}
+	return DiffFiles(joinDiffs(r.Head, r.Staged)), r.Err
+}
+
+// AmendDiff returns the parts of Head and Staged that change files, one after the other rather
+// than combined as by git.
+func (r *FakeRepo) AmendDiff(files []string) (string, error) {
+	return FilterDiff(joinDiffs(r.Head, r.Staged), files), r.Err
+}
+
+// ChangedFiles returns the files of Staged and Unstaged as modified, and those of Untracked as untracked.
+func (r *FakeRepo) ChangedFiles() ([]FileStatus, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	var files []FileStatus
+	for _, file := range DiffFiles(joinDiffs(r.Staged, r.Unstaged)) {
+		files = append(files, FileStatus{Path: file, Status: translateStatus("M")})
+	}
+	for _, file := range DiffFiles(r.Untracked) {
+		files = append(files, FileStatus{Path: file, Status: translateStatus("??")})
+	}
+	return files, nil
+}
+
+// EnsureStaged stages Unstaged without asking if nothing is staged.
+func (r *FakeRepo) EnsureStaged() error {
+	switch {
+	case r.Err != nil:
+		return r.Err
+	case r.Staged != "":
+		return nil
+	case r.Unstaged == "":
+		return ErrNoChanges
+	}
+	r.Staged, r.Unstaged = r.Unstaged, ""
+	return nil
+}
+
+// StageAll stages Unstaged, and Untracked with untracked.
+func (r *FakeRepo) StageAll(untracked bool) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.Staged, r.Unstaged = joinDiffs(r.Staged, r.Unstaged), ""
+	if untracked {
+		r.Staged, r.Untracked = joinDiffs(r.Staged, r.Untracked), ""
+	}
+	return nil
+}
+
+// StageFiles moves the parts of Unstaged and Untracked that change files to Staged.
+func (r *FakeRepo) StageFiles(files []string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.Staged = joinDiffs(r.Staged, FilterDiff(r.Unstaged, files), FilterDiff(r.Untracked, files))
+	r.Unstaged = FilterDiff(r.Unstaged, otherFiles(r.Unstaged, files))
+	r.Untracked = FilterDiff(r.Untracked, otherFiles(r.Untracked, files))
+	return nil
+}
+
+// CurrentBranch returns Branch.
+func (r *FakeRepo) CurrentBranch() (string, error) {
+	return r.Branch, r.Err
+}
+
+// RecentSubjects returns up to n of Subjects after the first skip.
+func (r *FakeRepo) RecentSubjects(n, skip int) ([]string, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	subjects := r.Subjects[min(skip, len(r.Subjects)):]
+	return slices.Clone(subjects[:min(n, len(subjects))]), nil
+}
+
+// BranchDiffStat returns the diffstat of BaseStats for base, and an error for an unknown base.
+func (r *FakeRepo) BranchDiffStat(base string) (string, error) {
+	if r.Err != nil {
+		return "", r.Err
+	}
+	stat, ok := r.BaseStats[base]
+	if !ok {
+		return "", fmt.Errorf("cannot compare HEAD with %s", base)
+	}
+	return stat, nil
+}
+
+// CommitTemplate returns Template.
+func (r *FakeRepo) CommitTemplate() (string, error) {
+	return r.Template, r.Err
+}
+
+// TemplateCoAuthors returns the co-authors listed in Template.
+func (r *FakeRepo) TemplateCoAuthors() ([]string, error) {
+	return templateCoAuthors(r.Template), r.Err
+}
+
+// Commit records a commit of Staged in Commits, and adds its subject to Subjects, or with Amend,
+// replaces the last commit with one of Head and Staged. Like git, it fails without changes unless
+// AllowEmpty is set, or with Amend without a commit.
+func (r *FakeRepo) Commit(message string, opts CommitOptions) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	diff := r.Staged
+	if opts.Amend {
+		if len(r.Subjects) == 0 {
+			return ErrNoCommits
+		}
+		diff = joinDiffs(r.Head, r.Staged)
+		r.Subjects = r.Subjects[1:]
+	} else if diff == "" && !opts.AllowEmpty {
+		return ErrNoChanges
+	}
+	subject, _, _ := strings.Cut(message, "\n")
+	r.Subjects = append([]string{subject}, r.Subjects...)
+	r.Commits = append(r.Commits, FakeCommit{Message: message, Options: opts, Diff: diff})
+	r.Head, r.Staged = diff, ""
+	return nil
+}
+
+// Push counts the call in Pushes. It returns ErrDetachedHead without a Branch.
+func (r *FakeRepo) Push() error {
+	if r.Err != nil {
+		return r.Err
+	}
+	if r.Branch == "" {
+		return ErrDetachedHead
+	}
+	r.Pushes++
+	return nil
+}
+
+// otherFiles returns the files of diff that are not in files.
+func otherFiles(diff string, files []string) []string {
+	var others []string
+	for _, file := range DiffFiles(diff) {
+		if !slices.Contains(files, file) {
+			others = append(others, file)
+		}
+	}
+	return others
+}
+
+// joinDiffs returns the diffs one after the other.
+func joinDiffs(diffs ...string) string {
+	var parts []string
+	for _, diff := range diffs {
+		if diff = strings.TrimRight(diff, "\n"); diff != "" {
+			parts = append(parts, diff)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
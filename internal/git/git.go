@@ -1,26 +1,126 @@
 package git
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/ui"
 )
 
 // FileStatus represents a file's path and its current Git status.
 type FileStatus struct {
-	Path   string // Path of the file
-	Status string // Current Git status of the file
+	Path     string // Path of the file
+	OrigPath string // Path the file was renamed or copied from, if any
+	Status   string // Current Git status of the file
 }
 
-// ErrNotGitRepo is returned when the current directory is not a Git repository.
-var ErrNotGitRepo = errors.New("not a Git repository")
+// CommitOptions holds optional settings for GitCommit.
+type CommitOptions struct {
+	Amend         bool // Replace the last commit instead of creating a new one
+	Only          bool // With Amend, change only the message and leave staged changes out (--only)
+	GPGSign       bool // Sign the commit with GPG (-S)
+	Signoff       bool // Add a Signed-off-by trailer (-s)
+	NoVerify      bool // Skip the pre-commit and commit-msg hooks (--no-verify)
+	NoPostRewrite bool // With Amend, skip the post-rewrite hook (--no-post-rewrite)
+	AllowEmpty    bool // Commit even if nothing is staged, e.g. to mark an event (--allow-empty)
+}
+
+var (
+	// ErrNotGitRepo is returned when the current directory is not a Git repository.
+	ErrNotGitRepo = errors.New("not a Git repository")
+	// ErrNoCommits is returned when amending in a repository without commits.
+	ErrNoCommits = errors.New("there is no commit to amend")
+	// ErrNoChanges is returned when there are no changes to describe or stage.
+	ErrNoChanges = errors.New("no changes detected")
+	// ErrNothingStaged is returned when the user chooses not to stage any of the changes.
+	ErrNothingStaged = errors.New("no staged files")
+)
+
+var (
+	// workDir is the directory given with -C, or empty for the current directory.
+	workDir string
+	// topLevel caches the directory Git commands run in, see commandDir.
+	topLevel *string
+	// quiet suppresses the progress messages printed while staging, see SetQuiet.
+	quiet bool
+)
+
+// SetQuiet suppresses the messages that only report progress, e.g. for a quiet mode.
+// Questions are still asked.
+func SetQuiet(q bool) {
+	quiet = q
+}
+
+// SetWorkDir makes every Git command run in dir instead of the current directory, like "git -C dir".
+func SetWorkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("cannot change to %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("cannot change to %s: not a directory", dir)
+	}
+	if workDir, err = filepath.Abs(dir); err != nil {
+		return fmt.Errorf("cannot change to %s: %w", dir, err)
+	}
+	topLevel = nil
+	return nil
+}
+
+// WorkDir returns the directory set with SetWorkDir, or the current directory.
+func WorkDir() (string, error) {
+	if workDir != "" {
+		return workDir, nil
+	}
+	return os.Getwd()
+}
+
+// commandDir returns the directory Git commands run in: the top level of the working tree that contains
+// the work directory, which is the linked worktree itself for linked worktrees. Git reports file paths
+// relative to the top level, so running there lets them be passed back as pathspecs.
+// Outside a working tree, it returns the work directory.
+func commandDir() string {
+	if topLevel == nil {
+		dir := workDir
+		cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+		cmd.Dir = workDir
+		if output, err := cmd.Output(); err == nil {
+			dir = strings.TrimSpace(string(output))
+		}
+		topLevel = &dir
+	}
+	return *topLevel
+}
+
+// newCommand creates a command that runs in commandDir, and records it at the info level.
+// Git prints non-ASCII characters in paths as they are instead of as octal escapes.
+func newCommand(name string, args ...string) *exec.Cmd {
+	if name == "git" {
+		args = append([]string{"-c", "core.quotePath=false"}, args...)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Dir = commandDir()
+	logging.Info("running command", "command", name+" "+strings.Join(args, " "))
+	return cmd
+}
 
 // AssertGitRepo checks if the current directory is a Git repository.
 // It returns an error if the directory is not a Git repository.
 func AssertGitRepo() error {
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	if useGoGit {
+		if _, err := openRepository(); !fallBack("open the repository", err) {
+			if err != nil {
+				return ErrNotGitRepo
+			}
+			return nil
+		}
+	}
+	cmd := newCommand("git", "rev-parse", "--is-inside-work-tree")
 	if err := cmd.Run(); err != nil {
 		return ErrNotGitRepo
 	}
@@ -29,56 +129,229 @@ func AssertGitRepo() error {
 
 // GetStagedFiles returns a slice of staged file names.
 // It executes the Git command to get the names of files that are staged for commit.
+// Renamed files are listed under their old and new name, so that a diff of the files detects the rename.
 func GetStagedFiles() ([]string, error) {
-	output, err := execGitCommand("git", "diff", "--name-only", "--cached")
+	if useGoGit {
+		if files, err := goGitStagedFiles(); !fallBack("list the staged files", err) {
+			return files, err
+		}
+	}
+	output, err := execGitCommandRaw("git", "diff", "--name-only", "-z", "--cached", "--no-renames")
 	if err != nil {
 		return nil, err
 	}
-	return filterEmptyStrings(strings.Split(output, "\n")), nil
+	return splitPaths(output), nil
 }
 
 // GetChangedFiles returns a slice of FileStatus for all files with changes.
 // It executes the Git status command and parses the output to retrieve changed files and their statuses.
 func GetChangedFiles() ([]FileStatus, error) {
-	output, err := execGitCommand("git", "status", "--porcelain")
+	if useGoGit {
+		if files, err := goGitChangedFiles(); !fallBack("list the changed files", err) {
+			return files, err
+		}
+	}
+	output, err := execGitCommandRaw("git", "status", "--porcelain=v2", "-z")
 	if err != nil {
 		return nil, fmt.Errorf("error getting git status: %w", err)
 	}
+	return parseStatus(output)
+}
 
-	var changedFiles []FileStatus
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) < 4 {
-			continue
+// GetDiff returns the diff of the provided list of files.
+// It runs the Git diff command for the specified files and returns the output,
+// describing renamed and copied files as such instead of as a deletion and an addition.
+func GetDiff(files []string) (string, error) {
+	if useGoGit {
+		if diff, err := goGitDiff(files); !fallBack("diff the staged files", err) {
+			return diff, err
 		}
-		status := strings.TrimSpace(line[:2])
-		file := strings.TrimSpace(line[3:])
-		changedFiles = append(changedFiles, FileStatus{
-			Path:   file,
-			Status: translateStatus(status),
-		})
 	}
+	args := append([]string{"diff", "--staged", "--find-renames", "--find-copies", "--"}, files...)
+	return execGitCommand("git", args...)
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning git status output: %w", err)
+// GetAmendFiles returns the files changed by the last commit together with the currently staged files,
+// i.e. the files an amended commit would change.
+func GetAmendFiles() ([]string, error) {
+	base, err := amendBase()
+	if err != nil {
+		return nil, err
 	}
-
-	return changedFiles, nil
+	output, err := execGitCommandRaw("git", "diff", "--name-only", "-z", "--cached", "--no-renames", base)
+	if err != nil {
+		return nil, err
+	}
+	return splitPaths(output), nil
 }
 
-// GetDiff returns the diff of the provided list of files.
-// It runs the Git diff command for the specified files and returns the output.
-func GetDiff(files []string) (string, error) {
-	args := append([]string{"diff", "--staged", "--"}, files...)
+// GetAmendDiff returns the diff an amended commit would introduce for the provided files:
+// the changes of the last commit combined with the staged changes.
+func GetAmendDiff(files []string) (string, error) {
+	base, err := amendBase()
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"diff", "--cached", "--find-renames", "--find-copies", base, "--"}, files...)
 	return execGitCommand("git", args...)
 }
 
-// GitCommit creates a new Git commit with the provided message.
-// It runs the Git commit command with the specified commit message.
-func GitCommit(message string) error {
-	_, err := execGitCommand("git", "commit", "-m", message)
-	return err
+// amendBase returns the revision an amended commit is compared against: the parent of HEAD,
+// or the empty tree if HEAD is a root commit.
+func amendBase() (string, error) {
+	if !HasCommits() {
+		return "", ErrNoCommits
+	}
+	if parent, err := execGitCommand("git", "rev-parse", "--verify", "--quiet", "HEAD^"); err == nil {
+		return parent, nil
+	}
+
+	// Hashes the empty tree so both SHA-1 and SHA-256 repositories are supported.
+	emptyTree, err := execGitCommandInput("", "git", "hash-object", "-t", "tree", "--stdin")
+	if err != nil {
+		return "", fmt.Errorf("error getting the empty tree: %w", err)
+	}
+	return emptyTree, nil
+}
+
+// GetCurrentBranch returns the short name of the checked-out branch,
+// or an empty string when HEAD is detached.
+func GetCurrentBranch() (string, error) {
+	if err := AssertGitRepo(); err != nil {
+		return "", err
+	}
+	if useGoGit {
+		if branch, err := goGitCurrentBranch(); !fallBack("read the current branch", err) {
+			return branch, err
+		}
+	}
+	// symbolic-ref fails for a detached HEAD, which is not an error here.
+	branch, err := execGitCommand("git", "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "", nil
+	}
+	return branch, nil
+}
+
+// GetBranchDiffStat returns the diffstat of the commits on HEAD that are not on base ("base...HEAD").
+func GetBranchDiffStat(base string) (string, error) {
+	output, err := execGitCommand("git", "diff", "--stat", base+"...HEAD")
+	if err != nil {
+		return "", fmt.Errorf("cannot compare HEAD with %s; check that the branch exists and shares history with HEAD: %w", base, err)
+	}
+	return output, nil
+}
+
+// GetRecentSubjects returns the subjects of up to n of the most recent non-merge commits on HEAD,
+// newest first, after skipping the first skip commits. A repository without commits yields none.
+func GetRecentSubjects(n, skip int) ([]string, error) {
+	if useGoGit {
+		if subjects, err := goGitRecentSubjects(n, skip); !fallBack("read the recent commits", err) {
+			return subjects, err
+		}
+	}
+	if !HasCommits() {
+		return nil, nil
+	}
+	output, err := execGitCommand("git", "log", "--no-merges", "--format=%s", fmt.Sprintf("--max-count=%d", n), fmt.Sprintf("--skip=%d", skip))
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit history: %w", err)
+	}
+	return filterEmptyStrings(strings.Split(output, "\n")), nil
+}
+
+// HasCommits reports whether HEAD points to a commit, which is not the case in a new repository.
+func HasCommits() bool {
+	if useGoGit {
+		if hasCommits, err := goGitHasCommits(); !fallBack("read HEAD", err) {
+			return hasCommits
+		}
+	}
+	_, err := execGitCommand("git", "rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
+
+// GetRepoRoot returns the absolute path of the top-level directory of the working tree.
+func GetRepoRoot() (string, error) {
+	if useGoGit {
+		if root, err := goGitRepoRoot(); !fallBack("find the repository root", err) {
+			if err != nil {
+				return "", ErrNotGitRepo
+			}
+			return root, nil
+		}
+	}
+	root, err := execGitCommand("git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", ErrNotGitRepo
+	}
+	return root, nil
+}
+
+// GetHooksDir returns the directory Git runs hooks from, honoring core.hooksPath.
+func GetHooksDir() (string, error) {
+	dir, err := execGitCommand("git", "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", ErrNotGitRepo
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(commandDir(), dir)
+	}
+	return dir, nil
+}
+
+// GetRemoteURL returns the URL of the named remote, such as "origin".
+func GetRemoteURL(name string) (string, error) {
+	return execGitCommand("git", "remote", "get-url", name)
+}
+
+// GitCommit creates a new Git commit with the provided message, or amends the last one,
+// passing the signing and hook options in opts on to Git.
+// The message is passed on standard input ("git commit -F -") so that a subject
+// and body spanning several lines are committed exactly as given.
+func GitCommit(message string, opts CommitOptions) error {
+	// Only commits with go-git without a git binary, since go-git runs no hooks and cannot sign.
+	if !hasGitBinary() {
+		return goGitCommit(message, opts)
+	}
+
+	args := []string{"commit", "-F", "-"}
+	if opts.Amend {
+		args = append(args, "--amend")
+	}
+	if opts.Only {
+		args = append(args, "--only")
+	}
+	if opts.GPGSign {
+		args = append(args, "--gpg-sign")
+	}
+	if opts.Signoff {
+		args = append(args, "--signoff")
+	}
+	if opts.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	if opts.NoPostRewrite {
+		args = append(args, "--no-post-rewrite")
+	}
+	if opts.AllowEmpty {
+		args = append(args, "--allow-empty")
+	}
+
+	// Shows the output of hooks and of the signing program, which explains most failures.
+	// The reflog entry is marked so that UndoCommit can tell the commit was created by the tool.
+	action := ReflogAction
+	if opts.Amend {
+		action += " amend"
+	}
+	cmd := newCommand("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_REFLOG_ACTION="+action)
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git commit failed: %w", err)
+	}
+	return nil
 }
 
 // EnsureFilesAreStaged checks if there are any staged files and prompts to stage if necessary.
@@ -94,32 +367,88 @@ func EnsureFilesAreStaged() error {
 			return err
 		}
 		if len(changedFiles) == 0 {
-			return errors.New("no changes detected")
+			return ErrNoChanges
+		}
+
+		// Lets the user pick the files to stage in a terminal, and asks to stage everything otherwise.
+		if ui.IsInteractive() {
+			return pickFilesToStage(changedFiles)
 		}
 
-		fmt.Println("The following files have changes:")
-		for _, file := range changedFiles {
-			fmt.Printf("%s: %s\n", file.Status, file.Path)
+		if !quiet {
+			fmt.Println("The following files have changes:")
+			for _, file := range changedFiles {
+				fmt.Printf("%s: %s\n", file.Status, file.Path)
+			}
 		}
 
 		if !promptYesNo("Do you want to stage all these changes?") {
-			return errors.New("no staged files")
+			return ErrNothingStaged
 		}
 
 		if _, err := execGitCommand("git", "add", "."); err != nil {
 			return fmt.Errorf("error staging files: %w", err)
 		}
-		fmt.Println("Changes staged successfully.")
+		if !quiet {
+			fmt.Println("Changes staged successfully.")
+		}
 	}
 	return nil
 }
 
 // Helper functions
 
+// pickFilesToStage shows the changed files as a checklist and stages the selected ones.
+func pickFilesToStage(changedFiles []FileStatus) error {
+	options := make([]string, len(changedFiles))
+	for i, file := range changedFiles {
+		options[i] = fmt.Sprintf("%-10s %s", file.Status, file.Path)
+	}
+
+	indexes, err := ui.MultiSelect("Select the files to stage:", options)
+	if errors.Is(err, ui.ErrCanceled) || (err == nil && len(indexes) == 0) {
+		return ErrNothingStaged
+	}
+	if err != nil {
+		return err
+	}
+
+	// Stages additions, modifications and deletions of the selected paths alike,
+	// including the original path of a rename so that the rename is staged whole.
+	var files []string
+	for _, i := range indexes {
+		files = append(files, changedFiles[i].Path)
+		if changedFiles[i].OrigPath != "" {
+			files = append(files, changedFiles[i].OrigPath)
+		}
+	}
+	if err := StageFiles(files); err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Printf("Staged %d file(s).\n", len(indexes))
+	}
+	return nil
+}
+
 // execGitCommand executes a Git command and returns its output as a string.
 // It captures any error that occurs during command execution.
 func execGitCommand(name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
+	cmd := newCommand(name, args...)
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// execGitCommandRaw executes a Git command and returns its output unmodified.
+func execGitCommandRaw(name string, args ...string) (string, error) {
+	output, err := newCommand(name, args...).Output()
+	return string(output), err
+}
+
+// execGitCommandInput executes a Git command with input on its standard input and returns its output as a string.
+func execGitCommandInput(input, name string, args ...string) (string, error) {
+	cmd := newCommand(name, args...)
+	cmd.Stdin = strings.NewReader(input)
 	output, err := cmd.Output()
 	return strings.TrimSpace(string(output)), err
 }
@@ -0,0 +1,498 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/binary"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+const (
+	// BackendGoGit reads the repository with the go-git library, which needs no git binary.
+	// Operations go-git cannot perform fall back to the git binary if there is one.
+	BackendGoGit = "go-git"
+	// BackendExec runs the git binary for every operation.
+	BackendExec = "exec"
+)
+
+// errUnsupported is returned by the go-git implementations for repositories they cannot handle.
+var errUnsupported = errors.New("not supported by go-git")
+
+// indexLinePattern matches the object names in the "index" line of a file diff, which go-git writes in full.
+var indexLinePattern = regexp.MustCompile(`(?m)^(index [0-9a-f]{7})[0-9a-f]{33}\.\.([0-9a-f]{7})[0-9a-f]{33}`)
+
+var (
+	// useGoGit selects the go-git implementations of the operations that have one.
+	useGoGit = true
+	// gitBinary caches whether a git binary is available, see hasGitBinary.
+	gitBinary = sync.OnceValue(func() bool {
+		_, err := exec.LookPath("git")
+		return err == nil
+	})
+)
+
+// SetBackend selects how the repository is read: BackendGoGit or BackendExec.
+func SetBackend(backend string) error {
+	switch backend {
+	case BackendGoGit:
+		useGoGit = true
+	case BackendExec:
+		useGoGit = false
+	default:
+		return fmt.Errorf("unknown git backend: %s", backend)
+	}
+	return nil
+}
+
+// hasGitBinary reports whether a git binary is on PATH, which the exec implementations need.
+func hasGitBinary() bool {
+	return gitBinary()
+}
+
+// fallBack reports whether a go-git operation that failed with err is done with the git binary
+// instead, which needs one, and records why.
+func fallBack(operation string, err error) bool {
+	if err == nil || !hasGitBinary() {
+		return false
+	}
+	logging.Debug("go-git cannot "+operation+", running git instead", "error", err)
+	return true
+}
+
+// openRepository opens the repository containing the work directory, including linked worktrees.
+func openRepository() (*gogit.Repository, error) {
+	dir, err := WorkDir()
+	if err != nil {
+		return nil, err
+	}
+	return gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+}
+
+// goGitStatus returns the status of the working tree.
+func goGitStatus() (gogit.Status, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	return worktree.Status()
+}
+
+// goGitHeadTree returns the tree of the HEAD commit, or nil if there are no commits yet.
+func goGitHeadTree(repo *gogit.Repository) (*object.Tree, error) {
+	head, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// goGitStagedFiles is the go-git implementation of GetStagedFiles.
+// It compares the index with the HEAD tree, without reading the working tree.
+func goGitStagedFiles() ([]string, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	index, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := goGitHeadTree(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lists the files whose staged version differs from HEAD, or that are not in HEAD.
+	staged := map[string]bool{}
+	inIndex := make(map[string]bool, len(index.Entries))
+	for _, entry := range index.Entries {
+		inIndex[entry.Name] = true
+		// Files added with "git add -N" are in the index, but git does not consider them staged.
+		if entry.IntentToAdd {
+			continue
+		}
+		if tree != nil {
+			if file, err := tree.FindEntry(entry.Name); err == nil && file.Hash == entry.Hash && file.Mode == entry.Mode {
+				continue
+			}
+		}
+		staged[entry.Name] = true
+	}
+
+	// Lists the files of HEAD that were removed from the index.
+	if tree != nil {
+		walker := object.NewTreeWalker(tree, true, nil)
+		defer walker.Close()
+		for {
+			path, entry, err := walker.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if !entry.Mode.IsFile() && entry.Mode != filemode.Submodule {
+				continue
+			}
+			if !inIndex[path] {
+				staged[path] = true
+			}
+		}
+	}
+
+	files := make([]string, 0, len(staged))
+	for path := range staged {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// goGitChangedFiles is the go-git implementation of GetChangedFiles.
+func goGitChangedFiles() ([]FileStatus, error) {
+	status, err := goGitStatus()
+	if err != nil {
+		return nil, err
+	}
+	var files []FileStatus
+	for path, file := range status {
+		if file.Staging == gogit.Unmodified && file.Worktree == gogit.Unmodified {
+			continue
+		}
+		status := FileStatus{Path: path, Status: statusName(string([]byte{byte(file.Staging), byte(file.Worktree)}))}
+		if file.Staging == gogit.Renamed {
+			status.OrigPath = file.Extra
+		}
+		files = append(files, status)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// goGitFile is a version of a file in a patch.
+type goGitFile struct {
+	path string
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+func (f *goGitFile) Hash() plumbing.Hash     { return f.hash }
+func (f *goGitFile) Mode() filemode.FileMode { return f.mode }
+func (f *goGitFile) Path() string            { return f.path }
+
+// goGitChunk is a run of equal, added or removed text in a patch.
+type goGitChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c goGitChunk) Content() string       { return c.content }
+func (c goGitChunk) Type() fdiff.Operation { return c.op }
+
+// goGitFilePatch is the change to a single file.
+type goGitFilePatch struct {
+	from, to *goGitFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (p *goGitFilePatch) IsBinary() bool        { return p.binary }
+func (p *goGitFilePatch) Chunks() []fdiff.Chunk { return p.chunks }
+func (p *goGitFilePatch) Files() (from, to fdiff.File) {
+	// Returns untyped nils for missing files, which the encoder compares against nil.
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+// goGitPatch is a set of file patches.
+type goGitPatch []fdiff.FilePatch
+
+func (p goGitPatch) FilePatches() []fdiff.FilePatch { return p }
+func (p goGitPatch) Message() string                { return "" }
+
+// goGitDiff is the go-git implementation of GetDiff: the staged changes to files, compared with HEAD.
+func goGitDiff(files []string) (string, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return "", err
+	}
+	index, err := repo.Storer.Index()
+	if err != nil {
+		return "", err
+	}
+	tree, err := goGitHeadTree(repo)
+	if err != nil {
+		return "", err
+	}
+
+	// Pairs the HEAD and staged versions of every file.
+	var added, deleted, changed []*goGitFilePatch
+	for _, path := range files {
+		patch := &goGitFilePatch{}
+		if tree != nil {
+			if entry, err := tree.FindEntry(path); err == nil {
+				patch.from = &goGitFile{path: path, hash: entry.Hash, mode: entry.Mode}
+			}
+		}
+		if entry, err := index.Entry(path); err == nil {
+			patch.to = &goGitFile{path: path, hash: entry.Hash, mode: entry.Mode}
+		}
+		switch {
+		case patch.from == nil && patch.to == nil:
+		case patch.from == nil:
+			added = append(added, patch)
+		case patch.to == nil:
+			deleted = append(deleted, patch)
+		case *patch.from != *patch.to:
+			changed = append(changed, patch)
+		}
+	}
+
+	// Detects files that were renamed without changes.
+	unmatched, remaining := 0, len(deleted)
+	for _, add := range added {
+		for i, del := range deleted {
+			if del != nil && del.from.hash == add.to.hash && del.from.mode == add.to.mode {
+				add.from, deleted[i] = del.from, nil
+				remaining--
+				break
+			}
+		}
+		if add.from == nil {
+			unmatched++
+		}
+	}
+	// Files renamed with changes and copies of changed or deleted files are only detected by git,
+	// which compares their contents.
+	if unmatched > 0 && remaining+len(changed) > 0 && hasGitBinary() {
+		return "", fmt.Errorf("detecting renames of changed files and copies: %w", errUnsupported)
+	}
+
+	var patch goGitPatch
+	for _, group := range [][]*goGitFilePatch{changed, added, deleted} {
+		for _, filePatch := range group {
+			if filePatch == nil {
+				continue
+			}
+			if err := loadChunks(repo, filePatch); err != nil {
+				return "", err
+			}
+			patch = append(patch, filePatch)
+		}
+	}
+	sort.Slice(patch, func(i, j int) bool { return patchPath(patch[i]) < patchPath(patch[j]) })
+
+	var b bytes.Buffer
+	if err := fdiff.NewUnifiedEncoder(&b, fdiff.DefaultContextLines).Encode(patch); err != nil {
+		return "", err
+	}
+	// Abbreviates the object names like git does, since they are of no use in the prompt.
+	return indexLinePattern.ReplaceAllString(strings.TrimSpace(b.String()), "$1..$2"), nil
+}
+
+// patchPath returns the path a file patch is sorted by.
+func patchPath(patch fdiff.FilePatch) string {
+	from, to := patch.Files()
+	if to != nil {
+		return to.Path()
+	}
+	return from.Path()
+}
+
+// loadChunks reads both versions of the file and computes the changes between them.
+func loadChunks(repo *gogit.Repository, patch *goGitFilePatch) error {
+	var before, after []byte
+	var err error
+	if patch.from != nil {
+		if before, err = readBlob(repo, patch.from); err != nil {
+			return err
+		}
+	}
+	if patch.to != nil {
+		if after, err = readBlob(repo, patch.to); err != nil {
+			return err
+		}
+	}
+
+	for _, content := range [][]byte{before, after} {
+		if isBinary, _ := binary.IsBinary(bytes.NewReader(content)); isBinary {
+			patch.binary = true
+			return nil
+		}
+	}
+	for _, d := range diff.Do(string(before), string(after)) {
+		op := fdiff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		}
+		patch.chunks = append(patch.chunks, goGitChunk{content: d.Text, op: op})
+	}
+	return nil
+}
+
+// readBlob returns the contents of a version of a file.
+func readBlob(repo *gogit.Repository, file *goGitFile) ([]byte, error) {
+	if file.mode == filemode.Submodule {
+		return nil, errUnsupported
+	}
+	blob, err := repo.BlobObject(file.hash)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// goGitRepoRoot is the go-git implementation of GetRepoRoot.
+func goGitRepoRoot() (string, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	return worktree.Filesystem.Root(), nil
+}
+
+// goGitCurrentBranch is the go-git implementation of GetCurrentBranch.
+func goGitCurrentBranch() (string, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", nil
+	}
+	return head.Target().Short(), nil
+}
+
+// goGitHasCommits is the go-git implementation of HasCommits.
+func goGitHasCommits() (bool, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return false, err
+	}
+	if _, err := repo.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// goGitRecentSubjects is the go-git implementation of GetRecentSubjects.
+func goGitRecentSubjects(n, skip int) ([]string, error) {
+	repo, err := openRepository()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	commits, err := repo.Log(&gogit.LogOptions{Order: gogit.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer commits.Close()
+
+	var subjects []string
+	for len(subjects) < n {
+		commit, err := commits.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if commit.NumParents() > 1 {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		subjects = append(subjects, subject)
+	}
+	return subjects, nil
+}
+
+// goGitCommit creates a commit with go-git, which is used when there is no git binary.
+// Hooks are not run, and signing is not supported.
+func goGitCommit(message string, opts CommitOptions) error {
+	if opts.GPGSign || opts.Signoff || opts.Only {
+		return fmt.Errorf("signing, sign-off and --only require the git binary: %w", errUnsupported)
+	}
+	repo, err := openRepository()
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	commitOpts := &gogit.CommitOptions{
+		Amend:             opts.Amend,
+		AllowEmptyCommits: opts.AllowEmpty,
+		Author:            envSignature("GIT_AUTHOR"),
+		Committer:         envSignature("GIT_COMMITTER"),
+	}
+	if _, err := worktree.Commit(message, commitOpts); err != nil {
+		return fmt.Errorf("git commit failed: %w", err)
+	}
+	return nil
+}
+
+// envSignature returns the identity set by the prefix_NAME and prefix_EMAIL environment variables,
+// which git prefers to its configuration, or nil to let go-git read the configuration.
+func envSignature(prefix string) *object.Signature {
+	name, email := os.Getenv(prefix+"_NAME"), os.Getenv(prefix+"_EMAIL")
+	if name == "" || email == "" {
+		return nil
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}
+}
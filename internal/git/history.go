@@ -0,0 +1,284 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrMergesInHistory is returned when rewording would have to rewrite merge commits.
+var ErrMergesInHistory = errors.New("cannot reword commits that are followed by merge commits")
+
+// commitObject holds the parts of a commit object that are kept when it is rewritten.
+type commitObject struct {
+	tree     string   // Hash of the commit's tree
+	parents  []string // Hashes of the parent commits
+	author   string   // Author line: name, email, timestamp and time zone
+	encoding string   // Encoding of the message, empty for UTF-8
+	signed   bool     // Whether the commit carries a GPG or SSH signature
+	message  string   // Full commit message
+}
+
+// ResolveCommit returns the full hash of the commit named by rev, such as "HEAD~2" or an abbreviated hash.
+func ResolveCommit(rev string) (string, error) {
+	sha, err := execGitCommand("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown commit: %s", rev)
+	}
+	return sha, nil
+}
+
+// GetCommitFiles returns the files changed by the commit, relative to its first parent.
+func GetCommitFiles(sha string) ([]string, error) {
+	output, err := execGitCommandRaw("git", "diff-tree", "--no-commit-id", "--name-only", "-z", "-r", "--root", sha)
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit %s: %w", sha, err)
+	}
+	return splitPaths(output), nil
+}
+
+// GetCommitDiff returns the changes the commit made to the provided files.
+func GetCommitDiff(sha string, files []string) (string, error) {
+	args := append([]string{"diff-tree", "--no-commit-id", "-p", "-r", "--root", "--find-renames", "--find-copies", sha, "--"}, files...)
+	return execGitCommand("git", args...)
+}
+
+// GetCommitMessage returns the full message of the commit.
+func GetCommitMessage(sha string) (string, error) {
+	return execGitCommand("git", "log", "-1", "--format=%B", sha)
+}
+
+// IsPushed reports whether the commit is contained in any remote-tracking branch,
+// in which case rewriting it requires a force push.
+func IsPushed(sha string) bool {
+	output, err := execGitCommand("git", "branch", "--remotes", "--contains", sha)
+	return err == nil && output != ""
+}
+
+// GetDefaultBranch returns the remote-tracking branch that pull requests usually target:
+// the branch origin/HEAD points to, or the first of origin/main, origin/master, main and master that exists.
+func GetDefaultBranch() (string, error) {
+	if ref, err := execGitCommand("git", "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil && ref != "" {
+		return ref, nil
+	}
+	for _, candidate := range []string{"origin/main", "origin/master", "main", "master"} {
+		if _, err := ResolveCommit(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("cannot determine the base branch; pass it explicitly")
+}
+
+// GetBranchFiles returns the files changed on HEAD since it diverged from base ("base...HEAD").
+func GetBranchFiles(base string) ([]string, error) {
+	output, err := execGitCommandRaw("git", "diff", "--name-only", "-z", "--no-renames", base+"...HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare HEAD with %s: %w", base, err)
+	}
+	return splitPaths(output), nil
+}
+
+// GetBranchDiff returns the changes to files on HEAD since it diverged from base.
+func GetBranchDiff(base string, files []string) (string, error) {
+	args := append([]string{"diff", "--find-renames", "--find-copies", base + "...HEAD", "--"}, files...)
+	return execGitCommand("git", args...)
+}
+
+// GetBranchSubjects returns the subjects of the commits on HEAD that are not on base, oldest first.
+func GetBranchSubjects(base string) ([]string, error) {
+	output, err := execGitCommand("git", "log", "--reverse", "--no-merges", "--format=%s", base+"..HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare HEAD with %s: %w", base, err)
+	}
+	return filterEmptyStrings(strings.Split(output, "\n")), nil
+}
+
+// GetCommitRange returns the hashes of the commits in a range such as "main..HEAD", oldest first.
+func GetCommitRange(revRange string) ([]string, error) {
+	output, err := execGitCommand("git", "rev-list", "--reverse", revRange)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit range: %s", revRange)
+	}
+	return filterEmptyStrings(strings.Split(output, "\n")), nil
+}
+
+// CheckReword returns an error if the commit cannot be reworded: it must be an ancestor of HEAD
+// and must not be followed by merge commits.
+func CheckReword(sha string) error {
+	if _, err := execGitCommand("git", "merge-base", "--is-ancestor", sha, "HEAD"); err != nil {
+		return fmt.Errorf("commit %s is not part of the current branch", sha)
+	}
+	merges, err := execGitCommand("git", "rev-list", "--merges", "--max-count=1", sha+"..HEAD")
+	if err != nil {
+		return err
+	}
+	if merges != "" {
+		return ErrMergesInHistory
+	}
+	return nil
+}
+
+// SignedCommits returns the hashes of the signed commits among the commit and every commit after it up to HEAD.
+// Rewording the commit drops their signatures unless commit.gpgsign is set, in which case they are signed again.
+func SignedCommits(sha string) ([]string, error) {
+	revs, err := execGitCommand("git", "rev-list", sha+"..HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("error listing commits: %w", err)
+	}
+	var signed []string
+	for _, rev := range append(filterEmptyStrings(strings.Split(revs, "\n")), sha) {
+		commit, err := readCommit(rev)
+		if err != nil {
+			return nil, err
+		}
+		if commit.signed {
+			signed = append(signed, rev)
+		}
+	}
+	return signed, nil
+}
+
+// ResignsCommits reports whether commit.gpgsign is set, so that rewritten commits are signed again.
+func ResignsCommits() bool {
+	value, err := execGitCommand("git", "config", "--type=bool", "--get", "commit.gpgsign")
+	return err == nil && value == "true"
+}
+
+// RewordCommits replaces the messages of the given commits, which must be ancestors of HEAD,
+// mapping each hash to its new message, and returns the new hash of HEAD.
+// Every commit from the oldest reworded one up to HEAD is recreated with the same tree, author and message encoding,
+// so the index and the working tree are unaffected. The recreated commits are signed when commit.gpgsign is set;
+// otherwise the signatures of signed commits are lost (see SignedCommits). The previous HEAD is saved in ORIG_HEAD.
+func RewordCommits(messages map[string]string) (string, error) {
+	oldHead, err := ResolveCommit("HEAD")
+	if err != nil {
+		return "", ErrNoCommits
+	}
+
+	// Finds the oldest commit to reword, i.e. the one with the most commits after it.
+	oldest, depth := "", -1
+	for sha := range messages {
+		if err := CheckReword(sha); err != nil {
+			return "", err
+		}
+		count, err := execGitCommand("git", "rev-list", "--count", sha+"..HEAD")
+		if err != nil {
+			return "", err
+		}
+		if n, _ := strconv.Atoi(count); n > depth {
+			oldest, depth = sha, n
+		}
+	}
+	if oldest == "" {
+		return oldHead, nil
+	}
+
+	// Lists the commits to recreate, oldest first, with their parents.
+	// A root commit has no parent to exclude, so the whole history is listed.
+	revRange := oldest + "^..HEAD"
+	if _, err := execGitCommand("git", "rev-parse", "--verify", "--quiet", oldest+"^"); err != nil {
+		revRange = "HEAD"
+	}
+	revs, err := execGitCommand("git", "rev-list", "--reverse", "--parents", revRange)
+	if err != nil {
+		return "", fmt.Errorf("error listing commits: %w", err)
+	}
+
+	sign := ResignsCommits()
+	rewritten := map[string]string{}
+	newHead := oldHead
+	for _, line := range filterEmptyStrings(strings.Split(revs, "\n")) {
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return "", ErrMergesInHistory
+		}
+
+		commit, err := readCommit(fields[0])
+		if err != nil {
+			return "", err
+		}
+		if message, ok := messages[fields[0]]; ok {
+			// Generated messages are UTF-8, whatever the encoding of the original one.
+			commit.message = strings.TrimSpace(message) + "\n"
+			commit.encoding = ""
+		}
+		for i, parent := range commit.parents {
+			if replacement, ok := rewritten[parent]; ok {
+				commit.parents[i] = replacement
+			}
+		}
+
+		if newHead, err = writeCommit(commit, sign); err != nil {
+			return "", err
+		}
+		rewritten[fields[0]] = newHead
+	}
+
+	// Moves the current branch, recording the change in the reflog.
+	if _, err := execGitCommand("git", "update-ref", "-m", "ai-generate-commit: reword", "HEAD", newHead, oldHead); err != nil {
+		return "", fmt.Errorf("error updating HEAD: %w", err)
+	}
+	if _, err := execGitCommand("git", "update-ref", "ORIG_HEAD", oldHead); err != nil {
+		return "", fmt.Errorf("error saving ORIG_HEAD: %w", err)
+	}
+	return newHead, nil
+}
+
+// readCommit parses the commit object with the given hash.
+func readCommit(sha string) (commitObject, error) {
+	raw, err := execGitCommandRaw("git", "cat-file", "commit", sha)
+	if err != nil {
+		return commitObject{}, fmt.Errorf("error reading commit %s: %w", sha, err)
+	}
+
+	header, message, _ := strings.Cut(raw, "\n\n")
+	commit := commitObject{message: message}
+	for _, line := range strings.Split(header, "\n") {
+		name, value, _ := strings.Cut(line, " ")
+		switch name {
+		case "tree":
+			commit.tree = value
+		case "parent":
+			commit.parents = append(commit.parents, value)
+		case "author":
+			commit.author = value
+		case "encoding":
+			commit.encoding = value
+		case "gpgsig", "gpgsig-sha256":
+			commit.signed = true
+		}
+	}
+	return commit, nil
+}
+
+// writeCommit creates a commit object with the author and message encoding of the original commit
+// and returns its hash, signing it if sign is set.
+// The committer is the current user, as with "git commit --amend" or "git rebase".
+func writeCommit(commit commitObject, sign bool) (string, error) {
+	var args []string
+	if commit.encoding != "" {
+		// The message is passed in its original encoding, which commit-tree records in the encoding header.
+		args = append(args, "-c", "i18n.commitEncoding="+commit.encoding)
+	}
+	args = append(args, "commit-tree", commit.tree)
+	if sign {
+		args = append(args, "-S")
+	}
+	for _, parent := range commit.parents {
+		args = append(args, "-p", parent)
+	}
+
+	// Splits "Name <email> timestamp zone" into the author environment variables.
+	name, rest, _ := strings.Cut(commit.author, " <")
+	email, date, _ := strings.Cut(rest, "> ")
+	cmd := newCommand("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME="+name, "GIT_AUTHOR_EMAIL="+email, "GIT_AUTHOR_DATE="+date)
+	cmd.Stdin = strings.NewReader(commit.message)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error writing commit: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,174 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFileName is the name of the repository file listing paths to leave out of the prompt.
+const IgnoreFileName = ".aicommitignore"
+
+// Ignore decides which files are left out of the prompt, using gitignore syntax.
+// Rules are checked in order and the last matching rule wins, so a later "!pattern" re-includes files.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+// ignoreRule is a single parsed gitignore pattern.
+type ignoreRule struct {
+	segments []string // Pattern split at slashes, relative to the repository root
+	negate   bool     // Whether the rule re-includes matching files ("!pattern")
+	dirOnly  bool     // Whether the rule only matches directories ("pattern/")
+}
+
+// NewIgnore creates an Ignore from gitignore-style patterns.
+// Blank patterns and comments are skipped.
+func NewIgnore(patterns []string) (*Ignore, error) {
+	ignore := &Ignore{}
+	for _, pattern := range patterns {
+		if err := ignore.add(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return ignore, nil
+}
+
+// AddFile appends the rules of the gitignore-style file at path. A missing file is not an error.
+func (ig *Ignore) AddFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if err := ig.add(scanner.Text()); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Match reports whether the slash-separated file path, relative to the repository root, is ignored.
+func (ig *Ignore) Match(file string) bool {
+	segments := strings.Split(file, "/")
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.match(segments) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// Filter splits files into those that are included and those that are ignored.
+func (ig *Ignore) Filter(files []string) (included, excluded []string) {
+	for _, file := range files {
+		if ig.Match(file) {
+			excluded = append(excluded, file)
+		} else {
+			included = append(included, file)
+		}
+	}
+	return included, excluded
+}
+
+// add parses a line in gitignore syntax and appends it as a rule:
+//   - blank lines and lines starting with "#" are skipped;
+//   - a leading "!" negates the pattern; "\#" and "\!" escape a literal first character;
+//   - a trailing "/" matches directories only;
+//   - a pattern without a slash, such as "*.min.js", matches a name in any directory;
+//   - a pattern containing a slash, such as "dist/**" or "/docs", matches from the repository root;
+//   - "**" matches any number of directories, and a matched directory matches every file below it.
+func (ig *Ignore) add(line string) error {
+	// Drops trailing whitespace unless it is escaped.
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	rule := ignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+	if !ValidPattern(line) {
+		return fmt.Errorf("invalid pattern %q", line)
+	}
+
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	ig.rules = append(ig.rules, rule)
+	return nil
+}
+
+// match reports whether the rule matches the file or one of its parent directories.
+func (r ignoreRule) match(segments []string) bool {
+	// A directory-only rule cannot match the file itself, which is never a directory.
+	last := len(segments)
+	if r.dirOnly {
+		last--
+	}
+	for i := last; i > 0; i-- {
+		if matchSegments(r.segments, segments[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPattern reports whether pattern is a well-formed glob pattern.
+func ValidPattern(pattern string) bool {
+	for _, segment := range strings.Split(strings.TrimPrefix(pattern, "!"), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSegments matches path segments against pattern segments, where a "**" segment
+// matches zero or more path segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Tries every possible number of segments for "**".
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], segments[0]); err != nil || !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,101 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GetStagedPaths returns every path changed in the index, listing both sides of a rename.
+// Unlike GetStagedFiles, the result covers all index entries that differ from HEAD.
+func GetStagedPaths() ([]string, error) {
+	output, err := execGitCommandRaw("git", "diff", "--cached", "--name-only", "-z", "--no-renames")
+	if err != nil {
+		return nil, err
+	}
+	return splitPaths(output), nil
+}
+
+// StageAll stages the changes and deletions of all tracked files, like "git commit -a".
+// With untracked, new files that are not ignored are staged too.
+func StageAll(untracked bool) error {
+	args := []string{"add", "--update"}
+	if untracked {
+		args = []string{"add", "--all"}
+	}
+	if _, err := execGitCommand("git", args...); err != nil {
+		return fmt.Errorf("error staging files: %w", err)
+	}
+	return nil
+}
+
+// StageFiles stages the changes of files, their deletion and, for new files, their addition,
+// like "git add --all -- files".
+func StageFiles(files []string) error {
+	args := append([]string{"add", "--all", "--"}, files...)
+	if _, err := execGitCommand("git", args...); err != nil {
+		return fmt.Errorf("error staging files: %w", err)
+	}
+	return nil
+}
+
+// WriteIndexTree saves the current index as a tree object and returns its hash.
+// The tree can later be restored with ReadTree.
+func WriteIndexTree() (string, error) {
+	tree, err := execGitCommand("git", "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("error saving the index: %w", err)
+	}
+	return tree, nil
+}
+
+// ReadTree replaces the index with the contents of tree, which may be any tree-ish such as "HEAD".
+// An empty tree string empties the index. The working tree is not modified.
+func ReadTree(tree string) error {
+	args := []string{"read-tree", tree}
+	if tree == "" {
+		args = []string{"read-tree", "--empty"}
+	}
+	if _, err := execGitCommand("git", args...); err != nil {
+		return fmt.Errorf("error restoring the index from %s: %w", tree, err)
+	}
+	return nil
+}
+
+// StageFromTree sets the index entries of files to their versions in tree,
+// removing files that do not exist in tree. The working tree is not modified.
+func StageFromTree(tree string, files []string) error {
+	// Lists the entries of the files that exist in tree, NUL-separated so that unusual paths are not quoted.
+	args := append([]string{"ls-tree", "-r", "-z", "--full-tree", tree, "--"}, files...)
+	entries, err := execGitCommand("git", args...)
+	if err != nil {
+		return fmt.Errorf("error reading tree %s: %w", tree, err)
+	}
+	entries = strings.TrimRight(entries, "\x00")
+
+	present := map[string]bool{}
+	for _, entry := range filterEmptyStrings(strings.Split(entries, "\x00")) {
+		if _, file, ok := strings.Cut(entry, "\t"); ok {
+			present[file] = true
+		}
+	}
+	if len(present) > 0 {
+		if _, err := execGitCommandInput(entries+"\x00", "git", "update-index", "-z", "--index-info"); err != nil {
+			return fmt.Errorf("error staging files: %w", err)
+		}
+	}
+
+	// Removes the files that were deleted.
+	var removed []string
+	for _, file := range files {
+		if !present[file] {
+			removed = append(removed, file)
+		}
+	}
+	if len(removed) > 0 {
+		args := append([]string{"update-index", "--force-remove", "--"}, removed...)
+		if _, err := execGitCommand("git", args...); err != nil {
+			return fmt.Errorf("error staging deletions: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package git
+
+import "strings"
+
+// DiffFiles returns the path of every file in diff, in the order of the diff. Renamed and copied
+// files are listed by their new path. Text before the first "diff --git" line, such as the
+// headers of a mailed patch, is ignored.
+func DiffFiles(diff string) []string {
+	var files []string
+	for _, section := range diffSections(diff) {
+		if path := sectionPath(section); path != "" {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// FilterDiff returns the parts of diff that change one of files, as listed by DiffFiles.
+func FilterDiff(diff string, files []string) string {
+	keep := make(map[string]bool, len(files))
+	for _, file := range files {
+		keep[file] = true
+	}
+	var b strings.Builder
+	for _, section := range diffSections(diff) {
+		if keep[sectionPath(section)] {
+			b.WriteString(section)
+		}
+	}
+	return b.String()
+}
+
+// diffSections splits diff into one section per file like splitDiff, leaving out anything that
+// does not start with a "diff --git" line.
+func diffSections(diff string) []string {
+	if !strings.HasPrefix(diff, "diff --git ") {
+		i := strings.Index(diff, "\ndiff --git ")
+		if i < 0 {
+			return nil
+		}
+		diff = diff[i+1:]
+	}
+	return splitDiff(diff)
+}
+
+// sectionPath returns the path of the file changed by the diff of a single file.
+func sectionPath(section string) string {
+	name := sectionStat(section).Name
+	if _, to, found := strings.Cut(name, " → "); found {
+		return to
+	}
+	return name
+}
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrDetachedHead is returned when pushing while no branch is checked out.
+var ErrDetachedHead = errors.New("HEAD is detached; check out a branch to push")
+
+// Push pushes the current branch with "git push". A branch without an upstream is pushed to its
+// push remote and set up to track the branch of the same name there, like "git push -u origin BRANCH".
+// Git's output, including credential prompts, is connected to the terminal; SetQuiet leaves only errors.
+func Push() error {
+	args := []string{"push"}
+	if quiet {
+		args = append(args, "--quiet")
+	}
+	if _, err := execGitCommand("git", "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err != nil {
+		branch, err := GetCurrentBranch()
+		if err != nil {
+			return err
+		}
+		if branch == "" {
+			return ErrDetachedHead
+		}
+		remote, err := pushRemote(branch)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--set-upstream", remote, branch)
+	}
+
+	cmd := newCommand("git", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git push failed: %w", err)
+	}
+	return nil
+}
+
+// pushRemote returns the remote a new branch is pushed to: the branch's pushRemote, remote.pushDefault,
+// the only remote of the repository, or "origin" if there are several.
+func pushRemote(branch string) (string, error) {
+	for _, key := range []string{"branch." + branch + ".pushRemote", "remote.pushDefault"} {
+		if remote, err := execGitCommand("git", "config", "--get", key); err == nil && remote != "" {
+			return remote, nil
+		}
+	}
+	output, err := execGitCommand("git", "remote")
+	if err != nil {
+		return "", fmt.Errorf("error listing remotes: %w", err)
+	}
+	remotes := filterEmptyStrings(strings.Split(output, "\n"))
+	switch len(remotes) {
+	case 0:
+		return "", fmt.Errorf("the repository has no remote to push to")
+	case 1:
+		return remotes[0], nil
+	}
+	return "origin", nil
+}
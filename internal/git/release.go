@@ -0,0 +1,68 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// githubMergePattern matches the subject of merge commits created for GitHub pull requests.
+	githubMergePattern = regexp.MustCompile(`^Merge pull request (#\d+) from \S+`)
+	// gitlabMergePattern matches the line GitLab adds to the body of merge request merge commits.
+	gitlabMergePattern = regexp.MustCompile(`^See merge request \S*(![0-9]+)$`)
+)
+
+// GetPreviousTag returns the most recent tag reachable from the parent of rev,
+// which is the tag of the previous release when rev is a release.
+func GetPreviousTag(rev string) (string, error) {
+	tag, err := execGitCommand("git", "describe", "--tags", "--abbrev=0", rev+"^")
+	if err != nil {
+		return "", fmt.Errorf("no tag found before %s", rev)
+	}
+	return tag, nil
+}
+
+// GetMergedChanges returns one line per change merged between from and to, oldest first.
+// Only the first-parent history is read, so a merged pull request is a single change: its title,
+// taken from the merge commit, with the pull request number. Squash merges and direct commits
+// are described by their subject.
+func GetMergedChanges(from, to string) ([]string, error) {
+	output, err := execGitCommand("git", "log", "--first-parent", "--reverse", "--format=%s%x00%b%x1e", from+".."+to)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the commits between %s and %s: %w", from, to, err)
+	}
+
+	var changes []string
+	for _, record := range strings.Split(output, "\x1e") {
+		subject, body, _ := strings.Cut(strings.TrimSpace(record), "\x00")
+		if subject == "" {
+			continue
+		}
+		changes = append(changes, mergedChange(subject, body))
+	}
+	return changes, nil
+}
+
+// mergedChange describes a first-parent commit, preferring the pull request title of merge commits.
+func mergedChange(subject, body string) string {
+	lines := filterEmptyStrings(strings.Split(body, "\n"))
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+
+	// GitHub: "Merge pull request #12 from user/branch" with the title as the body.
+	if match := githubMergePattern.FindStringSubmatch(subject); match != nil && len(lines) > 0 {
+		return fmt.Sprintf("%s (%s)", lines[0], match[1])
+	}
+
+	// GitLab: "Merge branch 'x' into 'main'" with the title and "See merge request group/project!12" in the body.
+	if strings.HasPrefix(subject, "Merge branch ") && len(lines) > 0 {
+		for _, line := range lines {
+			if match := gitlabMergePattern.FindStringSubmatch(line); match != nil && line != lines[0] {
+				return fmt.Sprintf("%s (%s)", lines[0], match[1])
+			}
+		}
+	}
+	return subject
+}
@@ -0,0 +1,72 @@
+package git
+
+// GitRepo is the repository the commands describe and commit to. NewRepo returns the repository
+// of the work directory; FakeRepo stands in for it in tests, without a Git binary or a repository
+// on disk.
+type GitRepo interface {
+	// AssertRepo returns ErrNotGitRepo outside of a repository.
+	AssertRepo() error
+	// Root returns the absolute path of the top-level directory of the working tree.
+	Root() (string, error)
+	// StagedFiles returns the staged files, renamed files under their old and new name.
+	StagedFiles() ([]string, error)
+	// Diff returns the diff of the staged changes to files.
+	Diff(files []string) (string, error)
+	// AmendFiles returns the files an amended commit would change: those of the last commit and the staged ones.
+	AmendFiles() ([]string, error)
+	// AmendDiff returns the diff of files an amended commit would introduce.
+	AmendDiff(files []string) (string, error)
+	// ChangedFiles returns the files with staged, unstaged or untracked changes, see GetChangedFiles.
+	ChangedFiles() ([]FileStatus, error)
+	// EnsureStaged offers to stage changes if none are staged, see EnsureFilesAreStaged.
+	EnsureStaged() error
+	// StageAll stages the changes of all tracked files, and of new files if untracked is set.
+	StageAll(untracked bool) error
+	// StageFiles stages the changes of files, new and deleted files included.
+	StageFiles(files []string) error
+	// CurrentBranch returns the short name of the checked-out branch, "" if HEAD is detached.
+	CurrentBranch() (string, error)
+	// RecentSubjects returns the subjects of up to n recent non-merge commits, newest first, after skipping skip.
+	RecentSubjects(n, skip int) ([]string, error)
+	// BranchDiffStat returns the diffstat of the commits on HEAD that are not on base.
+	BranchDiffStat(base string) (string, error)
+	// CommitTemplate returns the contents of the commit.template file, "" if there is none.
+	CommitTemplate() (string, error)
+	// TemplateCoAuthors returns the co-authors listed in the commit template.
+	TemplateCoAuthors() ([]string, error)
+	// Commit commits the staged changes with message, or amends the last commit, see GitCommit.
+	Commit(message string, opts CommitOptions) error
+	// Push pushes the current branch, setting up its upstream if it has none.
+	Push() error
+}
+
+var (
+	_ GitRepo = execRepo{}
+	_ GitRepo = (*FakeRepo)(nil)
+)
+
+// execRepo is the repository of the work directory, accessed with the git binary or go-git, see SetBackend.
+type execRepo struct{}
+
+// NewRepo returns the repository of the work directory, see SetWorkDir.
+func NewRepo() GitRepo {
+	return execRepo{}
+}
+
+func (execRepo) AssertRepo() error                               { return AssertGitRepo() }
+func (execRepo) Root() (string, error)                           { return GetRepoRoot() }
+func (execRepo) StagedFiles() ([]string, error)                  { return GetStagedFiles() }
+func (execRepo) Diff(files []string) (string, error)             { return GetDiff(files) }
+func (execRepo) AmendFiles() ([]string, error)                   { return GetAmendFiles() }
+func (execRepo) AmendDiff(files []string) (string, error)        { return GetAmendDiff(files) }
+func (execRepo) ChangedFiles() ([]FileStatus, error)             { return GetChangedFiles() }
+func (execRepo) EnsureStaged() error                             { return EnsureFilesAreStaged() }
+func (execRepo) StageAll(untracked bool) error                   { return StageAll(untracked) }
+func (execRepo) StageFiles(files []string) error                 { return StageFiles(files) }
+func (execRepo) CurrentBranch() (string, error)                  { return GetCurrentBranch() }
+func (execRepo) RecentSubjects(n, skip int) ([]string, error)    { return GetRecentSubjects(n, skip) }
+func (execRepo) BranchDiffStat(base string) (string, error)      { return GetBranchDiffStat(base) }
+func (execRepo) CommitTemplate() (string, error)                 { return GetCommitTemplate() }
+func (execRepo) TemplateCoAuthors() ([]string, error)            { return GetTemplateCoAuthors() }
+func (execRepo) Commit(message string, opts CommitOptions) error { return GitCommit(message, opts) }
+func (execRepo) Push() error                                     { return Push() }
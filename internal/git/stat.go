@@ -0,0 +1,120 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FileStat holds the number of lines a diff adds to and removes from a single file.
+type FileStat struct {
+	Name    string // Path, or "old → new" for renames and copies
+	Added   int    // Number of added lines
+	Removed int    // Number of removed lines
+	Binary  bool   // Whether the file is binary, in which case no lines are counted
+	New     bool   // Whether the file was created
+	Deleted bool   // Whether the file was deleted
+}
+
+// DiffStats counts the added and removed lines of every file in diff, in the order of the diff.
+func DiffStats(diff string) []FileStat {
+	var stats []FileStat
+	for _, section := range splitDiff(diff) {
+		if stat := sectionStat(section); stat.Name != "" {
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// DiffStat summarizes diff like "git diff --stat": one line per file with the number of added and
+// removed lines, followed by the totals. It returns an empty string for an empty diff.
+func DiffStat(diff string) string {
+	if strings.TrimSpace(diff) == "" {
+		return ""
+	}
+
+	stats := DiffStats(diff)
+	width, added, removed := 0, 0, 0
+	for _, stat := range stats {
+		width = max(width, len([]rune(stat.Name)))
+		added += stat.Added
+		removed += stat.Removed
+	}
+
+	var b strings.Builder
+	for _, stat := range stats {
+		counts := fmt.Sprintf("+%d -%d", stat.Added, stat.Removed)
+		if stat.Binary {
+			counts = "binary"
+		}
+		fmt.Fprintf(&b, " %s%s | %s\n", stat.Name, strings.Repeat(" ", width-len([]rune(stat.Name))), counts)
+	}
+	fmt.Fprintf(&b, " %d file(s) changed, %d insertion(s)(+), %d deletion(s)(-)", len(stats), added, removed)
+	return b.String()
+}
+
+// sectionStat counts the changes in the diff of a single file.
+func sectionStat(section string) FileStat {
+	var stat FileStat
+	var from, to, oldPath, newPath string
+	inHunk := false
+	for _, line := range strings.Split(section, "\n") {
+		if inHunk {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				stat.Added++
+			case strings.HasPrefix(line, "-"):
+				stat.Removed++
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "copy from "):
+			from = unquotePath(line[strings.Index(line, "from ")+len("from "):])
+		case strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "copy to "):
+			to = unquotePath(line[strings.Index(line, "to ")+len("to "):])
+		case strings.HasPrefix(line, "--- "):
+			oldPath = strings.TrimPrefix(unquotePath(line[len("--- "):]), "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath = strings.TrimPrefix(unquotePath(line[len("+++ "):]), "b/")
+		case strings.HasPrefix(line, "Binary files "):
+			stat.Binary = true
+		case strings.HasPrefix(line, "new file mode "):
+			stat.New = true
+		case strings.HasPrefix(line, "deleted file mode "):
+			stat.Deleted = true
+		case strings.HasPrefix(line, "diff --git "):
+			// Takes the new path from the header for binary and mode-only changes, which have no "+++" line.
+			if i := strings.LastIndex(line, ` "b/`); i >= 0 {
+				newPath = strings.TrimPrefix(unquotePath(line[i+1:]), "b/")
+			} else if i := strings.LastIndex(line, " b/"); i >= 0 {
+				newPath = line[i+len(" b/"):]
+			}
+		}
+	}
+
+	switch {
+	case from != "" && to != "":
+		stat.Name = from + " → " + to
+	case newPath != "" && newPath != "/dev/null":
+		stat.Name = newPath
+	default:
+		stat.Name = oldPath
+	}
+	return stat
+}
+
+// unquotePath returns a path as written in a diff header. Git quotes paths with special characters
+// like a C string, and ends "---" and "+++" lines of paths with spaces with a tab.
+func unquotePath(path string) string {
+	path = strings.TrimSuffix(path, "\t")
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
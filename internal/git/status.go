@@ -0,0 +1,77 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseStatus parses the output of "git status --porcelain=v2 -z". Entries are separated by NUL
+// characters and paths are never quoted, so names with spaces, quotes or non-ASCII characters are
+// returned as they are. Renamed and copied entries carry the original path in OrigPath.
+func parseStatus(output string) ([]FileStatus, error) {
+	var files []FileStatus
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" || entry[0] == '#' || entry[0] == '!' {
+			continue
+		}
+
+		// The path is the last field of an entry and may contain spaces, so the fields before it are counted.
+		var count int
+		switch entry[0] {
+		case '1': // "1 XY sub mH mI mW hH hI path"
+			count = 9
+		case '2': // "2 XY sub mH mI mW hH hI score path", followed by the original path as its own entry
+			count = 10
+		case 'u': // "u XY sub m1 m2 m3 mW h1 h2 h3 path"
+			count = 11
+		case '?': // "? path"
+			count = 2
+		default:
+			return nil, fmt.Errorf("unexpected git status entry: %q", entry)
+		}
+		fields := strings.SplitN(entry, " ", count)
+		if len(fields) < count || fields[count-1] == "" || (entry[0] != '?' && len(fields[1]) != 2) {
+			return nil, fmt.Errorf("malformed git status entry: %q", entry)
+		}
+
+		file := FileStatus{Path: fields[len(fields)-1]}
+		switch entry[0] {
+		case '?':
+			file.Status = translateStatus("??")
+		case 'u':
+			file.Status = translateStatus("U")
+		default:
+			file.Status = statusName(fields[1])
+		}
+		if entry[0] == '2' {
+			if i+1 == len(entries) {
+				return nil, fmt.Errorf("missing original path of git status entry: %q", entry)
+			}
+			i++
+			file.OrigPath = entries[i]
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// statusName describes the two-letter status of a file, in which the first letter is the status in
+// the index and the second the status in the working tree. Unchanged sides are "." or " ".
+func statusName(xy string) string {
+	if xy == "??" {
+		return translateStatus(xy)
+	}
+	for _, code := range xy {
+		if code != '.' && code != ' ' {
+			return translateStatus(string(code))
+		}
+	}
+	return translateStatus("")
+}
+
+// splitPaths splits the output of a Git command run with -z into paths.
+func splitPaths(output string) []string {
+	return filterEmptyStrings(strings.Split(output, "\x00"))
+}
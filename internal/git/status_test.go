@@ -0,0 +1,117 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []FileStatus
+		wantErr bool
+	}{
+		{
+			name:   "empty",
+			output: "",
+			want:   nil,
+		},
+		{
+			name:   "modified in the index",
+			output: "1 M. N... 100644 100644 100644 1111111 2222222 main.go\x00",
+			want:   []FileStatus{{Path: "main.go", Status: "Modified"}},
+		},
+		{
+			name:   "modified in the working tree",
+			output: "1 .M N... 100644 100644 100644 1111111 1111111 main.go\x00",
+			want:   []FileStatus{{Path: "main.go", Status: "Modified"}},
+		},
+		{
+			name:   "added and deleted",
+			output: "1 A. N... 000000 100644 100644 0000000 2222222 new.go\x001 D. N... 100644 000000 000000 1111111 0000000 old.go\x00",
+			want: []FileStatus{
+				{Path: "new.go", Status: "Added"},
+				{Path: "old.go", Status: "Deleted"},
+			},
+		},
+		{
+			name:   "renamed",
+			output: "2 R. N... 100644 100644 100644 1111111 1111111 R100 internal/new.go\x00internal/old.go\x00",
+			want:   []FileStatus{{Path: "internal/new.go", OrigPath: "internal/old.go", Status: "Renamed"}},
+		},
+		{
+			name:   "copied",
+			output: "2 C. N... 100644 100644 100644 1111111 1111111 C75 copy.go\x00orig.go\x00",
+			want:   []FileStatus{{Path: "copy.go", OrigPath: "orig.go", Status: "Copied"}},
+		},
+		{
+			name:   "unmerged",
+			output: "u UU N... 100644 100644 100644 100644 1111111 2222222 3333333 conflict.go\x00",
+			want:   []FileStatus{{Path: "conflict.go", Status: "Updated but unmerged"}},
+		},
+		{
+			name:   "untracked",
+			output: "? notes.txt\x00",
+			want:   []FileStatus{{Path: "notes.txt", Status: "Untracked"}},
+		},
+		{
+			name:   "headers and ignored files are skipped",
+			output: "# branch.oid 1111111\x00# branch.head main\x00! build/out\x00? notes.txt\x00",
+			want:   []FileStatus{{Path: "notes.txt", Status: "Untracked"}},
+		},
+		{
+			name:   "spaces in paths",
+			output: "2 R. N... 100644 100644 100644 1111111 1111111 R90 my docs/new name.md\x00my docs/old name.md\x00? a  b.txt\x00",
+			want: []FileStatus{
+				{Path: "my docs/new name.md", OrigPath: "my docs/old name.md", Status: "Renamed"},
+				{Path: "a  b.txt", Status: "Untracked"},
+			},
+		},
+		{
+			name:   "non-ASCII paths",
+			output: "1 .M N... 100644 100644 100644 1111111 1111111 docs/résumé.md\x00? 日本語.txt\x00",
+			want: []FileStatus{
+				{Path: "docs/résumé.md", Status: "Modified"},
+				{Path: "日本語.txt", Status: "Untracked"},
+			},
+		},
+		{
+			name:    "unknown entry",
+			output:  "x something\x00",
+			wantErr: true,
+		},
+		{
+			name:    "missing fields",
+			output:  "1 .M N... 100644\x00",
+			wantErr: true,
+		},
+		{
+			name:    "invalid status",
+			output:  "1 M N... 100644 100644 100644 1111111 1111111 main.go\x00",
+			wantErr: true,
+		},
+		{
+			name:    "untracked without a path",
+			output:  "?\x00",
+			wantErr: true,
+		},
+		{
+			name:    "rename without the original path",
+			output:  "2 R. N... 100644 100644 100644 1111111 1111111 R100 new.go",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatus(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStatus() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
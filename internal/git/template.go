@@ -0,0 +1,43 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// GetCommitTemplate returns the contents of the file set as commit.template,
+// or an empty string if none is set or the file does not exist.
+func GetCommitTemplate() (string, error) {
+	path, err := execGitCommand("git", "config", "--path", "--get", "commit.template")
+	if err != nil || path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read commit template: %w", err)
+	}
+	return string(data), nil
+}
+
+// StripComments removes comment lines and surplus blank lines from a commit message, as git does
+// for messages written in an editor. It honors core.commentChar when the git binary is available.
+func StripComments(message string) (string, error) {
+	if hasGitBinary() {
+		output, err := execGitCommandInput(message, "git", "stripspace", "--strip-comments")
+		if err != nil {
+			return "", fmt.Errorf("failed to strip comments: %w", err)
+		}
+		return output, nil
+	}
+
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CoAuthorTrailer is the trailer GitHub and GitLab use to credit additional authors of a commit.
+const CoAuthorTrailer = "Co-authored-by"
+
+// trailerPattern matches a trailer such as "Reviewed-by: Jane Doe <jane@example.com>".
+var trailerPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*:\s*\S`)
+
+// ValidTrailer reports whether trailer has the "Token: value" form git expects.
+func ValidTrailer(trailer string) bool {
+	return trailerPattern.MatchString(trailer)
+}
+
+// AddTrailers appends trailers such as "Ticket: PROJ-12" to the trailer block of message,
+// skipping those the message already contains.
+func AddTrailers(message string, trailers []string) (string, error) {
+	if len(trailers) == 0 {
+		return message, nil
+	}
+	args := []string{"interpret-trailers", "--if-exists", "addIfDifferent"}
+	for _, trailer := range trailers {
+		args = append(args, "--trailer", trailer)
+	}
+	// Without a final newline, git takes the last paragraph for a trailer block and appends to it.
+	output, err := execGitCommandInput(strings.TrimRight(message, "\n")+"\n", "git", args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to add trailers: %w", err)
+	}
+	return output, nil
+}
+
+// GetTemplateCoAuthors returns the co-authors listed in the file set as commit.template,
+// which pairing tools such as git-mob keep up to date with the current pair.
+func GetTemplateCoAuthors() ([]string, error) {
+	template, err := GetCommitTemplate()
+	if err != nil {
+		return nil, err
+	}
+	return templateCoAuthors(template), nil
+}
+
+// templateCoAuthors returns the co-authors listed in the contents of a commit template.
+func templateCoAuthors(template string) []string {
+	var coAuthors []string
+	for _, line := range strings.Split(template, "\n") {
+		token, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if found && strings.EqualFold(token, CoAuthorTrailer) && strings.TrimSpace(value) != "" {
+			coAuthors = append(coAuthors, strings.TrimSpace(value))
+		}
+	}
+	return coAuthors
+}
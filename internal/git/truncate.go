@@ -0,0 +1,121 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Omission describes what TruncateDiff left out of the diff of a file.
+type Omission struct {
+	File  string // Path of the file; empty for the end of the diff, cut off as a last resort
+	Hunks int    // Number of hunks left out
+	Lines int    // Number of lines left out
+}
+
+// String describes the omission, e.g. "2 hunks of main.go (340 lines)".
+func (o Omission) String() string {
+	if o.File == "" {
+		return fmt.Sprintf("the end of the diff (%d lines)", o.Lines)
+	}
+	hunks := "1 hunk"
+	if o.Hunks != 1 {
+		hunks = fmt.Sprintf("%d hunks", o.Hunks)
+	}
+	return fmt.Sprintf("%s of %s (%d lines)", hunks, o.File, o.Lines)
+}
+
+// hunk is a hunk of a file's diff, from its "@@" line to the next one.
+type hunk struct {
+	section int    // Index of the file's section in the diff
+	text    string // The hunk, ending with a newline unless it ends the diff
+	tokens  int    // Number of tokens of text
+}
+
+// TruncateDiff shortens diff until count, which returns the number of tokens of a text, finds at most
+// limit tokens in it, and returns the result together with what was left out. The largest hunks are
+// replaced by a note first, since a huge hunk, such as one of generated code, says little per token,
+// and every file keeps its header this way; if that is not enough, the end of the diff is cut off.
+// Text before the first file, such as a summary of the changed files, is kept.
+func TruncateDiff(diff string, limit int, count func(string) int) (string, []Omission) {
+	total := count(diff)
+	if total <= limit {
+		return diff, nil
+	}
+
+	// Splits the diff into the text before the first file, the headers of the files and their hunks.
+	preamble, sections := diff, []string(nil)
+	if start := strings.Index("\n"+diff, "\ndiff --git "); start >= 0 {
+		preamble, sections = diff[:start], splitDiff(diff[start:])
+	}
+	headers := make([]string, len(sections))
+	hunks := make([][]hunk, len(sections))
+	var all []*hunk
+	for i, section := range sections {
+		parts := strings.Split(section, "\n@@")
+		headers[i] = parts[0]
+		for _, part := range parts[1:] {
+			text := "@@" + part
+			hunks[i] = append(hunks[i], hunk{section: i, text: text, tokens: count(text)})
+		}
+		for j := range hunks[i] {
+			all = append(all, &hunks[i][j])
+		}
+	}
+
+	// Replaces the largest hunks by a note until the diff fits, keeping the line that locates each hunk.
+	sort.SliceStable(all, func(a, b int) bool { return all[a].tokens > all[b].tokens })
+	omitted := make(map[int]*Omission)
+	for _, h := range all {
+		if total <= limit {
+			break
+		}
+		location, rest, _ := strings.Cut(h.text, "\n")
+		lines := strings.Count(strings.TrimSuffix(rest, "\n"), "\n") + 1
+		note := fmt.Sprintf("%s\n[%d lines left out to fit the context window]", location, lines)
+		if strings.HasSuffix(h.text, "\n") {
+			note += "\n" // The last hunk of a file ends with the line break before the next file
+		}
+		if count(note) >= h.tokens {
+			continue
+		}
+		total -= h.tokens - count(note)
+		h.text, h.tokens = note, count(note)
+
+		o := omitted[h.section]
+		if o == nil {
+			o = &Omission{File: sectionPath(sections[h.section])}
+			omitted[h.section] = o
+		}
+		o.Hunks++
+		o.Lines += lines
+	}
+
+	var b strings.Builder
+	b.WriteString(preamble)
+	var omissions []Omission
+	for i := range sections {
+		b.WriteString(headers[i])
+		for _, h := range hunks[i] {
+			b.WriteString("\n")
+			b.WriteString(h.text)
+		}
+		if o := omitted[i]; o != nil {
+			omissions = append(omissions, *o)
+		}
+	}
+	truncated := b.String()
+	if count(truncated) <= limit {
+		return truncated, omissions
+	}
+
+	// Cuts off as many lines at the end as needed, found by binary search since counting is not free.
+	lines := strings.SplitAfter(truncated, "\n")
+	const tail = "\n[the rest of the diff was left out to fit the context window]"
+	cut := sort.Search(len(lines), func(n int) bool {
+		return count(strings.Join(lines[:len(lines)-n], "")+tail) <= limit
+	})
+	keep := lines[:len(lines)-cut]
+	omissions = append(omissions, Omission{Lines: cut})
+	return strings.TrimSuffix(strings.Join(keep, ""), "\n") + tail, omissions
+}
@@ -0,0 +1,60 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReflogAction starts the reflog entries of the commits created by the tool, e.g.
+// "commit (ai-generate-commit): add login page". Amended commits add " amend".
+const ReflogAction = "commit (ai-generate-commit)"
+
+// ErrNotToolCommit is returned by LastToolCommit when HEAD was last moved by something other than the tool.
+var ErrNotToolCommit = errors.New("the last commit was not created by ai-generate-commit")
+
+// ToolCommit describes the latest commit created by the tool.
+type ToolCommit struct {
+	Hash    string // Abbreviated hash of the commit
+	Subject string // First line of its message
+	Amend   bool   // Whether the commit amended an earlier one
+}
+
+// LastToolCommit returns the commit HEAD points to if the tool created it and HEAD has not moved since,
+// according to the latest entry of HEAD's reflog. Otherwise it returns ErrNotToolCommit.
+func LastToolCommit() (ToolCommit, error) {
+	output, err := execGitCommand("git", "log", "--walk-reflogs", "-1", "--format=%h%x00%gs%x00%s", "HEAD")
+	if err != nil || output == "" {
+		return ToolCommit{}, ErrNotToolCommit
+	}
+	fields := strings.SplitN(output, "\x00", 3)
+	if len(fields) != 3 {
+		return ToolCommit{}, fmt.Errorf("unexpected reflog entry: %q", output)
+	}
+	action, _, _ := strings.Cut(fields[1], ":")
+	if action != ReflogAction && action != ReflogAction+" amend" {
+		return ToolCommit{}, fmt.Errorf("%w; HEAD was last changed by %q", ErrNotToolCommit, fields[1])
+	}
+	return ToolCommit{Hash: fields[0], Subject: fields[2], Amend: action != ReflogAction}, nil
+}
+
+// UndoCommit moves HEAD back to where it was before commit, which LastToolCommit returned, so that
+// the changes of the commit are staged again and the working tree is kept, like "git reset --soft HEAD~".
+// Undoing an amend restores the commit that was amended, and undoing the first commit of a branch
+// leaves the branch without commits.
+func UndoCommit(commit ToolCommit) error {
+	target := "HEAD@{1}"
+	if !commit.Amend {
+		target = "HEAD~"
+	}
+	if _, err := execGitCommand("git", "rev-parse", "--verify", "--quiet", target); err != nil {
+		if _, err := execGitCommand("git", "update-ref", "-d", "HEAD"); err != nil {
+			return fmt.Errorf("error undoing the commit: %w", err)
+		}
+		return nil
+	}
+	if _, err := execGitCommand("git", "reset", "--soft", target); err != nil {
+		return fmt.Errorf("error undoing the commit: %w", err)
+	}
+	return nil
+}
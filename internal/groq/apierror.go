@@ -0,0 +1,124 @@
+package groq
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxPlainErrorLength limits the length of an error body that is not JSON, shown as it is.
+const maxPlainErrorLength = 200
+
+// APIError is an error the API reported for a request, with the details found in the response body.
+type APIError struct {
+	StatusCode int    // HTTP status code of the response
+	Message    string // Description of the error, e.g. "model `llama3-8b-8192` has been decommissioned"
+	Type       string // Kind of error, e.g. "invalid_request_error"; empty if not reported
+	Code       string // Machine-readable error code, e.g. "model_decommissioned"; empty if not reported
+}
+
+// Error describes the error with its message and status, e.g. "model `llama3-8b-8192` has been
+// decommissioned (status code 400, model_decommissioned)", or with the status alone if the body
+// did not describe it.
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		if e.StatusCode == http.StatusTooManyRequests {
+			return "rate limited (status code 429)"
+		}
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+	// Some providers report errors with a successful status, which is not worth mentioning.
+	var details []string
+	if e.StatusCode != http.StatusOK {
+		details = append(details, fmt.Sprintf("status code %d", e.StatusCode))
+	}
+	if e.Code != "" && !strings.Contains(e.Message, e.Code) {
+		details = append(details, e.Code)
+	}
+	if len(details) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, strings.Join(details, ", "))
+}
+
+// apiErrorBody is the error in a response body. Most providers follow the OpenAI API with
+// {"error": {"message": ..., "type": ..., "code": ...}}; Google APIs report a "status" instead of a
+// type and a numeric code, and a few other servers send {"error": "..."}, {"message": ...} or {"detail": ...}.
+type apiErrorBody struct {
+	Error   json.RawMessage `json:"error"`
+	Message string          `json:"message"`
+	Detail  json.RawMessage `json:"detail"`
+}
+
+// apiErrorDetails are the details of an error in the format of the OpenAI API.
+type apiErrorDetails struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Status  string          `json:"status"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// statusError returns the error for a response with an unsuccessful status, wrapping ErrRequest
+// and an *APIError with the details from body.
+func statusError(resp *http.Response, body []byte) error {
+	return fmt.Errorf("%w: %w", ErrRequest, parseAPIError(resp.StatusCode, body))
+}
+
+// parseAPIError reads the details of an error from the body of a response with the status code.
+// A short body that is not JSON is taken as the message; other bodies, such as HTML error pages of
+// proxies, leave the message empty.
+func parseAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	body = bytes.TrimSpace(body)
+	// Google APIs wrap the error in an array.
+	if bytes.HasPrefix(body, []byte("[")) {
+		var list []json.RawMessage
+		if json.Unmarshal(body, &list) == nil && len(list) > 0 {
+			body = list[0]
+		}
+	}
+
+	var parsed apiErrorBody
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		if text := string(body); len(text) <= maxPlainErrorLength && !strings.HasPrefix(text, "<") && !strings.Contains(text, "\n") {
+			apiErr.Message = text
+		}
+		return apiErr
+	}
+
+	var details apiErrorDetails
+	switch {
+	case json.Unmarshal(parsed.Error, &details) == nil && details.Message != "":
+		apiErr.Message = details.Message
+		apiErr.Type = cmp.Or(details.Type, details.Status)
+		apiErr.Code = rawString(details.Code)
+	case rawString(parsed.Error) != "":
+		apiErr.Message = rawString(parsed.Error)
+	case parsed.Message != "":
+		apiErr.Message = parsed.Message
+	default:
+		apiErr.Message = rawString(parsed.Detail)
+	}
+	// A numeric code repeating the status code says nothing new.
+	if apiErr.Code == fmt.Sprint(statusCode) {
+		apiErr.Code = ""
+	}
+	apiErr.Message = strings.TrimSpace(apiErr.Message)
+	return apiErr
+}
+
+// rawString returns a JSON string or number as text, or "" for anything else.
+func rawString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
@@ -2,13 +2,19 @@ package groq
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
 )
 
 const (
@@ -16,31 +22,104 @@ const (
 	contentType = "application/json"                                // The content type for API requests
 )
 
+// ErrRequest is wrapped by the errors of requests that reached out to the API and failed, e.g. because
+// the server could not be reached or answered with an error, as opposed to errors in the configuration.
+var ErrRequest = errors.New("API request failed")
+
 // Message represents a single message in the conversation with the AI.
 type Message struct {
 	Role    string `json:"role"`    // The role of the sender (e.g., "user", "assistant")
 	Content string `json:"content"` // The content of the message
 }
 
+// Parameters holds optional sampling parameters for a completion request.
+// Nil fields are omitted from the request so the provider's defaults apply.
+type Parameters struct {
+	Temperature *float64 `json:"temperature,omitempty"` // Sampling temperature, lower is more deterministic
+	MaxTokens   *int     `json:"max_tokens,omitempty"`  // Maximum number of tokens to generate
+	TopP        *float64 `json:"top_p,omitempty"`       // Nucleus sampling probability
+	Seed        *int     `json:"seed,omitempty"`        // Seed of the sampling, for repeatable replies where supported
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"` // Format the reply must follow, e.g. a JSON object
+}
+
+// ResponseFormat asks for a reply in a structured format, see the JSON mode of the OpenAI API.
+type ResponseFormat struct {
+	Type       string      `json:"type"`                  // "json_object", or "json_schema" to enforce JSONSchema
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"` // Schema of the reply with the "json_schema" type
+}
+
+// JSONSchema names a JSON schema that the reply must be valid against.
+type JSONSchema struct {
+	Name   string         `json:"name"`             // Name of the schema, letters, digits, "_" and "-" only
+	Schema map[string]any `json:"schema"`           // The JSON schema itself
+	Strict bool           `json:"strict,omitempty"` // Whether the provider must keep to the schema exactly
+}
+
 // CompletionRequest holds the request payload sent to the API for generating a completion.
 type CompletionRequest struct {
-	Model    string    `json:"model"`    // The model to use for generating completions
-	Messages []Message `json:"messages"` // The messages that make up the conversation context
+	Model    string    `json:"model"`            // The model to use for generating completions
+	Messages []Message `json:"messages"`         // The messages that make up the conversation context
+	Stream   bool      `json:"stream,omitempty"` // Whether the completion is sent in pieces as it is generated
+	Parameters
 }
 
 // CompletionResponse represents the response payload from the API.
 type CompletionResponse struct {
+	ID      string `json:"id"`    // The identifier of the completion, for support requests
+	Model   string `json:"model"` // The model that actually generated the completion
 	Choices []struct {
 		Message struct {
 			Content string `json:"content"` // The generated content from the AI
 		} `json:"message"` // The message structure in the API response
+		FinishReason string `json:"finish_reason"` // Why generation stopped, e.g. "stop" or "length"
 	} `json:"choices"` // The list of choices returned by the API
+	Usage Usage `json:"usage"` // The tokens the request used, if the provider reports them
+}
+
+// Usage counts the tokens used by completion requests, as reported by the API.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`     // Tokens in the messages sent
+	CompletionTokens int `json:"completion_tokens"` // Tokens in the generated completion
+	TotalTokens      int `json:"total_tokens"`      // Sum of prompt and completion tokens
+}
+
+// ModelInfo describes a single entry of the models endpoint response.
+type ModelInfo struct {
+	ID   string `json:"id"`   // The model identifier passed in completion requests
+	Type string `json:"type"` // The model type, reported by some providers (e.g., "chat", "embedding")
 }
 
 // Client represents a GROQ API client.
+// It can also talk to any other OpenAI-compatible chat completions endpoint.
 type Client struct {
-	httpClient *http.Client // The HTTP client used to make requests
-	apiKey     string       // The API key for authenticating with the GROQ API
+	handler Handler         // Sends requests through the interceptors to the API
+	baseURL string          // The chat completions endpoint requests are sent to
+	ctx     context.Context // Context the requests are sent with, see SetContext
+
+	mu      sync.Mutex    // Guards usage and elapsed, since requests may run concurrently
+	usage   Usage         // Tokens used by all completion requests so far
+	elapsed time.Duration // Time taken by all successful completion requests so far
+}
+
+// TokenFunc returns a bearer token for a request.
+// It is called before every request so implementations can refresh expired tokens.
+type TokenFunc func() (string, error)
+
+// ClientOptions configures a Client for an OpenAI-compatible chat completions endpoint.
+type ClientOptions struct {
+	BaseURL string        // The chat completions endpoint; defaults to BaseURL
+	APIKey  string        // The bearer token sent with each request; may be empty for local servers
+	Timeout time.Duration // The HTTP timeout; defaults to 30 seconds
+	Token   TokenFunc     // Optional bearer token source that takes precedence over APIKey
+	Retry   RetryPolicy   // How failed requests are retried; the zero value never retries
+
+	// Transport sends the requests, e.g. through a proxy; nil uses http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// Interceptors handle every attempt of a request, the first one outermost, after it is retried
+	// and before the Authorization header is set, see Interceptor.
+	Interceptors []Interceptor
 }
 
 // NewClient creates a new GROQ API client.
@@ -54,64 +133,271 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("GROQ_APIKEY not set")
 	}
 
+	return NewClientWithOptions(ClientOptions{APIKey: apiKey}), nil
+}
+
+// NewClientWithOptions creates a new client for the endpoint described by opts.
+// Unset options fall back to the GROQ defaults.
+func NewClientWithOptions(opts ClientOptions) *Client {
+	if opts.BaseURL == "" {
+		opts.BaseURL = BaseURL
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	// Requests go through opts.Transport, e.g. to use a proxy, and time out after opts.Timeout.
+	httpClient := &http.Client{Timeout: opts.Timeout, Transport: opts.Transport}
+
+	// Retries wrap everything else, so that every attempt is authenticated and described anew.
+	interceptors := []Interceptor{retryInterceptor(opts.Retry)}
+	interceptors = append(interceptors, opts.Interceptors...)
+	interceptors = append(interceptors, authInterceptor(opts.APIKey, opts.Token), debugInterceptor)
+
 	return &Client{
-		httpClient: &http.Client{Timeout: 30 * time.Second}, // Set a timeout for HTTP requests
-		apiKey:     apiKey,                                  // Store the API key in the client
-	}, nil
+		handler: chain(sendHTTP(httpClient), interceptors...),
+		baseURL: opts.BaseURL,
+		ctx:     context.Background(),
+	}
+}
+
+// SetContext makes the requests sent from now on, and the waits between their retries, end once ctx
+// is done. It must not be called while requests are sent.
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
 }
 
 // GenerateCompletion sends a request to the GROQ API and returns the generated completion content.
-// It takes a slice of messages that represents the conversation context and the model to be used.
-func (c *Client) GenerateCompletion(messages []Message, model string) (string, error) {
+// It takes a slice of messages that represents the conversation context, the model to be used and optional sampling parameters.
+func (c *Client) GenerateCompletion(messages []Message, model string, params Parameters) (string, error) {
+	return c.complete(messages, model, params, nil)
+}
+
+// GenerateCompletionStream is like GenerateCompletion, but asks the API to stream the completion and
+// passes every piece of its text to onDelta as it arrives. A provider that ignores the request and
+// answers with the whole completion at once works too; onDelta then receives all of it.
+func (c *Client) GenerateCompletionStream(messages []Message, model string, params Parameters, onDelta func(string)) (string, error) {
+	return c.complete(messages, model, params, onDelta)
+}
+
+// complete sends a completion request, streamed if onDelta is not nil, and returns the completion content.
+func (c *Client) complete(messages []Message, model string, params Parameters, onDelta func(string)) (string, error) {
 	// Marshal the request body into JSON format
 	reqBody, err := json.Marshal(CompletionRequest{
-		Model:    model,
-		Messages: messages,
+		Model:      model,
+		Messages:   messages,
+		Stream:     onDelta != nil,
+		Parameters: params,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	// Create a new HTTP request
-	req, err := http.NewRequest(http.MethodPost, BaseURL, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.baseURL, bytes.NewReader(reqBody))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
-
-	// Set the necessary headers for the request
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", contentType)
+	if onDelta != nil {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
-	// Send the request to the GROQ API
-	resp, err := c.httpClient.Do(req)
+	// Send the request to the GROQ API through the interceptors
+	start := time.Now()
+	resp, err := c.handler(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close() // Ensure the response body is closed
+	defer resp.Body.Close()
 
 	// Check if the response status code indicates success
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		body, err := readAll(resp)
+		if err != nil {
+			return "", err
+		}
+		return "", statusError(resp, body)
 	}
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	read := readStream(onDelta)
+	if read == nil {
+		read = readAll
+	}
+	body, err := read(resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return "", err
 	}
 
 	// Unmarshal the response body into the CompletionResponse struct
 	var completionResp CompletionResponse
 	if err := json.Unmarshal(body, &completionResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		debugCompletion(nil, body)
+		return "", fmt.Errorf("%w: failed to unmarshal response: %w", ErrRequest, err)
 	}
+	debugCompletion(&completionResp, nil)
+
+	c.mu.Lock()
+	c.usage.PromptTokens += completionResp.Usage.PromptTokens
+	c.usage.CompletionTokens += completionResp.Usage.CompletionTokens
+	c.usage.TotalTokens += completionResp.Usage.TotalTokens
+	c.elapsed += time.Since(start)
+	c.mu.Unlock()
 
-	// Check if any completion choices were returned
+	// Check if any completion choices were returned; some providers report errors with a successful status.
 	if len(completionResp.Choices) == 0 {
-		return "", fmt.Errorf("no completion choices returned")
+		if apiErr := parseAPIError(resp.StatusCode, body); apiErr.Message != "" {
+			return "", fmt.Errorf("%w: %w", ErrRequest, apiErr)
+		}
+		return "", fmt.Errorf("%w: no completion choices returned", ErrRequest)
 	}
 
 	// Return the content of the first completion choice
 	return completionResp.Choices[0].Message.Content, nil
 }
 
+// Usage returns the tokens used by all completion requests of the client so far.
+// Providers that do not report usage count as zero.
+func (c *Client) Usage() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage
+}
+
+// Elapsed returns the time taken by all successful completion requests of the client so far,
+// retries and streaming included.
+func (c *Client) Elapsed() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.elapsed
+}
+
+// ListModels returns the IDs of the chat models available from the API, sorted by name.
+// It queries the OpenAI-compatible models endpoint next to the chat completions endpoint.
+func (c *Client) ListModels() ([]string, error) {
+	modelsURL := strings.TrimSuffix(c.baseURL, "/chat/completions") + "/models"
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, modelsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.handler(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := readAll(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp, body)
+	}
+
+	// Most providers wrap the list in a "data" field, some return a bare array.
+	var models []ModelInfo
+	var wrapped struct {
+		Data []ModelInfo `json:"data"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil {
+		models = wrapped.Data
+	} else if err := json.Unmarshal(body, &models); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal response: %w", ErrRequest, err)
+	}
+
+	var ids []string
+	for _, model := range models {
+		// Skips embedding, image and other non-chat models when the provider reports a type.
+		if model.Type != "" && model.Type != "chat" {
+			continue
+		}
+		ids = append(ids, model.ID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// debugRequest records a request at the debug level: the messages of a completion request as plain
+// text, which is easier to read than the escaped payload, the headers with credentials redacted, and
+// the payload.
+func debugRequest(req *http.Request, messages []Message, payload []byte) {
+	if !logging.Enabled(slog.LevelDebug) {
+		return
+	}
+	attrs := []any{"method", req.Method, "url", req.URL.String()}
+	for i, message := range messages {
+		attrs = append(attrs, slog.String(fmt.Sprintf("message.%d.%s", i, message.Role), strings.TrimRight(message.Content, "\n")))
+	}
+	var headers []any
+	for _, name := range sortedKeys(req.Header) {
+		value := strings.Join(req.Header[name], ", ")
+		if name == "Authorization" || strings.Contains(strings.ToLower(name), "key") {
+			value = "[REDACTED]"
+		}
+		headers = append(headers, name, value)
+	}
+	attrs = append(attrs, slog.Group("header", headers...))
+	if len(payload) > 0 {
+		var indented bytes.Buffer
+		if json.Indent(&indented, payload, "", "  ") != nil {
+			indented.Reset()
+			indented.Write(payload)
+		}
+		attrs = append(attrs, "payload", indented.String())
+	}
+	logging.Debug("API request", attrs...)
+}
+
+// debugResponse records a response at the debug level: its status and timing, rate limit headers,
+// and the body, if it is not nil.
+func debugResponse(resp *http.Response, elapsed time.Duration, body []byte) {
+	if !logging.Enabled(slog.LevelDebug) {
+		return
+	}
+	attrs := []any{"status", resp.Status, "elapsed", elapsed.Round(time.Millisecond)}
+	var headers []any
+	for _, name := range sortedKeys(resp.Header) {
+		if lower := strings.ToLower(name); strings.Contains(lower, "ratelimit") || lower == "retry-after" || strings.HasSuffix(lower, "request-id") {
+			headers = append(headers, name, strings.Join(resp.Header[name], ", "))
+		}
+	}
+	attrs = append(attrs, slog.Group("header", headers...))
+	if body != nil {
+		attrs = append(attrs, "body", strings.TrimRight(string(body), "\n"))
+	}
+	logging.Debug("API response", attrs...)
+}
+
+// debugCompletion records the completion of a successful response at the debug level: its metadata,
+// or the body if it could not be parsed.
+func debugCompletion(completion *CompletionResponse, body []byte) {
+	if !logging.Enabled(slog.LevelDebug) {
+		return
+	}
+	if completion == nil {
+		logging.Debug("API completion could not be parsed", "body", strings.TrimRight(string(body), "\n"))
+		return
+	}
+	var attrs []any
+	if completion.ID != "" {
+		attrs = append(attrs, "id", completion.ID)
+	}
+	if completion.Model != "" {
+		attrs = append(attrs, "model", completion.Model)
+	}
+	for i, choice := range completion.Choices {
+		attrs = append(attrs, slog.Group(fmt.Sprintf("choice.%d", i), "finish_reason", choice.FinishReason, "characters", len(choice.Message.Content)))
+	}
+	usage := completion.Usage
+	attrs = append(attrs, "prompt_tokens", usage.PromptTokens, "completion_tokens", usage.CompletionTokens, "total_tokens", usage.TotalTokens)
+	logging.Debug("API completion", attrs...)
+}
+
+// sortedKeys returns the names of header in alphabetical order.
+func sortedKeys(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,139 @@
+package groq
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// Handler sends a request to the API and returns the response, whose body the caller closes.
+// Errors that prevented a response wrap ErrRequest.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Interceptor handles a request on its way to the API: it may change the request, pass it on to next,
+// even several times, and inspect or replace the response. Cross-cutting behaviors such as
+// authentication, retries, logging and metrics are interceptors, so requests need not know about them.
+// A request with a body can be sent again with a copy of the body from its GetBody.
+type Interceptor func(req *http.Request, next Handler) (*http.Response, error)
+
+// chain returns a handler that passes requests through interceptors, the first one outermost, to last.
+func chain(last Handler, interceptors ...Interceptor) Handler {
+	handler := last
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, next)
+		}
+	}
+	return handler
+}
+
+// sendHTTP returns the handler that sends requests with httpClient, last in the chain.
+func sendHTTP(httpClient *http.Client) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			var certErr *tls.CertificateVerificationError
+			if errors.As(err, &certErr) {
+				return nil, fmt.Errorf("%w: %w; if a proxy inspects TLS traffic, set CA_CERT_FILE to the certificate of its CA", ErrRequest, err)
+			}
+			return nil, fmt.Errorf("%w: %w", ErrRequest, err)
+		}
+		return resp, nil
+	}
+}
+
+// authInterceptor sets the Authorization header of every request to a bearer token: one from token
+// if it is not nil, obtained again for every attempt so that it can be refreshed, or else apiKey,
+// unless it is empty as for local servers.
+func authInterceptor(apiKey string, token TokenFunc) Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		bearer := apiKey
+		if token != nil {
+			var err error
+			if bearer, err = token(); err != nil {
+				return nil, fmt.Errorf("failed to obtain access token: %w", err)
+			}
+		}
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		return next(req)
+	}
+}
+
+// debugInterceptor records every request and response at the debug level, see debugRequest and
+// debugResponse. Only the bodies of unsuccessful responses are shown, since successful ones are
+// described once they have been parsed, see debugCompletion.
+func debugInterceptor(req *http.Request, next Handler) (*http.Response, error) {
+	if !logging.Enabled(slog.LevelDebug) {
+		return next(req)
+	}
+	payload, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	var completion CompletionRequest
+	if json.Unmarshal(payload, &completion) != nil {
+		completion.Messages = nil
+	}
+	debugRequest(req, completion.Messages, payload)
+
+	start := time.Now()
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	if resp.StatusCode != http.StatusOK {
+		// Reads the body to show it, and puts it back for the caller.
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read response body: %w", ErrRequest, err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	debugResponse(resp, time.Since(start), body)
+	return resp, nil
+}
+
+// requestBody returns a copy of the body of req, nil if it has none.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// resend returns a copy of req to send it again, with a new copy of its body.
+func resend(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// streamed reports whether req asks for a streamed response, whose body is read as it arrives.
+func streamed(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+}
@@ -0,0 +1,199 @@
+package groq
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// RetryPolicy controls how requests that failed for a reason that may pass are retried: network
+// errors, timeouts, the server errors 408, 500, 502, 503 and 504, and rate limits (429), for which
+// the time given by the Retry-After or x-ratelimit-reset-* headers is waited instead of the backoff.
+// Other errors, such as an invalid API key or request, are never retried. Requests are only ever
+// retried before a usable response has arrived, so retrying never produces a second completion
+// the caller would see.
+type RetryPolicy struct {
+	Retries    int           // Number of retries after the first attempt; 0 disables retrying
+	Backoff    time.Duration // Delay before the first retry, doubled for every further retry
+	MaxElapsed time.Duration // Time since the first attempt after which no retry is started; 0 for no limit
+
+	// MaxRateLimitWait is the longest wait for a rate limit to reset after a 429 response. A longer
+	// wait fails right away with the time to try again, and 0 never waits.
+	MaxRateLimitWait time.Duration
+}
+
+// notify is told about every retry, see SetNotify.
+var notify func(message string)
+
+// SetNotify makes f receive a short description of every retry, such as "API request failed: unexpected
+// status code: 503; retrying in 1.2s (attempt 2/3)", e.g. to show it to the user. A nil f stops it.
+func SetNotify(f func(message string)) {
+	notify = f
+}
+
+// retryableStatus reports whether a response with the status code may succeed when sent again.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryInterceptor retries the requests that failed in a way that may pass, as policy allows.
+// Network errors and responses with a retryable status are returned as an error once no retry is
+// left; responses with any other status are returned for the caller to check, with their body
+// still readable. The body of a successful response is read here, so that an error while reading
+// it is retried too, except for a streamed response, which may have been passed on in part already.
+func retryInterceptor(policy RetryPolicy) Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		first := time.Now()
+		for attempt := 1; ; attempt++ {
+			// The body of a request can only be sent once, so every attempt gets a copy.
+			attemptReq, err := resend(req)
+			if err != nil {
+				return nil, err
+			}
+			resp, body, err := sendAttempt(attemptReq, next)
+
+			// Keeps the response unless it failed in a way that may pass, and a retry is allowed.
+			var failure error
+			delay := policy.Backoff << (attempt - 1)
+			// Waits between half and all of the delay, so that clients failing together do not retry together.
+			if delay > 0 {
+				delay = delay/2 + rand.N(delay/2+1)
+			}
+			var certErr *tls.CertificateVerificationError
+			switch {
+			case errors.As(err, &certErr):
+				// An untrusted certificate stays untrusted, so the request is not retried.
+				return nil, err
+			case err != nil && !errors.Is(err, ErrRequest):
+				// Errors that did not come from the API, such as a failure to obtain a token, are not retried.
+				return nil, err
+			case err != nil:
+				failure = err
+			case resp.StatusCode == http.StatusTooManyRequests:
+				// Waits until the rate limit resets, if the response tells when, but not longer than allowed.
+				if wait, ok := rateLimitWait(resp.Header, time.Now()); ok {
+					delay = wait
+				}
+				if delay > policy.MaxRateLimitWait {
+					return nil, fmt.Errorf("%w; try again in %s", statusError(resp, body), delay.Round(time.Second))
+				}
+				failure = statusError(resp, body)
+			case retryableStatus(resp.StatusCode):
+				failure = statusError(resp, body)
+			default:
+				return resp, nil
+			}
+			if attempt > policy.Retries || (policy.MaxElapsed > 0 && time.Since(first)+delay > policy.MaxElapsed) {
+				return nil, failure
+			}
+			if notify != nil {
+				notify(fmt.Sprintf("%v; retrying in %.1fs (attempt %d/%d)", failure, delay.Seconds(), attempt+1, policy.Retries+1))
+			}
+			logging.Debug("retrying API request", "error", failure, "delay", delay, "attempt", attempt+1, "attempts", policy.Retries+1)
+			// Stops waiting when the request is canceled, e.g. by the caller of the library.
+			timer := time.NewTimer(delay)
+			select {
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// sendAttempt sends a single attempt of req with next and reads the response body, unless the
+// response is a successful stream. The body read is put back into the response, so that the caller
+// can read it again.
+func sendAttempt(req *http.Request, next Handler) (*http.Response, []byte, error) {
+	resp, err := next(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusOK && streamed(req) {
+		return resp, nil, nil
+	}
+	defer resp.Body.Close()
+	body, err := readAll(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, body, nil
+}
+
+// readAll reads the whole body of resp.
+func readAll(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read response body: %w", ErrRequest, err)
+	}
+	return body, nil
+}
+
+// rateLimitWait returns how long to wait before a rate limited request may succeed, from the
+// Retry-After header, given in seconds or as a date, or from the x-ratelimit-reset-* headers sent
+// by OpenAI-compatible APIs, e.g. "x-ratelimit-reset-tokens: 7.66s". The reset of an exhausted limit,
+// whose x-ratelimit-remaining-* header is 0, is preferred over the others.
+func rateLimitWait(header http.Header, now time.Time) (time.Duration, bool) {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return max(time.Duration(seconds*float64(time.Second)), 0), true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return max(date.Sub(now), 0), true
+		}
+	}
+
+	var wait, exhausted time.Duration
+	found, foundExhausted := false, false
+	for name := range header {
+		limit, ok := strings.CutPrefix(strings.ToLower(name), "x-ratelimit-reset")
+		if !ok {
+			continue
+		}
+		reset, ok := parseReset(header.Get(name), now)
+		if !ok {
+			continue
+		}
+		wait, found = max(wait, reset), true
+		// Pairs e.g. x-ratelimit-reset-tokens with x-ratelimit-remaining-tokens.
+		if remaining := header.Get("x-ratelimit-remaining" + limit); remaining == "0" {
+			exhausted, foundExhausted = max(exhausted, reset), true
+		}
+	}
+	if foundExhausted {
+		return exhausted, true
+	}
+	return wait, found
+}
+
+// parseReset parses the value of a x-ratelimit-reset-* header: a duration such as "2m59.56s",
+// a number of seconds, or a point in time in RFC 3339 format.
+func parseReset(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if d, err := time.ParseDuration(value); err == nil {
+		return max(d, 0), true
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return max(time.Duration(seconds*float64(time.Second)), 0), true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
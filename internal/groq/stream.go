@@ -0,0 +1,105 @@
+package groq
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// streamChunk is a piece of a streamed completion, sent as a server-sent event.
+type streamChunk struct {
+	ID      string `json:"id"`    // The identifier of the completion
+	Model   string `json:"model"` // The model that generates the completion
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"` // The text added by the chunk
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"` // Set in the last chunk of the choice
+	} `json:"choices"`
+	Usage *Usage `json:"usage"` // Sent in the last chunk by providers that report it
+	XGroq *struct {
+		Usage *Usage `json:"usage"` // Where Groq reports the usage of a streamed completion
+	} `json:"x_groq"`
+	Error *struct {
+		Message string `json:"message"` // Why generation failed after the stream had started
+	} `json:"error"`
+}
+
+// readStream returns a function that reads a streamed completion from a successful response, passes
+// every piece of text to onDelta, and returns the completion in the form of an unstreamed response
+// body, so that it is handled like one. A response that is not an event stream is read whole and
+// its text passed to onDelta at once. It returns nil if onDelta is nil, so the response is read whole.
+func readStream(onDelta func(string)) func(*http.Response) ([]byte, error) {
+	if onDelta == nil {
+		return nil
+	}
+	return func(resp *http.Response) ([]byte, error) {
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+			body, err := readAll(resp)
+			if err != nil {
+				return nil, err
+			}
+			var completion CompletionResponse
+			if json.Unmarshal(body, &completion) == nil && len(completion.Choices) > 0 {
+				onDelta(completion.Choices[0].Message.Content)
+			}
+			return body, nil
+		}
+
+		var id, model, finishReason string
+		var content strings.Builder
+		var usage Usage
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			// Each event is a "data:" line; comments and other fields are ignored.
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+			var chunk streamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return nil, fmt.Errorf("%w: failed to unmarshal stream chunk: %w", ErrRequest, err)
+			}
+			if chunk.Error != nil {
+				return nil, fmt.Errorf("%w: %s", ErrRequest, chunk.Error.Message)
+			}
+			id, model = cmp.Or(chunk.ID, id), cmp.Or(chunk.Model, model)
+			if chunk.Usage != nil {
+				usage = *chunk.Usage
+			} else if chunk.XGroq != nil && chunk.XGroq.Usage != nil {
+				usage = *chunk.XGroq.Usage
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			if delta := chunk.Choices[0].Delta.Content; delta != "" {
+				content.WriteString(delta)
+				onDelta(delta)
+			}
+			finishReason = cmp.Or(chunk.Choices[0].FinishReason, finishReason)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%w: failed to read response body: %w", ErrRequest, err)
+		}
+
+		// Assembles the body an unstreamed request would have received.
+		return json.Marshal(map[string]any{
+			"id":    id,
+			"model": model,
+			"choices": []map[string]any{{
+				"message":       map[string]string{"content": content.String()},
+				"finish_reason": finishReason,
+			}},
+			"usage": usage,
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Package history keeps a local log of the generated commit messages, so that earlier suggestions
+// can be found and reused.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+)
+
+// fileName is the name of the history file, stored next to the global configuration file.
+const fileName = ".ai-commit-history.jsonl"
+
+// Status tells what became of a generated message.
+type Status string
+
+const (
+	StatusAccepted Status = "accepted" // The message was committed
+	StatusRejected Status = "rejected" // The message was declined, replaced or not picked
+	StatusPrinted  Status = "printed"  // The message was printed or written for other tools, e.g. with -dry-run or by the hook
+)
+
+// Entry is a generated message in the history.
+type Entry struct {
+	Time     time.Time `json:"time"`     // When the message was generated
+	Repo     string    `json:"repo"`     // Root of the repository the message was generated in, if any
+	Provider string    `json:"provider"` // Provider the message was generated with
+	Model    string    `json:"model"`    // Model the message was generated with
+	Status   Status    `json:"status"`   // What became of the message
+	Message  string    `json:"message"`  // The message as shown to the user
+}
+
+// Path returns the path of the history file.
+func Path() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), fileName)
+}
+
+// Append adds entries to the end of the history and drops the oldest entries beyond HISTORY_SIZE.
+// Nothing is recorded when HISTORY_SIZE is 0.
+func Append(entries ...Entry) error {
+	size, _, err := config.GetInt("HISTORY_SIZE")
+	if err != nil || size == 0 || len(entries) == 0 {
+		return err
+	}
+
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode history entry: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	file, err := os.OpenFile(Path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return trim(size)
+}
+
+// Load returns the entries of the history, oldest first. Lines that cannot be read are skipped.
+func Load() ([]Entry, error) {
+	lines, err := readLines()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, line := range lines {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// trim rewrites the history with only its last size entries if it has more.
+func trim(size int) error {
+	lines, err := readLines()
+	if err != nil || len(lines) <= size {
+		return err
+	}
+	data := append(bytes.Join(lines[len(lines)-size:], []byte("\n")), '\n')
+	// Replaces the file in one step, so that an interrupted trim never loses the history.
+	tmp := Path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, Path()); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// readLines returns the non-empty lines of the history file, or nothing if it does not exist.
+func readLines() ([][]byte, error) {
+	file, err := os.Open(Path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, bytes.Clone(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return lines, nil
+}
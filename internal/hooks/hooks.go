@@ -0,0 +1,60 @@
+// Package hooks runs the shell commands configured to take part in generating and committing a
+// message: PRE_GENERATE_HOOK gets the diff before it is sent to the AI, POST_GENERATE_HOOK the
+// generated message, and POST_COMMIT_HOOK the message that was committed. The first two can replace
+// what they get by printing something else, and stop the command by failing.
+//
+// These are not Git hooks: they run for the commands of the tool only, whichever way Git is called.
+// Nor do they come with a repository: the keys are not RepoSafe, so only the user configures them.
+package hooks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/shell"
+)
+
+// The configuration keys of the hooks, which Run takes.
+const (
+	PreGenerate  = "PRE_GENERATE_HOOK"
+	PostGenerate = "POST_GENERATE_HOOK"
+	PostCommit   = "POST_COMMIT_HOOK"
+)
+
+// ErrVetoed is returned when a hook fails, which stops the command.
+var ErrVetoed = errors.New("stopped by a hook")
+
+// Run runs the hook configured under key, one of the constants above, in dir with input on stdin,
+// and returns what it printed, or input if it printed nothing but whitespace. It returns input
+// right away if the hook is not configured, and an error wrapping ErrVetoed if the hook fails.
+// The hook's stderr is passed through, so that it can tell why it failed.
+func Run(key, dir, input string) (string, error) {
+	command, err := config.GetConfig(key)
+	if err != nil || command == "" {
+		return input, err
+	}
+
+	cmd := shell.Command(command)
+	// Tells a script shared by several hooks which one it runs as, e.g. "post_commit".
+	cmd.Env = append(os.Environ(), "AI_COMMIT_HOOK="+strings.ToLower(strings.TrimSuffix(key, "_HOOK")))
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	logging.Info("running hook", "key", key, "command", command)
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s failed: %w", ErrVetoed, key, err)
+	}
+	if strings.TrimSpace(stdout.String()) == "" {
+		return input, nil
+	}
+	logging.Debug("hook replaced its input", "key", key)
+	return stdout.String(), nil
+}
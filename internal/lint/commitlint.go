@@ -0,0 +1,132 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// conventionalTypes are the types allowed by @commitlint/config-conventional.
+var conventionalTypes = []string{"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test"}
+
+// commitlintConfig is the part of a commitlint configuration the linter understands.
+type commitlintConfig struct {
+	Extends []string                     `json:"extends"`
+	Rules   map[string][]json.RawMessage `json:"rules"`
+}
+
+// commitlintRule is a rule of a commitlint configuration: [level, "always" | "never", value].
+// Level 0 disables the rule.
+type commitlintRule struct {
+	level  int
+	always bool
+	value  json.RawMessage
+}
+
+// applyCommitlint overrides rules with the commitlint configuration in root, if there is one.
+// Only JSON configurations are read: .commitlintrc.json, .commitlintrc and the "commitlint" field of package.json.
+func applyCommitlint(rules *Rules, root string) error {
+	cfg, path, err := findCommitlintConfig(root)
+	if err != nil || cfg == nil {
+		return err
+	}
+
+	// Starts from the conventional preset, which most configurations extend.
+	if slices.Contains(cfg.Extends, "@commitlint/config-conventional") {
+		rules.MaxHeaderLength = 100
+		rules.MaxBodyLineLength = 100
+		rules.Types = conventionalTypes
+		rules.RequireType = true
+		rules.NoTrailingPeriod = true
+	}
+
+	for name, raw := range cfg.Rules {
+		rule, err := parseCommitlintRule(raw)
+		if err != nil {
+			return fmt.Errorf("invalid rule %s in %s: %w", name, path, err)
+		}
+		switch name {
+		case "header-max-length":
+			rules.MaxHeaderLength = rule.intValue()
+		case "body-max-line-length":
+			rules.MaxBodyLineLength = rule.intValue()
+		case "type-enum":
+			rules.Types = nil
+			if rule.level > 0 && rule.always {
+				if err := json.Unmarshal(rule.value, &rules.Types); err != nil {
+					return fmt.Errorf("invalid rule %s in %s: %w", name, path, err)
+				}
+			}
+		case "type-empty":
+			rules.RequireType = rule.level > 0 && !rule.always
+		case "subject-full-stop":
+			rules.NoTrailingPeriod = rule.level > 0 && !rule.always
+		}
+	}
+	return nil
+}
+
+// findCommitlintConfig reads the first commitlint configuration found in root.
+// It returns nil if the repository has none.
+func findCommitlintConfig(root string) (*commitlintConfig, string, error) {
+	for _, name := range []string{".commitlintrc.json", ".commitlintrc"} {
+		path := filepath.Join(root, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, path, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var cfg commitlintConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, path, fmt.Errorf("failed to parse %s, only JSON configurations are supported: %w", path, err)
+		}
+		return &cfg, path, nil
+	}
+
+	path := filepath.Join(root, "package.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path, nil
+	}
+	var pkg struct {
+		Commitlint *commitlintConfig `json:"commitlint"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, path, nil
+	}
+	return pkg.Commitlint, path, nil
+}
+
+// parseCommitlintRule decodes a rule given as [level], [level, applicable] or [level, applicable, value].
+func parseCommitlintRule(raw []json.RawMessage) (commitlintRule, error) {
+	rule := commitlintRule{always: true}
+	if len(raw) == 0 {
+		return rule, fmt.Errorf("missing level")
+	}
+	if err := json.Unmarshal(raw[0], &rule.level); err != nil {
+		return rule, fmt.Errorf("invalid level: %w", err)
+	}
+	if len(raw) > 1 {
+		var applicable string
+		if err := json.Unmarshal(raw[1], &applicable); err != nil {
+			return rule, fmt.Errorf("invalid applicable value: %w", err)
+		}
+		rule.always = applicable != "never"
+	}
+	if len(raw) > 2 {
+		rule.value = raw[2]
+	}
+	return rule, nil
+}
+
+// intValue returns the numeric value of an enabled rule, or 0 if the rule is disabled.
+func (r commitlintRule) intValue() int {
+	var n int
+	if r.level == 0 || json.Unmarshal(r.value, &n) != nil {
+		return 0
+	}
+	return n
+}
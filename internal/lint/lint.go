@@ -0,0 +1,153 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+)
+
+// ErrViolations is returned when a commit message still breaks the rules after every retry.
+var ErrViolations = errors.New("commit message breaks the lint rules")
+
+var (
+	// headerPattern matches a Conventional Commits header and captures its type and subject.
+	headerPattern = regexp.MustCompile(`^([A-Za-z]+)(\([^)]*\))?!?: (.*)$`)
+	// prefixPattern matches the prefixes put before the subject by the other styles: the bracket type
+	// and file list of the default style, and gitmoji shortcodes.
+	prefixPattern = regexp.MustCompile(`^(\[[^\]]*\]\s*(\([^)]*\)\s*)?|:[a-z0-9_+-]+:\s*)`)
+)
+
+// nonImperative lists common verbs whose imperative form is written differently, keyed by the wrong form.
+var nonImperative = map[string]string{
+	"adds": "add", "fixes": "fix", "updates": "update", "removes": "remove", "changes": "change",
+	"makes": "make", "uses": "use", "improves": "improve", "moves": "move", "renames": "rename",
+	"creates": "create", "deletes": "delete", "implements": "implement", "refactors": "refactor",
+	"supports": "support", "handles": "handle", "allows": "allow", "bumps": "bump", "sets": "set",
+	"introduces": "introduce", "replaces": "replace", "ensures": "ensure", "prevents": "prevent",
+	"extracts": "extract", "simplifies": "simplify", "cleans": "clean", "upgrades": "upgrade",
+	"converts": "convert", "documents": "document", "enables": "enable", "disables": "disable",
+}
+
+// notPastTense lists words ending in "ed" or "ing" that are valid imperatives.
+var notPastTense = map[string]bool{
+	"embed": true, "feed": true, "need": true, "seed": true, "speed": true, "shed": true, "proceed": true,
+	"exceed": true, "succeed": true, "bring": true, "ping": true, "ring": true, "string": true, "swing": true,
+}
+
+// Rules are the checks applied to generated commit messages.
+// Zero values disable the corresponding check.
+type Rules struct {
+	MaxHeaderLength   int      // Maximum length of the first line
+	MaxBodyLineLength int      // Maximum length of the lines after the first
+	Types             []string // Allowed Conventional Commits types
+	RequireType       bool     // Whether the header must have a Conventional Commits type
+	Imperative        bool     // Whether the subject must start with a verb in the imperative mood
+	NoTrailingPeriod  bool     // Whether the subject must not end with a period
+}
+
+// LoadRules returns the rules configured with the LINT_* keys, overridden by the rules of a commitlint
+// configuration in the repository root. It returns nil if LINT is disabled.
+func LoadRules() (*Rules, error) {
+	rules, err := RulesFrom(config.GetConfig)
+	if err != nil || rules == nil {
+		return nil, err
+	}
+
+	root, err := git.GetRepoRoot()
+	if err != nil {
+		return rules, nil
+	}
+	if err := applyCommitlint(rules, root); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// RulesFrom returns the rules set with the LINT_* keys read through get, without looking for a
+// commitlint configuration. It returns nil if LINT is disabled.
+func RulesFrom(get config.Getter) (*Rules, error) {
+	enabled, _, err := get.Bool("LINT")
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	rules := &Rules{NoTrailingPeriod: true}
+	if rules.MaxHeaderLength, _, err = get.Int("LINT_MAX_SUBJECT_LENGTH"); err != nil {
+		return nil, err
+	}
+	if rules.Types, _, err = get.List("LINT_TYPES"); err != nil {
+		return nil, err
+	}
+	if rules.Imperative, _, err = get.Bool("LINT_IMPERATIVE"); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Check returns a description of every rule the message breaks.
+func (r *Rules) Check(message string) []string {
+	var violations []string
+	header, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	header = strings.TrimSpace(header)
+
+	if header == "" {
+		return []string{"the first line must not be empty"}
+	}
+	if r.MaxHeaderLength > 0 && len([]rune(header)) > r.MaxHeaderLength {
+		violations = append(violations, fmt.Sprintf("the first line must be at most %d characters long, it has %d", r.MaxHeaderLength, len([]rune(header))))
+	}
+
+	// Checks the type of Conventional Commits headers, and takes the subject after it.
+	subject := header
+	if match := headerPattern.FindStringSubmatch(header); match != nil {
+		if len(r.Types) > 0 && !slices.Contains(r.Types, match[1]) {
+			violations = append(violations, fmt.Sprintf("the type %q must be one of: %s", match[1], strings.Join(r.Types, ", ")))
+		}
+		subject = match[3]
+	} else if r.RequireType {
+		violations = append(violations, `the first line must start with a type, e.g. "fix: ..." or "feat(scope): ..."`)
+	} else {
+		subject = strings.TrimLeftFunc(prefixPattern.ReplaceAllString(header, ""), func(c rune) bool {
+			return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+		})
+	}
+
+	if r.NoTrailingPeriod && strings.HasSuffix(subject, ".") {
+		violations = append(violations, "the subject must not end with a period")
+	}
+	if r.Imperative {
+		if problem := imperativeProblem(subject); problem != "" {
+			violations = append(violations, problem)
+		}
+	}
+
+	if r.MaxBodyLineLength > 0 {
+		for _, line := range strings.Split(body, "\n") {
+			if len([]rune(line)) > r.MaxBodyLineLength {
+				violations = append(violations, fmt.Sprintf("body lines must be at most %d characters long", r.MaxBodyLineLength))
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// imperativeProblem describes why the first word of subject is not in the imperative mood,
+// or returns an empty string if it appears to be.
+func imperativeProblem(subject string) string {
+	word, _, _ := strings.Cut(subject, " ")
+	lower := strings.ToLower(strings.TrimRight(word, ":,"))
+	if imperative, ok := nonImperative[lower]; ok {
+		return fmt.Sprintf("the subject must use the imperative mood: %q instead of %q", imperative, word)
+	}
+	if len(lower) > 4 && !notPastTense[lower] && (strings.HasSuffix(lower, "ed") || strings.HasSuffix(lower, "ing")) {
+		return fmt.Sprintf("the subject must use the imperative mood, e.g. \"Add\" instead of \"Added\" or \"Adding\", not %q", word)
+	}
+	return ""
+}
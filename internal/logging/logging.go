@@ -0,0 +1,183 @@
+// Package logging records the diagnostics of the tool with log/slog: the Git commands that run, the
+// configuration in use, how the prompt was built and the API requests and responses. Records are
+// discarded until Configure is called, so that the packages can log without knowing whether they
+// run in the command or in a program using pkg/aicommit.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The levels of --log-level and LOG_LEVEL.
+const (
+	LevelDebug = "debug" // How the prompt was built and every API request and response
+	LevelInfo  = "info"  // The Git commands that run and the configuration in use
+	LevelWarn  = "warn"  // Problems that are worked around, such as a profile that cannot be read
+	LevelError = "error" // Only failures
+)
+
+// Levels lists the level names, from the most to the least verbose.
+var Levels = []string{LevelDebug, LevelInfo, LevelWarn, LevelError}
+
+// logger receives the records, see Configure.
+var logger = slog.New(discardHandler{})
+
+// ParseLevel returns the level named name, one of Levels.
+func ParseLevel(name string) (slog.Level, error) {
+	switch name {
+	case LevelDebug:
+		return slog.LevelDebug, nil
+	case LevelInfo:
+		return slog.LevelInfo, nil
+	case LevelWarn:
+		return slog.LevelWarn, nil
+	case LevelError:
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q, expected one of %s", name, strings.Join(Levels, ", "))
+}
+
+// Configure records the diagnostics of level and above. They are appended to the file at path with
+// their time, one record per line, or printed to stderr in a readable form if path is empty.
+// The file stays open for the rest of the process.
+func Configure(level slog.Level, path string) error {
+	if path == "" {
+		logger = slog.New(newConsoleHandler(os.Stderr, level))
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	logger = slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: level}))
+	return nil
+}
+
+// Logger returns the logger the records are sent to, e.g. to add attributes to several records.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// Enabled reports whether records of level are recorded, e.g. to skip preparing expensive attributes.
+func Enabled(level slog.Level) bool {
+	return logger.Enabled(context.Background(), level)
+}
+
+// Debug records msg with attributes given as key-value pairs or slog.Attr, like slog.Debug.
+func Debug(msg string, args ...any) {
+	logger.Debug(msg, args...)
+}
+
+// Info records msg at the info level, see Debug.
+func Info(msg string, args ...any) {
+	logger.Info(msg, args...)
+}
+
+// Warn records msg at the warn level, see Debug.
+func Warn(msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
+
+// Error records msg at the error level, see Debug.
+func Error(msg string, args ...any) {
+	logger.Error(msg, args...)
+}
+
+// discardHandler drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// consoleHandler prints records for a person reading stderr: the level and message, followed by the
+// attributes as key=value pairs. Values spanning several lines, such as a prompt or a payload, are
+// printed below as blocks, which are easier to read than escaped strings.
+type consoleHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  slog.Level
+	attrs  []slog.Attr // Attributes added with WithAttrs, their keys prefixed
+	prefix string      // Prefix of the keys of the groups opened with WithGroup, e.g. "headers."
+}
+
+// newConsoleHandler returns a consoleHandler printing the records of level and above to w.
+func newConsoleHandler(w io.Writer, level slog.Level) *consoleHandler {
+	return &consoleHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var line, blocks strings.Builder
+	fmt.Fprintf(&line, "%s: %s", strings.ToLower(r.Level.String()), r.Message)
+	add := func(attr slog.Attr) {
+		value := attr.Value.String()
+		switch {
+		case strings.Contains(value, "\n"):
+			fmt.Fprintf(&blocks, "--- %s ---\n%s\n", attr.Key, strings.TrimRight(value, "\n"))
+		case value == "" || strings.ContainsAny(value, " \t\"="):
+			fmt.Fprintf(&line, " %s=%q", attr.Key, value)
+		default:
+			fmt.Fprintf(&line, " %s=%s", attr.Key, value)
+		}
+	}
+	for _, attr := range h.attrs {
+		add(attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		for _, attr := range flatten(h.prefix, attr) {
+			add(attr)
+		}
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line.String()+"\n"+blocks.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		clone.attrs = append(clone.attrs, flatten(h.prefix, attr)...)
+	}
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// flatten returns attr with its value resolved and its key prefixed, or the attributes of a group
+// with the group's name added to the prefix. Empty attributes are left out, as by slog's handlers.
+func flatten(prefix string, attr slog.Attr) []slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return nil
+	}
+	if attr.Value.Kind() != slog.KindGroup {
+		return []slog.Attr{{Key: prefix + attr.Key, Value: attr.Value}}
+	}
+	if attr.Key != "" {
+		prefix += attr.Key + "."
+	}
+	var attrs []slog.Attr
+	for _, member := range attr.Value.Group() {
+		attrs = append(attrs, flatten(prefix, member)...)
+	}
+	return attrs
+}
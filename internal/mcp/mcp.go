@@ -0,0 +1,308 @@
+// Package mcp serves tools over the Model Context Protocol (https://modelcontextprotocol.io), so that
+// AI agents and editors supporting it can call them. It implements the stdio transport: JSON-RPC 2.0
+// messages, one per line, read from the client on standard input and written to standard output.
+//
+// Only what tools need is implemented: the handshake, listing and calling tools, and asking the user
+// for a confirmation through the client ("elicitation").
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// ProtocolVersion is the version of the protocol the server speaks. A client asking for an older
+// version is answered with this one, and decides whether it can go on.
+const ProtocolVersion = "2025-06-18"
+
+// The error codes of JSON-RPC.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// ErrDeclined is returned by Confirm when the user declined or dismissed the confirmation.
+var ErrDeclined = errors.New("declined by the user")
+
+// ErrNoElicitation is returned by Confirm when the client cannot ask the user anything.
+var ErrNoElicitation = errors.New("the client cannot ask for confirmations")
+
+// Tool is a tool the server offers. Its result is the text handed to the model; an error is handed
+// over as a failed result rather than a protocol error, so that the model can react to it.
+type Tool struct {
+	Name        string                                     // Name the tool is called by, e.g. "commit"
+	Description string                                     // What the tool does and when to use it, for the model
+	Properties  map[string]Property                        // Arguments of the tool, by name
+	Required    []string                                   // Names of the arguments that must be given
+	ReadOnly    bool                                       // The tool changes nothing, so clients may call it without asking
+	Destructive bool                                       // The tool makes changes that are not easily undone
+	Call        func(args json.RawMessage) (string, error) // Runs the tool with its arguments as a JSON object
+}
+
+// Property describes an argument of a Tool, as a JSON schema.
+type Property struct {
+	Type        string    `json:"type"`            // "string", "boolean", "integer" or "array"
+	Description string    `json:"description"`     // What the argument means
+	Items       *Property `json:"items,omitempty"` // Type of the elements of an array
+}
+
+// Server answers the requests of one client. Use NewServer.
+type Server struct {
+	name, version string
+	tools         []Tool
+
+	in      *bufio.Reader
+	out     io.Writer
+	mu      sync.Mutex        // Serializes writes to out
+	queued  []json.RawMessage // Requests read while waiting for the response to a request of the server
+	nextID  int               // ID of the next request of the server
+	elicits bool              // The client declared the elicitation capability
+}
+
+// message is a JSON-RPC request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewServer returns a server introducing itself as name and version, offering tools.
+func NewServer(name, version string, tools []Tool) *Server {
+	return &Server{name: name, version: version, tools: tools}
+}
+
+// Serve answers the messages read from r on w until r ends, which is how a client stops the server.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.in, s.out = bufio.NewReader(r), w
+	for {
+		line, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.handle(line)
+	}
+}
+
+// Confirm asks the user, through the client, to confirm what prompt describes. It returns
+// ErrDeclined unless the user accepted, and ErrNoElicitation if the client cannot ask.
+func (s *Server) Confirm(prompt string) error {
+	if !s.elicits {
+		return ErrNoElicitation
+	}
+	result, err := s.request("elicitation/create", map[string]any{
+		"message": prompt,
+		"requestedSchema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"confirm": map[string]any{"type": "boolean", "title": "Confirm", "description": "Check to go ahead"},
+			},
+			"required": []string{"confirm"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var answer struct {
+		Action  string `json:"action"`
+		Content struct {
+			Confirm bool `json:"confirm"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(result, &answer); err != nil {
+		return fmt.Errorf("invalid answer to the confirmation: %w", err)
+	}
+	if answer.Action != "accept" || !answer.Content.Confirm {
+		return ErrDeclined
+	}
+	return nil
+}
+
+// read returns the next message, those read while waiting for a response first.
+func (s *Server) read() (json.RawMessage, error) {
+	if len(s.queued) > 0 {
+		line := s.queued[0]
+		s.queued = s.queued[1:]
+		return line, nil
+	}
+	for {
+		line, err := s.in.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// handle answers the request or notification in line.
+func (s *Server) handle(line json.RawMessage) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		s.respondError(json.RawMessage("null"), codeParseError, "invalid JSON: "+err.Error())
+		return
+	}
+	logging.Debug("MCP message", "method", msg.Method, "id", string(msg.ID))
+	if msg.ID == nil {
+		// Notifications, such as notifications/initialized, need no answer.
+		return
+	}
+	if msg.Method == "" {
+		// A response to a request of the server that is no longer awaited.
+		return
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			Capabilities struct {
+				Elicitation *json.RawMessage `json:"elicitation"`
+			} `json:"capabilities"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			s.respondError(msg.ID, codeInvalidParams, err.Error())
+			return
+		}
+		s.elicits = params.Capabilities.Elicitation != nil
+		s.respond(msg.ID, map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		})
+	case "ping":
+		s.respond(msg.ID, map[string]any{})
+	case "tools/list":
+		s.respond(msg.ID, map[string]any{"tools": s.describeTools()})
+	case "tools/call":
+		s.callTool(msg)
+	default:
+		s.respondError(msg.ID, codeMethodNotFound, "unknown method "+msg.Method)
+	}
+}
+
+// describeTools returns the tools as listed by tools/list.
+func (s *Server) describeTools() []map[string]any {
+	tools := make([]map[string]any, 0, len(s.tools))
+	for _, tool := range s.tools {
+		properties := tool.Properties
+		if properties == nil {
+			properties = map[string]Property{}
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(tool.Required) > 0 {
+			schema["required"] = tool.Required
+		}
+		tools = append(tools, map[string]any{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"inputSchema": schema,
+			"annotations": map[string]bool{"readOnlyHint": tool.ReadOnly, "destructiveHint": tool.Destructive},
+		})
+	}
+	return tools
+}
+
+// callTool runs the tool requested by msg and answers with its result.
+func (s *Server) callTool(msg message) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		s.respondError(msg.ID, codeInvalidParams, err.Error())
+		return
+	}
+	i := slices.IndexFunc(s.tools, func(tool Tool) bool { return tool.Name == params.Name })
+	if i < 0 {
+		s.respondError(msg.ID, codeInvalidParams, "unknown tool "+params.Name)
+		return
+	}
+	if params.Arguments == nil {
+		params.Arguments = json.RawMessage("{}")
+	}
+
+	logging.Info("calling MCP tool", "tool", params.Name)
+	text, err := s.tools[i].Call(params.Arguments)
+	if err != nil {
+		logging.Info("MCP tool failed", "tool", params.Name, "error", err)
+		text = "Error: " + err.Error()
+	}
+	s.respond(msg.ID, map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": err != nil,
+	})
+}
+
+// request sends a request to the client and returns the result of its response. Requests of the
+// client read in the meantime are answered afterwards, and notifications are dropped.
+func (s *Server) request(method string, params any) (json.RawMessage, error) {
+	s.nextID++
+	id := json.RawMessage(fmt.Sprintf(`"server-%d"`, s.nextID))
+	if err := s.write(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return nil, err
+	}
+	for {
+		line, err := s.in.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("no answer to %s: %w", method, err)
+		}
+		var msg message
+		if json.Unmarshal(line, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			s.queued = append(s.queued, line)
+		case msg.Method == "" && string(msg.ID) == string(id):
+			if msg.Error != nil {
+				return nil, fmt.Errorf("%s failed: %s", method, msg.Error.Message)
+			}
+			return msg.Result, nil
+		}
+	}
+}
+
+// respond answers the request with id with result.
+func (s *Server) respond(id json.RawMessage, result any) {
+	s.write(map[string]any{"jsonrpc": "2.0", "id": id, "result": result})
+}
+
+// respondError answers the request with id with an error.
+func (s *Server) respondError(id json.RawMessage, code int, text string) {
+	s.write(map[string]any{"jsonrpc": "2.0", "id": id, "error": rpcError{Code: code, Message: text}})
+}
+
+// write sends value to the client as one line.
+func (s *Server) write(value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode MCP message: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write MCP message: %w", err)
+	}
+	return nil
+}
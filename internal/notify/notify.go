@@ -0,0 +1,143 @@
+// Package notify tells the webhooks of chat services and other endpoints about the commits made
+// with the tool, so that a team can follow which commits have generated messages.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+)
+
+// The kinds of webhooks, which expect different payloads.
+const (
+	slack   = "slack"
+	discord = "discord"
+	generic = "generic"
+)
+
+const (
+	// timeout bounds each webhook request, so that a slow endpoint does not hold up the command.
+	timeout = 5 * time.Second
+	// discordLimit is the most characters Discord accepts in the content of a message.
+	discordLimit = 2000
+)
+
+// Commit is a commit the webhooks are told about.
+type Commit struct {
+	Repository string // The origin remote as "host/path", e.g. "github.com/owner/app", or the name of the directory
+	Branch     string // Branch the commit was made on, "" for a detached HEAD
+	SHA        string // Hash of the commit
+	Message    string // The full commit message
+}
+
+// webhookKind returns the kind of the webhook at rawURL, from its host and path.
+func webhookKind(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return generic
+	}
+	switch host := strings.ToLower(u.Hostname()); {
+	case host == "hooks.slack.com":
+		return slack
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		return discord
+	}
+	return generic
+}
+
+// Send posts commit to every webhook of urls at the same time, in the payload each expects, and
+// returns the errors of those that failed.
+func Send(urls []string, commit Commit) error {
+	transport, err := provider.NewTransport()
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: transport, Timeout: timeout}
+
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i, webhook := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = post(client, webhook, commit)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// post posts commit to the webhook at rawURL. The URL is left out of errors, since its path is the
+// secret of the webhook.
+func post(client *http.Client, rawURL string, commit Commit) error {
+	body, err := json.Marshal(payload(webhookKind(rawURL), commit))
+	if err != nil {
+		return err
+	}
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	resp, err := client.Post(rawURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to notify the webhook on %s: %w", host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err := fmt.Errorf("the webhook on %s answered %s", host, resp.Status)
+		if detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512)); len(bytes.TrimSpace(detail)) > 0 {
+			err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(detail))
+		}
+		return err
+	}
+	return nil
+}
+
+// payload returns the body of the request to a webhook of kind.
+func payload(kind string, commit Commit) any {
+	subject, _, _ := strings.Cut(commit.Message, "\n")
+	short := commit.SHA[:min(len(commit.SHA), 7)]
+	where := commit.Repository
+	if commit.Branch != "" {
+		where += " (" + commit.Branch + ")"
+	}
+
+	switch kind {
+	case slack:
+		// Slack reads &, < and > as markup, see https://api.slack.com/reference/surfaces/formatting.
+		escaper := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+		return map[string]string{
+			"text": fmt.Sprintf("Commit `%s` on *%s* with a generated message:\n```%s```", short, escaper.Replace(where), escaper.Replace(commit.Message)),
+		}
+	case discord:
+		header := fmt.Sprintf("Commit `%s` on **%s** with a generated message:\n", short, where)
+		message := []rune(commit.Message)
+		if room := discordLimit - utf8.RuneCountInString(header) - len("```\n\n```"); len(message) > room {
+			message = append(message[:max(room-3, 0)], []rune("...")...)
+		}
+		return map[string]string{"content": header + "```\n" + string(message) + "\n```"}
+	}
+	return map[string]string{
+		"event":      "commit",
+		"repository": commit.Repository,
+		"branch":     commit.Branch,
+		"sha":        commit.SHA,
+		"subject":    subject,
+		"message":    commit.Message,
+		"generator":  "ai-generate-commit",
+	}
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/shell"
+)
+
+// ErrNoAPIKey is returned when the provider needs an API key and none is configured.
+var ErrNoAPIKey = errors.New("no API key")
+
+// resolveAPIKey returns the API key for preset.
+// When APIKEY_COMMAND is set, the key is read from the command's output at runtime
+// (e.g. "pass show groq/api"), so it never has to be stored in the config file.
+// Otherwise the key stored under the preset's API key config key is used.
+// APIKEY_COMMAND is not RepoSafe, so a cloned repository cannot set the command, neither in its
+// configuration file nor in its .env file.
+func resolveAPIKey(preset Preset, lookup LookupFunc) (string, error) {
+	command, err := lookup("APIKEY_COMMAND")
+	if err != nil {
+		return "", fmt.Errorf("failed to get APIKEY_COMMAND: %w", err)
+	}
+	if command != "" {
+		return runAPIKeyCommand(command)
+	}
+
+	apiKey, err := lookup(preset.APIKeyConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", preset.APIKeyConfig, err)
+	}
+	if apiKey == "" {
+		return "", fmt.Errorf("%w: %s not set; run 'ai-generate-commit init' to configure a provider", ErrNoAPIKey, preset.APIKeyConfig)
+	}
+	return apiKey, nil
+}
+
+// runAPIKeyCommand runs command through the system shell and returns the first line of its output.
+// The command's stderr is passed through so password managers can prompt for unlocking.
+func runAPIKeyCommand(command string) (string, error) {
+	cmd := shell.Command(command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	logging.Info("running APIKEY_COMMAND", "command", command)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("APIKEY_COMMAND failed: %w", err)
+	}
+
+	// Tools like pass print the secret on the first line followed by optional metadata.
+	apiKey, _, _ := strings.Cut(stdout.String(), "\n")
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return "", fmt.Errorf("APIKEY_COMMAND produced no output")
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+const (
+	// llamaCppDefaultURL is the address llama.cpp's server and llamafile listen on by default.
+	llamaCppDefaultURL = "http://localhost:8080"
+	// llamaCppHealthTimeout bounds the health check so a dead server fails fast.
+	llamaCppHealthTimeout = 3 * time.Second
+)
+
+// llamaCppPreset targets a local llama.cpp server or llamafile.
+// Small local models are slow on CPU, so the timeout is far more generous than for hosted APIs.
+var llamaCppPreset = Preset{
+	Name:         "llamacpp",
+	BaseURL:      llamaCppDefaultURL + "/v1/chat/completions",
+	DefaultModel: "local", // llama.cpp serves whichever model it was started with
+	Timeout:      5 * time.Minute,
+	// Many llama.cpp versions give requests a context of 4096 tokens unless started with a larger -c.
+	ContextWindow: 4096,
+}
+
+// newLlamaCppClient creates a client for the llama.cpp server configured in LLAMACPP_URL.
+// It checks the server's health endpoint first so the diff is never sent to a server that is down or still loading.
+// The endpoint in opts is replaced by that of the server.
+func newLlamaCppClient(preset Preset, lookup LookupFunc, opts groq.ClientOptions) (*groq.Client, Preset, error) {
+	serverURL, err := lookup("LLAMACPP_URL")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get LLAMACPP_URL: %w", err)
+	}
+	if serverURL == "" {
+		serverURL = llamaCppDefaultURL
+	}
+	serverURL = strings.TrimRight(serverURL, "/")
+	preset.BaseURL = serverURL + "/v1/chat/completions"
+
+	if err := checkLlamaCppHealth(serverURL, opts.Transport); err != nil {
+		return nil, Preset{}, err
+	}
+
+	opts.BaseURL = preset.BaseURL
+	return groq.NewClientWithOptions(opts), preset, nil
+}
+
+// checkLlamaCppHealth queries the /health endpoint of a llama.cpp server.
+// The server answers 503 while the model is still loading.
+func checkLlamaCppHealth(serverURL string, transport http.RoundTripper) error {
+	httpClient := &http.Client{Timeout: llamaCppHealthTimeout, Transport: transport}
+	resp, err := httpClient.Get(serverURL + "/health")
+	if err != nil {
+		return fmt.Errorf("%w: llama.cpp server not reachable at %s: %w", groq.ErrRequest, serverURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusServiceUnavailable:
+		return fmt.Errorf("%w: llama.cpp server at %s is still loading the model", groq.ErrRequest, serverURL)
+	default:
+		return fmt.Errorf("%w: llama.cpp server at %s is unhealthy: status code %d", groq.ErrRequest, serverURL, resp.StatusCode)
+	}
+}
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+const (
+	// mockReplay serves the recorded responses without any network access.
+	mockReplay = "replay"
+	// mockRecord sends the requests to MOCK_RECORD_PROVIDER and records its responses.
+	mockRecord = "record"
+	// mockDefaultFixtures is the directory the fixtures are kept in when MOCK_FIXTURES is not set.
+	mockDefaultFixtures = ".ai-commit-fixtures"
+)
+
+// ErrNoFixture is reported when the mock provider replays a request that was never recorded.
+var ErrNoFixture = errors.New("no recorded response for this request")
+
+// mockPreset serves recorded responses, for tests and demos without access to a live API.
+var mockPreset = Preset{
+	Name:         "mock",
+	BaseURL:      "http://mock.invalid/v1/chat/completions",
+	DefaultModel: "mock",
+	Timeout:      10 * time.Second,
+}
+
+// fixture is a recorded request and the response it got, stored as a JSON file named after its key.
+type fixture struct {
+	Request struct {
+		Method string          `json:"method"` // HTTP method, e.g. "POST"
+		Path   string          `json:"path"`   // Path of the URL, for reference only
+		Body   json.RawMessage `json:"body"`   // Body of the request, null if it had none
+	} `json:"request"`
+	Response struct {
+		Status      int    `json:"status"`      // HTTP status code
+		ContentType string `json:"contentType"` // Content type of the body, e.g. "text/event-stream" for streams
+		Body        string `json:"body"`        // Body of the response
+	} `json:"response"`
+}
+
+// newMockClient creates a client that replays the responses recorded in MOCK_FIXTURES, or with
+// MOCK_MODE set to record, a client for MOCK_RECORD_PROVIDER that records the responses it gets.
+func newMockClient(preset Preset, lookup LookupFunc, opts groq.ClientOptions) (*groq.Client, Preset, error) {
+	mode, err := lookup("MOCK_MODE")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get MOCK_MODE: %w", err)
+	}
+	dir, err := lookup("MOCK_FIXTURES")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get MOCK_FIXTURES: %w", err)
+	}
+	if dir == "" {
+		dir = mockDefaultFixtures
+	}
+
+	if mode != mockRecord {
+		opts.Transport = &replayTransport{dir: dir}
+		return groq.NewClientWithOptions(opts), preset, nil
+	}
+
+	// Builds the client of the recorded provider with its own settings, recording what it receives.
+	upstream, err := lookup("MOCK_RECORD_PROVIDER")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get MOCK_RECORD_PROVIDER: %w", err)
+	}
+	upstreamPreset, err := Lookup(upstream)
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("invalid MOCK_RECORD_PROVIDER: %w", err)
+	}
+	if upstream == "" || upstreamPreset.Name == preset.Name {
+		return nil, Preset{}, errors.New("set MOCK_RECORD_PROVIDER to the provider whose responses are recorded")
+	}
+	upstreamLookup := func(key string) (string, error) {
+		if key == "PROVIDER" {
+			return upstream, nil
+		}
+		return lookup(key)
+	}
+	return newClient(upstreamLookup, func(transport http.RoundTripper) http.RoundTripper {
+		return &recordTransport{dir: dir, next: transport}
+	}, opts.Interceptors)
+}
+
+// fixtureKey returns the key of the fixture of a request: a hash of its method, the last element
+// of its path, and its body without the model, so that the fixtures recorded with one provider
+// and model can be replayed with the mock provider.
+func fixtureKey(method, urlPath string, body []byte) string {
+	var request map[string]any
+	if json.Unmarshal(body, &request) == nil {
+		delete(request, "model")
+		// Marshals the keys of maps in order, so the key does not depend on the order of the fields.
+		if canonical, err := json.Marshal(request); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.Sum256([]byte(method + " " + path.Base(urlPath) + "\n" + string(body)))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// readRequestBody reads the body of req and replaces it, so that it can still be sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// replayTransport answers requests with the responses recorded in dir.
+type replayTransport struct {
+	dir string // Directory of the fixtures
+}
+
+// RoundTrip returns the recorded response to req. A request that was not recorded gets a response
+// with status 404 describing the problem, so that it is reported like an error of the API.
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := fixtureKey(req.Method, req.URL.Path, body)
+	data, err := os.ReadFile(filepath.Join(t.dir, key+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		message, _ := json.Marshal(fmt.Sprintf("%v in %s (fixture %s); record it with MOCK_MODE set to record", ErrNoFixture, t.dir, key))
+		return mockResponse(req, http.StatusNotFound, "application/json", `{"error":{"message":`+string(message)+`}}`), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", key, err)
+	}
+	return mockResponse(req, f.Response.Status, f.Response.ContentType, f.Response.Body), nil
+}
+
+// mockResponse returns a response to req with the status, content type and body.
+func mockResponse(req *http.Request, status int, contentType, body string) *http.Response {
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// recordTransport sends requests with next and records their responses in dir.
+type recordTransport struct {
+	dir  string            // Directory of the fixtures
+	next http.RoundTripper // Transport that sends the requests
+}
+
+// RoundTrip sends req and records the response, replacing an earlier recording of the same request.
+// Headers, which hold the API key, are not recorded.
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var f fixture
+	f.Request.Method = req.Method
+	f.Request.Path = req.URL.Path
+	f.Request.Body = json.RawMessage("null")
+	if json.Valid(body) {
+		f.Request.Body = body
+	}
+	f.Response.Status = resp.StatusCode
+	f.Response.ContentType = resp.Header.Get("Content-Type")
+	f.Response.Body = string(respBody)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	key := fixtureKey(req.Method, req.URL.Path, body)
+	if err := os.WriteFile(filepath.Join(t.dir, key+".json"), append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/daemon"
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+// Preset describes a built-in chat completions backend.
+type Preset struct {
+	Name         string        // Name used in the PROVIDER config key
+	BaseURL      string        // Chat completions endpoint
+	DefaultModel string        // Model used when none is provided
+	APIKeyConfig string        // Config key holding the API key, empty if none is needed
+	Timeout      time.Duration // HTTP timeout for completion requests
+
+	// ContextWindow is the number of tokens the provider's models can read when the model is not
+	// known, or 0 to assume the default.
+	ContextWindow int
+}
+
+const (
+	// defaultProvider is the provider used when PROVIDER is not set.
+	defaultProvider = "groq"
+)
+
+// ErrUnknownProvider is returned when the configured provider has no preset.
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// LookupFunc returns the value of a configuration key.
+// config.GetConfig satisfies it.
+type LookupFunc func(key string) (string, error)
+
+// presets holds the built-in providers keyed by name.
+var presets = map[string]Preset{
+	"groq": {
+		Name:         "groq",
+		BaseURL:      groq.BaseURL,
+		DefaultModel: "llama3-8b-8192",
+		APIKeyConfig: "GROQ_APIKEY",
+		Timeout:      30 * time.Second,
+	},
+	"together": {
+		Name:         "together",
+		BaseURL:      "https://api.together.xyz/v1/chat/completions",
+		DefaultModel: "meta-llama/Llama-3.3-70B-Instruct-Turbo",
+		APIKeyConfig: "TOGETHER_APIKEY",
+		Timeout:      60 * time.Second,
+	},
+	"llamacpp": llamaCppPreset,
+	"vertex":   vertexPreset,
+	"mock":     mockPreset,
+}
+
+// aliases maps alternative provider names to their preset.
+var aliases = map[string]string{
+	"llamafile": "llamacpp",
+}
+
+// Lookup returns the preset registered under name.
+func Lookup(name string) (Preset, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = defaultProvider
+	}
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return preset, nil
+}
+
+// Names returns the names of all built-in providers, the default one first.
+func Names() []string {
+	names := []string{defaultProvider}
+	for name := range presets {
+		if name != defaultProvider {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// New creates a client for the provider selected in the configuration.
+// It returns the client together with the preset it was built from.
+func New() (*groq.Client, Preset, error) {
+	return NewWithLookup(config.GetConfig)
+}
+
+// NewWithLookup is like New but reads the settings through lookup, and passes the requests of the
+// client through interceptors, see groq.ClientOptions. This allows building a client from settings
+// that have not been saved yet, or that are not in the configuration at all.
+func NewWithLookup(lookup LookupFunc, interceptors ...groq.Interceptor) (*groq.Client, Preset, error) {
+	return newClient(lookup, nil, interceptors)
+}
+
+// newClient builds the client of NewWithLookup, sending its requests through the transport
+// returned by wrap, if it is not nil, for the one it would use otherwise, such as the daemon's.
+func newClient(lookup LookupFunc, wrap func(http.RoundTripper) http.RoundTripper, interceptors []groq.Interceptor) (*groq.Client, Preset, error) {
+	name, err := lookup("PROVIDER")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get PROVIDER: %w", err)
+	}
+
+	preset, err := Lookup(name)
+	if err != nil {
+		return nil, Preset{}, err
+	}
+
+	retry, err := retryPolicy(lookup)
+	if err != nil {
+		return nil, Preset{}, err
+	}
+
+	transport, err := newTransport(lookup)
+	if err != nil {
+		return nil, Preset{}, err
+	}
+	// Sends the requests through the daemon when it runs, reusing its connections.
+	var roundTripper http.RoundTripper = transport
+	useDaemon, _, err := config.Getter(lookup).Bool("DAEMON")
+	if err != nil {
+		return nil, Preset{}, err
+	}
+	if useDaemon {
+		network, err := networkSettings(lookup)
+		if err != nil {
+			return nil, Preset{}, err
+		}
+		roundTripper = daemon.Transport(network, transport)
+	}
+	if wrap != nil {
+		roundTripper = wrap(roundTripper)
+	}
+
+	// Options shared by all providers; the provider-specific constructors fill in the rest.
+	opts := groq.ClientOptions{
+		BaseURL:   preset.BaseURL,
+		Timeout:   preset.Timeout,
+		Retry:     retry,
+		Transport: roundTripper,
+
+		Interceptors: interceptors,
+	}
+	switch preset.Name {
+	case "llamacpp":
+		return newLlamaCppClient(preset, lookup, opts)
+	case "vertex":
+		return newVertexClient(preset, lookup, opts)
+	case "mock":
+		return newMockClient(preset, lookup, opts)
+	}
+
+	apiKey, err := resolveAPIKey(preset, lookup)
+	if err != nil {
+		return nil, Preset{}, err
+	}
+
+	opts.APIKey = apiKey
+	return groq.NewClientWithOptions(opts), preset, nil
+}
+
+// retryPolicy returns the retry policy set with RETRIES, RETRY_BACKOFF, RETRY_MAX_ELAPSED and
+// RATE_LIMIT_MAX_WAIT, read through lookup.
+func retryPolicy(lookup LookupFunc) (groq.RetryPolicy, error) {
+	get := config.Getter(lookup)
+	retries, _, err := get.Int("RETRIES")
+	if err != nil {
+		return groq.RetryPolicy{}, err
+	}
+	backoff, _, err := get.Float("RETRY_BACKOFF")
+	if err != nil {
+		return groq.RetryPolicy{}, err
+	}
+	maxElapsed, _, err := get.Float("RETRY_MAX_ELAPSED")
+	if err != nil {
+		return groq.RetryPolicy{}, err
+	}
+	maxWait, _, err := get.Float("RATE_LIMIT_MAX_WAIT")
+	if err != nil {
+		return groq.RetryPolicy{}, err
+	}
+	return groq.RetryPolicy{
+		Retries:          retries,
+		Backoff:          time.Duration(backoff * float64(time.Second)),
+		MaxElapsed:       time.Duration(maxElapsed * float64(time.Second)),
+		MaxRateLimitWait: time.Duration(maxWait * float64(time.Second)),
+	}, nil
+}
@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/ui"
+)
+
+var (
+	// ErrInvalidProxy is returned when PROXY_URL is not a proxy URL the client can use.
+	ErrInvalidProxy = errors.New("invalid PROXY_URL")
+	// ErrInvalidCACert is returned when CA_CERT_FILE contains no certificate.
+	ErrInvalidCACert = errors.New("invalid CA_CERT_FILE")
+)
+
+// insecureWarning makes sure that the warning about TLS_INSECURE is shown once per run.
+var insecureWarning sync.Once
+
+// proxySchemes are the proxy protocols supported by net/http.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// newTransport returns the HTTP transport used for all requests to the provider, including health
+// checks and token refreshes, with the proxy configured by proxyFunc and the TLS settings of tlsConfig,
+// both read through lookup.
+func newTransport(lookup LookupFunc) (*http.Transport, error) {
+	proxy, err := proxyFunc(lookup)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := tlsConfig(lookup)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// NewTransport returns a transport with the proxy and TLS settings of the provider, for the other
+// services the tool talks to, such as issue trackers.
+func NewTransport() (*http.Transport, error) {
+	return newTransport(config.GetConfig)
+}
+
+// NewDaemonTransport returns the transport of the requests the daemon forwards, built from the
+// configuration, and the network settings it was built from, see networkSettings.
+func NewDaemonTransport() (*http.Transport, string, error) {
+	transport, err := newTransport(config.GetConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	network, err := networkSettings(config.GetConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	return transport, network, nil
+}
+
+// networkSettings identifies the settings newTransport builds a transport from, read through lookup:
+// the proxy and TLS keys and the proxy environment variables. A run sends its requests through the
+// daemon only if they are the same as those of the daemon.
+func networkSettings(lookup LookupFunc) (string, error) {
+	var values []string
+	for _, key := range []string{"PROXY_URL", "CA_CERT_FILE", "TLS_INSECURE"} {
+		value, err := lookup(key)
+		if err != nil {
+			return "", fmt.Errorf("failed to get %s: %w", key, err)
+		}
+		values = append(values, value)
+	}
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY", "ALL_PROXY", "NO_PROXY"} {
+		values = append(values, os.Getenv(name), os.Getenv(strings.ToLower(name)))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(values, "\x00")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// tlsConfig returns the TLS settings of API requests. The certificates in CA_CERT_FILE, such as that
+// of a company proxy that inspects TLS traffic, are trusted in addition to those of the system.
+// TLS_INSECURE disables certificate verification altogether, which is warned about on every run.
+func tlsConfig(lookup LookupFunc) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	caFile, err := lookup("CA_CERT_FILE")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CA_CERT_FILE: %w", err)
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCACert, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool() // The system certificates cannot be loaded on every platform
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s contains no PEM-encoded certificate", ErrInvalidCACert, caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	insecure, _, err := config.Getter(lookup).Bool("TLS_INSECURE")
+	if err != nil {
+		return nil, err
+	}
+	if insecure {
+		cfg.InsecureSkipVerify = true
+		insecureWarning.Do(func() {
+			fmt.Fprintln(os.Stderr, ui.Colorize(ui.Red, "WARNING: TLS certificate verification is disabled (TLS_INSECURE). "+
+				"Anyone on the network can read the diffs and API keys sent to the provider, or answer in its place. "+
+				"Set CA_CERT_FILE to the certificate of your proxy's CA instead."))
+		})
+	}
+	return cfg, nil
+}
+
+// proxyFunc returns the function choosing the proxy of a request. PROXY_URL takes precedence over
+// the HTTPS_PROXY and HTTP_PROXY environment variables, which in turn take precedence over ALL_PROXY,
+// as with curl; "none" disables the proxy. NO_PROXY is honored in every case, and requests to
+// localhost, such as those to a local llama.cpp server, never use a proxy.
+func proxyFunc(lookup LookupFunc) (func(*http.Request) (*url.URL, error), error) {
+	value, err := lookup("PROXY_URL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PROXY_URL: %w", err)
+	}
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	if cfg.HTTPProxy == "" && cfg.HTTPSProxy == "" {
+		all := cmp.Or(os.Getenv("ALL_PROXY"), os.Getenv("all_proxy"))
+		cfg.HTTPProxy, cfg.HTTPSProxy = all, all
+	}
+	if value != "" {
+		if err := checkProxyURL(value); err != nil {
+			return nil, err
+		}
+		cfg.HTTPProxy, cfg.HTTPSProxy = value, value
+	}
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// checkProxyURL returns an error wrapping ErrInvalidProxy unless value is a URL with a supported scheme and a host.
+func checkProxyURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProxy, err)
+	}
+	for _, scheme := range proxySchemes {
+		if u.Scheme == scheme && u.Host != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q must be a URL such as http://proxy:3128 or socks5://127.0.0.1:1080", ErrInvalidProxy, value)
+}
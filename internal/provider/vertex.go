@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+const (
+	// vertexDefaultRegion is the region used when VERTEX_REGION is not set.
+	vertexDefaultRegion = "us-central1"
+	// vertexScope is the OAuth scope required by the Vertex AI API.
+	vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// vertexPreset targets Vertex AI's OpenAI-compatible endpoint.
+// The endpoint depends on the project and region, so BaseURL is filled in when the client is built.
+var vertexPreset = Preset{
+	Name:         "vertex",
+	DefaultModel: "google/gemini-2.0-flash-001",
+	Timeout:      60 * time.Second,
+}
+
+// newVertexClient creates a client for Vertex AI authenticated with Application Default Credentials.
+// Credentials are discovered the same way gcloud does: GOOGLE_APPLICATION_CREDENTIALS,
+// the gcloud user credentials file, or the metadata server when running on GCP.
+// The endpoint in opts is replaced by that of the project and region.
+func newVertexClient(preset Preset, lookup LookupFunc, opts groq.ClientOptions) (*groq.Client, Preset, error) {
+	// Tokens are refreshed through the same transport as the requests, so that they use the proxy too.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: opts.Transport})
+	creds, err := google.FindDefaultCredentials(ctx, vertexScope)
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to find application default credentials: %w", err)
+	}
+
+	project, err := lookup("VERTEX_PROJECT")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get VERTEX_PROJECT: %w", err)
+	}
+	if project == "" {
+		project = creds.ProjectID // Fall back to the project the credentials belong to
+	}
+	if project == "" {
+		return nil, Preset{}, fmt.Errorf("VERTEX_PROJECT not set")
+	}
+
+	region, err := lookup("VERTEX_REGION")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get VERTEX_REGION: %w", err)
+	}
+	if region == "" {
+		region = vertexDefaultRegion
+	}
+
+	model, err := lookup("VERTEX_MODEL")
+	if err != nil {
+		return nil, Preset{}, fmt.Errorf("failed to get VERTEX_MODEL: %w", err)
+	}
+	if model != "" {
+		preset.DefaultModel = model
+	}
+	preset.BaseURL = vertexEndpoint(project, region)
+
+	// The credentials' token source caches the access token and refreshes it once it expires.
+	tokenSource := creds.TokenSource
+	opts.BaseURL = preset.BaseURL
+	opts.Token = func() (string, error) {
+		token, err := tokenSource.Token()
+		if err != nil {
+			return "", err
+		}
+		return token.AccessToken, nil
+	}
+	return groq.NewClientWithOptions(opts), preset, nil
+}
+
+// vertexEndpoint builds the OpenAI-compatible chat completions URL for a project and region.
+// The "global" location is served from the region-less host.
+func vertexEndpoint(project, region string) string {
+	host := region + "-aiplatform.googleapis.com"
+	if region == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	return fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/endpoints/openapi/chat/completions", host, project, region)
+}
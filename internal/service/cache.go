@@ -0,0 +1,82 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/cache"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+)
+
+// cacheTTL returns how long generated messages are reused, set in hours with CACHE_TTL; 0 disables the cache.
+func (g *CommitMessageGenerator) cacheTTL() (time.Duration, error) {
+	hours, _, err := g.get.Float("CACHE_TTL")
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(hours * float64(time.Hour)), nil
+}
+
+// promptKey returns the cache key of the prompt of the last generation in style: everything that
+// decides the message, from the provider and model to the diff.
+func (g *CommitMessageGenerator) promptKey(style string) (string, error) {
+	params, err := json.Marshal(g.params)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := json.Marshal(g.prompt)
+	if err != nil {
+		return "", err
+	}
+	language := ""
+	if g.language != nil {
+		language = g.language.name
+	}
+	return cache.Key(g.provider, g.model, style, language, fmt.Sprint(g.lint != nil), string(params), string(prompt)), nil
+}
+
+// cached returns the message cached for the prompt of the last generation in style, if any, and
+// remembers the key of the prompt so that the message generated for it can be cached. With NoCache,
+// nothing is reused, but the new message is still cached.
+func (g *CommitMessageGenerator) cached(style string) (string, bool, error) {
+	g.cacheKey, g.cachedAt = "", time.Time{}
+	ttl, err := g.cacheTTL()
+	if err != nil || ttl <= 0 {
+		return "", false, err
+	}
+	if g.cacheKey, err = g.promptKey(style); err != nil {
+		return "", false, err
+	}
+	if g.noCache {
+		return "", false, nil
+	}
+	message, at, ok := cache.Get(g.cacheKey, ttl)
+	if ok {
+		g.cachedAt = at
+	}
+	logging.Debug("looked up the message cache", "key", g.cacheKey, "hit", ok)
+	return message, ok, nil
+}
+
+// remember caches message for the prompt of the last generation, replacing an earlier message, so
+// that the message shown last is shown again. A failure is only reported, since it merely costs
+// another request later.
+func (g *CommitMessageGenerator) remember(message string) {
+	if g.cacheKey == "" {
+		return
+	}
+	ttl, err := g.cacheTTL()
+	if err == nil {
+		err = cache.Put(g.cacheKey, message, ttl)
+	}
+	if err != nil && notify != nil {
+		notify(fmt.Sprintf("Warning: the message was not cached: %v", err))
+	}
+}
+
+// CachedAt returns when the message returned by the last generation was generated, if it was taken
+// from the cache instead of being generated again.
+func (g *CommitMessageGenerator) CachedAt() (time.Time, bool) {
+	return g.cachedAt, !g.cachedAt.IsZero()
+}
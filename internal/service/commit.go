@@ -1,14 +1,24 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
 	"github.com/hambosto/ai-generate-commit/internal/groq"
+	"github.com/hambosto/ai-generate-commit/internal/lint"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+	"github.com/hambosto/ai-generate-commit/internal/tracker"
 )
 
 const (
-	defaultModel  = "llama3-8b-8192" // Default model to use for generating commit messages
 	defaultPrompt = `
 KEEP IN MIND THAT STICK TO THE POINT TO ONLY REPLY WITH MY PROMPTED MESSAGE!!! DO NOT ADD ANY ADDITIONAL INFORMATION !!!
 DO NOT SAY "Here is the commit message" OR SUCH LIKE THAT. JUST REPLY ONLY THE COMMIT MESSAGE ITSELF !!!
@@ -30,49 +40,490 @@ You are an AI designed to generate concise and meaningful commit messages for co
 `
 )
 
+// ErrEmptyMessage is returned when the AI replies with an empty commit message, which git would refuse.
+var ErrEmptyMessage = errors.New("the AI returned an empty commit message")
+
 // CommitMessageGenerator handles the generation of commit messages.
 type CommitMessageGenerator struct {
-	client *groq.Client // GROQ API client used for generating messages
-	model  string       // Model to use for the generation
+	client   *groq.Client    // API client used for generating messages
+	get      config.Getter   // Source of the settings, see Options.Config
+	isolated bool            // Whether the settings come from Options.Config, without the prompts directory
+	provider string          // Name of the provider the client talks to
+	model    string          // Model to use for the generation
+	params   groq.Parameters // Sampling parameters sent with every request
+	repo     RepoContext     // Repository information added to the prompt
+	lint     *lint.Rules     // Rules generated messages must follow, nil if linting is disabled
+	language *language       // Language messages are written in, nil to leave it to the model
+	stream   func(string)    // Receives the text of a message as it is generated, see SetStream
+	window   int             // Number of tokens the model can read, see fitDiff
+	omitted  []git.Omission  // What was left out of the last diff to fit the context window
+	noCache  bool            // Whether cached messages are ignored, though still replaced
+	cacheKey string          // Cache key of the prompt of the last generation, "" if it is not cached
+	cachedAt time.Time       // When the message of the last generation was generated, if it was cached
+
+	structured string               // Mode of STRUCTURED_OUTPUT, "" if messages are replied as text
+	template   *template.Template   // Template of STRUCTURED_TEMPLATE, nil to use that of the commit style
+	format     *groq.ResponseFormat // Response format of the last generation, nil if it is replied as text
+
+	offline      bool  // Whether messages are written from the diff alone, see offlineMessage
+	offlineCause error // The error that made the generator write messages offline, nil if it was asked to
+
+	// prompt holds the messages of the last generation, which Regenerate continues.
+	prompt []groq.Message
+}
+
+// Options holds per-invocation settings for a CommitMessageGenerator.
+// Unset fields fall back to the configuration.
+type Options struct {
+	Model      string          // Model to use; falls back to MODEL, then to the provider's default
+	Parameters groq.Parameters // Sampling parameters; nil fields fall back to TEMPERATURE, MAX_TOKENS, TOP_P and SEED
+	Context    RepoContext     // Information about the repository added to the prompt
+	NoCache    bool            // Generate new messages instead of reusing cached ones, which are replaced
+	Offline    bool            // Write messages from the diff alone instead of asking the AI
+
+	// Config reads the settings of the generator, from the provider to the commit style; nil reads the
+	// configuration with config.GetConfig. The commitlint configuration of the repository and the
+	// templates of the prompts directory are only used with the configuration.
+	Config config.Getter
+	// Interceptors handle the requests of the generator to the API, see groq.ClientOptions.
+	Interceptors []groq.Interceptor
+}
+
+// RepoContext holds information about the repository that helps the AI describe a change.
+// Empty fields are left out of the prompt.
+type RepoContext struct {
+	Branch     string   // Current branch, whose name often contains a ticket ID or the feature being built
+	BaseBranch string   // Branch the current branch is compared against, e.g. "origin/main"
+	BaseStat   string   // Diffstat of the commits on the current branch that are not on BaseBranch
+	Examples   []string // Subjects of recent commits, used as examples of the repository's conventions
+	Convention string   // Commit style detected in the history, used when COMMIT_STYLE is "auto"
+
+	// Issue is the issue the branch refers to, as fetched from the issue tracker, or nil.
+	Issue *tracker.Issue
 }
 
 // NewCommitMessageGenerator creates a new CommitMessageGenerator.
-// It initializes a GROQ client and sets the model to the default if not provided.
-func NewCommitMessageGenerator(model string) (*CommitMessageGenerator, error) {
-	client, err := groq.NewClient()
+// It initializes a client for the configured provider and resolves the model and
+// sampling parameters from opts, then from the configuration. Without an API key or
+// a reachable provider, messages are written offline if OFFLINE_FALLBACK allows it.
+func NewCommitMessageGenerator(opts Options) (*CommitMessageGenerator, error) {
+	get, isolated := opts.Config, opts.Config != nil
+	if !isolated {
+		get = config.GetConfig
+	}
+	if opts.Offline {
+		return newOfflineGenerator(get, opts, nil), nil
+	}
+	client, preset, err := provider.NewWithLookup(provider.LookupFunc(get), opts.Interceptors...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GROQ client: %w", err)
+		if offlineFallback(get, err) {
+			return newOfflineGenerator(get, opts, err), nil
+		}
+		return nil, fmt.Errorf("failed to create API client: %w", err)
 	}
 
+	model := opts.Model
 	if model == "" {
-		model = defaultModel // Use default model if none is provided
+		// Use the configured model, or the provider's default if none is configured.
+		model, err = get("MODEL")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get model: %w", err)
+		}
+		if model == "" {
+			model = preset.DefaultModel
+		}
+	}
+
+	params, err := resolveParameters(get, opts.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the configuration is read together with the commitlint configuration of the repository.
+	var rules *lint.Rules
+	if !isolated {
+		rules, err = lint.LoadRules()
+	} else {
+		rules, err = lint.RulesFrom(get)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lang, err := commitLanguage(get)
+	if err != nil {
+		return nil, err
+	}
+
+	window, err := contextWindow(get, model, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	structured, tmpl, err := structuredOutput(get)
+	if err != nil {
+		return nil, err
 	}
 
 	return &CommitMessageGenerator{
-		client: client, // Set the GROQ client
-		model:  model,  // Set the model
+		client:   client,       // Set the GROQ client
+		get:      get,          // Set the source of the settings
+		isolated: isolated,     // Set whether the settings were injected
+		provider: preset.Name,  // Set the provider name
+		model:    model,        // Set the model
+		params:   params,       // Set the sampling parameters
+		repo:     opts.Context, // Set the repository context
+		lint:     rules,        // Set the lint rules
+		language: lang,         // Set the language of the messages
+		window:   window,       // Set the context window of the model
+		noCache:  opts.NoCache, // Set whether cached messages are reused
+
+		structured: structured, // Set the structured output mode
+		template:   tmpl,       // Set the template of structured messages
 	}, nil
 }
 
+// Provider returns the name of the provider messages are generated with, e.g. "groq".
+func (g *CommitMessageGenerator) Provider() string {
+	return g.provider
+}
+
+// Model returns the model messages are generated with.
+func (g *CommitMessageGenerator) Model() string {
+	return g.model
+}
+
+// Usage returns the tokens used by all requests of the generator so far.
+func (g *CommitMessageGenerator) Usage() groq.Usage {
+	if g.client == nil {
+		return groq.Usage{}
+	}
+	return g.client.Usage()
+}
+
+// Elapsed returns the time the AI took to answer the requests of the generator so far, zero for
+// messages written offline or taken from the cache.
+func (g *CommitMessageGenerator) Elapsed() time.Duration {
+	if g.client == nil {
+		return 0
+	}
+	return g.client.Elapsed()
+}
+
+// SetStream makes f receive the text of the messages and pull request descriptions generated from
+// now on as it arrives, e.g. to show it live. The text is the model's reply before it is checked and
+// formatted, and candidates generated together are not streamed. A nil f stops it.
+func (g *CommitMessageGenerator) SetStream(f func(delta string)) {
+	g.stream = f
+}
+
+// SetContext makes the requests of the generator to the API, and the waits between their retries,
+// end once ctx is done.
+func (g *CommitMessageGenerator) SetContext(ctx context.Context) {
+	if g.client != nil {
+		g.client.SetContext(ctx)
+	}
+}
+
+// deterministicSeed is the seed used with DETERMINISTIC when SEED is not set.
+const deterministicSeed = 42
+
+// resolveParameters fills the unset sampling parameters from the settings read through get. With
+// DETERMINISTIC, the temperature is 0 and the seed is fixed, whatever TEMPERATURE says.
+func resolveParameters(get config.Getter, params groq.Parameters) (groq.Parameters, error) {
+	deterministic, _, err := get.Bool("DETERMINISTIC")
+	if err != nil {
+		return params, err
+	}
+	if deterministic && params.Temperature == nil {
+		temperature := 0.0
+		params.Temperature = &temperature
+	}
+
+	if params.Temperature == nil {
+		temperature, ok, err := get.Float("TEMPERATURE")
+		if err != nil {
+			return params, err
+		}
+		if ok {
+			params.Temperature = &temperature
+		}
+	}
+
+	if params.MaxTokens == nil {
+		maxTokens, ok, err := get.Int("MAX_TOKENS")
+		if err != nil {
+			return params, err
+		}
+		if ok {
+			params.MaxTokens = &maxTokens
+		}
+	}
+
+	if params.TopP == nil {
+		topP, ok, err := get.Float("TOP_P")
+		if err != nil {
+			return params, err
+		}
+		if ok {
+			params.TopP = &topP
+		}
+	}
+
+	if params.Seed == nil {
+		seed, ok, err := get.Int("SEED")
+		if err != nil {
+			return params, err
+		}
+		if ok {
+			params.Seed = &seed
+		} else if deterministic {
+			seed = deterministicSeed
+			params.Seed = &seed
+		}
+	}
+	return params, nil
+}
+
 // GenerateCommitMessage creates a commit message based on the provided git diff.
-// It uses the configured or default prompt to instruct the AI on how to generate the message.
+// It uses the prompt of the configured commit style to instruct the AI on how to generate the message.
 func (g *CommitMessageGenerator) GenerateCommitMessage(diff string) (string, error) {
-	commitPrompt, err := config.GetConfig("COMMIT_PROMPT")
+	if g.offline {
+		return g.offlineMessage(diff)
+	}
+	message, err := g.generate(g.userMessage(diff), diff)
+	if err != nil && g.fallBack(err) {
+		return g.offlineMessage(diff)
+	}
+	return message, err
+}
+
+// GenerateFromDescription creates a commit message for a commit without changes, such as a marker
+// commit made with --allow-empty, from the author's short description of its purpose.
+func (g *CommitMessageGenerator) GenerateFromDescription(description string) (string, error) {
+	if g.offline {
+		return g.offlineDescription(description)
+	}
+	message, err := g.generate(g.contextMessage()+"This commit changes no files. Write its message from the author's description of its purpose:\n"+description, "")
+	if err != nil && g.fallBack(err) {
+		return g.offlineDescription(description)
+	}
+	return message, err
+}
+
+// generate asks the AI for a commit message in the configured style, described by the user message
+// with the diff it contains. The message last generated for the same prompt is reused for CACHE_TTL hours.
+func (g *CommitMessageGenerator) generate(user, diff string) (string, error) {
+	style, err := g.startPrompt(user, diff)
 	if err != nil {
-		return "", fmt.Errorf("failed to get commit prompt: %w", err)
+		return "", err
 	}
+	if message, ok, err := g.cached(style); err != nil || ok {
+		return message, err
+	}
+	message, err := g.complete(style, slices.Clone(g.prompt), g.stream)
+	if err != nil {
+		return "", err
+	}
+	g.remember(message)
+	return message, nil
+}
 
-	if commitPrompt == "" {
-		commitPrompt = defaultPrompt // Use default prompt if none is set in config
+// startPrompt sets the prompt of a new generation from the user message and the diff it describes,
+// and returns the commit style it uses.
+func (g *CommitMessageGenerator) startPrompt(user, diff string) (string, error) {
+	style, err := g.commitStyle()
+	if err != nil {
+		return "", err
+	}
+	commitPrompt, err := g.systemPrompt(style, g.promptData(style, diff))
+	if err != nil {
+		return "", err
+	}
+	if g.language != nil {
+		commitPrompt += g.language.instruction()
+	}
+	g.format = nil
+	if g.structured != "" {
+		fields, err := g.structuredFields(style)
+		if err != nil {
+			return "", err
+		}
+		commitPrompt += structuredInstruction(fields)
+		g.format = responseFormat(g.structured, fields)
 	}
 
 	// Create messages for the API request
-	messages := []groq.Message{
-		{Role: "system", Content: commitPrompt},                                // System prompt to guide AI
-		{Role: "user", Content: fmt.Sprintf("Here's the git diff:\n%s", diff)}, // User message with the git diff
+	g.prompt = []groq.Message{
+		{Role: "system", Content: commitPrompt}, // System prompt to guide AI
+		{Role: "user", Content: user},           // User message with the repository context and the change
+	}
+	return style, nil
+}
+
+// GenerateCandidates creates n alternative commit messages for the provided git diff with parallel
+// requests, so the user can pick the best one. Identical messages are only returned once.
+func (g *CommitMessageGenerator) GenerateCandidates(diff string, n int) ([]string, error) {
+	// Offline, there is only one message to write.
+	if g.offline {
+		message, err := g.offlineMessage(diff)
+		if err != nil {
+			return nil, err
+		}
+		return []string{message}, nil
+	}
+	style, err := g.startPrompt(g.userMessage(diff), diff)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sends the requests at once; each one gets its own copy of the conversation for lint retries.
+	messages := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			messages[i], errs[i] = g.complete(style, slices.Clone(g.prompt), nil)
+		}()
+	}
+	wg.Wait()
+
+	// Keeps the candidates that succeeded, failing only if none did.
+	var candidates []string
+	for i, message := range messages {
+		if errs[i] == nil && !slices.Contains(candidates, message) {
+			candidates = append(candidates, message)
+		}
+	}
+	if len(candidates) == 0 {
+		if g.fallBack(errs[0]) {
+			return g.GenerateCandidates(diff, n)
+		}
+		return nil, errs[0]
+	}
+	return candidates, nil
+}
+
+// Regenerate asks the AI for another message for the change of the last generation, optionally with
+// a hint from the user such as "mention the API rename". The AI is shown its previous message,
+// so it knows what to improve on.
+func (g *CommitMessageGenerator) Regenerate(previous, hint string) (string, error) {
+	// Offline, the same message would be written again.
+	if g.offline {
+		return "", ErrOffline
+	}
+	if len(g.prompt) == 0 {
+		return "", errors.New("no message has been generated yet")
+	}
+	g.cachedAt = time.Time{}
+	style, err := g.commitStyle()
+	if err != nil {
+		return "", err
+	}
+
+	request := "Write a different commit message for the same change, following the same rules."
+	if hint != "" {
+		request += "\nTake this into account: " + hint
+	}
+	messages := append(slices.Clone(g.prompt),
+		groq.Message{Role: "assistant", Content: previous},
+		groq.Message{Role: "user", Content: request},
+	)
+	message, err := g.complete(style, messages, g.stream)
+	if err != nil {
+		return "", err
+	}
+	// The new message replaces the cached one, so generating again shows the message seen last.
+	g.remember(message)
+	return message, nil
+}
+
+// complete sends the conversation to the AI and checks its reply against the rules of style.
+// The reply is passed to stream as it arrives unless stream is nil.
+func (g *CommitMessageGenerator) complete(style string, messages []groq.Message, stream func(string)) (string, error) {
+	// A JSON object is not worth watching as it is written.
+	if g.format != nil {
+		stream = nil
 	}
 
 	// Call the GROQ client to generate the completion
-	return g.client.GenerateCompletion(messages, g.model)
+	message, err := g.completion(messages, g.messageParams(), stream)
+	if err != nil {
+		return "", err
+	}
+
+	// Check the reply against the rules of the commit style
+	if g.lint == nil {
+		message, err = g.formatMessage(style, message)
+	} else {
+		message, err = g.lintMessage(style, messages, message)
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(message) == "" {
+		return "", ErrEmptyMessage
+	}
+	return message, nil
 }
 
+// completion sends the conversation to the AI with params, streaming the reply to stream unless it is nil.
+func (g *CommitMessageGenerator) completion(messages []groq.Message, params groq.Parameters, stream func(string)) (string, error) {
+	if stream == nil {
+		return g.client.GenerateCompletion(messages, g.model, params)
+	}
+	return g.client.GenerateCompletionStream(messages, g.model, params, stream)
+}
+
+// userMessage builds the user message from the repository context and the diff, shortened to fit
+// the context window of the model.
+func (g *CommitMessageGenerator) userMessage(diff string) string {
+	return g.contextMessage() + "Here's the git diff:\n" + g.fitDiff(diff)
+}
+
+// maxIssueDescription is the most characters of the description of an issue included in the prompt,
+// whose beginning usually says what the task is.
+const maxIssueDescription = 2000
+
+// contextMessage describes the repository context for the user message, ending with a blank line.
+func (g *CommitMessageGenerator) contextMessage() string {
+	var b strings.Builder
+	if g.repo.Branch != "" {
+		fmt.Fprintf(&b, "Current branch: %s\n", g.repo.Branch)
+		b.WriteString("Use the branch name for context, such as a ticket ID or the feature being worked on, but describe the diff.\n\n")
+	}
+	if issue := g.repo.Issue; issue != nil {
+		fmt.Fprintf(&b, "The branch belongs to issue %s: %s\n", issue.Key, issue.Title)
+		if issue.Description != "" {
+			fmt.Fprintf(&b, "Description of the issue:\n%s\n", truncateRunes(issue.Description, maxIssueDescription))
+		}
+		b.WriteString("Use the issue to explain why the change is made, but describe what the diff does, which may be only part of the issue.\n\n")
+	}
+	if g.repo.BaseStat != "" {
+		fmt.Fprintf(&b, "Changes already on this branch compared to %s:\n%s\n\n", g.repo.BaseBranch, g.repo.BaseStat)
+	}
+	if len(g.repo.Examples) > 0 {
+		b.WriteString("Recent commit messages in this repository, newest first:\n")
+		for _, example := range g.repo.Examples {
+			fmt.Fprintf(&b, "- %s\n", example)
+		}
+		if g.language != nil {
+			// The configured language wins over the language of the history.
+			b.WriteString("Match their tone, capitalization and conventions where they do not conflict with the required format and language.\n\n")
+		} else {
+			b.WriteString("Match their language, tone, capitalization and conventions where they do not conflict with the required format.\n\n")
+		}
+	}
+	return b.String()
+}
+
+// truncateRunes shortens s to at most limit characters, marking the cut with an ellipsis.
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-3]) + "..."
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"strings"
+)
+
+// MergeCommitTemplate fills a commit template, such as the file set as commit.template, with message.
+// Templates with {subject} and {body} placeholders get the subject and body in those places; the body
+// follows the subject if only {subject} is present, and the subject goes first if only {body} is.
+// Other templates are appended below the message, so their sections and checklists are kept.
+// Comment lines are left in place for the caller or git to strip.
+func MergeCommitTemplate(message, template string) string {
+	message = strings.TrimSpace(message)
+	template = strings.TrimLeft(template, "\r\n")
+	if strings.TrimSpace(template) == "" {
+		return message
+	}
+
+	hasSubject := strings.Contains(template, subjectPlaceholder)
+	hasBody := strings.Contains(template, bodyPlaceholder)
+	if !hasSubject && !hasBody {
+		return message + "\n\n" + template
+	}
+
+	subject, body, _ := strings.Cut(message, "\n")
+	body = strings.TrimSpace(body)
+
+	var lines []string
+	if !hasSubject {
+		lines = append(lines, subject, "")
+	}
+	for _, line := range strings.Split(template, "\n") {
+		switch {
+		case strings.Contains(line, subjectPlaceholder):
+			lines = append(lines, strings.ReplaceAll(line, subjectPlaceholder, subject))
+			if !hasBody && body != "" {
+				lines = append(lines, "", body)
+			}
+		case strings.TrimSpace(line) == bodyPlaceholder:
+			// Drops the line of an empty body instead of leaving a blank line in its place.
+			if body != "" {
+				lines = append(lines, body)
+			}
+		default:
+			lines = append(lines, strings.ReplaceAll(line, bodyPlaceholder, body))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
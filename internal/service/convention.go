@@ -0,0 +1,62 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// minConventionSample is the number of commits below which the history is too short to tell its convention.
+const minConventionSample = 3
+
+var (
+	// conventionalPattern matches Conventional Commits subjects such as "feat(api)!: add pagination".
+	conventionalPattern = regexp.MustCompile(`^[a-z]+(\([^)]*\))?!?: \S`)
+	// bracketPattern matches subjects of the default style such as "[Fix] (main.go) handle empty diffs".
+	bracketPattern = regexp.MustCompile(`^\[[A-Za-z]+\] \S`)
+)
+
+// DetectStyle returns the commit style that most of the subjects follow: conventional, gitmoji,
+// the bracket style of StyleDefault, or plain when none of them applies. It returns an empty
+// string if there are too few subjects or no style is used by more than half of them.
+func DetectStyle(subjects []string) string {
+	if len(subjects) < minConventionSample {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	for _, subject := range subjects {
+		counts[subjectStyle(subject)]++
+	}
+
+	// Requires a majority, so a repository that mixes conventions keeps the default.
+	for style, count := range counts {
+		if 2*count > len(subjects) {
+			return style
+		}
+	}
+	return ""
+}
+
+// subjectStyle classifies a single commit subject.
+func subjectStyle(subject string) string {
+	switch {
+	case conventionalPattern.MatchString(subject):
+		return StyleConventional
+	case hasGitmoji(subject):
+		return StyleGitmoji
+	case bracketPattern.MatchString(subject):
+		return StyleDefault
+	default:
+		return StylePlain
+	}
+}
+
+// hasGitmoji reports whether subject starts with a gitmoji or its shortcode.
+func hasGitmoji(subject string) bool {
+	for _, g := range gitmojis {
+		if strings.HasPrefix(subject, g.code) || strings.HasPrefix(subject, strings.TrimSuffix(g.emoji, variationSelector)) {
+			return true
+		}
+	}
+	return false
+}
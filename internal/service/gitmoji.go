@@ -0,0 +1,158 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidGitmoji is returned when a gitmoji-style message does not start with an emoji from the official list.
+var ErrInvalidGitmoji = errors.New("generated message does not start with a valid gitmoji")
+
+// gitmoji is an entry of the official gitmoji list (https://gitmoji.dev).
+type gitmoji struct {
+	emoji       string // Emoji character, as listed by gitmoji.dev
+	code        string // Shortcode, e.g. ":bug:"
+	description string // When to use the emoji
+}
+
+// gitmojis is the official gitmoji list.
+var gitmojis = []gitmoji{
+	{"🎨", ":art:", "Improve structure / format of the code."},
+	{"⚡️", ":zap:", "Improve performance."},
+	{"🔥", ":fire:", "Remove code or files."},
+	{"🐛", ":bug:", "Fix a bug."},
+	{"🚑️", ":ambulance:", "Critical hotfix."},
+	{"✨", ":sparkles:", "Introduce new features."},
+	{"📝", ":memo:", "Add or update documentation."},
+	{"🚀", ":rocket:", "Deploy stuff."},
+	{"💄", ":lipstick:", "Add or update the UI and style files."},
+	{"🎉", ":tada:", "Begin a project."},
+	{"✅", ":white_check_mark:", "Add, update, or pass tests."},
+	{"🔒️", ":lock:", "Fix security or privacy issues."},
+	{"🔐", ":closed_lock_with_key:", "Add or update secrets."},
+	{"🔖", ":bookmark:", "Release / Version tags."},
+	{"🚨", ":rotating_light:", "Fix compiler / linter warnings."},
+	{"🚧", ":construction:", "Work in progress."},
+	{"💚", ":green_heart:", "Fix CI Build."},
+	{"⬇️", ":arrow_down:", "Downgrade dependencies."},
+	{"⬆️", ":arrow_up:", "Upgrade dependencies."},
+	{"📌", ":pushpin:", "Pin dependencies to specific versions."},
+	{"👷", ":construction_worker:", "Add or update CI build system."},
+	{"📈", ":chart_with_upwards_trend:", "Add or update analytics or track code."},
+	{"♻️", ":recycle:", "Refactor code."},
+	{"➕", ":heavy_plus_sign:", "Add a dependency."},
+	{"➖", ":heavy_minus_sign:", "Remove a dependency."},
+	{"🔧", ":wrench:", "Add or update configuration files."},
+	{"🔨", ":hammer:", "Add or update development scripts."},
+	{"🌐", ":globe_with_meridians:", "Internationalization and localization."},
+	{"✏️", ":pencil2:", "Fix typos."},
+	{"💩", ":poop:", "Write bad code that needs to be improved."},
+	{"⏪️", ":rewind:", "Revert changes."},
+	{"🔀", ":twisted_rightwards_arrows:", "Merge branches."},
+	{"📦️", ":package:", "Add or update compiled files or packages."},
+	{"👽️", ":alien:", "Update code due to external API changes."},
+	{"🚚", ":truck:", "Move or rename resources (e.g.: files, paths, routes)."},
+	{"📄", ":page_facing_up:", "Add or update license."},
+	{"💥", ":boom:", "Introduce breaking changes."},
+	{"🍱", ":bento:", "Add or update assets."},
+	{"♿️", ":wheelchair:", "Improve accessibility."},
+	{"💡", ":bulb:", "Add or update comments in source code."},
+	{"🍻", ":beers:", "Write code drunkenly."},
+	{"💬", ":speech_balloon:", "Add or update text and literals."},
+	{"🗃️", ":card_file_box:", "Perform database related changes."},
+	{"🔊", ":loud_sound:", "Add or update logs."},
+	{"🔇", ":mute:", "Remove logs."},
+	{"👥", ":busts_in_silhouette:", "Add or update contributor(s)."},
+	{"🚸", ":children_crossing:", "Improve user experience / usability."},
+	{"🏗️", ":building_construction:", "Make architectural changes."},
+	{"📱", ":iphone:", "Work on responsive design."},
+	{"🤡", ":clown_face:", "Mock things."},
+	{"🥚", ":egg:", "Add or update an easter egg."},
+	{"🙈", ":see_no_evil:", "Add or update a .gitignore file."},
+	{"📸", ":camera_flash:", "Add or update snapshots."},
+	{"⚗️", ":alembic:", "Perform experiments."},
+	{"🔍️", ":mag:", "Improve SEO."},
+	{"🏷️", ":label:", "Add or update types."},
+	{"🌱", ":seedling:", "Add or update seed files."},
+	{"🚩", ":triangular_flag_on_post:", "Add, update, or remove feature flags."},
+	{"🥅", ":goal_net:", "Catch errors."},
+	{"💫", ":dizzy:", "Add or update animations and transitions."},
+	{"🗑️", ":wastebasket:", "Deprecate code that needs to be cleaned up."},
+	{"🛂", ":passport_control:", "Work on code related to authorization, roles and permissions."},
+	{"🩹", ":adhesive_bandage:", "Simple fix for a non-critical issue."},
+	{"🧐", ":monocle_face:", "Data exploration/inspection."},
+	{"⚰️", ":coffin:", "Remove dead code."},
+	{"🧪", ":test_tube:", "Add a failing test."},
+	{"👔", ":necktie:", "Add or update business logic."},
+	{"🩺", ":stethoscope:", "Add or update healthcheck."},
+	{"🧱", ":bricks:", "Infrastructure related changes."},
+	{"🧑‍💻", ":technologist:", "Improve developer experience."},
+	{"💸", ":money_with_wings:", "Add sponsorships or money related infrastructure."},
+	{"🧵", ":thread:", "Add or update code related to multithreading or concurrency."},
+	{"🦺", ":safety_vest:", "Add or update code related to validation."},
+	{"✈️", ":airplane:", "Improve offline support."},
+}
+
+// variationSelector is the emoji presentation selector that models often add or drop.
+const variationSelector = "\uFE0F"
+
+// gitmojiPrompt builds the system prompt of the gitmoji style from the official list.
+func gitmojiPrompt() string {
+	var b strings.Builder
+	b.WriteString(`
+You are an AI that writes git commit messages in the gitmoji style.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Format:
+<emoji> <subject>
+
+<body>
+
+Rules:
+  1. <emoji> is exactly one emoji from the list below, the one that best describes the intent of the change.
+  2. <subject> is written in the imperative mood, starts with a capital letter, has no trailing period, and keeps the whole first line within 72 characters.
+  3. <body> is optional: separated from the subject by a blank line, it explains what changed and why, wrapped at 72 characters. Omit it for trivial changes.
+
+Allowed emojis:
+`)
+	for _, g := range gitmojis {
+		fmt.Fprintf(&b, "  %s %s\n", g.emoji, g.description)
+	}
+	b.WriteString(`
+Example:
+🐛 Fix crash when the staged diff is empty
+`)
+	return b.String()
+}
+
+// formatGitmoji checks that message starts with an emoji from the official list and normalizes it:
+// a shortcode such as ":bug:" is replaced by its emoji, the emoji is written in its canonical form,
+// and exactly one space separates it from the subject.
+func formatGitmoji(message string) (string, error) {
+	message = strings.TrimSpace(message)
+
+	for _, g := range gitmojis {
+		// Accepts the shortcode and the emoji with or without the variation selector.
+		for _, prefix := range []string{g.code, g.emoji, strings.TrimSuffix(g.emoji, variationSelector)} {
+			rest, ok := strings.CutPrefix(message, prefix)
+			if !ok {
+				continue
+			}
+			rest = strings.TrimPrefix(rest, variationSelector)
+
+			// Rejects prefixes of a longer emoji, e.g. "🧑" of "🧑‍💻" followed by a zero-width joiner.
+			if strings.HasPrefix(rest, "\u200D") {
+				continue
+			}
+			subject := strings.TrimSpace(rest)
+			if subject == "" {
+				return "", fmt.Errorf("%w: missing subject after %s", ErrInvalidGitmoji, g.emoji)
+			}
+			return g.emoji + " " + subject, nil
+		}
+	}
+
+	firstLine, _, _ := strings.Cut(message, "\n")
+	return "", fmt.Errorf("%w: %q", ErrInvalidGitmoji, firstLine)
+}
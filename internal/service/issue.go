@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+)
+
+const (
+	// issuePlaceholder is replaced by the issue reference in ISSUE_TEMPLATE.
+	issuePlaceholder = "{issue}"
+	// subjectPlaceholder is replaced by the generated subject line in ISSUE_TEMPLATE and commit templates.
+	subjectPlaceholder = "{subject}"
+	// bodyPlaceholder is replaced by the generated body in commit templates.
+	bodyPlaceholder = "{body}"
+)
+
+// FindIssueReference returns the issue reference in a branch name, such as "PROJ-123" in
+// "feat/PROJ-123-new-login" or "#456" in "issue-456-crash", using ISSUE_PATTERN.
+// It returns an empty string if the branch names no issue.
+func FindIssueReference(branch string) (string, error) {
+	pattern, err := config.GetConfig("ISSUE_PATTERN")
+	if err != nil || pattern == "" || branch == "" {
+		return "", err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid ISSUE_PATTERN: %w", err)
+	}
+
+	// Uses the first capture group that matched, or the whole match if the pattern has none.
+	match := re.FindStringSubmatch(branch)
+	if match == nil {
+		return "", nil
+	}
+	reference := match[0]
+	for _, group := range match[1:] {
+		if group != "" {
+			reference = group
+			break
+		}
+	}
+
+	// Numeric references are issue numbers, which GitHub and GitLab link when written as "#456".
+	if strings.Trim(reference, "0123456789") == "" {
+		reference = "#" + reference
+	}
+	return reference, nil
+}
+
+// InsertIssueReference adds the issue reference to the subject line of message using ISSUE_TEMPLATE,
+// e.g. "[{issue}] {subject}" or "{subject} ({issue})". The message is returned unchanged if it
+// already mentions the reference or the template is "none".
+func InsertIssueReference(message, reference string) (string, error) {
+	if reference == "" || strings.Contains(message, reference) {
+		return message, nil
+	}
+	template, err := config.GetConfig("ISSUE_TEMPLATE")
+	if err != nil || template == "" || template == "none" {
+		return message, err
+	}
+
+	subject, body, hasBody := strings.Cut(message, "\n")
+	subject = strings.NewReplacer(issuePlaceholder, reference, subjectPlaceholder, subject).Replace(template)
+	if hasBody {
+		return subject + "\n" + body, nil
+	}
+	return subject, nil
+}
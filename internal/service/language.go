@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+)
+
+// ErrWrongLanguage is returned when a generated message is not written in the language set with COMMIT_LANGUAGE.
+var ErrWrongLanguage = errors.New("generated message is not written in the requested language")
+
+// language is a language messages can be generated in and checked against.
+type language struct {
+	name    string              // English name used in the prompt, e.g. "Japanese"
+	aliases []string            // ISO 639-1 code and other names accepted in COMMIT_LANGUAGE, lowercase
+	script  *unicode.RangeTable // Script the language is written in; nil for the Latin script
+}
+
+// languages are the languages whose messages are checked after generation. Other values of
+// COMMIT_LANGUAGE are passed to the AI as they are, without a check.
+var languages = []language{
+	{"English", []string{"en"}, nil},
+	{"German", []string{"de", "deutsch"}, nil},
+	{"French", []string{"fr", "français", "francais"}, nil},
+	{"Spanish", []string{"es", "español", "espanol"}, nil},
+	{"Portuguese", []string{"pt", "português", "portugues"}, nil},
+	{"Italian", []string{"it", "italiano"}, nil},
+	{"Dutch", []string{"nl", "nederlands"}, nil},
+	{"Polish", []string{"pl", "polski"}, nil},
+	{"Turkish", []string{"tr", "türkçe", "turkce"}, nil},
+	{"Swedish", []string{"sv", "svenska"}, nil},
+	{"Indonesian", []string{"id", "bahasa indonesia", "indonesia"}, nil},
+	{"Malay", []string{"ms", "bahasa melayu", "melayu"}, nil},
+	{"Vietnamese", []string{"vi", "tiếng việt"}, nil},
+	{"Japanese", []string{"ja", "jp", "日本語"}, unicode.Han},
+	{"Chinese", []string{"zh", "cn", "中文"}, unicode.Han},
+	{"Korean", []string{"ko", "kr", "한국어"}, unicode.Hangul},
+	{"Russian", []string{"ru", "русский"}, unicode.Cyrillic},
+	{"Ukrainian", []string{"uk", "українська"}, unicode.Cyrillic},
+	{"Greek", []string{"el", "ελληνικά"}, unicode.Greek},
+	{"Arabic", []string{"ar", "العربية"}, unicode.Arabic},
+	{"Hebrew", []string{"he", "עברית"}, unicode.Hebrew},
+	{"Hindi", []string{"hi", "हिन्दी"}, unicode.Devanagari},
+	{"Thai", []string{"th", "ไทย"}, unicode.Thai},
+}
+
+// englishWords are frequent English words that are not words of the other Latin-script languages,
+// used to notice a message written in English instead.
+var englishWords = map[string]bool{
+	"the": true, "and": true, "with": true, "when": true, "this": true, "that": true, "which": true,
+	"into": true, "instead": true, "without": true, "from": true, "its": true, "should": true,
+	"would": true, "were": true, "have": true, "been": true, "now": true,
+}
+
+// LanguageNames returns the names of the languages whose messages are checked, e.g. for completion.
+func LanguageNames() []string {
+	names := make([]string, len(languages))
+	for i, lang := range languages {
+		names[i] = lang.name
+	}
+	return names
+}
+
+// commitLanguage returns the language set with COMMIT_LANGUAGE, read through get, or nil if none is set.
+// A language that is not in the list is returned with only its name, so it is not checked.
+func commitLanguage(get config.Getter) (*language, error) {
+	value, err := get("COMMIT_LANGUAGE")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit language: %w", err)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	for _, lang := range languages {
+		if strings.EqualFold(value, lang.name) {
+			return &lang, nil
+		}
+		for _, alias := range lang.aliases {
+			if strings.EqualFold(value, alias) {
+				return &lang, nil
+			}
+		}
+	}
+	return &language{name: value}, nil
+}
+
+// instruction returns the sentences added to the system prompt to ask for the language.
+func (l *language) instruction() string {
+	return fmt.Sprintf("\nWrite the commit message in %s. Keep the fixed parts of the format, such as types, "+
+		"prefixes in brackets and emojis, as well as code identifiers and file names, exactly as they are.\n", l.name)
+}
+
+// english reports whether the language is English, the only language the imperative lint rule understands.
+func (l *language) english() bool {
+	return l.name == "English"
+}
+
+// check returns an error wrapping ErrWrongLanguage if message is evidently not written in the language.
+// Languages with their own script must use it; the other languages must not read like English.
+// Languages that are not in the list always pass.
+func (l *language) check(message string) error {
+	if !l.known() {
+		return nil
+	}
+	var ok bool
+	switch {
+	case l.name == "Japanese":
+		// Japanese mixes kanji with kana, which tells it apart from Chinese.
+		ok = countLetters(message, unicode.Hiragana)+countLetters(message, unicode.Katakana) >= 2
+	case l.name == "Chinese":
+		ok = countLetters(message, unicode.Han) >= 2 &&
+			countLetters(message, unicode.Hiragana)+countLetters(message, unicode.Katakana) == 0
+	case l.script != nil:
+		ok = countLetters(message, l.script) >= 2
+	case l.english():
+		ok = countLetters(message, unicode.Latin)*2 >= countLetters(message, nil)
+	default:
+		ok = countLetters(message, unicode.Latin)*2 >= countLetters(message, nil) && !readsLikeEnglish(message)
+	}
+	if !ok {
+		return fmt.Errorf("%w: it must be written in %s", ErrWrongLanguage, l.name)
+	}
+	return nil
+}
+
+// known reports whether the language is in the list of languages that are checked.
+func (l *language) known() bool {
+	for _, lang := range languages {
+		if lang.name == l.name {
+			return true
+		}
+	}
+	return false
+}
+
+// countLetters returns the number of letters of the given script in s, or of all letters if script is nil.
+func countLetters(s string, script *unicode.RangeTable) int {
+	n := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) && (script == nil || unicode.Is(script, r)) {
+			n++
+		}
+	}
+	return n
+}
+
+// readsLikeEnglish reports whether s contains English words that other languages do not have:
+// two of them, or one in a message of a few words, such as a short subject.
+func readsLikeEnglish(s string) bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	hits := 0
+	for _, word := range words {
+		if englishWords[word] {
+			hits++
+		}
+	}
+	return hits >= 2 || (hits == 1 && len(words) < 12)
+}
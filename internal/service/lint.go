@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+	"github.com/hambosto/ai-generate-commit/internal/lint"
+)
+
+// lintMessage formats and checks the AI's reply. While the message breaks the lint rules or cannot be
+// formatted, the AI is told what is wrong and asked for a corrected message, up to LINT_RETRIES times.
+func (g *CommitMessageGenerator) lintMessage(style string, messages []groq.Message, reply string) (string, error) {
+	retries, _, err := g.get.Int("LINT_RETRIES")
+	if err != nil {
+		return "", err
+	}
+
+	// Types are only checked for the conventional style; the other styles have no type or their own.
+	rules := *g.lint
+	if style != StyleConventional {
+		rules.Types = nil
+		rules.RequireType = false
+	}
+	// The imperative mood is only recognized in English.
+	if g.language != nil && !g.language.english() {
+		rules.Imperative = false
+	}
+
+	for attempt := 0; ; attempt++ {
+		message, err := g.formatMessage(style, reply)
+		var violations []string
+		if err != nil {
+			violations = []string{err.Error()}
+		} else {
+			violations = rules.Check(message)
+		}
+		if len(violations) == 0 {
+			return message, nil
+		}
+		if attempt == retries {
+			return "", fmt.Errorf("%w after %d attempt(s):\n  - %s", lint.ErrViolations, attempt+1, strings.Join(violations, "\n  - "))
+		}
+
+		correction := "Reply with the corrected commit message only."
+		if g.format != nil {
+			correction = "Reply with the JSON object of the corrected commit message only."
+		}
+		messages = append(messages,
+			groq.Message{Role: "assistant", Content: reply},
+			groq.Message{Role: "user", Content: fmt.Sprintf(
+				"The commit message breaks these rules:\n- %s\n%s",
+				strings.Join(violations, "\n- "), correction)},
+		)
+		if reply, err = g.client.GenerateCompletion(messages, g.model, g.messageParams()); err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"strings"
+)
+
+const (
+	// bodyWidth is the column at which body lines are wrapped.
+	bodyWidth = 72
+)
+
+const multilinePrompt = `
+You are an AI that writes git commit messages with a subject line and a body.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Format:
+<subject>
+
+- <bullet point>
+- <bullet point>
+
+Rules:
+  1. <subject> summarizes the change in the imperative mood in 50 characters or fewer, starts with a capital letter, and has no trailing period.
+  2. Leave exactly one blank line between the subject and the body.
+  3. The body is a list of bullet points starting with "- ". Each bullet explains why a part of the change was made, not only what was changed.
+  4. Use one to five bullets, depending on the size of the change. Wrap lines at 72 characters.
+
+Example:
+Retry requests when the API is rate limited
+
+- Requests failed immediately on HTTP 429, so large diffs often
+  produced no message at all.
+- Honor the Retry-After header so retries do not make the rate
+  limiting worse.
+`
+
+// formatMultiline normalizes a subject-and-body message: the subject is followed by exactly
+// one blank line, and body lines are wrapped at bodyWidth, indenting continuation lines of bullets.
+func formatMultiline(message string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	subject := strings.TrimSpace(lines[0])
+
+	// Drops the blank lines between the subject and the body.
+	body := lines[1:]
+	for len(body) > 0 && strings.TrimSpace(body[0]) == "" {
+		body = body[1:]
+	}
+	if len(body) == 0 {
+		return subject, nil
+	}
+
+	wrapped := make([]string, 0, len(body))
+	for _, line := range body {
+		wrapped = append(wrapped, wrapLine(strings.TrimRight(line, " \t"), bodyWidth)...)
+	}
+	return subject + "\n\n" + strings.Join(wrapped, "\n"), nil
+}
+
+// wrapLine splits line into lines of at most width characters at word boundaries.
+// Continuation lines of a bullet ("- ", "* ") are indented to align with its text.
+// Words longer than width are kept whole.
+func wrapLine(line string, width int) []string {
+	if len([]rune(line)) <= width {
+		return []string{line}
+	}
+
+	// Keeps the leading indentation and bullet marker on the first line only.
+	trimmed := strings.TrimLeft(line, " \t")
+	prefix := line[:len(line)-len(trimmed)]
+	indent := prefix
+	for _, bullet := range []string{"- ", "* "} {
+		if strings.HasPrefix(trimmed, bullet) {
+			prefix += bullet
+			indent += strings.Repeat(" ", len(bullet))
+			trimmed = trimmed[len(bullet):]
+			break
+		}
+	}
+
+	var lines []string
+	current := prefix
+	currentLength := len([]rune(prefix))
+	empty := true
+	for _, word := range strings.Fields(trimmed) {
+		wordLength := len([]rune(word))
+		if !empty && currentLength+1+wordLength > width {
+			lines = append(lines, current)
+			current, currentLength, empty = indent, len([]rune(indent)), true
+		}
+		if !empty {
+			current += " "
+			currentLength++
+		}
+		current += word
+		currentLength += wordLength
+		empty = false
+	}
+	return append(lines, current)
+}
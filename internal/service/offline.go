@@ -0,0 +1,430 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+)
+
+const (
+	// offlineProvider and offlineModel stand in for the provider and model of messages written offline.
+	offlineProvider = "offline"
+	offlineModel    = "heuristic"
+	// maxOfflineNames is the number of names listed in an offline subject before the rest is counted.
+	maxOfflineNames = 3
+	// maxOfflineObject is the length of the list of names in an offline subject above which only
+	// their number is given.
+	maxOfflineObject = 50
+	// maxOfflineBullets is the number of files listed in the body of an offline multiline message.
+	maxOfflineBullets = 10
+	// omittedFiles introduces the list of the files whose diff the generate command leaves out.
+	omittedFiles = "Files changed whose diff is omitted:\n"
+)
+
+// ErrOffline is returned by the generations that only the AI can do when messages are written offline.
+var ErrOffline = errors.New("the AI is not available offline")
+
+// change is the kind of change a diff makes, as far as it can be told without the AI.
+type change int
+
+const (
+	changeModified change = iota // Changes code in some other way
+	changeAdded                  // Creates files or adds definitions such as functions
+	changeRemoved                // Deletes files
+	changeRenamed                // Moves files without changing them
+	changeDocs                   // Changes documentation only
+	changeTests                  // Changes tests only
+	changeConfig                 // Changes configuration, dependencies or build files only
+)
+
+// changeTypes are the types of each kind of change in the default, conventional and gitmoji styles.
+var changeTypes = map[change]struct{ bracket, conventional, gitmoji string }{
+	changeModified: {"Update", "refactor", "♻️"},
+	changeAdded:    {"Add", "feat", "✨"},
+	changeRemoved:  {"Remove", "refactor", "🔥"},
+	changeRenamed:  {"Update", "refactor", "🚚"},
+	changeDocs:     {"Update", "docs", "📝"},
+	changeTests:    {"Update", "test", "✅"},
+	changeConfig:   {"Chore", "build", "🔧"},
+}
+
+// definition matches the name of a function, method, class or type defined on a line of code in
+// common languages, e.g. "func (g *Generator) Generate(" or "def generate(".
+var definition = regexp.MustCompile(`\b(?:func|def|function|fn|class|interface|struct|type)\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`)
+
+// configFiles are the names of files whose changes count as configuration, dependency or build changes.
+var configFiles = []string{
+	"go.mod", "go.sum", "package.json", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.toml",
+	"Cargo.lock", "requirements.txt", "pyproject.toml", "Gemfile", "Gemfile.lock", "Makefile", "Dockerfile",
+	".gitignore", ".editorconfig",
+}
+
+// offlineFallback reports whether messages are written offline after err, because no API key is
+// configured or the provider cannot be reached, and OFFLINE_FALLBACK is set in the settings read
+// through get. Errors reported by the API, such as an invalid key, are not covered, since they need fixing.
+func offlineFallback(get config.Getter, err error) bool {
+	var netErr net.Error
+	if !errors.Is(err, provider.ErrNoAPIKey) && !errors.As(err, &netErr) {
+		return false
+	}
+	fallback, _, cfgErr := get.Bool("OFFLINE_FALLBACK")
+	return cfgErr == nil && fallback
+}
+
+// newOfflineGenerator creates a CommitMessageGenerator that writes messages from the diff alone with
+// the settings read through get, because of cause, or because it was asked to if cause is nil.
+func newOfflineGenerator(get config.Getter, opts Options, cause error) *CommitMessageGenerator {
+	return &CommitMessageGenerator{
+		get:          get,
+		isolated:     opts.Config != nil,
+		provider:     offlineProvider,
+		model:        offlineModel,
+		repo:         opts.Context,
+		offline:      true,
+		offlineCause: cause,
+	}
+}
+
+// Offline reports whether messages are written from the diff alone, without the AI.
+func (g *CommitMessageGenerator) Offline() bool {
+	return g.offline
+}
+
+// fallBack switches to writing messages offline if offlineFallback allows it after err.
+func (g *CommitMessageGenerator) fallBack(err error) bool {
+	if !offlineFallback(g.get, err) {
+		return false
+	}
+	g.offline, g.offlineCause = true, err
+	logging.Info("writing messages offline", "cause", err)
+	return true
+}
+
+// unavailable returns the error of a generation that needs the AI while messages are written
+// offline: the error that caused it, or ErrOffline if it was asked for.
+func (g *CommitMessageGenerator) unavailable() error {
+	if g.offlineCause != nil {
+		return g.offlineCause
+	}
+	return ErrOffline
+}
+
+// warnOffline warns that a message is written offline because of an error, which would otherwise
+// go unnoticed.
+func (g *CommitMessageGenerator) warnOffline() {
+	if g.offlineCause != nil && notify != nil {
+		notify(fmt.Sprintf("Warning: %v; writing the message from the changed files without the AI instead.", g.offlineCause))
+	}
+}
+
+// offlineMessage writes a message in the configured style from the names of the changed files and
+// the definitions the diff adds or changes, e.g. "[Add] (parser.go) Add ParseHeader".
+func (g *CommitMessageGenerator) offlineMessage(diff string) (string, error) {
+	style, err := g.commitStyle()
+	if err != nil {
+		return "", err
+	}
+	stats := offlineStats(diff)
+	if len(stats) == 0 {
+		return "", ErrEmptyMessage
+	}
+	g.warnOffline()
+	kind, verb, object, where := describeDiff(diff, stats)
+	subject := verb + " " + object
+	// The default style lists the files anyway.
+	if style != StyleDefault {
+		subject += where
+	}
+	return renderOffline(style, kind, subject, scopeOf(stats), changedFiles(stats), offlineBody(stats)), nil
+}
+
+// offlineDescription writes a message in the configured style from the description of an empty commit.
+func (g *CommitMessageGenerator) offlineDescription(description string) (string, error) {
+	style, err := g.commitStyle()
+	if err != nil {
+		return "", err
+	}
+	subject := strings.TrimSuffix(strings.TrimSpace(description), ".")
+	if subject == "" {
+		return "", ErrEmptyMessage
+	}
+	g.warnOffline()
+	return renderOffline(style, changeConfig, subject, "", "", ""), nil
+}
+
+// renderOffline puts the parts of an offline message together in style. files is the list of
+// changed files of the default style, left out if it is too long, and body that of the multiline style.
+func renderOffline(style string, kind change, subject, scope, files, body string) string {
+	types := changeTypes[kind]
+	switch style {
+	case StyleConventional:
+		if scope != "" {
+			scope = "(" + scope + ")"
+		}
+		return fmt.Sprintf("%s%s: %s", types.conventional, scope, lowerFirst(subject))
+	case StyleMultiline, StyleDetailed, StyleCorporate, StyleEmojiFree:
+		return capitalize(subject) + "\n\n" + body
+	case StylePlain, StyleConcise:
+		return capitalize(subject)
+	case StyleKernel:
+		if scope != "" {
+			return scope + ": " + lowerFirst(subject)
+		}
+		return capitalize(subject)
+	case StyleGitmoji:
+		return types.gitmoji + " " + capitalize(subject)
+	}
+	// Lists the files like the default prompt asks the AI to, if they are short enough.
+	if files != "" && len(files) <= 60 {
+		return fmt.Sprintf("[%s] (%s) %s", types.bracket, files, capitalize(subject))
+	}
+	return fmt.Sprintf("[%s] %s", types.bracket, capitalize(subject))
+}
+
+// offlineStats returns the changes to the files of diff, including the pure renames and the files
+// whose diff was left out, which the diff only names.
+func offlineStats(diff string) []git.FileStat {
+	diff, omitted, _ := strings.Cut(diff, omittedFiles)
+	var stats []git.FileStat
+	if strings.TrimSpace(diff) != "" {
+		stats = git.DiffStats(diff)
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		for _, prefix := range []string{"renamed ", "copied "} {
+			if name, ok := strings.CutPrefix(line, prefix); ok && strings.Contains(name, " → ") {
+				stats = append(stats, git.FileStat{Name: name})
+			}
+		}
+	}
+	for _, line := range strings.Split(omitted, "\n") {
+		if name, ok := strings.CutPrefix(line, "- "); ok {
+			stats = append(stats, git.FileStat{Name: name})
+		}
+	}
+	return stats
+}
+
+// describeDiff tells the kind of change diff makes and describes it by a verb and its object, such
+// as "add" and "ParseHeader", followed by where the object is, e.g. " to parse.go", if it is
+// in a single file that the object does not name.
+func describeDiff(diff string, stats []git.FileStat) (kind change, verb, object, where string) {
+	names := make([]string, len(stats))
+	for i, stat := range stats {
+		names[i] = path.Base(sectionName(stat))
+	}
+	files := listNames(names, "files")
+	to, in := "", ""
+	if len(stats) == 1 {
+		to, in = " to "+names[0], " in "+names[0]
+	}
+
+	switch {
+	case all(stats, func(stat git.FileStat) bool { return stat.New }):
+		return changeAdded, "add", files, ""
+	case all(stats, func(stat git.FileStat) bool { return stat.Deleted }):
+		return changeRemoved, "remove", files, ""
+	case all(stats, func(stat git.FileStat) bool { return renamed(stat) && stat.Added+stat.Removed == 0 }):
+		if from, to, ok := strings.Cut(stats[0].Name, " → "); ok && len(stats) == 1 {
+			return changeRenamed, "rename", path.Base(from) + " to " + path.Base(to), ""
+		}
+		return changeRenamed, "rename", files, ""
+	case all(stats, func(stat git.FileStat) bool { return isDocs(sectionName(stat)) }):
+		return changeDocs, "update", files, ""
+	case all(stats, func(stat git.FileStat) bool { return isTest(sectionName(stat)) }):
+		return changeTests, "update tests in", files, ""
+	case all(stats, func(stat git.FileStat) bool { return isConfig(sectionName(stat)) }):
+		return changeConfig, "update", files, ""
+	}
+
+	added, changed := definitions(diff)
+	if len(added) > 0 {
+		return changeAdded, "add", listNames(added, "definitions"), to
+	}
+	if len(changed) > 0 {
+		return changeModified, "update", listNames(changed, "definitions"), in
+	}
+	return changeModified, "update", files, ""
+}
+
+// definitions returns the names of the definitions diff adds, and of those it changes, as named by
+// the headers of its hunks or by changed lines that define them, in the order of the diff.
+func definitions(diff string) (added, changed []string) {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(diff, "\n") {
+		var list *[]string
+		var text string
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			continue
+		case strings.HasPrefix(line, "+"):
+			list, text = &added, line[1:]
+		case strings.HasPrefix(line, "-"):
+			list, text = &changed, line[1:]
+		case strings.HasPrefix(line, "@@"):
+			// The header of a hunk ends with the line that encloses it, often a function.
+			if _, context, ok := strings.Cut(line[2:], "@@"); ok {
+				list, text = &changed, context
+			}
+		}
+		if list == nil {
+			continue
+		}
+		if m := definition.FindStringSubmatch(text); m != nil && !seen[m[1]] {
+			seen[m[1]] = true
+			*list = append(*list, m[1])
+		}
+	}
+	// A definition whose line changed shows up as removed and added, but is not new.
+	var newOnly []string
+	for _, name := range added {
+		if !slices.Contains(changed, name) {
+			newOnly = append(newOnly, name)
+		}
+	}
+	return newOnly, changed
+}
+
+// listNames lists names in a sentence, e.g. "a, b and c", or counts them as things if they are
+// too many or too long to list.
+func listNames(names []string, things string) string {
+	var list string
+	switch n := len(names); {
+	case n == 1:
+		list = names[0]
+	case n <= maxOfflineNames:
+		list = strings.Join(names[:n-1], ", ") + " and " + names[n-1]
+	default:
+		list = fmt.Sprintf("%s, %s and %d other %s", names[0], names[1], n-2, things)
+	}
+	if len(list) > maxOfflineObject {
+		return fmt.Sprintf("%d %s", len(names), things)
+	}
+	return list
+}
+
+// changedFiles returns the names of the changed files separated by commas, as the default style lists them.
+func changedFiles(stats []git.FileStat) string {
+	names := make([]string, len(stats))
+	for i, stat := range stats {
+		names[i] = path.Base(sectionName(stat))
+	}
+	return strings.Join(names, ", ")
+}
+
+// scopeOf returns the directory all changed files are in as the scope of a conventional message,
+// or "" if they are in several directories or at the root of the repository.
+func scopeOf(stats []git.FileStat) string {
+	dir := path.Dir(sectionName(stats[0]))
+	for _, stat := range stats[1:] {
+		if path.Dir(sectionName(stat)) != dir {
+			return ""
+		}
+	}
+	if dir == "." {
+		return ""
+	}
+	return path.Base(dir)
+}
+
+// offlineBody lists the changed files with the numbers of their changed lines, for the multiline style.
+func offlineBody(stats []git.FileStat) string {
+	var lines []string
+	for i, stat := range stats {
+		if i == maxOfflineBullets {
+			lines = append(lines, fmt.Sprintf("- And %d more files", len(stats)-i))
+			break
+		}
+		verb := "Update"
+		switch {
+		case stat.New:
+			verb = "Add"
+		case stat.Deleted:
+			verb = "Remove"
+		case renamed(stat):
+			verb = "Rename"
+		}
+		if stat.Binary {
+			lines = append(lines, fmt.Sprintf("- %s %s", verb, stat.Name))
+		} else {
+			lines = append(lines, fmt.Sprintf("- %s %s (+%d -%d)", verb, stat.Name, stat.Added, stat.Removed))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sectionName returns the path of the changed file, the new one of a renamed file.
+func sectionName(stat git.FileStat) string {
+	if _, to, ok := strings.Cut(stat.Name, " → "); ok {
+		return to
+	}
+	return stat.Name
+}
+
+// renamed reports whether the file was renamed or copied.
+func renamed(stat git.FileStat) bool {
+	return strings.Contains(stat.Name, " → ")
+}
+
+// isDocs reports whether the file at path name is documentation.
+func isDocs(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".rst", ".adoc", ".txt":
+		return !isConfig(name)
+	}
+	return strings.HasPrefix(name, "docs/") || strings.Contains(name, "/docs/")
+}
+
+// isTest reports whether the file at path name is a test in a common naming scheme.
+func isTest(name string) bool {
+	base := path.Base(name)
+	return strings.Contains(base, "_test.") || strings.HasPrefix(base, "test_") || strings.Contains(base, ".test.") ||
+		strings.Contains(base, ".spec.") || strings.HasPrefix(name, "tests/") || strings.Contains(name, "/tests/")
+}
+
+// isConfig reports whether the file at path name is a configuration, dependency or build file.
+func isConfig(name string) bool {
+	if slices.Contains(configFiles, path.Base(name)) || strings.HasPrefix(name, ".github/") {
+		return true
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yml", ".yaml", ".toml", ".ini", ".cfg":
+		return true
+	}
+	return false
+}
+
+// all reports whether f holds for all stats.
+func all(stats []git.FileStat, f func(git.FileStat) bool) bool {
+	for _, stat := range stats {
+		if !f(stat) {
+			return false
+		}
+	}
+	return true
+}
+
+// capitalize returns s with its first letter in upper case.
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// lowerFirst returns s with its first letter in lower case, unless the first word looks like a name.
+func lowerFirst(s string) string {
+	word, _, _ := strings.Cut(s, " ")
+	if s == "" || strings.ToLower(word[1:]) != word[1:] {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+// ErrEmptyPullRequest is returned when the AI's reply does not contain a pull request title.
+var ErrEmptyPullRequest = errors.New("the AI did not return a pull request title")
+
+const pullRequestPrompt = `
+You are an AI that writes pull request descriptions for code reviewers.
+Reply with the pull request only. Do not add explanations or wrap the reply in markdown code fences.
+
+Format:
+<title>
+
+## Summary
+<one or two sentences describing what the pull request does and why>
+
+## Changes
+- <notable change>
+- <notable change>
+
+## Testing
+<how the changes were or can be tested, based on the tests in the diff; say so if there are none>
+
+Rules:
+  1. <title> is a single line of at most 72 characters in the imperative mood, without a trailing period and without a "Title:" prefix.
+  2. Focus on the intent and the effect of the changes, not on listing every file.
+`
+
+// PullRequest is a generated pull request title and description.
+type PullRequest struct {
+	Title string // Single-line title
+	Body  string // Markdown description
+}
+
+// GeneratePullRequest creates a pull request title and description from the commit subjects
+// and the combined diff of a branch.
+func (g *CommitMessageGenerator) GeneratePullRequest(subjects []string, diff string) (PullRequest, error) {
+	if g.offline {
+		return PullRequest{}, g.unavailable()
+	}
+	var user strings.Builder
+	if len(subjects) > 0 {
+		fmt.Fprintf(&user, "Commits on the branch, oldest first:\n- %s\n\n", strings.Join(subjects, "\n- "))
+	}
+	user.WriteString(g.userMessage(diff))
+
+	messages := []groq.Message{
+		{Role: "system", Content: pullRequestPrompt},
+		{Role: "user", Content: user.String()},
+	}
+	reply, err := g.completion(messages, g.params, g.stream)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	return parsePullRequest(reply)
+}
+
+// parsePullRequest splits the AI's reply into the title on the first line and the body after it.
+func parsePullRequest(reply string) (PullRequest, error) {
+	title, body, _ := strings.Cut(strings.TrimSpace(reply), "\n")
+
+	// Removes decorations models tend to add to the title.
+	title = strings.TrimSpace(strings.TrimLeft(title, "# "))
+	title = strings.TrimPrefix(title, "Title:")
+	title = strings.Trim(strings.TrimSpace(title), "*\"`")
+	if title == "" {
+		return PullRequest{}, ErrEmptyPullRequest
+	}
+	return PullRequest{Title: title, Body: strings.TrimSpace(body)}, nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The prompts of the preset styles are templates like those of the prompts directory, see PromptData,
+// so they can point the AI at the files or branch of the change.
+
+const concisePrompt = `
+You are an AI that writes short git commit messages.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Rules:
+  1. Write a single line of at most 50 characters.
+  2. Use the imperative mood and start with a capital letter, e.g. "Fix crash on empty diff".
+  3. Name the most important change only. Leave out details, prefixes and a trailing period.
+`
+
+const detailedPrompt = `
+You are an AI that writes thorough git commit messages for developers reading the history years later.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Format:
+<subject>
+
+<body>
+
+Rules:
+  1. <subject> summarizes the change in the imperative mood in 50 characters or fewer, starts with a capital letter, and has no trailing period.
+  2. Leave exactly one blank line between the subject and the body.
+  3. The body is one to three paragraphs of prose explaining what the code did before, why that was a problem, and how the change solves it. Mention side effects and rejected alternatives if the diff shows them.
+  4. Wrap lines at 72 characters.
+{{- if gt (len .Files) 1}}
+  5. The change touches {{len .Files}} files: explain how the parts fit together instead of describing each file.
+{{- end}}
+`
+
+const corporatePrompt = `
+You are an AI that writes git commit messages for a corporate codebase, whose history is read by reviewers, auditors and other teams.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Format:
+<subject>
+
+<body>
+
+Rules:
+  1. <subject> states the change in the imperative mood in 72 characters or fewer, starts with a capital letter, and has no trailing period.
+{{- with .Branch}}
+  2. The current branch is "{{.}}". If it contains a ticket ID such as ABC-123, start the subject with the ID and a colon, e.g. "ABC-123: Validate invoice totals".
+{{- else}}
+  2. Do not invent ticket IDs.
+{{- end}}
+  3. The body explains the reason for the change and its impact in complete sentences, wrapped at 72 characters.
+  4. Keep a neutral, professional tone: no slang, jokes, emoji, exclamation marks or first person.
+`
+
+const emojiFreePrompt = `
+You are an AI that writes git commit messages in plain text, for repositories and tools that do not accept emoji.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Format:
+<subject>
+
+<body>
+
+Rules:
+  1. <subject> summarizes the change in the imperative mood in 50 characters or fewer, starts with a capital letter, and has no trailing period.
+  2. The body is optional: separated from the subject by a blank line, it explains why the change was made, wrapped at 72 characters. Omit it for trivial changes.
+  3. Never use emoji, gitmoji shortcodes such as :bug:, or other pictographic symbols anywhere in the message.
+`
+
+const kernelPrompt = `
+You are an AI that writes git commit messages in the style of the Linux kernel.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Format:
+<subsystem>: <summary>
+
+<body>
+
+Rules:
+  1. <subsystem> names the part of the code the change belongs to, derived from the paths of the changed files, e.g. "net/ipv4" or "docs".
+  2. <summary> is in the imperative mood, starts with a lowercase letter, has no trailing period, and keeps the whole first line within 72 characters.
+  3. The body describes the problem first and then how the change solves it, in paragraphs without bullet points, wrapped at 72 characters. Write as if giving orders to the code ("Make xyzzy do frotz"), never "This patch" or "I".
+  4. Do not add Signed-off-by or other trailers.
+{{- if and .Files (le (len .Files) 20)}}
+
+The changed files are:
+{{- range .Files}}
+  - {{.}}
+{{- end}}
+{{- end}}
+`
+
+// formatEmojiFree removes the emoji and gitmoji shortcodes the AI wrote despite the prompt, then
+// formats the message like the multiline style.
+func formatEmojiFree(message string) (string, error) {
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		cleaned := line
+		for _, g := range gitmojis {
+			cleaned = strings.ReplaceAll(cleaned, g.code, "")
+		}
+		cleaned = strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.So, r) || r == '\u200D' || r == '\uFE0F' {
+				return -1
+			}
+			return r
+		}, cleaned)
+		if cleaned != line {
+			// Closes the gaps the emoji leave, keeping the indentation of the line.
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			lines[i] = indent + strings.Join(strings.Fields(cleaned), " ")
+		}
+	}
+	return formatMultiline(strings.Join(lines, "\n"))
+}
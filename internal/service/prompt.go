@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+)
+
+const (
+	// promptsDirName is the name of the directory of prompt templates, next to the global configuration file.
+	promptsDirName = ".ai-commit-prompts"
+	// promptExt is the extension of the files in the prompts directory.
+	promptExt = ".tmpl"
+)
+
+// ErrUnknownPrompt is returned for a prompt that is neither a built-in commit style nor a template
+// in the prompts directory.
+var ErrUnknownPrompt = errors.New("unknown prompt")
+
+// PromptData holds the variables a prompt template can use, e.g. {{.Branch}} or {{join .Files ", "}}.
+type PromptData struct {
+	Style         string   // Commit style the message is written in, e.g. "conventional"
+	Files         []string // Paths of the changed files, in the order of the diff
+	DiffStat      string   // Added and removed lines per file, like "git diff --stat"
+	Branch        string   // Current branch, empty on a detached HEAD
+	Language      string   // Language set with COMMIT_LANGUAGE, empty to follow the recent commits
+	RecentCommits []string // Subjects of recent commits, newest first
+}
+
+// promptFuncs are the functions prompt templates can call besides those of text/template.
+var promptFuncs = template.FuncMap{
+	"join": func(elems []string, sep string) string { return strings.Join(elems, sep) },
+}
+
+// samplePromptData is rendered by CheckPrompt to find variables that do not exist.
+var samplePromptData = PromptData{
+	Style:         StyleDefault,
+	Files:         []string{"main.go"},
+	DiffStat:      " main.go | +1 -1\n 1 file(s) changed, 1 insertion(s)(+), 1 deletion(s)(-)",
+	Branch:        "main",
+	RecentCommits: []string{"Add a feature"},
+}
+
+// PromptsDir returns the directory of prompt templates. A template named after a commit style, e.g.
+// "conventional.tmpl", replaces the built-in prompt of that style; PROMPT_TEMPLATE selects any other.
+func PromptsDir() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), promptsDirName)
+}
+
+// PromptPath returns the path of the template named name in the prompts directory.
+func PromptPath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid prompt name %q", name)
+	}
+	return filepath.Join(PromptsDir(), name+promptExt), nil
+}
+
+// PromptNames returns the names of the templates in the prompts directory, sorted.
+func PromptNames() ([]string, error) {
+	entries, err := os.ReadDir(PromptsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the prompts directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), promptExt); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// BuiltinPrompt returns the built-in prompt of the commit style name, and whether there is one.
+func BuiltinPrompt(name string) (string, bool) {
+	prompt, ok := stylePrompts[name]
+	return strings.TrimLeft(prompt, "\n"), ok
+}
+
+// ReadPrompt returns the text of the prompt named name: its template in the prompts directory, or
+// else the built-in prompt of the commit style of that name. It reports whether it is a template.
+func ReadPrompt(name string) (string, bool, error) {
+	path, err := PromptPath(name)
+	if err != nil {
+		return "", false, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return string(data), true, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", false, fmt.Errorf("failed to read prompt: %w", err)
+	}
+	if prompt, ok := BuiltinPrompt(name); ok {
+		return prompt, false, nil
+	}
+	return "", false, fmt.Errorf("%w %q: create it with \"ai-generate-commit prompts edit %s\"", ErrUnknownPrompt, name, name)
+}
+
+// CheckPrompt validates that text is a prompt template that only uses existing variables.
+func CheckPrompt(text string) error {
+	_, err := renderPrompt(text, samplePromptData)
+	return err
+}
+
+// renderPrompt executes the prompt template text with data.
+func renderPrompt(text string, data PromptData) (string, error) {
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// promptData returns the variables of the prompt templates for a message in style describing diff.
+func (g *CommitMessageGenerator) promptData(style, diff string) PromptData {
+	data := PromptData{
+		Style:         style,
+		Files:         git.DiffFiles(diff),
+		DiffStat:      git.DiffStat(diff),
+		Branch:        g.repo.Branch,
+		RecentCommits: slices.Clone(g.repo.Examples),
+	}
+	if g.language != nil {
+		data.Language = g.language.name
+	}
+	return data
+}
+
+// customPrompt returns the text of the prompt template replacing the built-in prompt of style, or ""
+// if there is none: the template selected with PROMPT_TEMPLATE, then COMMIT_PROMPT for the default
+// style, then the template named after style in the prompts directory. The prompts directory
+// belongs to the configuration, so with Options.Config, PROMPT_TEMPLATE may only name a built-in prompt.
+func (g *CommitMessageGenerator) customPrompt(style string) (string, error) {
+	name, err := g.get("PROMPT_TEMPLATE")
+	if err != nil {
+		return "", fmt.Errorf("failed to get prompt template: %w", err)
+	}
+	if name != "" && g.isolated {
+		if text, ok := BuiltinPrompt(name); ok {
+			return text, nil
+		}
+		return "", fmt.Errorf("%w %q: only the built-in prompts are available with Options.Config", ErrUnknownPrompt, name)
+	}
+	if name != "" {
+		text, _, err := ReadPrompt(name)
+		return text, err
+	}
+
+	if style == StyleDefault {
+		commitPrompt, err := g.get("COMMIT_PROMPT")
+		if err != nil {
+			return "", fmt.Errorf("failed to get commit prompt: %w", err)
+		}
+		if commitPrompt != "" {
+			return commitPrompt, nil
+		}
+	}
+
+	if g.isolated {
+		return "", nil
+	}
+	path, err := PromptPath(style)
+	if err != nil {
+		return "", err
+	}
+	text, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read prompt: %w", err)
+	}
+	return string(text), nil
+}
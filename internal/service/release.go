@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+const (
+	// AudienceUser writes release notes for the users of the project, leaving out internal changes.
+	AudienceUser = "user"
+	// AudienceDeveloper writes release notes for contributors and integrators, including internal changes.
+	AudienceDeveloper = "developer"
+)
+
+// ErrEmptyReleaseNotes is returned when the AI's reply is empty.
+var ErrEmptyReleaseNotes = errors.New("the AI did not return any release notes")
+
+const releaseNotesPrompt = `
+You are an AI that writes release notes from the list of changes merged since the previous release.
+Reply with the release notes only, in Markdown. Do not add explanations or wrap the reply in markdown code fences.
+
+Format:
+## <version>
+
+<one or two sentences summarizing the release>
+
+### <section>
+- <change> (<pull request reference, if the change has one>)
+
+Rules:
+  1. Group the changes into sections such as "New Features", "Improvements", "Bug Fixes" and "Breaking Changes". Omit empty sections.
+  2. Merge changes that describe the same thing into one entry, and keep pull request references such as "#12" or "!12".
+  3. Do not invent changes that are not in the list.
+`
+
+// audiencePrompts holds the instructions for each release notes audience.
+var audiencePrompts = map[string]string{
+	AudienceUser: `
+Audience: the users of the project.
+  - Describe what changed from the user's point of view, in plain language, without implementation details.
+  - Leave out changes that users cannot notice, such as refactoring, tests, CI and dependency updates.`,
+	AudienceDeveloper: `
+Audience: the developers of and integrators with the project.
+  - Be precise and technical; mention affected packages, APIs and configuration.
+  - Include internal changes such as refactoring, tests, CI and dependency updates in a "Maintenance" section.`,
+}
+
+// GenerateReleaseNotes creates Markdown release notes for version from the changes merged since the previous release.
+// The audience is taken from RELEASE_AUDIENCE unless one is given.
+func (g *CommitMessageGenerator) GenerateReleaseNotes(version string, changes []string, audience string) (string, error) {
+	if g.offline {
+		return "", g.unavailable()
+	}
+	if audience == "" {
+		var err error
+		if audience, err = g.get("RELEASE_AUDIENCE"); err != nil {
+			return "", err
+		}
+	}
+	instructions, ok := audiencePrompts[audience]
+	if !ok {
+		return "", fmt.Errorf("unknown release notes audience: %s", audience)
+	}
+
+	user := fmt.Sprintf("Version: %s\n\nChanges merged since the previous release, oldest first:\n- %s",
+		version, strings.Join(changes, "\n- "))
+
+	messages := []groq.Message{
+		{Role: "system", Content: releaseNotesPrompt + instructions},
+		{Role: "user", Content: user},
+	}
+	notes, err := g.client.GenerateCompletion(messages, g.model, g.params)
+	if err != nil {
+		return "", err
+	}
+	notes = strings.TrimSpace(notes)
+	if notes == "" {
+		return "", ErrEmptyReleaseNotes
+	}
+	return notes, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+// ErrInvalidPlan is returned when the AI's reply cannot be read as a commit plan.
+var ErrInvalidPlan = errors.New("the AI did not return a valid commit plan")
+
+const splitPrompt = `
+You are an AI that splits a set of staged changes into small, logical git commits.
+Group the changed files so that each commit contains one coherent change, for example a feature, a bug fix, a refactoring, or a documentation update.
+Order the commits so that each one builds on the previous ones. Keep files that depend on each other in the same commit.
+
+Reply with JSON only, without markdown code fences, in exactly this form:
+{"commits": [{"files": ["path/one.go", "path/two.go"], "summary": "short description of the change"}]}
+
+Rules:
+  1. Every changed file must appear in exactly one commit, spelled exactly as listed.
+  2. Use a single commit if the changes belong together.
+`
+
+// CommitGroup is a set of files proposed to be committed together.
+type CommitGroup struct {
+	Files   []string `json:"files"`   // Paths of the files in the commit
+	Summary string   `json:"summary"` // Short description of the change, used for display
+}
+
+// PlanSplit asks the AI to divide the changes to files, described by diff, into logical commits.
+// Every file ends up in exactly one group: files the AI leaves out are added as a final group,
+// and unknown or repeated files are dropped.
+func (g *CommitMessageGenerator) PlanSplit(files []string, diff string) ([]CommitGroup, error) {
+	if g.offline {
+		return nil, g.unavailable()
+	}
+	user := fmt.Sprintf("Changed files:\n- %s\n\n%s", strings.Join(files, "\n- "), g.userMessage(diff))
+	messages := []groq.Message{
+		{Role: "system", Content: splitPrompt},
+		{Role: "user", Content: user},
+	}
+
+	reply, err := g.client.GenerateCompletion(messages, g.model, g.params)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := parsePlan(reply)
+	if err != nil {
+		return nil, err
+	}
+	return normalizePlan(groups, files), nil
+}
+
+// parsePlan extracts the commit groups from the AI's reply, tolerating text around the JSON object.
+func parsePlan(reply string) ([]CommitGroup, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, ErrInvalidPlan
+	}
+
+	var plan struct {
+		Commits []CommitGroup `json:"commits"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &plan); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPlan, err)
+	}
+	if len(plan.Commits) == 0 {
+		return nil, fmt.Errorf("%w: no commits proposed", ErrInvalidPlan)
+	}
+	return plan.Commits, nil
+}
+
+// normalizePlan makes sure every file belongs to exactly one group and drops empty groups.
+func normalizePlan(groups []CommitGroup, files []string) []CommitGroup {
+	remaining := make(map[string]bool, len(files))
+	for _, file := range files {
+		remaining[file] = true
+	}
+
+	var plan []CommitGroup
+	for _, group := range groups {
+		var groupFiles []string
+		for _, file := range group.Files {
+			if remaining[file] {
+				groupFiles = append(groupFiles, file)
+				delete(remaining, file)
+			}
+		}
+		if len(groupFiles) > 0 {
+			plan = append(plan, CommitGroup{Files: groupFiles, Summary: group.Summary})
+		}
+	}
+
+	// Keeps the order of files for the ones the AI forgot.
+	var leftover []string
+	for _, file := range files {
+		if remaining[file] {
+			leftover = append(leftover, file)
+		}
+	}
+	if len(leftover) > 0 {
+		plan = append(plan, CommitGroup{Files: leftover, Summary: "remaining changes"})
+	}
+	return plan
+}
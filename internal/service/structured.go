@@ -0,0 +1,280 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+)
+
+const (
+	// structuredOff leaves the format of the reply to the prompt.
+	structuredOff = "off"
+	// structuredSchema asks for a JSON object valid against a schema of the fields of the style.
+	structuredSchema = "json_schema"
+	// structuredObject asks for any JSON object, for providers that do not support schemas.
+	structuredObject = "json_object"
+)
+
+// ErrInvalidStructured is returned when the AI's reply is not the JSON object asked for with STRUCTURED_OUTPUT.
+var ErrInvalidStructured = errors.New("the AI did not reply with the requested JSON object")
+
+// structuredMessage is a commit message as the JSON object asked for with STRUCTURED_OUTPUT, and
+// the data of the template that renders it.
+type structuredMessage struct {
+	Type     string `json:"type"`     // Kind of change, e.g. "feat", "Fix" or an emoji
+	Scope    string `json:"scope"`    // Affected area, e.g. a package or the changed files
+	Subject  string `json:"subject"`  // Summary of the change without any prefix
+	Body     string `json:"body"`     // Explanation of the change
+	Breaking string `json:"breaking"` // Description of the break of backward compatibility
+}
+
+// structuredField is a field of the JSON object of a commit message.
+type structuredField struct {
+	name        string   // Name of the field in structuredMessage
+	description string   // What the field holds, shown to the AI
+	values      []string // Allowed values, nil for any text
+}
+
+// defaultTypes are the types of the default style.
+var defaultTypes = []string{"Add", "Fix", "Update", "Remove", "Chore"}
+
+// fieldDescriptions describe the fields of the JSON object, by style for those whose meaning
+// depends on it, with the entry for "" applying to the other styles.
+var fieldDescriptions = map[string]map[string]string{
+	"": {
+		"type":     "Kind of change",
+		"scope":    "Short noun naming the affected area, such as a package or module, or an empty string if there is no clear one",
+		"subject":  "Summary of the change in the imperative mood, without a type, scope or trailing period",
+		"body":     "What changed and why, wrapped at 72 characters, or an empty string for trivial changes",
+		"breaking": "How the change breaks backward compatibility and how to migrate, or an empty string if it does not",
+	},
+	StyleDefault: {
+		"type":  "Kind of change: Add for new features, functions or files, Fix for bug fixes, Update for modifications of existing code, Remove for deletions, Chore for maintenance",
+		"scope": "Names of the changed files separated by commas if they are 60 characters or fewer together, otherwise an empty string",
+	},
+	StyleMultiline: {
+		"body": `One to five bullet points starting with "- " on separate lines, each explaining why a part of the change was made`,
+	},
+	StyleGitmoji: {
+		"type": "The emoji that best describes the intent of the change",
+	},
+	StyleDetailed: {
+		"body": "One to three paragraphs explaining what the code did before, why that was a problem and how the change solves it, wrapped at 72 characters",
+	},
+	StyleCorporate: {
+		"subject": "Summary of the change in the imperative mood, led by the ticket ID of the branch and a colon if it has one",
+		"body":    "The reason for the change and its impact in complete sentences, wrapped at 72 characters",
+	},
+	StyleKernel: {
+		"scope": `Subsystem the change belongs to, derived from the paths of the changed files, e.g. "net/ipv4"`,
+		"body":  "The problem and how the change solves it, in paragraphs without bullet points, wrapped at 72 characters",
+	},
+}
+
+// styleFields are the fields of the JSON object of each style, in the order they are asked for.
+var styleFields = map[string][]string{
+	StyleDefault:      {"type", "scope", "subject"},
+	StyleConventional: {"type", "scope", "subject", "body", "breaking"},
+	StyleMultiline:    {"subject", "body"},
+	StylePlain:        {"subject"},
+	StyleGitmoji:      {"type", "subject", "body"},
+	StyleConcise:      {"subject"},
+	StyleDetailed:     {"subject", "body"},
+	StyleCorporate:    {"subject", "body"},
+	StyleEmojiFree:    {"subject", "body"},
+	StyleKernel:       {"scope", "subject", "body"},
+}
+
+// styleTemplates render the JSON object of a commit message in each style.
+var styleTemplates = map[string]string{
+	StyleDefault: `[{{.Type}}] {{with .Scope}}({{.}}) {{end}}{{.Subject}}`,
+	StyleConventional: `{{.Type}}{{with .Scope}}({{.}}){{end}}{{if .Breaking}}!{{end}}: {{.Subject}}
+{{- with .Body}}
+
+{{.}}{{end}}
+{{- with .Breaking}}
+
+BREAKING CHANGE: {{.}}{{end}}`,
+	StyleMultiline: `{{.Subject}}
+{{- with .Body}}
+
+{{.}}{{end}}`,
+	StylePlain: `{{.Subject}}`,
+	StyleGitmoji: `{{.Type}} {{.Subject}}
+{{- with .Body}}
+
+{{.}}{{end}}`,
+	StyleConcise: `{{.Subject}}`,
+	StyleDetailed: `{{.Subject}}
+{{- with .Body}}
+
+{{.}}{{end}}`,
+	StyleCorporate: `{{.Subject}}
+{{- with .Body}}
+
+{{.}}{{end}}`,
+	StyleEmojiFree: `{{.Subject}}
+{{- with .Body}}
+
+{{.}}{{end}}`,
+	StyleKernel: `{{with .Scope}}{{.}}: {{end}}{{.Subject}}
+{{- with .Body}}
+
+{{.}}{{end}}`,
+}
+
+// structuredOutput returns the mode of STRUCTURED_OUTPUT, "" if it is off, and the template of
+// STRUCTURED_TEMPLATE, nil if the template of the commit style is used, both read through get.
+func structuredOutput(get config.Getter) (string, *template.Template, error) {
+	mode, err := get("STRUCTURED_OUTPUT")
+	if err != nil || mode == "" || mode == structuredOff {
+		return "", nil, err
+	}
+	text, err := get("STRUCTURED_TEMPLATE")
+	if err != nil || text == "" {
+		return mode, nil, err
+	}
+	tmpl, err := parseStructuredTemplate(text)
+	if err != nil {
+		return "", nil, err
+	}
+	return mode, tmpl, nil
+}
+
+// parseStructuredTemplate parses the text of a template rendering a structuredMessage.
+func parseStructuredTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// structuredFields returns the fields of the JSON object of a message in style. A custom template
+// may use any field, so all of them are asked for then.
+func (g *CommitMessageGenerator) structuredFields(style string) ([]structuredField, error) {
+	names, ok := styleFields[style]
+	if !ok || g.template != nil {
+		names = styleFields[StyleConventional]
+	}
+
+	var types []string
+	switch style {
+	case StyleDefault:
+		types = defaultTypes
+	case StyleConventional:
+		var err error
+		if types, _, err = g.get.List("LINT_TYPES"); err != nil {
+			return nil, err
+		}
+	case StyleGitmoji:
+		for _, gitmoji := range gitmojis {
+			types = append(types, gitmoji.emoji)
+		}
+	}
+
+	fields := make([]structuredField, len(names))
+	for i, name := range names {
+		description, ok := fieldDescriptions[style][name]
+		if !ok {
+			description = fieldDescriptions[""][name]
+		}
+		fields[i] = structuredField{name: name, description: description}
+		if name == "type" {
+			fields[i].values = types
+		}
+	}
+	return fields, nil
+}
+
+// structuredInstruction is added to the system prompt to ask for the message as a JSON object of fields.
+func structuredInstruction(fields []structuredField) string {
+	var b strings.Builder
+	b.WriteString("\nInstead of the commit message itself, reply with a JSON object holding its parts in these fields, all of them strings:\n")
+	for _, field := range fields {
+		fmt.Fprintf(&b, "  - %q: %s", field.name, field.description)
+		if len(field.values) > 0 {
+			fmt.Fprintf(&b, "; one of: %s", strings.Join(field.values, ", "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("The message is assembled from the fields, so do not repeat the type or scope in the subject.\n")
+	return b.String()
+}
+
+// responseFormat returns the response format of mode for a JSON object of fields.
+func responseFormat(mode string, fields []structuredField) *groq.ResponseFormat {
+	if mode != structuredSchema {
+		return &groq.ResponseFormat{Type: structuredObject}
+	}
+	properties := make(map[string]any, len(fields))
+	required := make([]string, len(fields))
+	for i, field := range fields {
+		property := map[string]any{"type": "string", "description": field.description}
+		if len(field.values) > 0 {
+			property["enum"] = field.values
+		}
+		properties[field.name] = property
+		required[i] = field.name
+	}
+	return &groq.ResponseFormat{
+		Type: structuredSchema,
+		JSONSchema: &groq.JSONSchema{
+			Name: "commit_message",
+			Schema: map[string]any{
+				"type":                 "object",
+				"properties":           properties,
+				"required":             required,
+				"additionalProperties": false,
+			},
+			Strict: true,
+		},
+	}
+}
+
+// renderStructured renders the JSON object of a message in style, with the custom template if there
+// is one. Values outside the allowed ones are left for the formatting and lint checks to report.
+func (g *CommitMessageGenerator) renderStructured(style, reply string) (string, error) {
+	// Models without a JSON mode may still wrap the object in a code fence or a sentence.
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return "", ErrInvalidStructured
+	}
+	var message structuredMessage
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &message); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidStructured, err)
+	}
+	for _, field := range []*string{&message.Type, &message.Scope, &message.Subject, &message.Body, &message.Breaking} {
+		*field = strings.TrimSpace(*field)
+	}
+	if message.Subject == "" {
+		return "", fmt.Errorf("%w: the subject is empty", ErrInvalidStructured)
+	}
+
+	tmpl := g.template
+	if tmpl == nil {
+		text, ok := styleTemplates[style]
+		if !ok {
+			return "", fmt.Errorf("unknown commit style: %s", style)
+		}
+		tmpl = template.Must(parseStructuredTemplate(text))
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, message); err != nil {
+		return "", fmt.Errorf("failed to render the message: %w", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// messageParams returns the parameters of requests for commit messages: the sampling parameters,
+// and the response format of the last generation if STRUCTURED_OUTPUT is enabled.
+func (g *CommitMessageGenerator) messageParams() groq.Parameters {
+	params := g.params
+	params.ResponseFormat = g.format
+	return params
+}
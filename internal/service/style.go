@@ -0,0 +1,190 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// StyleAuto selects the style that the recent commits of the repository follow,
+	// falling back to StyleDefault when they follow none.
+	StyleAuto = "auto"
+	// StyleDefault is the bracket style of the default prompt, e.g. "[Fix] (main.go) ...".
+	// A custom COMMIT_PROMPT or PROMPT_TEMPLATE replaces its prompt.
+	StyleDefault = "default"
+	// StyleConventional follows the Conventional Commits specification.
+	StyleConventional = "conventional"
+	// StyleMultiline is a short subject followed by a body of bullet points explaining the change.
+	StyleMultiline = "multiline"
+	// StylePlain is a single capitalized subject line in the imperative mood without any prefix.
+	StylePlain = "plain"
+	// StyleGitmoji prefixes the subject with an emoji from the gitmoji list, e.g. "🐛 Fix crash on empty diff".
+	StyleGitmoji = "gitmoji"
+)
+
+// The preset styles, whose prompts are templates kept with the built-in styles, see preset.go.
+const (
+	// StyleConcise is a single line of at most 50 characters naming the most important change.
+	StyleConcise = "concise"
+	// StyleDetailed is a subject followed by paragraphs explaining the problem and the solution.
+	StyleDetailed = "detailed"
+	// StyleCorporate is a formal subject, led by the ticket ID of the branch if any, and a body giving the reason and impact.
+	StyleCorporate = "corporate"
+	// StyleEmojiFree is a subject and an optional body guaranteed to contain no emoji.
+	StyleEmojiFree = "emoji-free"
+	// StyleKernel follows the Linux kernel conventions, e.g. "net/ipv4: fix checksum of fragments".
+	StyleKernel = "kernel"
+)
+
+const conventionalPrompt = `
+You are an AI that writes git commit messages following the Conventional Commits 1.0.0 specification.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Format:
+<type>(<scope>): <subject>
+
+<body>
+
+<footer>
+
+Rules:
+  1. <type> is one of: feat, fix, docs, style, refactor, perf, test, build, ci, chore, revert.
+     - feat: a new feature
+     - fix: a bug fix
+     - docs: documentation only changes
+     - style: formatting changes that do not affect the meaning of the code
+     - refactor: a code change that neither fixes a bug nor adds a feature
+     - perf: a code change that improves performance
+     - test: adding or correcting tests
+     - build: changes to the build system or dependencies
+     - ci: changes to CI configuration
+     - chore: other changes that do not modify source or test files
+     - revert: reverts a previous commit
+  2. <scope> is optional: a short noun naming the affected area (e.g. a package or module). Omit the parentheses when there is no clear scope.
+  3. <subject> is written in the imperative mood, starts with a lowercase letter, has no trailing period, and keeps the whole first line within 72 characters.
+  4. <body> is optional: separated from the subject by a blank line, it explains what changed and why, wrapped at 72 characters. Omit it for trivial changes.
+  5. If the change breaks backward compatibility, add "!" after the type/scope and a footer starting with "BREAKING CHANGE: " describing the break and the migration. Otherwise omit the footer.
+
+Example:
+feat(config): support per-repository configuration files
+
+Load .ai-commit.json from the repository root and merge it over the
+global configuration so each repository can use its own prompt.
+`
+
+const plainPrompt = `
+You are an AI that writes git commit messages.
+Reply with the commit message only. Do not add explanations, quotes, or markdown code fences.
+
+Rules:
+  1. Write a single line of at most 72 characters.
+  2. Use the imperative mood and start with a capital letter, e.g. "Add dark mode to the settings page".
+  3. Do not add a type, scope, emoji or any other prefix, and do not end with a period.
+`
+
+// stylePrompts maps each built-in commit style to its system prompt.
+var stylePrompts = map[string]string{
+	StyleDefault:      defaultPrompt,
+	StyleConventional: conventionalPrompt,
+	StyleMultiline:    multilinePrompt,
+	StylePlain:        plainPrompt,
+	StyleGitmoji:      gitmojiPrompt(),
+	StyleConcise:      concisePrompt,
+	StyleDetailed:     detailedPrompt,
+	StyleCorporate:    corporatePrompt,
+	StyleEmojiFree:    emojiFreePrompt,
+	StyleKernel:       kernelPrompt,
+}
+
+// Styles returns the built-in and preset commit styles, "auto" excluded, in the order of COMMIT_STYLE.
+func Styles() []string {
+	return []string{
+		StyleDefault, StyleConventional, StyleMultiline, StylePlain, StyleGitmoji,
+		StyleConcise, StyleDetailed, StyleCorporate, StyleEmojiFree, StyleKernel,
+	}
+}
+
+// styleFormatters post-process the model's reply for styles with strict formatting rules.
+var styleFormatters = map[string]func(string) (string, error){
+	StyleMultiline: formatMultiline,
+	StyleGitmoji:   formatGitmoji,
+	StyleDetailed:  formatMultiline,
+	StyleCorporate: formatMultiline,
+	StyleEmojiFree: formatEmojiFree,
+	StyleKernel:    formatMultiline,
+}
+
+// commitStyle returns the configured commit style. The "auto" style resolves to the convention
+// detected in the repository's history, or to the default style if none was detected or a
+// custom COMMIT_PROMPT or PROMPT_TEMPLATE is set.
+func (g *CommitMessageGenerator) commitStyle() (string, error) {
+	style, err := g.get("COMMIT_STYLE")
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit style: %w", err)
+	}
+	if style != StyleAuto {
+		if style == "" {
+			return StyleDefault, nil
+		}
+		return style, nil
+	}
+
+	commitPrompt, err := g.get("COMMIT_PROMPT")
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit prompt: %w", err)
+	}
+	promptTemplate, err := g.get("PROMPT_TEMPLATE")
+	if err != nil {
+		return "", fmt.Errorf("failed to get prompt template: %w", err)
+	}
+	if commitPrompt != "" || promptTemplate != "" || g.repo.Convention == "" {
+		return StyleDefault, nil
+	}
+	return g.repo.Convention, nil
+}
+
+// systemPrompt returns the system prompt for the given commit style, rendered with data: a custom
+// prompt template (see customPrompt), or else the built-in prompt of the style.
+func (g *CommitMessageGenerator) systemPrompt(style string, data PromptData) (string, error) {
+	custom, err := g.customPrompt(style)
+	if err != nil {
+		return "", err
+	}
+	if custom != "" {
+		return renderPrompt(custom, data)
+	}
+
+	prompt, ok := stylePrompts[style]
+	if !ok {
+		return "", fmt.Errorf("unknown commit style: %s", style)
+	}
+	return renderPrompt(prompt, data)
+}
+
+// formatMessage trims the generated message and applies the style's formatter, if any.
+func formatMessage(style, message string) (string, error) {
+	message = strings.TrimSpace(message)
+	if formatter, ok := styleFormatters[style]; ok {
+		return formatter(message)
+	}
+	return message, nil
+}
+
+// formatMessage formats the generated message for style and checks that it is written in the
+// configured language. A structured reply is rendered into the message first.
+func (g *CommitMessageGenerator) formatMessage(style, message string) (string, error) {
+	if g.format != nil {
+		var err error
+		if message, err = g.renderStructured(style, message); err != nil {
+			return "", err
+		}
+	}
+	message, err := formatMessage(style, message)
+	if err != nil || g.language == nil {
+		return message, err
+	}
+	if err := g.language.check(message); err != nil {
+		return "", err
+	}
+	return message, nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+	"github.com/hambosto/ai-generate-commit/internal/logging"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+	"github.com/hambosto/ai-generate-commit/internal/tokens"
+)
+
+const (
+	// defaultContextWindow is the number of tokens assumed for models that are not known. It is
+	// small enough for most models, so that an unknown model shortens diffs rather than fails.
+	defaultContextWindow = 8192
+	// defaultReplyTokens is the room left for the reply when MAX_TOKENS is not set.
+	defaultReplyTokens = 1024
+	// promptMargin is the room left for the parts of the conversation that are not estimated,
+	// such as the message framing of the API and lint retries.
+	promptMargin = 512
+	// minDiffTokens is the least room given to the diff, however large the rest of the prompt.
+	minDiffTokens = 512
+)
+
+// notify receives warnings about the prompt, see SetNotify.
+var notify func(message string)
+
+// SetNotify makes f receive warnings about the prompt, such as the parts of a diff left out to fit the
+// context window of the model, e.g. to show them to the user. A nil f stops it.
+func SetNotify(f func(message string)) {
+	notify = f
+}
+
+// contextWindows are the numbers of tokens well-known models can read, by a part of their name,
+// checked in order.
+var contextWindows = []struct {
+	name   string
+	tokens int
+}{
+	{"gemini", 1048576},
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"llama-4", 131072},
+	{"llama-3.1", 131072},
+	{"llama-3.2", 131072},
+	{"llama-3.3", 131072},
+	{"llama3.1", 131072},
+	{"llama3.2", 131072},
+	{"llama3.3", 131072},
+	{"deepseek", 65536},
+	{"qwen", 32768},
+	{"mixtral", 32768},
+	{"mistral", 32768},
+	{"gemma", 8192},
+}
+
+// windowSuffix matches the size of the context window at the end of model names such as "llama3-8b-8192".
+var windowSuffix = regexp.MustCompile(`-(\d{4,7})$`)
+
+// contextWindow returns the number of tokens the model can read: CONTEXT_WINDOW if it is set in the
+// settings read through get, that of a well-known model, that of the provider's models, or else
+// defaultContextWindow.
+func contextWindow(get config.Getter, model string, preset provider.Preset) (int, error) {
+	window, ok, err := get.Int("CONTEXT_WINDOW")
+	if err != nil || ok {
+		return window, err
+	}
+	name := strings.ToLower(model)
+	if m := windowSuffix.FindStringSubmatch(name); m != nil {
+		return strconv.Atoi(m[1])
+	}
+	for _, known := range contextWindows {
+		if strings.Contains(name, known.name) {
+			return known.tokens, nil
+		}
+	}
+	if preset.ContextWindow > 0 {
+		return preset.ContextWindow, nil
+	}
+	return defaultContextWindow, nil
+}
+
+// ContextWindow returns the number of tokens the model is assumed to be able to read.
+func (g *CommitMessageGenerator) ContextWindow() int {
+	return g.window
+}
+
+// Omitted returns what was left out of the last diff sent to the AI to fit the context window,
+// or nil if it was sent whole.
+func (g *CommitMessageGenerator) Omitted() []git.Omission {
+	return g.omitted
+}
+
+// fitDiff shortens diff so that the prompt fits the context window of the model, with room for the
+// system prompt, the repository context and the reply, and records what was left out for Omitted.
+// The largest hunks are left out first, see git.TruncateDiff; files excluded with DIFF_EXCLUDE or
+// .aicommitignore are never part of diff in the first place.
+func (g *CommitMessageGenerator) fitDiff(diff string) string {
+	reply := defaultReplyTokens
+	if g.params.MaxTokens != nil {
+		reply = *g.params.MaxTokens
+	}
+	// The system prompt of the commit style stands in for those of pull requests and splits,
+	// which are of a similar length.
+	system := defaultPrompt
+	if style, err := g.commitStyle(); err == nil {
+		if prompt, err := g.systemPrompt(style, g.promptData(style, diff)); err == nil {
+			system = prompt
+		}
+	}
+	used := tokens.Estimate(system) + tokens.Estimate(g.contextMessage()) + reply + promptMargin
+	diff, g.omitted = git.TruncateDiff(diff, max(g.window-used, minDiffTokens), tokens.Estimate)
+	logging.Debug("fitted the diff to the context window", "model", g.model, "window", g.window, "reserved", used,
+		"diff_tokens", tokens.Estimate(diff), "omitted", len(g.omitted))
+	if len(g.omitted) > 0 && notify != nil {
+		parts := make([]string, len(g.omitted))
+		for i, omission := range g.omitted {
+			parts[i] = omission.String()
+		}
+		notify(fmt.Sprintf("Warning: the diff does not fit the context window of %s (%d tokens); left out %s. "+
+			"Set CONTEXT_WINDOW if the model can read more.", g.model, g.window, strings.Join(parts, ", ")))
+	}
+	return diff
+}
@@ -0,0 +1,21 @@
+// Package shell runs the commands the user configures, such as APIKEY_COMMAND, the hooks and the
+// editor, through the shell of the system, so that they may contain arguments, quotes and pipes.
+package shell
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Command returns the command running command with "sh -c", or "cmd /C" on Windows. args are
+// passed after command, as the positional parameters of sh, so that they need no quoting there.
+func Command(command string, args ...string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", strings.Join(append([]string{command}, args...), " "))
+	}
+	if len(args) > 0 {
+		command += ` "$@"`
+	}
+	return exec.Command("sh", append([]string{"-c", command, "sh"}, args...)...)
+}
@@ -0,0 +1,199 @@
+// Package telemetry records anonymous usage statistics once TELEMETRY is enabled: the command that
+// ran, the provider, how long the AI took to answer and how many generated messages were accepted
+// or rejected. Diffs, messages, file and branch names, repository paths and API keys are never
+// recorded, and events carry no identifier of the user or the machine.
+//
+// Events are queued in a file next to the global configuration file and sent in one batch about
+// once a day, so that a command never waits for the network more than once a day.
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+)
+
+const (
+	// fileName is the name of the queue of events not sent yet, stored next to the global configuration file.
+	fileName = ".ai-commit-telemetry.jsonl"
+	// sendInterval is how long events are queued before they are sent.
+	sendInterval = 24 * time.Hour
+	// maxEvents is the number of queued events kept when they cannot be sent; older ones are dropped.
+	maxEvents = 1000
+	// sendTimeout bounds the time a command waits for the endpoint.
+	sendTimeout = 3 * time.Second
+)
+
+// DefaultURL is the endpoint events are sent to unless TELEMETRY_URL is set, injected by release
+// builds with -ldflags "-X github.com/hambosto/ai-generate-commit/internal/telemetry.DefaultURL=...".
+// Without either, events stay in the queue on this computer.
+var DefaultURL = ""
+
+// Event describes one run of a command.
+type Event struct {
+	Time      time.Time `json:"time"`                 // When the command ended, to the hour
+	Version   string    `json:"version"`              // Version of the tool
+	OS        string    `json:"os"`                   // Operating system, e.g. "linux"
+	Arch      string    `json:"arch"`                 // Processor architecture, e.g. "amd64"
+	Command   string    `json:"command"`              // Name of the command, e.g. "generate"
+	Provider  string    `json:"provider,omitempty"`   // Provider messages were generated with, if any
+	LatencyMS int64     `json:"latency_ms,omitempty"` // Milliseconds the AI took to answer, if it was asked
+	Accepted  int       `json:"accepted,omitempty"`   // Generated messages that were committed
+	Rejected  int       `json:"rejected,omitempty"`   // Generated messages that were declined or replaced
+	Printed   int       `json:"printed,omitempty"`    // Generated messages that were printed for other tools
+	ExitCode  int       `json:"exit_code"`            // Exit code of the command, 0 on success
+}
+
+// Path returns the path of the queue of events not sent yet.
+func Path() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), fileName)
+}
+
+// Enabled reports whether events are recorded: TELEMETRY is enabled and DO_NOT_TRACK, honored by
+// many tools, is not set.
+func Enabled() (bool, error) {
+	if DoNotTrack() {
+		return false, nil
+	}
+	enabled, _, err := config.Getter(userSetting).Bool("TELEMETRY")
+	return enabled, err
+}
+
+// userSetting returns the value of key from the global configuration file, where "telemetry enable"
+// writes it, or from the environment, and skips the profiles and the repository file: telemetry is
+// the user's choice, which a repository must neither make nor redirect.
+func userSetting(key string) (string, error) {
+	setting, err := config.ResolveKey(key)
+	if err != nil {
+		return "", err
+	}
+	for _, value := range append([]config.Value{setting.Value}, setting.Shadowed...) {
+		switch value.Source {
+		case config.SourceDefault, config.SourceGlobal, config.SourceEnv:
+			return value.Value, nil
+		}
+	}
+	return "", nil
+}
+
+// DoNotTrack reports whether the DO_NOT_TRACK environment variable turns telemetry off: it is set
+// to anything but "" or "0", see https://consoledonottrack.com.
+func DoNotTrack() bool {
+	value := os.Getenv("DO_NOT_TRACK")
+	return value != "" && value != "0"
+}
+
+// Endpoint returns where events are sent: TELEMETRY_URL or DefaultURL, "" if there is neither.
+func Endpoint() (string, error) {
+	endpoint, err := userSetting("TELEMETRY_URL")
+	if err != nil || endpoint != "" {
+		return endpoint, err
+	}
+	return DefaultURL, nil
+}
+
+// Record queues event if telemetry is enabled, and sends the queue once its oldest event is due.
+// The time of event is rounded down to the hour, which is all the statistics need.
+func Record(event Event) error {
+	if enabled, err := Enabled(); err != nil || !enabled {
+		return err
+	}
+	event.Time = event.Time.UTC().Truncate(time.Hour)
+
+	events, err := Pending()
+	if err != nil {
+		return err
+	}
+	events = append(events, event)
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	if err := write(events); err != nil {
+		return err
+	}
+	if time.Since(events[0].Time) < sendInterval {
+		return nil
+	}
+	return Send()
+}
+
+// Pending returns the queued events, oldest first.
+func Pending() ([]Event, error) {
+	data, err := os.ReadFile(Path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telemetry queue: %w", err)
+	}
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event Event
+		// Skips lines that cannot be parsed rather than losing the whole queue.
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// Send sends the queued events to the endpoint and empties the queue once they were received.
+// Without an endpoint, the events are kept.
+func Send() error {
+	endpoint, err := Endpoint()
+	if err != nil || endpoint == "" {
+		return err
+	}
+	events, err := Pending()
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	body, err := json.Marshal(map[string][]Event{"events": events})
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry events: %w", err)
+	}
+
+	client := &http.Client{Timeout: sendTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry events: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to send telemetry events: %s", resp.Status)
+	}
+	return Clear()
+}
+
+// Clear deletes the queued events.
+func Clear() error {
+	if err := os.Remove(Path()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete telemetry queue: %w", err)
+	}
+	return nil
+}
+
+// write replaces the queue with events.
+func write(events []Event) error {
+	var data []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode telemetry event: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(Path(), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write telemetry queue: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/git"
+)
+
+// githubAPI is the REST API of github.com.
+const githubAPI = "https://api.github.com"
+
+// githubRemote matches the URLs of GitHub repositories in the forms Git accepts, capturing the owner
+// and the name, e.g. "git@github.com:owner/repo.git" and "https://github.com/owner/repo".
+var githubRemote = regexp.MustCompile(`github\.com[:/]([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// githubIssue is an issue as returned by the REST API and by "gh issue view --json".
+type githubIssue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// fetchGitHub returns the issue number of the repository of the origin remote, with GITHUB_TOKEN,
+// or with the GitHub CLI (gh) and its login if no token is set.
+func fetchGitHub(number string) (*Issue, error) {
+	token, err := config.GetConfig("GITHUB_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	var issue githubIssue
+	if token != "" {
+		err = fetchGitHubAPI(number, token, &issue)
+	} else {
+		err = fetchGitHubCLI(number, &issue)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Issue{Key: "#" + number, Title: issue.Title, Description: strings.TrimSpace(issue.Body)}, nil
+}
+
+// fetchGitHubAPI reads the issue from the REST API.
+func fetchGitHubAPI(number, token string, issue *githubIssue) error {
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return fmt.Errorf("failed to find the GitHub repository: %w", err)
+	}
+	match := githubRemote.FindStringSubmatch(strings.TrimSpace(remote))
+	if match == nil {
+		return fmt.Errorf("the origin remote %s is not a repository on github.com", remote)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/repos/%s/%s/issues/%s", githubAPI, match[1], match[2], number), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create the GitHub request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	return send(req, "GitHub", "#"+number, issue)
+}
+
+// fetchGitHubCLI reads the issue with "gh issue view", which finds the repository and host itself.
+func fetchGitHubCLI(number string, issue *githubIssue) error {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return fmt.Errorf("ISSUE_TRACKER is github, but neither GITHUB_TOKEN is set nor the GitHub CLI (gh) installed")
+	}
+	dir, err := git.WorkDir()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("gh", "issue", "view", number, "--json", "number,title,body")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "Could not resolve") {
+			return fmt.Errorf("%w on GitHub: #%s", ErrNotFound, number)
+		}
+		return fmt.Errorf("gh issue view failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(output, issue); err != nil {
+		return fmt.Errorf("invalid output of gh issue view: %w", err)
+	}
+	return nil
+}
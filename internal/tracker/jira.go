@@ -0,0 +1,56 @@
+package tracker
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+)
+
+// fetchJira returns the issue key from the Jira site of JIRA_URL, signing in with JIRA_TOKEN: as the
+// account of JIRA_EMAIL on Jira Cloud, or as a personal access token on Jira Data Center. JIRA_URL is
+// not RepoSafe, so the token only goes to a site the user configured, not to one named by a repository.
+func fetchJira(key string) (*Issue, error) {
+	siteURL, err := config.GetConfig("JIRA_URL")
+	if err != nil {
+		return nil, err
+	}
+	email, err := config.GetConfig("JIRA_EMAIL")
+	if err != nil {
+		return nil, err
+	}
+	token, err := config.GetConfig("JIRA_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	if siteURL == "" || token == "" {
+		return nil, fmt.Errorf("ISSUE_TRACKER is jira, but JIRA_URL or JIRA_TOKEN is not set")
+	}
+
+	// Version 2 of the API is used since it returns the description as text on every edition of Jira.
+	endpoint := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=summary,description", strings.TrimRight(siteURL, "/"), url.PathEscape(key))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Jira request: %w", err)
+	}
+	if email != "" {
+		req.SetBasicAuth(email, token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	var result struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+		} `json:"fields"`
+	}
+	if err := send(req, "Jira", key, &result); err != nil {
+		return nil, err
+	}
+	return &Issue{Key: cmp.Or(result.Key, key), Title: result.Fields.Summary, Description: strings.TrimSpace(result.Fields.Description)}, nil
+}
@@ -0,0 +1,66 @@
+package tracker
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+)
+
+// linearAPI is the GraphQL endpoint of Linear.
+const linearAPI = "https://api.linear.app/graphql"
+
+// linearQuery looks an issue up by its identifier, such as "ENG-123".
+const linearQuery = `query Issue($id: String!) { issue(id: $id) { identifier title description } }`
+
+// fetchLinear returns the issue identifier from Linear, signing in with LINEAR_API_KEY.
+func fetchLinear(identifier string) (*Issue, error) {
+	apiKey, err := config.GetConfig("LINEAR_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("ISSUE_TRACKER is linear, but LINEAR_API_KEY is not set")
+	}
+
+	body, err := json.Marshal(map[string]any{"query": linearQuery, "variables": map[string]string{"id": identifier}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, linearAPI, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Linear request: %w", err)
+	}
+	// Personal API keys are sent as they are, without the "Bearer" of OAuth tokens.
+	req.Header.Set("Authorization", apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Data struct {
+			Issue *struct {
+				Identifier  string `json:"identifier"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+			} `json:"issue"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := send(req, "Linear", identifier, &result); err != nil {
+		return nil, err
+	}
+	// GraphQL reports failures in the body, including issues that do not exist.
+	issue := result.Data.Issue
+	if issue == nil {
+		if len(result.Errors) > 0 && !strings.Contains(strings.ToLower(result.Errors[0].Message), "not found") {
+			return nil, fmt.Errorf("Linear answered for %s: %s", identifier, result.Errors[0].Message)
+		}
+		return nil, fmt.Errorf("%w in Linear: %s", ErrNotFound, identifier)
+	}
+	return &Issue{Key: cmp.Or(issue.Identifier, identifier), Title: issue.Title, Description: strings.TrimSpace(issue.Description)}, nil
+}
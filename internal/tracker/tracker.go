@@ -0,0 +1,93 @@
+// Package tracker fetches the issue a branch refers to from the issue tracker of the team, so that the
+// AI knows the task a change belongs to.
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+)
+
+// The issue trackers, as named by ISSUE_TRACKER.
+const (
+	Jira   = "jira"
+	Linear = "linear"
+	GitHub = "github"
+)
+
+// timeout bounds each request to an issue tracker, which is only worth a short wait.
+const timeout = 10 * time.Second
+
+// ErrNotFound is returned when the tracker has no issue of the reference, or it is not visible to the token.
+var ErrNotFound = errors.New("issue not found")
+
+// issueKey matches the keys of Jira and Linear issues, a project or team key and a number such as "PROJ-123".
+var issueKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-[0-9]+$`)
+
+// Issue is an issue of a tracker.
+type Issue struct {
+	Key         string // Reference of the issue, e.g. "PROJ-123" or "#456"
+	Title       string // One-line summary of the issue
+	Description string // Text of the issue, in the markup of the tracker
+}
+
+// Fetch returns the issue of reference, as found in the branch name by service.FindIssueReference,
+// from the tracker selected with ISSUE_TRACKER. It returns nil without error if no tracker is
+// selected or the reference is not one of its issues, such as "#456" for Jira. ISSUE_TRACKER is not
+// RepoSafe, so that a repository cannot make the tool send requests or run gh.
+func Fetch(reference string) (*Issue, error) {
+	name, err := config.GetConfig("ISSUE_TRACKER")
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case Jira:
+		if issueKey.MatchString(reference) {
+			return fetchJira(reference)
+		}
+	case Linear:
+		if issueKey.MatchString(reference) {
+			return fetchLinear(reference)
+		}
+	case GitHub:
+		if number, ok := strings.CutPrefix(reference, "#"); ok {
+			return fetchGitHub(number)
+		}
+	}
+	return nil, nil
+}
+
+// send sends req to the tracker called name with the proxy and TLS settings of the provider, and
+// decodes the JSON response into result. A 404 response about reference is ErrNotFound.
+func send(req *http.Request, name, reference string, result any) error {
+	transport, err := provider.NewTransport()
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: transport, Timeout: timeout}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w in %s: %s", ErrNotFound, name, reference)
+	case resp.StatusCode/100 != 2:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s answered %s for %s: %s", name, resp.Status, reference, strings.TrimSpace(string(detail)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Action is what the user chose to do when leaving the TUI.
+type Action int
+
+const (
+	// ActionQuit means the user left without committing.
+	ActionQuit Action = iota
+	// ActionCommit means the user accepted the message for a commit of the staged changes.
+	ActionCommit
+	// ActionSplit means the user asked to split the staged changes into several commits.
+	ActionSplit
+)
+
+// Options holds the staged files and the callbacks the TUI uses to show and generate messages.
+type Options struct {
+	Files      []string                          // Staged files listed on the left
+	Diff       func(file string) (string, error) // Returns the diff of one staged file for the diff pane
+	Generate   func() (string, error)            // Generates the first message
+	Regenerate func(hint string) (string, error) // Generates a new message, following an optional hint
+	// Edit opens message in the user's editor and returns the edited message. It runs while the TUI
+	// has released the terminal, so it may use standard input and output.
+	Edit func(message string) (string, error)
+}
+
+// Result is returned by Run once the user leaves the TUI.
+type Result struct {
+	Action  Action
+	Message string // Message shown when leaving, empty if none was generated
+}
+
+// Run shows the full-screen TUI until the user commits, splits or quits.
+func Run(opts Options) (Result, error) {
+	final, err := tea.NewProgram(newModel(opts), tea.WithAltScreen()).Run()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to run the TUI: %w", err)
+	}
+	m := final.(model)
+	return Result{Action: m.action, Message: m.message}, nil
+}
+
+// pane identifies the pane that receives the movement keys.
+type pane int
+
+const (
+	filesPane pane = iota
+	diffPane
+)
+
+// messageMsg carries the result of generating, regenerating or editing the message.
+type messageMsg struct {
+	message string
+	err     error
+}
+
+var (
+	borderStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8"))
+	focusStyle   = borderStyle.BorderForeground(lipgloss.Color("12"))
+	titleStyle   = lipgloss.NewStyle().Bold(true)
+	cursorStyle  = lipgloss.NewStyle().Reverse(true)
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	hunkStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	helpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+// model is the bubbletea model of the TUI.
+type model struct {
+	opts   Options
+	cursor int            // Index of the selected file
+	focus  pane           // Pane that receives the movement keys
+	diffs  map[int]string // Colored diffs of the files shown so far, by index
+
+	diff    viewport.Model
+	spinner spinner.Model
+	hint    textinput.Model
+
+	message    string
+	generating bool // Whether a message is being generated
+	hinting    bool // Whether the hint for a regeneration is being entered
+	err        error
+
+	width, height int
+	action        Action
+}
+
+func newModel(opts Options) model {
+	hint := textinput.New()
+	hint.Prompt = "Hint (optional): "
+	hint.Placeholder = "e.g. mention the new flag"
+	return model{
+		opts:       opts,
+		diffs:      map[int]string{},
+		diff:       viewport.New(0, 0),
+		spinner:    spinner.New(spinner.WithSpinner(spinner.Dot)),
+		hint:       hint,
+		generating: true,
+	}
+}
+
+// Init starts generating the first message.
+func (m model) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick, generate(m.opts.Generate))
+}
+
+// generate runs fn in the background and delivers its message as a messageMsg.
+func generate(fn func() (string, error)) tea.Cmd {
+	return func() tea.Msg {
+		message, err := fn()
+		return messageMsg{message: message, err: err}
+	}
+}
+
+// execFunc runs a function as a tea.ExecCommand, while the TUI has released the terminal.
+type execFunc func() error
+
+func (f execFunc) Run() error        { return f() }
+func (execFunc) SetStdin(io.Reader)  {}
+func (execFunc) SetStdout(io.Writer) {}
+func (execFunc) SetStderr(io.Writer) {}
+
+// edit opens the message in the editor, delivering the edited message as a messageMsg.
+func (m model) edit() tea.Cmd {
+	var edited string
+	run := execFunc(func() (err error) {
+		edited, err = m.opts.Edit(m.message)
+		return err
+	})
+	return tea.Exec(run, func(err error) tea.Msg {
+		if err == nil && edited == "" {
+			err = errors.New("the edited message is empty")
+		}
+		return messageMsg{message: edited, err: err}
+	})
+}
+
+// Update handles key presses, resizes and finished generations.
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		m.showDiff()
+		return m, nil
+
+	case messageMsg:
+		m.generating = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.message = msg.message
+		}
+		m.layout()
+		return m, nil
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
+	case tea.KeyMsg:
+		if m.hinting {
+			return m.updateHint(msg)
+		}
+		return m.updateKey(msg)
+	}
+	return m, nil
+}
+
+// updateHint handles keys while the hint for a regeneration is entered.
+func (m model) updateHint(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.hinting, m.generating, m.err = false, true, nil
+		hint := strings.TrimSpace(m.hint.Value())
+		m.hint.Blur()
+		regenerate := m.opts.Regenerate
+		return m, tea.Batch(m.spinner.Tick, generate(func() (string, error) { return regenerate(hint) }))
+	case "esc":
+		m.hinting = false
+		m.hint.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.hint, cmd = m.hint.Update(msg)
+	return m, cmd
+}
+
+// updateKey handles keys outside of the hint input.
+func (m model) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	ready := !m.generating && m.message != ""
+	switch msg.String() {
+	case "q", "ctrl+c":
+		m.action = ActionQuit
+		return m, tea.Quit
+	case "enter", "y":
+		if ready {
+			m.action = ActionCommit
+			return m, tea.Quit
+		}
+	case "s":
+		if !m.generating {
+			m.action = ActionSplit
+			return m, tea.Quit
+		}
+	case "r":
+		if !m.generating {
+			m.hinting = true
+			m.hint.SetValue("")
+			return m, m.hint.Focus()
+		}
+	case "e":
+		if ready {
+			return m, m.edit()
+		}
+	case "tab":
+		m.focus = 1 - m.focus
+	case "up", "k":
+		if m.focus == filesPane {
+			m.moveCursor(-1)
+			return m, nil
+		}
+	case "down", "j":
+		if m.focus == filesPane {
+			m.moveCursor(1)
+			return m, nil
+		}
+	}
+
+	// Scrolls the diff with the remaining keys, e.g. page up and page down.
+	var cmd tea.Cmd
+	m.diff, cmd = m.diff.Update(msg)
+	return m, cmd
+}
+
+// moveCursor selects another file and shows its diff.
+func (m *model) moveCursor(delta int) {
+	if len(m.opts.Files) == 0 {
+		return
+	}
+	m.cursor = (m.cursor + delta + len(m.opts.Files)) % len(m.opts.Files)
+	m.showDiff()
+	m.diff.GotoTop()
+}
+
+// showDiff puts the diff of the selected file in the diff pane, reading it on first use.
+func (m *model) showDiff() {
+	if len(m.opts.Files) == 0 || m.diff.Width <= 0 {
+		return
+	}
+	content, ok := m.diffs[m.cursor]
+	if !ok {
+		diff, err := m.opts.Diff(m.opts.Files[m.cursor])
+		if err != nil {
+			content = errorStyle.Render(err.Error())
+		} else {
+			content = colorDiff(diff, m.diff.Width)
+		}
+		m.diffs[m.cursor] = content
+	}
+	m.diff.SetContent(content)
+}
+
+// layout sizes the diff pane to the space left by the file list and the message pane.
+func (m *model) layout() {
+	if m.width == 0 {
+		return
+	}
+	width := m.width - m.filesWidth() - 4
+	height := m.height - m.messageHeight() - 5
+	if width != m.diff.Width {
+		// Diffs are cut to the width of the pane, so they must be read again.
+		m.diffs = map[int]string{}
+		m.diff.Width = max(width, 1)
+		m.showDiff()
+	}
+	m.diff.Height = max(height, 1)
+}
+
+// filesWidth returns the inner width of the file list, which takes at most a third of the screen.
+func (m model) filesWidth() int {
+	width := len("Staged files")
+	for _, file := range m.opts.Files {
+		width = max(width, len([]rune(file))+2)
+	}
+	return min(width, m.width/3)
+}
+
+// messageHeight returns the inner height of the message pane, which takes at most a third of the screen.
+func (m model) messageHeight() int {
+	lines := strings.Count(m.message, "\n") + 2
+	return min(max(lines, 3), m.height/3)
+}
+
+// View draws the file list and diff side by side above the message and a line of key help.
+func (m model) View() string {
+	if m.width == 0 {
+		return ""
+	}
+
+	files := []string{titleStyle.Render("Staged files")}
+	for i, file := range m.opts.Files {
+		line := cut(file, m.filesWidth())
+		if i == m.cursor {
+			line = cursorStyle.Render(line)
+		}
+		files = append(files, line)
+	}
+	filesStyle, diffStyle := focusStyle, borderStyle
+	if m.focus == diffPane {
+		filesStyle, diffStyle = borderStyle, focusStyle
+	}
+	top := lipgloss.JoinHorizontal(lipgloss.Top,
+		filesStyle.Width(m.filesWidth()).Height(m.diff.Height).Render(strings.Join(files, "\n")),
+		diffStyle.Width(m.diff.Width).Height(m.diff.Height).Render(m.diff.View()),
+	)
+
+	var message string
+	switch {
+	case m.generating:
+		message = m.spinner.View() + " Generating the commit message..."
+	case m.message == "":
+		message = helpStyle.Render("No message yet. Press r to generate one.")
+	default:
+		lines := strings.Split(m.message, "\n")
+		for i, line := range lines {
+			lines[i] = cut(line, m.width-4)
+		}
+		if height := m.messageHeight(); len(lines) > height {
+			lines = append(lines[:height-1], helpStyle.Render("..."))
+		}
+		message = strings.Join(lines, "\n")
+	}
+	bottom := borderStyle.Width(m.width - 2).Height(m.messageHeight()).Render(message)
+
+	status := helpStyle.Render("enter commit • r regenerate • e edit • s split • tab pane • ↑/↓ move • pgup/pgdn scroll • q quit")
+	switch {
+	case m.hinting:
+		status = m.hint.View()
+	case m.err != nil:
+		status = errorStyle.Render("Error: " + m.err.Error())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, top, bottom, status)
+}
+
+// colorDiff colors added, removed and hunk header lines of a diff, cutting lines to width.
+func colorDiff(diff string, width int) string {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range lines {
+		line = cut(strings.ReplaceAll(line, "\t", "    "), width)
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			line = titleStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			line = addedStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			line = removedStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			line = hunkStyle.Render(line)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cut shortens s to at most width characters, so that lines do not wrap inside a pane.
+func cut(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	return string(runes[:width])
+}
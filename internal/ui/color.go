@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Color is an ANSI SGR code such as "31" for red.
+type Color string
+
+// Colors used in the output of the tool.
+const (
+	Bold  Color = "1"
+	Dim   Color = "2"
+	Red   Color = "31"
+	Green Color = "32"
+	Cyan  Color = "36"
+)
+
+// colorDisabled is set by DisableColor, e.g. for a --no-color flag.
+var colorDisabled bool
+
+// DisableColor turns colored output off for the rest of the run.
+func DisableColor() {
+	colorDisabled = true
+}
+
+// ColorEnabled reports whether output may be colored: standard output must be a terminal,
+// NO_COLOR (see https://no-color.org) must be unset or empty, TERM must not be "dumb",
+// and DisableColor must not have been called.
+func ColorEnabled() bool {
+	return !colorDisabled && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" &&
+		term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// Colorize wraps s in the escape sequences for color, or returns s unchanged if colors are disabled.
+func Colorize(color Color, s string) string {
+	if s == "" || !ColorEnabled() {
+		return s
+	}
+	return "\x1b[" + string(color) + "m" + s + "\x1b[0m"
+}
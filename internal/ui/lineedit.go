@@ -0,0 +1,212 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+// ErrOpenEditor is returned by EditLine, together with the text so far, when the user asks to
+// continue in an external editor.
+var ErrOpenEditor = errors.New("open the editor")
+
+// EditLine lets the user edit text on a single line after prompt, like the line editor of a shell,
+// and returns the edited text. The arrow keys, Home, End, Ctrl-A, Ctrl-E, Alt-B and Alt-F move the
+// cursor; Backspace, Delete, Ctrl-W, Ctrl-U and Ctrl-K delete. Enter confirms, Esc and Ctrl-C cancel
+// with ErrCanceled, and Ctrl-X returns ErrOpenEditor. Text wider than the terminal scrolls sideways.
+func EditLine(prompt, text string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from the terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	e := &lineEditor{prompt: prompt, line: []rune(text), cursor: utf8.RuneCountInString(text)}
+	e.render()
+
+	// Keeps the bytes of a key that was split across reads, e.g. a multibyte character in a paste.
+	buf := make([]byte, 256)
+	var pending []byte
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return "", fmt.Errorf("failed to read from the terminal: %w", err)
+		}
+		pending = append(pending, buf[:n]...)
+		for len(pending) > 0 {
+			key, size := nextKey(pending)
+			if size == 0 {
+				break
+			}
+			pending = pending[size:]
+			if err := e.handle(key); err != nil {
+				// Raw mode disables the translation of "\n", so the line is ended with "\r\n".
+				fmt.Print("\r\n")
+				if errors.Is(err, errLineDone) {
+					return string(e.line), nil
+				}
+				return string(e.line), err
+			}
+		}
+		e.render()
+	}
+}
+
+// errLineDone is returned by lineEditor.handle when the user confirms the line.
+var errLineDone = errors.New("done")
+
+// lineEditor holds the state of EditLine.
+type lineEditor struct {
+	prompt string
+	line   []rune
+	cursor int // Index in line the next character is inserted at
+	offset int // Index of the first character shown, when the line is wider than the terminal
+}
+
+// handle applies a key to the line. It returns errLineDone, ErrCanceled or ErrOpenEditor when editing ends.
+func (e *lineEditor) handle(key string) error {
+	switch key {
+	case "\r", "\n":
+		return errLineDone
+	case "\x1b", "\x03":
+		return ErrCanceled
+	case "\x18":
+		return ErrOpenEditor
+	case "\x04":
+		// Ctrl-D cancels on an empty line and deletes the next character otherwise, as in shells.
+		if len(e.line) == 0 {
+			return ErrCanceled
+		}
+		e.delete(e.cursor, e.cursor+1)
+	case "\x01", "\x1b[H", "\x1bOH", "\x1b[1~", "\x1b[7~":
+		e.cursor = 0
+	case "\x05", "\x1b[F", "\x1bOF", "\x1b[4~", "\x1b[8~":
+		e.cursor = len(e.line)
+	case "\x02", "\x1b[D", "\x1bOD":
+		e.cursor = max(e.cursor-1, 0)
+	case "\x06", "\x1b[C", "\x1bOC":
+		e.cursor = min(e.cursor+1, len(e.line))
+	case "\x1bb", "\x1b[1;5D", "\x1b[1;3D":
+		e.cursor = e.wordStart()
+	case "\x1bf", "\x1b[1;5C", "\x1b[1;3C":
+		e.cursor = e.wordEnd()
+	case "\x7f", "\x08":
+		e.delete(e.cursor-1, e.cursor)
+	case "\x1b[3~":
+		e.delete(e.cursor, e.cursor+1)
+	case "\x17", "\x1b\x7f":
+		e.delete(e.wordStart(), e.cursor)
+	case "\x15":
+		e.delete(0, e.cursor)
+	case "\x0b":
+		e.delete(e.cursor, len(e.line))
+	default:
+		// Inserts printable characters and ignores unknown control keys and escape sequences.
+		r, size := utf8.DecodeRuneInString(key)
+		if size != len(key) || !unicode.IsPrint(r) {
+			return nil
+		}
+		e.line = append(e.line[:e.cursor], append([]rune{r}, e.line[e.cursor:]...)...)
+		e.cursor++
+	}
+	return nil
+}
+
+// delete removes the characters from index start up to end, clamped to the line, and moves the cursor to start.
+func (e *lineEditor) delete(start, end int) {
+	start, end = max(start, 0), min(end, len(e.line))
+	if start >= end {
+		return
+	}
+	e.line = append(e.line[:start], e.line[end:]...)
+	e.cursor = start
+}
+
+// wordStart returns the index of the start of the word before the cursor.
+func (e *lineEditor) wordStart() int {
+	i := e.cursor
+	for i > 0 && unicode.IsSpace(e.line[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(e.line[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the index of the end of the word after the cursor.
+func (e *lineEditor) wordEnd() int {
+	i := e.cursor
+	for i < len(e.line) && unicode.IsSpace(e.line[i]) {
+		i++
+	}
+	for i < len(e.line) && !unicode.IsSpace(e.line[i]) {
+		i++
+	}
+	return i
+}
+
+// render redraws the prompt and the part of the line that fits the terminal, and places the cursor.
+func (e *lineEditor) render() {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	promptWidth := runewidth.StringWidth(e.prompt)
+	avail := max(width-promptWidth-1, 10)
+
+	// Scrolls just far enough to keep the cursor visible.
+	e.offset = min(e.offset, e.cursor)
+	for runewidth.StringWidth(string(e.line[e.offset:e.cursor])) > avail {
+		e.offset++
+	}
+	shown, used := e.offset, 0
+	for shown < len(e.line) && used+runewidth.RuneWidth(e.line[shown]) <= avail {
+		used += runewidth.RuneWidth(e.line[shown])
+		shown++
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\r\x1b[2K%s%s\r", e.prompt, string(e.line[e.offset:shown]))
+	if column := promptWidth + runewidth.StringWidth(string(e.line[e.offset:e.cursor])); column > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", column)
+	}
+	fmt.Print(b.String())
+}
+
+// nextKey returns the first key in b, which is a character, a control character or an escape
+// sequence, and its length in bytes. The length is 0 if b ends in the middle of a character.
+func nextKey(b []byte) (string, int) {
+	if b[0] == '\x1b' {
+		switch {
+		case len(b) == 1:
+			return "\x1b", 1
+		case b[1] == '[' || b[1] == 'O':
+			// CSI and SS3 sequences end with a byte between '@' and '~'.
+			for i := 2; i < len(b); i++ {
+				if b[i] >= '@' && b[i] <= '~' {
+					return string(b[:i+1]), i + 1
+				}
+			}
+			return string(b), len(b)
+		default:
+			// Alt and a key arrive as Esc followed by the key.
+			return string(b[:2]), 2
+		}
+	}
+	if b[0] < utf8.RuneSelf {
+		return string(b[:1]), 1
+	}
+	if !utf8.FullRune(b) {
+		return "", 0
+	}
+	_, size := utf8.DecodeRune(b)
+	return string(b[:size]), size
+}
@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrCanceled is returned when the user cancels a prompt.
+var ErrCanceled = errors.New("canceled")
+
+// IsInteractive reports whether both standard input and standard output are terminals.
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// MultiSelect shows options as a checklist and returns the indexes of the options the user selects.
+// All options start selected. The user moves with the arrow keys or j/k, toggles with space,
+// toggles all with "a", confirms with enter and cancels with q, Esc or Ctrl-C, which returns ErrCanceled.
+func MultiSelect(title string, options []string) ([]int, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from the terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	selected := make([]bool, len(options))
+	for i := range selected {
+		selected[i] = true
+	}
+	cursor := 0
+
+	// Raw mode disables the translation of "\n", so every line ends with "\r\n".
+	fmt.Printf("%s\r\n", title)
+	fmt.Print("(space: toggle, a: toggle all, enter: confirm, q: cancel)\r\n")
+	render(options, selected, cursor, false)
+
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from the terminal: %w", err)
+		}
+		key := string(buf[:n])
+
+		switch key {
+		case "\x1b[A", "k":
+			cursor = (cursor - 1 + len(options)) % len(options)
+		case "\x1b[B", "j":
+			cursor = (cursor + 1) % len(options)
+		case " ":
+			selected[cursor] = !selected[cursor]
+		case "a":
+			// Selects everything unless everything is already selected.
+			all := !allSelected(selected)
+			for i := range selected {
+				selected[i] = all
+			}
+		case "\r", "\n":
+			var indexes []int
+			for i, isSelected := range selected {
+				if isSelected {
+					indexes = append(indexes, i)
+				}
+			}
+			return indexes, nil
+		case "q", "\x1b", "\x03":
+			return nil, ErrCanceled
+		default:
+			continue
+		}
+		render(options, selected, cursor, true)
+	}
+}
+
+// render draws the checklist, first moving the cursor back over the previous drawing if redraw is set.
+func render(options []string, selected []bool, cursor int, redraw bool) {
+	var b strings.Builder
+	if redraw {
+		fmt.Fprintf(&b, "\x1b[%dA", len(options))
+	}
+	for i, option := range options {
+		pointer, box := " ", "[ ]"
+		if i == cursor {
+			pointer = ">"
+		}
+		if selected[i] {
+			box = "[x]"
+		}
+		fmt.Fprintf(&b, "\x1b[2K%s %s %s\r\n", pointer, box, option)
+	}
+	fmt.Print(b.String())
+}
+
+// allSelected reports whether every option is selected.
+func allSelected(selected []bool) bool {
+	for _, isSelected := range selected {
+		if !isSelected {
+			return false
+		}
+	}
+	return true
+}
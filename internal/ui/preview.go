@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+// Preview shows text on stderr, dimmed, while it is being generated, until it is cleared to make room
+// for the final version. The zero value shows nothing, as does a Preview when stderr is not a terminal.
+type Preview struct {
+	enabled bool            // Whether the text is shown
+	text    strings.Builder // Text shown so far
+}
+
+// NewPreview returns an empty Preview.
+func NewPreview() *Preview {
+	return &Preview{enabled: os.Getenv("TERM") != "dumb" && term.IsTerminal(int(os.Stderr.Fd()))}
+}
+
+// Enabled reports whether the preview shows anything.
+func (p *Preview) Enabled() bool {
+	return p.enabled
+}
+
+// Write adds text to the end of the preview.
+func (p *Preview) Write(text string) {
+	if !p.enabled {
+		return
+	}
+	p.text.WriteString(text)
+	stderrMu.Lock()
+	defer stderrMu.Unlock()
+	// Dims each line on its own, so that the lines of the terminal never stay dimmed.
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			fmt.Fprint(os.Stderr, "\n")
+		}
+		fmt.Fprint(os.Stderr, Colorize(Dim, line))
+	}
+}
+
+// Clear removes the text shown so far from the terminal and empties the preview. Lines that have
+// scrolled out of view cannot be removed.
+func (p *Preview) Clear() {
+	if !p.enabled || p.text.Len() == 0 {
+		return
+	}
+	width, _, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+	// Counts the rows the text takes, including the rows of lines wrapped by the terminal.
+	rows := 0
+	for _, line := range strings.Split(p.text.String(), "\n") {
+		rows += max(1, (runewidth.StringWidth(line)+width-1)/width)
+	}
+	p.text.Reset()
+
+	stderrMu.Lock()
+	defer stderrMu.Unlock()
+	if rows > 1 {
+		fmt.Fprintf(os.Stderr, "\x1b[%dA", rows-1)
+	}
+	fmt.Fprint(os.Stderr, "\r\x1b[J")
+}
@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// spinnerFrames are drawn one after another to animate a Spinner.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// stderrMu keeps the lines of Notify from being mixed into the redrawing of a spinner.
+var stderrMu sync.Mutex
+
+// Spinner animates a status line with the elapsed time on stderr while something slow, such as
+// a request to the AI, is running. The zero value shows nothing.
+type Spinner struct {
+	stop chan struct{} // Closed to stop the animation
+	done chan struct{} // Closed once the line has been cleared
+}
+
+// StartSpinner shows label with an animated spinner and the elapsed time on stderr until Stop is
+// called. Nothing is shown when stderr is not a terminal, so logs and pipes stay clean.
+func StartSpinner(label string) *Spinner {
+	if os.Getenv("TERM") == "dumb" || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return &Spinner{}
+	}
+	s := &Spinner{stop: make(chan struct{}), done: make(chan struct{})}
+	go s.run(label)
+	return s
+}
+
+// run redraws the status line until the spinner is stopped, then clears it.
+func (s *Spinner) run(label string) {
+	defer close(s.done)
+	start := time.Now()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		stderrMu.Lock()
+		fmt.Fprintf(os.Stderr, "\r\x1b[2K%s %s (%.1fs)", spinnerFrames[frame%len(spinnerFrames)], label, time.Since(start).Seconds())
+		stderrMu.Unlock()
+		select {
+		case <-s.stop:
+			fmt.Fprint(os.Stderr, "\r\x1b[2K")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop removes the status line. Calling it again has no effect.
+func (s *Spinner) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// Notify prints message on a line of its own on stderr. A running spinner is cleared first and
+// redraws itself below the message.
+func Notify(message string) {
+	stderrMu.Lock()
+	defer stderrMu.Unlock()
+	if os.Getenv("TERM") != "dumb" && term.IsTerminal(int(os.Stderr.Fd())) {
+		fmt.Fprint(os.Stderr, "\r\x1b[2K")
+	}
+	fmt.Fprintln(os.Stderr, message)
+}
@@ -0,0 +1,200 @@
+// Package aicommit generates commit messages for git diffs with an AI, like the ai-generate-commit
+// command, for use in other Go tools such as TUIs, bots and editor integrations.
+//
+// Unlike the command, it reads neither the configuration files nor the AI_COMMIT_* environment
+// variables, and it never prompts: every setting comes from Options. Generated messages are not
+// cached unless CACHE_TTL is given in Options.Settings.
+//
+//	msg, err := aicommit.Generate(ctx, aicommit.Diff(diff), aicommit.Options{
+//		Provider: "groq",
+//		APIKey:   os.Getenv("GROQ_API_KEY"),
+//		Style:    aicommit.StyleConventional,
+//	})
+package aicommit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hambosto/ai-generate-commit/internal/config"
+	"github.com/hambosto/ai-generate-commit/internal/groq"
+	"github.com/hambosto/ai-generate-commit/internal/provider"
+	"github.com/hambosto/ai-generate-commit/internal/service"
+)
+
+// The commit styles of Options.Style.
+const (
+	// StyleAuto follows the convention of Options.Examples, or StyleDefault if they follow none.
+	StyleAuto = service.StyleAuto
+	// StyleDefault puts the kind of change and the files in brackets, e.g. "[Fix] (main.go) ...".
+	StyleDefault = service.StyleDefault
+	// StyleConventional follows Conventional Commits, e.g. "fix(parser): ...".
+	StyleConventional = service.StyleConventional
+	// StyleMultiline writes a subject and a body of bullet points.
+	StyleMultiline = service.StyleMultiline
+	// StylePlain writes a plain subject line.
+	StylePlain = service.StylePlain
+	// StyleGitmoji starts the subject with an emoji, see gitmoji.dev.
+	StyleGitmoji = service.StyleGitmoji
+	// StyleConcise writes a single line of at most 50 characters.
+	StyleConcise = service.StyleConcise
+	// StyleDetailed writes a subject and paragraphs explaining the problem and the solution.
+	StyleDetailed = service.StyleDetailed
+	// StyleCorporate writes a formal subject, led by the ticket ID of the branch, and a body.
+	StyleCorporate = service.StyleCorporate
+	// StyleEmojiFree writes a subject and an optional body without any emoji.
+	StyleEmojiFree = service.StyleEmojiFree
+	// StyleKernel follows the Linux kernel conventions, e.g. "net/ipv4: ...".
+	StyleKernel = service.StyleKernel
+)
+
+// ErrEmptyDiff is returned by Generate for a diff without any changes.
+var ErrEmptyDiff = errors.New("the diff is empty")
+
+// Diff is the change a message is generated for, in the format of git diff, e.g. the output of
+// "git diff --staged".
+type Diff string
+
+// Options configures a generation. Only Provider and, for most providers, APIKey are needed;
+// unset fields fall back to the defaults of the command.
+type Options struct {
+	Provider string // Provider of the AI, e.g. "groq", "together" or "llamacpp"; defaults to "groq"
+	APIKey   string // API key of the provider, for providers that need one
+	Model    string // Model to use; defaults to the provider's default model
+	Style    string // Commit style, one of the Style constants; defaults to StyleDefault
+	Language string // Language the message is written in, e.g. "German"; defaults to the model's choice
+
+	Temperature *float64 // Sampling temperature; nil for the default
+	MaxTokens   int      // Maximum length of the reply in tokens; 0 for the default
+
+	Branch   string   // Current branch, whose name often tells the ticket or feature worked on
+	Examples []string // Subjects of recent commits, newest first, whose conventions are followed
+
+	// Offline writes the message from the diff alone, without the AI. Without it, a message is still
+	// written offline when no API key is given or the provider cannot be reached, unless
+	// OFFLINE_FALLBACK is false in Settings; Message.Offline tells.
+	Offline bool
+	// Stream receives the text of the message as it is generated, before it is checked and formatted.
+	Stream func(delta string)
+	// Settings holds further settings by the name of their configuration key, e.g. "LINT" or
+	// "PROXY_URL", as listed by "ai-generate-commit listConfig". They are overridden by the fields above.
+	Settings map[string]string
+}
+
+// Message is a generated commit message.
+type Message struct {
+	Subject  string // First line of the message
+	Body     string // The lines after the subject and the blank line following it, "" if there are none
+	Provider string // Provider that generated the message, "offline" if it was written without the AI
+	Model    string // Model that generated the message
+	Offline  bool   // Whether the message was written from the diff alone, see Options.Offline
+	Usage    Usage  // Tokens used to generate the message
+}
+
+// Usage counts the tokens used by a generation, as reported by the provider.
+type Usage struct {
+	PromptTokens     int // Tokens in the prompt
+	CompletionTokens int // Tokens in the generated reply
+	TotalTokens      int // Sum of prompt and completion tokens
+}
+
+// String returns the whole message, as it is committed.
+func (m Message) String() string {
+	if m.Body == "" {
+		return m.Subject
+	}
+	return m.Subject + "\n\n" + m.Body
+}
+
+// Generate generates a commit message for diff. The requests to the provider are canceled with ctx,
+// and Generate then returns the error of ctx.
+func Generate(ctx context.Context, diff Diff, opts Options) (Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+	if strings.TrimSpace(string(diff)) == "" {
+		return Message{}, ErrEmptyDiff
+	}
+
+	get, err := opts.config()
+	if err != nil {
+		return Message{}, err
+	}
+	params := groq.Parameters{Temperature: opts.Temperature}
+	if opts.MaxTokens > 0 {
+		params.MaxTokens = &opts.MaxTokens
+	}
+	generator, err := service.NewCommitMessageGenerator(service.Options{
+		Model:      opts.Model,
+		Parameters: params,
+		Context: service.RepoContext{
+			Branch:     opts.Branch,
+			Examples:   opts.Examples,
+			Convention: service.DetectStyle(opts.Examples),
+		},
+		Offline: opts.Offline,
+		Config:  get,
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	generator.SetContext(ctx)
+	generator.SetStream(opts.Stream)
+
+	text, err := generator.GenerateCommitMessage(string(diff))
+	// A canceled generation may have fallen back to writing the message offline.
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return Message{}, ctxErr
+	}
+	if err != nil {
+		return Message{}, err
+	}
+
+	usage := generator.Usage()
+	subject, body, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return Message{
+		Subject:  strings.TrimSpace(subject),
+		Body:     strings.TrimSpace(body),
+		Provider: generator.Provider(),
+		Model:    generator.Model(),
+		Offline:  generator.Offline(),
+		Usage: Usage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		},
+	}, nil
+}
+
+// config returns the settings of the generation: Settings, overridden by the other fields, without
+// the cache unless it is asked for.
+func (opts Options) config() (config.Getter, error) {
+	settings := map[string]string{"CACHE_TTL": "0"}
+	for key, value := range opts.Settings {
+		settings[key] = value
+	}
+
+	fields := map[string]string{
+		"PROVIDER":        opts.Provider,
+		"COMMIT_STYLE":    opts.Style,
+		"COMMIT_LANGUAGE": opts.Language,
+	}
+	if opts.APIKey != "" {
+		preset, err := provider.Lookup(opts.Provider)
+		if err != nil {
+			return nil, err
+		}
+		if preset.APIKeyConfig == "" {
+			return nil, fmt.Errorf("the %s provider takes no API key", preset.Name)
+		}
+		fields[preset.APIKeyConfig] = opts.APIKey
+	}
+	for key, value := range fields {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+	return config.Isolated(settings)
+}